@@ -0,0 +1,75 @@
+package checksum
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Size is the size of the checksum in bytes.
+const Size = 4
+
+// ErrCorruption is returned (wrapped in CorruptionError) when the stored checksum of a piece of data does not match
+// the checksum computed from the data.
+var ErrCorruption = errors.New("corruption")
+
+// castagnoliTable is the CRC32C (Castagnoli) table. CRC32C has hardware support on most modern CPUs, and
+// better error detection properties than the IEEE polynomial for the data sizes used here.
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// CorruptionError represents a corruption detected at the Offset in the file identified by FilePath.
+// It unwraps to ErrCorruption, so callers can use errors.Is(err, ErrCorruption).
+type CorruptionError struct {
+	FilePath string
+	Offset   int64
+	Reason   string
+}
+
+// NewCorruptionError creates a new instance of CorruptionError.
+func NewCorruptionError(filePath string, offset int64, reason string) *CorruptionError {
+	return &CorruptionError{
+		FilePath: filePath,
+		Offset:   offset,
+		Reason:   reason,
+	}
+}
+
+// Error returns the error message.
+func (err *CorruptionError) Error() string {
+	return fmt.Sprintf("%v in file %v at offset %v: %v", ErrCorruption, err.FilePath, err.Offset, err.Reason)
+}
+
+// Unwrap returns ErrCorruption.
+func (err *CorruptionError) Unwrap() error {
+	return ErrCorruption
+}
+
+// Compute computes the CRC32C checksum of the data.
+func Compute(data []byte) uint32 {
+	return crc32.Checksum(data, castagnoliTable)
+}
+
+// Append appends the CRC32C checksum of the data to the data.
+/*
+  --------------------------------
+ | data | 4 bytes CRC32C of data |
+  --------------------------------
+*/
+func Append(data []byte) []byte {
+	return binary.LittleEndian.AppendUint32(data, Compute(data))
+}
+
+// Verify verifies the buffer created using Append, and returns the data (buffer without the checksum).
+// filePath and offset identify the location of the buffer, and are used in the CorruptionError if the verification fails.
+func Verify(buffer []byte, filePath string, offset int64) ([]byte, error) {
+	if len(buffer) < Size {
+		return nil, NewCorruptionError(filePath, offset, fmt.Sprintf("buffer of size %v is too small to contain a checksum", len(buffer)))
+	}
+	data := buffer[:len(buffer)-Size]
+	expected := binary.LittleEndian.Uint32(buffer[len(buffer)-Size:])
+	if actual := Compute(data); actual != expected {
+		return nil, NewCorruptionError(filePath, offset, fmt.Sprintf("checksum mismatch, expected %x, actual %x", expected, actual))
+	}
+	return data, nil
+}
@@ -0,0 +1,35 @@
+package checksum
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendAndVerifyChecksum(t *testing.T) {
+	buffer := Append([]byte("raft consensus"))
+	assert.Equal(t, len("raft consensus")+Size, len(buffer))
+
+	data, err := Verify(buffer, "1.sst", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("raft consensus"), data)
+}
+
+func TestVerifyChecksumOfCorruptedData(t *testing.T) {
+	buffer := Append([]byte("raft consensus"))
+	buffer[2] = buffer[2] ^ 0x01
+
+	_, err := Verify(buffer, "1.sst", 10)
+	assert.ErrorIs(t, err, ErrCorruption)
+
+	var corruptionError *CorruptionError
+	assert.True(t, errors.As(err, &corruptionError))
+	assert.Equal(t, "1.sst", corruptionError.FilePath)
+	assert.Equal(t, int64(10), corruptionError.Offset)
+}
+
+func TestVerifyChecksumOfABufferSmallerThanChecksum(t *testing.T) {
+	_, err := Verify([]byte{1, 2}, "1.sst", 0)
+	assert.ErrorIs(t, err, ErrCorruption)
+}
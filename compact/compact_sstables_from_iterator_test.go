@@ -1,11 +1,14 @@
 package compact
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"go-lsm/compact/meta"
 	"go-lsm/kv"
 	"go-lsm/state"
 	"go-lsm/test_utility"
 	"go-lsm/txn"
+	"path/filepath"
 	"testing"
 )
 
@@ -32,7 +35,7 @@ func TestGenerateSSTablesFromASingleIterator(t *testing.T) {
 	)
 
 	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
-	ssTables, err := compaction.ssTablesFromIterator(iterator)
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(ssTables))
@@ -74,7 +77,7 @@ func TestGenerateSSTablesFromASingleIteratorHavingMultipleKeysWithDifferentTimes
 	oracle.SetBeginTimestamp(11)
 
 	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
-	ssTables, err := compaction.ssTablesFromIterator(iterator)
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(ssTables))
@@ -116,7 +119,7 @@ func TestGenerateSSTablesFromASingleIteratorHavingMultipleKeysWithDifferentTimes
 	oracle.SetBeginTimestamp(10)
 
 	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
-	ssTables, err := compaction.ssTablesFromIterator(iterator)
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(ssTables))
@@ -133,3 +136,337 @@ func TestGenerateSSTablesFromASingleIteratorHavingMultipleKeysWithDifferentTimes
 	assert.Nil(t, ssTableIterator.Next())
 	assert.False(t, ssTableIterator.IsValid())
 }
+
+func TestGenerateSSTablesFromAnIteratorHavingADeletedKeyWithAnOlderVersion(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+		},
+		[]kv.Value{
+			kv.EmptyValue,
+			kv.NewStringValue("Raft"),
+		},
+	)
+
+	oracle.SetBeginTimestamp(11)
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(ssTables))
+}
+
+func TestGenerateSSTablesFromAnIteratorHavingADeletedKeyWhichMustBeRetained(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+		},
+		[]kv.Value{
+			kv.EmptyValue,
+			kv.NewStringValue("Raft"),
+		},
+	)
+
+	oracle.SetBeginTimestamp(11)
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 10), ssTableIterator.Key())
+	assert.True(t, ssTableIterator.Value().IsEmpty())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
+
+type testCompactionFilter struct {
+	decisions map[string]meta.CompactionFilterDecision
+	values    map[string]kv.Value
+	filtered  []kv.Key
+	levels    []int
+}
+
+func (filter *testCompactionFilter) Filter(level int, key kv.Key, _ kv.Value) (meta.CompactionFilterDecision, kv.Value) {
+	filter.filtered = append(filter.filtered, key)
+	filter.levels = append(filter.levels, level)
+	return filter.decisions[key.RawString()], filter.values[key.RawString()]
+}
+
+func compactionWithFilter(oracle *txn.Oracle, storageState *state.StorageState, filter meta.CompactionFilter) *Compaction {
+	options := storageState.Options()
+	options.CompactionOptions.Filter = filter
+	return NewCompaction(oracle, storageState.SSTableIdGenerator(), options)
+}
+
+func TestGenerateSSTablesFromAnIteratorWithACompactionFilterWhichRemovesAKeyAlongWithItsOlderVersions(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+			kv.NewStringKeyWithTimestamp("storage", 10),
+		},
+		[]kv.Value{
+			kv.NewStringValue("VSR"),
+			kv.NewStringValue("Raft"),
+			kv.NewStringValue("NVMe"),
+		},
+	)
+
+	oracle.SetBeginTimestamp(11)
+
+	filter := &testCompactionFilter{decisions: map[string]meta.CompactionFilterDecision{"consensus": meta.RemoveValue}}
+	compaction := compactionWithFilter(oracle, storageState, filter)
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+	assert.Equal(t, []kv.Key{
+		kv.NewStringKeyWithTimestamp("consensus", 10),
+		kv.NewStringKeyWithTimestamp("storage", 10),
+	}, filter.filtered)
+	assert.Equal(t, []int{1, 1}, filter.levels)
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("storage", 10), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("NVMe"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
+
+func TestGenerateSSTablesFromAnIteratorWithACompactionFilterWhichRemovesAKeyThatMustBeRetainedAsDeleted(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+		},
+		[]kv.Value{
+			kv.NewStringValue("VSR"),
+			kv.NewStringValue("Raft"),
+		},
+	)
+
+	oracle.SetBeginTimestamp(11)
+
+	filter := &testCompactionFilter{decisions: map[string]meta.CompactionFilterDecision{"consensus": meta.RemoveValue}}
+	compaction := compactionWithFilter(oracle, storageState, filter)
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 10), ssTableIterator.Key())
+	assert.True(t, ssTableIterator.Value().IsEmpty())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
+
+func TestGenerateSSTablesFromAnIteratorWithACompactionFilterWhichChangesAValue(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 10),
+			kv.NewStringKeyWithTimestamp("storage", 10),
+		},
+		[]kv.Value{
+			kv.NewStringValue("VSR"),
+			kv.NewStringValue("NVMe"),
+		},
+	)
+
+	oracle.SetBeginTimestamp(11)
+
+	filter := &testCompactionFilter{
+		decisions: map[string]meta.CompactionFilterDecision{"consensus": meta.ChangeValue},
+		values:    map[string]kv.Value{"consensus": kv.NewStringValue("Raft")},
+	}
+	compaction := compactionWithFilter(oracle, storageState, filter)
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 10), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("Raft"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("storage", 10), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("NVMe"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
+
+func TestGenerateSSTablesFromAnIteratorWithACompactionFilterWhichDoesNotFilterTheKeysVisibleToRunningTransactions(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := txn.NewOracleWithLastCommitTimestamp(txn.NewExecutor(storageState), 10)
+
+	transaction := txn.NewReadonlyTransaction(oracle, storageState)
+	defer func() {
+		oracle.FinishBeginTimestamp(transaction)
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := newMockIterator(
+		[]kv.Key{
+			kv.NewStringKeyWithTimestamp("consensus", 9),
+			kv.NewStringKeyWithTimestamp("consensus", 8),
+			kv.NewStringKeyWithTimestamp("storage", 12),
+			kv.NewStringKeyWithTimestamp("storage", 7),
+		},
+		[]kv.Value{
+			kv.NewStringValue("Raft"),
+			kv.NewStringValue("Paxos"),
+			kv.NewStringValue("NVMe"),
+			kv.NewStringValue("SSD"),
+		},
+	)
+
+	filter := &testCompactionFilter{
+		decisions: map[string]meta.CompactionFilterDecision{"consensus": meta.RemoveValue, "storage": meta.RemoveValue},
+	}
+	compaction := compactionWithFilter(oracle, storageState, filter)
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(ssTables))
+	assert.Equal(t, []kv.Key{kv.NewStringKeyWithTimestamp("storage", 12)}, filter.filtered)
+
+	ssTableIterator, err := ssTables[0].SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 9), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("Raft"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("storage", 12), ssTableIterator.Key())
+	assert.True(t, ssTableIterator.Value().IsEmpty())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("storage", 7), ssTableIterator.Key())
+	assert.Equal(t, kv.NewStringValue("SSD"), ssTableIterator.Value())
+
+	assert.Nil(t, ssTableIterator.Next())
+	assert.False(t, ssTableIterator.IsValid())
+}
+
+type failingIterator struct {
+	*mockIterator
+	failAtIndex int
+}
+
+func (iterator *failingIterator) Next() error {
+	if iterator.currentIndex+1 == iterator.failAtIndex {
+		return errors.New("failed to read the next key")
+	}
+	return iterator.mockIterator.Next()
+}
+
+func TestGenerateSSTablesFromAnIteratorWhichFailsRemovesThePartialSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+	})
+	storageOptions.BlockSize = 32
+	storageOptions.SSTableSizeInBytes = 32
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	iterator := &failingIterator{
+		mockIterator: newMockIterator(
+			[]kv.Key{
+				kv.NewStringKeyWithTimestamp("bolt", 10),
+				kv.NewStringKeyWithTimestamp("consensus", 10),
+				kv.NewStringKeyWithTimestamp("etcd", 10),
+				kv.NewStringKeyWithTimestamp("storage", 10),
+			},
+			[]kv.Value{
+				kv.NewStringValue("B+Tree"),
+				kv.NewStringValue("Raft"),
+				kv.NewStringValue("KV"),
+				kv.NewStringValue("NVMe"),
+			},
+		),
+		failAtIndex: 3,
+	}
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
+	ssTables, err := compaction.ssTablesFromIterator(iterator, 1, true)
+
+	assert.Error(t, err)
+	assert.Nil(t, ssTables)
+
+	ssTableFiles, _ := filepath.Glob(filepath.Join(rootPath, "*.sst"))
+	assert.Empty(t, ssTableFiles)
+}
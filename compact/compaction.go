@@ -10,121 +10,211 @@ import (
 )
 
 // Compaction represents core logic to compact table.SSTable files.
+// The table.SSTable files to compact are decided by the CompactionStrategy selected in state.CompactionOptions.
 type Compaction struct {
 	oracle      *txn.Oracle
 	idGenerator *state.SSTableIdGenerator
 	options     state.StorageOptions
+	strategy    CompactionStrategy
 }
 
 // NewCompaction creates a new instance of Compaction.
+// It panics if the compaction strategy is not supported, state.StorageState validates the options before Compaction is created.
 func NewCompaction(oracle *txn.Oracle, idGenerator *state.SSTableIdGenerator, options state.StorageOptions) *Compaction {
+	strategy, err := NewCompactionStrategy(options.CompactionOptions)
+	if err != nil {
+		panic(err)
+	}
 	return &Compaction{
 		oracle:      oracle,
 		idGenerator: idGenerator,
 		options:     options,
+		strategy:    strategy,
 	}
 }
 
 // Start performs compaction given an instance of state.StorageStateSnapshot.
 // It is called from compaction goroutine at fixed intervals.
-// It returns an instance of state.StorageStateChangeEvent if any two levels are eligible for compaction.
+// It returns an instance of state.StorageStateChangeEvent if the CompactionStrategy finds anything to compact.
+// If the meta.CompactionDescription only drops the input SSTables, the state.StorageStateChangeEvent has no new SSTables.
+// If the input SSTables can be moved to the output level without being rewritten (refer to trivialMoveOf), the
+// state.StorageStateChangeEvent is a trivial move.
+// It returns an error if the compaction fails, the new SSTables of a failed compaction are removed, so the compaction can
+// be retried (refer to go_lsm.Db).
 func (compaction *Compaction) Start(snapshot state.StorageStateSnapshot) (state.StorageStateChangeEvent, error) {
-	simpleLeveledCompaction := NewSimpleLeveledCompaction(compaction.options.CompactionOptions.StrategyOptions)
-	description, ok := simpleLeveledCompaction.CompactionDescription(snapshot)
+	description, ok := compaction.strategy.CompactionDescription(snapshot)
 	if !ok {
 		return state.NoStorageStateChanges, nil
 	}
+	if description.DropOnly {
+		return state.NewStorageStateChangeEvent(nil, description), nil
+	}
+	if ssTables, ok := trivialMoveOf(description, snapshot); ok {
+		return state.NewStorageStateChangeEventForTrivialMove(ssTables, description), nil
+	}
 	ssTables, err := compaction.compact(description, snapshot)
 	if err != nil {
-		return state.NoStorageStateChanges, nil
+		return state.NoStorageStateChanges, err
 	}
 	event := state.NewStorageStateChangeEvent(ssTables, description)
 	return event, nil
 }
 
-// compact performs compaction by creating an instance of iterator.MergeIterator using the iterators present in adjacent levels
-// defined in meta.SimpleLeveledCompactionDescription.
-func (compaction *Compaction) compact(description meta.SimpleLeveledCompactionDescription, snapshot state.StorageStateSnapshot) ([]*table.SSTable, error) {
-	upperLevelSSTableIterator := make([]iterator.Iterator, 0, len(description.UpperLevelSSTableIds))
-	for _, ssTableId := range description.UpperLevelSSTableIds {
-		ssTable := snapshot.SSTables[ssTableId]
-		ssTableIterator, err := ssTable.SeekToFirst()
-		if err != nil {
-			return nil, nil
-		}
-		upperLevelSSTableIterator = append(upperLevelSSTableIterator, ssTableIterator)
+// compact performs compaction by splitting the compaction into key-range shards (subcompactions, refer to
+// Compaction.subcompactionsOf) which are merged concurrently. Each subcompaction creates an instance of
+// iterator.MergeIterator using the iterators of the input SSTables of all the levels defined in meta.CompactionDescription
+// (from the upper level to the lower level). With MaxSubcompactions <= 1, the compaction is a single shard.
+func (compaction *Compaction) compact(description meta.CompactionDescription, snapshot state.StorageStateSnapshot) ([]*table.SSTable, error) {
+	return compaction.runSubcompactions(
+		compaction.subcompactionsOf(description, snapshot),
+		description,
+		snapshot,
+		!hasOlderOverlappingSSTables(description, snapshot),
+	)
+}
+
+// hasOlderOverlappingSSTables returns true if any SSTable, which is not an input of the compaction and may contain the older
+// versions of the keys of the inputs, overlaps the raw key range of the inputs.
+// Such SSTables are the level0 SSTables older than the oldest level0 input, and the SSTables at the output level (or level1,
+// if level0 is the output level) and below. The newer SSTables (the level0 SSTables newer than the level0 inputs, and the
+// levels above the output level) do not matter.
+func hasOlderOverlappingSSTables(description meta.CompactionDescription, snapshot state.StorageStateSnapshot) bool {
+	inputSSTableIds := make(map[uint64]struct{})
+	var inputSSTables []*table.SSTable
+	for _, ssTableId := range description.AllSSTableIds() {
+		inputSSTableIds[ssTableId] = struct{}{}
+		inputSSTables = append(inputSSTables, snapshot.SSTables[ssTableId])
 	}
-	lowerLevelSSTableIterator := make([]iterator.Iterator, 0, len(description.LowerLevelSSTableIds))
-	for _, ssTableId := range description.LowerLevelSSTableIds {
-		ssTable := snapshot.SSTables[ssTableId]
-		ssTableIterator, err := ssTable.SeekToFirst()
-		if err != nil {
-			return nil, nil
+	if len(inputSSTables) == 0 {
+		return false
+	}
+	inputKeyRange := rawKeyRangeOf(inputSSTables)
+	overlaps := func(ssTableId uint64) bool {
+		if _, ok := inputSSTableIds[ssTableId]; ok {
+			return false
 		}
-		lowerLevelSSTableIterator = append(lowerLevelSSTableIterator, ssTableIterator)
+		return snapshot.SSTables[ssTableId].ContainsInclusive(inputKeyRange)
 	}
 
-	iterators := append(upperLevelSSTableIterator, lowerLevelSSTableIterator...)
-	return compaction.ssTablesFromIterator(iterator.NewMergeIterator(iterators, iterator.NoOperationOnCloseCallback))
+	level0Inputs, level0InputsSeen := len(description.SSTableIdsAt(0)), 0
+	for _, ssTableId := range snapshot.L0SSTableIds {
+		if _, ok := inputSSTableIds[ssTableId]; ok {
+			level0InputsSeen++
+			continue
+		}
+		if level0Inputs > 0 && level0InputsSeen == level0Inputs && overlaps(ssTableId) {
+			return true
+		}
+	}
+	for level := max(description.OutputLevel, 1); level <= len(snapshot.Levels); level++ {
+		for _, ssTableId := range snapshot.Levels[level-1].OverlappingSSTableIds(inputKeyRange) {
+			if overlaps(ssTableId) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // ssTablesFromIterator creates a slice of table.SSTable (/new SSTables) from the given iterator.
 // It skips all the keys with commit-timestamp <= maximum read-timestamp.
 // If the maximum read-timestamp in the system is 9, there is no point in storing any key with commit-timestamp < 9,
 // because all the read operations will be getting read-timestamp > 9 from txn.Oracle.
-func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) ([]*table.SSTable, error) {
+// A deleted key (with commit-timestamp <= maximum read-timestamp) is skipped along with its older versions, only if
+// dropTombstones is true. The tombstone must be retained if an SSTable (which is not a part of the compaction) may contain
+// an older version of the key (refer to hasOlderOverlappingSSTables).
+// The latest version of a key is passed to the meta.CompactionFilter (if configured in state.CompactionOptions), only if it
+// is not visible to any running transaction: its commit-timestamp must be greater than the begin-timestamps of all the
+// running transactions (refer to txn.Oracle.MaxActiveBeginTimestamp), else the filter would change their snapshots.
+// A removed key is skipped along with its older versions if dropTombstones is true and its commit-timestamp <= maximum
+// read-timestamp, else it is replaced with a tombstone, so that an older version (retained for the running transactions,
+// or in an SSTable which is not a part of the compaction) does not resurface.
+// The output is split into SSTables of SSTableSizeInBytes, except at level0, where every SSTable is a sorted run of its
+// own (refer to TieredCompaction), so a merge into level0 produces a single SSTable.
+// The new SSTables are built using the table.SSTableBuilderOptions of the outputLevel, and they are removed if an error
+// occurs, so a failed compaction does not leave partial outputs behind.
+func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator, outputLevel int, dropTombstones bool) ([]*table.SSTable, error) {
+	var builderOptions = compaction.options.SSTableBuilderOptionsAt(outputLevel)
 	var ssTableBuilder *table.SSTableBuilder
 	var newSSTables []*table.SSTable
 
 	var lastKey = kv.EmptyKey
 	var firstKeyOccurrence = false
 	var maxBeginTimestamp = compaction.oracle.MaxBeginTimestamp()
+	var maxActiveBeginTimestamp, hasActiveTransactions = compaction.oracle.MaxActiveBeginTimestamp()
+
+	invisibleToActiveTransactions := func(key kv.Key) bool {
+		return !hasActiveTransactions || key.Timestamp() > maxActiveBeginTimestamp
+	}
+
+	fail := func(err error) ([]*table.SSTable, error) {
+		removeSSTables(newSSTables)
+		return nil, err
+	}
 
 	for iterator.IsValid() {
-		if ssTableBuilder == nil {
-			ssTableBuilder = table.NewSSTableBuilderWithDefaultBlockSize()
-		}
 		sameAsLastRawKey := iterator.Key().IsRawKeyEqualTo(lastKey)
 		if !sameAsLastRawKey {
 			firstKeyOccurrence = true
 		}
 
-		if !sameAsLastRawKey && iterator.Key().Timestamp() <= maxBeginTimestamp && iterator.Value().IsEmpty() {
+		if dropTombstones && !sameAsLastRawKey && iterator.Key().Timestamp() <= maxBeginTimestamp && iterator.Value().IsEmpty() {
 			lastKey = iterator.Key()
+			firstKeyOccurrence = false
 			if err := iterator.Next(); err != nil {
-				return nil, err
+				return fail(err)
 			}
 			continue
 		}
 		if iterator.Key().Timestamp() <= maxBeginTimestamp {
 			if sameAsLastRawKey && !firstKeyOccurrence {
 				if err := iterator.Next(); err != nil {
-					return nil, err
+					return fail(err)
 				}
 				continue
 			}
 			firstKeyOccurrence = false
 		}
-		if int64(ssTableBuilder.EstimatedSize()) >= compaction.options.SSTableSizeInBytes && !sameAsLastRawKey {
+		value := iterator.Value()
+		if compaction.options.CompactionOptions.Filter != nil && !sameAsLastRawKey && invisibleToActiveTransactions(iterator.Key()) && !value.IsEmpty() {
+			decision, changedValue := compaction.options.CompactionOptions.Filter.Filter(outputLevel, iterator.Key(), value)
+			switch decision {
+			case meta.RemoveValue:
+				if dropTombstones && iterator.Key().Timestamp() <= maxBeginTimestamp {
+					lastKey = iterator.Key()
+					if err := iterator.Next(); err != nil {
+						return fail(err)
+					}
+					continue
+				}
+				value = kv.EmptyValue
+			case meta.ChangeValue:
+				value = changedValue
+			}
+		}
+		if outputLevel != 0 && ssTableBuilder != nil && int64(ssTableBuilder.EstimatedSize()) >= compaction.options.SSTableSizeInBytes && !sameAsLastRawKey {
 			ssTable, err := compaction.buildNewSStable(ssTableBuilder)
 			if err != nil {
-				return nil, err
+				return fail(err)
 			}
 			newSSTables = append(newSSTables, ssTable)
-			ssTableBuilder = table.NewSSTableBuilderWithDefaultBlockSize()
+			ssTableBuilder = nil
+		}
+		if ssTableBuilder == nil {
+			ssTableBuilder = table.NewSSTableBuilderWithOptions(builderOptions)
 		}
-		ssTableBuilder.Add(iterator.Key(), iterator.Value())
+		ssTableBuilder.Add(iterator.Key(), value)
 		if !sameAsLastRawKey {
 			lastKey = iterator.Key()
 		}
 		if err := iterator.Next(); err != nil {
-			return nil, err
+			return fail(err)
 		}
 	}
 	if ssTableBuilder != nil {
 		ssTable, err := compaction.buildNewSStable(ssTableBuilder)
 		if err != nil {
-			return nil, err
+			return fail(err)
 		}
 		newSSTables = append(newSSTables, ssTable)
 	}
@@ -134,9 +224,16 @@ func (compaction *Compaction) ssTablesFromIterator(iterator iterator.Iterator) (
 // buildNewSStable creates a new instance of table.SSTable.
 func (compaction *Compaction) buildNewSStable(ssTableBuilder *table.SSTableBuilder) (*table.SSTable, error) {
 	ssTableId := compaction.idGenerator.NextId()
-	ssTable, err := ssTableBuilder.Build(ssTableId, compaction.options.Path)
+	ssTable, err := ssTableBuilder.BuildWithReadOptions(ssTableId, compaction.options.Path, compaction.options.SSTableReadOptions())
 	if err != nil {
 		return nil, err
 	}
 	return ssTable, nil
 }
+
+// removeSSTables removes the given SSTables (the new SSTables of a failed compaction).
+func removeSSTables(ssTables []*table.SSTable) {
+	for _, ssTable := range ssTables {
+		_ = ssTable.Remove()
+	}
+}
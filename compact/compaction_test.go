@@ -13,6 +13,20 @@ import (
 
 const level1 = 1
 
+func compactionStorageOptions(rootPath string, compactionOptions state.CompactionOptions) state.StorageOptions {
+	if compactionOptions.MaxLevels == 0 {
+		compactionOptions.MaxLevels = 3
+	}
+	return state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  rootPath,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    8192,
+		CompactionOptions:     compactionOptions,
+	}
+}
+
 func TestStartSimpleLeveledCompactionWithCompactionDescription(t *testing.T) {
 	rootPath := test_utility.SetupADirectoryWithTestName(t)
 	storageOptions := state.StorageOptions{
@@ -22,9 +36,9 @@ func TestStartSimpleLeveledCompactionWithCompactionDescription(t *testing.T) {
 		FlushMemtableDuration: 1 * time.Millisecond,
 		SSTableSizeInBytes:    8192,
 		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
 			StrategyOptions: state.SimpleLeveledCompactionOptions{
 				NumberOfSSTablesRatioPercentage: 200,
-				MaxLevels:                       3,
 				Level0FilesCompactionTrigger:    2,
 			},
 		},
@@ -71,10 +85,9 @@ func TestStartSimpleLeveledCompactionWithCompactionDescription(t *testing.T) {
 	storageStateChangeEvent, err := compaction.Start(storageStateSnapshot)
 
 	assert.Nil(t, err)
-	assert.Equal(t, -1, storageStateChangeEvent.CompactionUpperLevel())
-	assert.Equal(t, 1, storageStateChangeEvent.CompactionLowerLevel())
-	assert.Equal(t, []uint64{3, 2}, storageStateChangeEvent.CompactionUpperLevelSSTableIds())
-	assert.Equal(t, []uint64{4}, storageStateChangeEvent.CompactionLowerLevelSSTableIds())
+	assert.Equal(t, 1, storageStateChangeEvent.CompactionOutputLevel())
+	assert.Equal(t, []uint64{3, 2}, storageStateChangeEvent.CompactionSSTableIdsAt(0))
+	assert.Equal(t, []uint64{4}, storageStateChangeEvent.CompactionSSTableIdsAt(1))
 }
 
 func TestStartSimpleLeveledCompactionBetweenL0AndL1WithSSTablesPresentOnlyInL0WithReadTimestampAsZero(t *testing.T) {
@@ -86,9 +99,9 @@ func TestStartSimpleLeveledCompactionBetweenL0AndL1WithSSTablesPresentOnlyInL0Wi
 		FlushMemtableDuration: 1 * time.Millisecond,
 		SSTableSizeInBytes:    8192,
 		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
 			StrategyOptions: state.SimpleLeveledCompactionOptions{
 				NumberOfSSTablesRatioPercentage: 200,
-				MaxLevels:                       3,
 				Level0FilesCompactionTrigger:    2,
 			},
 		},
@@ -152,9 +165,9 @@ func TestStartSimpleLeveledCompactionBetweenL0AndL1WithSSTablesPresentOnlyInL0Wi
 		FlushMemtableDuration: 1 * time.Millisecond,
 		SSTableSizeInBytes:    8192,
 		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
 			StrategyOptions: state.SimpleLeveledCompactionOptions{
 				NumberOfSSTablesRatioPercentage: 200,
-				MaxLevels:                       3,
 				Level0FilesCompactionTrigger:    2,
 			},
 		},
@@ -219,9 +232,9 @@ func TestStartSimpleLeveledCompactionBetweenL0AndL1WithNewSSTables(t *testing.T)
 		FlushMemtableDuration: 1 * time.Millisecond,
 		SSTableSizeInBytes:    8192,
 		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
 			StrategyOptions: state.SimpleLeveledCompactionOptions{
 				NumberOfSSTablesRatioPercentage: 200,
-				MaxLevels:                       3,
 				Level0FilesCompactionTrigger:    2,
 			},
 		},
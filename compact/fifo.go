@@ -0,0 +1,73 @@
+package compact
+
+import (
+	"go-lsm/compact/meta"
+	"go-lsm/state"
+	"slices"
+	"time"
+)
+
+// FIFOCompaction represents a FIFO compaction strategy, which never merges the table.SSTable files, and drops the oldest
+// whole SSTables once a limit is exceeded:
+// 1) Size: the oldest SSTables are dropped until the total size of the SSTables is <= MaxTotalSizeInBytes.
+// 2) Age: the oldest SSTables which were created (refer to table.Properties) more than MaxAge ago are dropped.
+// Memtables are flushed to level0, and FIFOCompaction never moves them further, so all the SSTables are at level0, ordered
+// from the latest to the oldest. The SSTables without a creation time (built before the property was introduced) are only
+// dropped by the size limit.
+// The dropped SSTables are recorded in the manifest as a compaction without new SSTables (refer to
+// meta.CompactionDescription), and they are deleted by table.SSTableCleaner.
+// FIFOCompaction suits time-series data, where only the most recent window of data is queried.
+type FIFOCompaction struct {
+	options state.FIFOCompactionOptions
+	now     func() time.Time
+}
+
+// NewFIFOCompaction creates a new instance of FIFOCompaction.
+func NewFIFOCompaction(options state.FIFOCompactionOptions) FIFOCompaction {
+	return FIFOCompaction{
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// CompactionDescription returns the meta.CompactionDescription which drops the oldest level0 SSTables exceeding the limits.
+// It returns meta.NothingToCompactDescription, false if no SSTable exceeds the limits.
+func (compaction FIFOCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.CompactionDescription, bool) {
+	var totalSizeInBytes int64
+	for _, ssTableId := range stateSnapshot.L0SSTableIds {
+		totalSizeInBytes += stateSnapshot.SSTables[ssTableId].SizeInBytes()
+	}
+
+	var ssTableIdsToDrop []uint64
+	for index := len(stateSnapshot.L0SSTableIds) - 1; index >= 0; index-- {
+		ssTableId := stateSnapshot.L0SSTableIds[index]
+		ssTable := stateSnapshot.SSTables[ssTableId]
+		if !compaction.exceedsTotalSize(totalSizeInBytes) && !compaction.hasExpired(ssTable.Properties().CreationTimeInUnixSeconds) {
+			break
+		}
+		ssTableIdsToDrop = append(ssTableIdsToDrop, ssTableId)
+		totalSizeInBytes -= ssTable.SizeInBytes()
+	}
+	if len(ssTableIdsToDrop) == 0 {
+		return meta.NothingToCompactDescription, false
+	}
+	slices.Reverse(ssTableIdsToDrop)
+	return meta.CompactionDescription{
+		Inputs:      []meta.CompactionInput{{Level: 0, SSTableIds: ssTableIdsToDrop}},
+		OutputLevel: 0,
+		DropOnly:    true,
+	}, true
+}
+
+// exceedsTotalSize returns true if the size limit is set, and the given total size exceeds it.
+func (compaction FIFOCompaction) exceedsTotalSize(totalSizeInBytes int64) bool {
+	return compaction.options.MaxTotalSizeInBytes > 0 && totalSizeInBytes > compaction.options.MaxTotalSizeInBytes
+}
+
+// hasExpired returns true if the age limit is set, and an SSTable with the given creation time is older than the limit.
+func (compaction FIFOCompaction) hasExpired(creationTimeInUnixSeconds uint64) bool {
+	if compaction.options.MaxAge <= 0 || creationTimeInUnixSeconds == 0 {
+		return false
+	}
+	return compaction.now().Sub(time.Unix(int64(creationTimeInUnixSeconds), 0)) > compaction.options.MaxAge
+}
@@ -0,0 +1,126 @@
+package compact
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go-lsm/kv"
+	"go-lsm/state"
+	"go-lsm/table"
+	"go-lsm/test_utility"
+	"go-lsm/txn"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFIFOCompactionWithNothingToDrop(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.FIFOCompactionStrategy,
+		FIFOOptions: state.FIFOCompactionOptions{
+			MaxTotalSizeInBytes: 1 << 20,
+			MaxAge:              time.Hour,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+
+	_, ok := NewFIFOCompaction(storageState.Options().CompactionOptions.FIFOOptions).CompactionDescription(storageState.Snapshot())
+	assert.False(t, ok)
+}
+
+func TestFIFOCompactionDropsTheOldestSSTablesExceedingTheTotalSize(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.FIFOCompactionStrategy,
+		FIFOOptions: state.FIFOCompactionOptions{
+			MaxTotalSizeInBytes: 1 << 20,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	oldestSSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 5))
+	olderSSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 6))
+	latestSSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("etcd", 7))
+
+	snapshot := storageState.Snapshot()
+	compaction := NewFIFOCompaction(state.FIFOCompactionOptions{
+		MaxTotalSizeInBytes: snapshot.SSTables[latestSSTableId].SizeInBytes(),
+	})
+
+	description, ok := compaction.CompactionDescription(snapshot)
+	assert.True(t, ok)
+	assert.True(t, description.DropOnly)
+	assert.Equal(t, 0, description.OutputLevel)
+	assert.Equal(t, []uint64{olderSSTableId, oldestSSTableId}, description.SSTableIdsAt(0))
+}
+
+func TestFIFOCompactionDropsTheSSTablesOlderThanTheMaxAge(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.FIFOCompactionStrategy,
+		FIFOOptions: state.FIFOCompactionOptions{
+			MaxAge: time.Hour,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	olderSSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 5))
+	latestSSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 6))
+
+	compaction := NewFIFOCompaction(storageState.Options().CompactionOptions.FIFOOptions)
+	compaction.now = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+
+	description, ok := compaction.CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{latestSSTableId, olderSSTableId}, description.SSTableIdsAt(0))
+}
+
+func TestStartFIFOCompactionAndApplyTheStorageStateChangeEvent(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.FIFOCompactionStrategy,
+		FIFOOptions: state.FIFOCompactionOptions{
+			MaxTotalSizeInBytes: 1,
+		},
+	})
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	ssTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 5))
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	event, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.True(t, event.HasAnyChanges())
+	assert.Equal(t, 0, len(event.NewSSTables))
+	assert.Nil(t, storageState.Apply(event, false))
+
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(0))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(table.SSTableFilePath(ssTableId, rootPath))
+		return os.IsNotExist(err)
+	}, 1*time.Second, 5*time.Millisecond)
+
+	_, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,7 @@
+package compact
+
+import "go-lsm/compact/meta"
+
+// CompactionFilter is the user hook which keeps, removes or changes the values of the keys during compaction.
+// It is an alias of meta.CompactionFilter, which is configured in state.CompactionOptions.
+type CompactionFilter = meta.CompactionFilter
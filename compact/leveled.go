@@ -0,0 +1,139 @@
+package compact
+
+import (
+	"go-lsm/compact/meta"
+	"go-lsm/kv"
+	"go-lsm/state"
+	"go-lsm/table"
+)
+
+// LeveledCompaction represents a size-based leveled compaction strategy (similar to the leveled compaction of RocksDB),
+// which compacts a single table.SSTable file of a level with only the overlapping table.SSTable files of the next level.
+// Every level (other than level0 and the last level) has a target size:
+// target size of level1 = BaseLevelSizeInBytes, target size of levelN = target size of level(N-1) * LevelSizeMultiplier.
+// Every level gets a score, and the level with the highest score (>= 1) is compacted:
+// 1) Score of level0 = number of files at level0 / Level0FilesCompactionTrigger.
+// 2) Score of levelN = size of levelN / target size of levelN.
+// The last level is never compacted, because there is no level below it.
+// Level0 files overlap, so all the files at level0 are compacted with the overlapping files of level1.
+// For any other level, a single file is picked using a round-robin cursor per level, which remembers the ending key of the
+// last compacted file, so that the compactions spread across the key space of the level.
+// The picked file is expanded with its adjacent files which share the boundary raw key (compaction may split the versions of
+// a raw key across two adjacent files), so that all the versions of a raw key move to the next level together.
+// The cursors are kept in memory, and start from the beginning of every level after a restart.
+type LeveledCompaction struct {
+	options state.LeveledCompactionOptions
+	cursors map[int]kv.Key
+}
+
+// NewLeveledCompaction creates a new instance of LeveledCompaction.
+func NewLeveledCompaction(options state.LeveledCompactionOptions) *LeveledCompaction {
+	return &LeveledCompaction{
+		options: options,
+		cursors: make(map[int]kv.Key),
+	}
+}
+
+// CompactionDescription returns the meta.CompactionDescription for the level with the highest score (>= 1).
+// It returns meta.NothingToCompactDescription, false if no level has a score >= 1.
+func (compaction *LeveledCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.CompactionDescription, bool) {
+	level, ok := compaction.levelToCompact(stateSnapshot)
+	if !ok {
+		return meta.NothingToCompactDescription, false
+	}
+	if level == 0 {
+		return compaction.level0CompactionDescription(stateSnapshot), true
+	}
+	return compaction.levelCompactionDescription(level, stateSnapshot), true
+}
+
+// levelToCompact returns the level with the highest score, if the score is >= 1.
+func (compaction *LeveledCompaction) levelToCompact(stateSnapshot state.StorageStateSnapshot) (int, bool) {
+	if len(stateSnapshot.Levels) == 0 {
+		return 0, false
+	}
+	levelToCompact, highestScore := 0, float64(len(stateSnapshot.L0SSTableIds))/float64(compaction.options.Level0FilesTrigger())
+	for level := 1; level < len(stateSnapshot.Levels); level++ {
+		var levelSize int64
+		for _, ssTableId := range stateSnapshot.SSTableIdsAt(level) {
+			levelSize += stateSnapshot.SSTables[ssTableId].SizeInBytes()
+		}
+		score := float64(levelSize) / float64(compaction.options.TargetSizeInBytesAt(level))
+		if score > highestScore {
+			levelToCompact, highestScore = level, score
+		}
+	}
+	return levelToCompact, highestScore >= 1
+}
+
+// level0CompactionDescription returns the meta.CompactionDescription which compacts all the files at level0 with the
+// overlapping files of level1.
+func (compaction *LeveledCompaction) level0CompactionDescription(stateSnapshot state.StorageStateSnapshot) meta.CompactionDescription {
+	var ssTables []*table.SSTable
+	for _, ssTableId := range stateSnapshot.L0SSTableIds {
+		ssTables = append(ssTables, stateSnapshot.SSTables[ssTableId])
+	}
+	return meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: stateSnapshot.L0SSTableIds},
+			{Level: 1, SSTableIds: stateSnapshot.Levels[0].OverlappingSSTableIds(rawKeyRangeOf(ssTables))},
+		},
+		OutputLevel: 1,
+	}
+}
+
+// levelCompactionDescription returns the meta.CompactionDescription which compacts the file (expanded with its adjacent
+// files sharing the boundary raw keys) picked by the cursor of the level with the overlapping files of the next level.
+func (compaction *LeveledCompaction) levelCompactionDescription(level int, stateSnapshot state.StorageStateSnapshot) meta.CompactionDescription {
+	ssTableIds := stateSnapshot.SSTableIdsAt(level)
+	ssTableAt := func(index int) *table.SSTable {
+		return stateSnapshot.SSTables[ssTableIds[index]]
+	}
+
+	first := 0
+	if cursor, ok := compaction.cursors[level]; ok {
+		for index := range ssTableIds {
+			if ssTableAt(index).StartingKey().IsRawKeyGreaterThan(cursor) {
+				first = index
+				break
+			}
+		}
+	}
+	last := first
+	for first > 0 && ssTableAt(first-1).EndingKey().IsRawKeyEqualTo(ssTableAt(first).StartingKey()) {
+		first--
+	}
+	for last < len(ssTableIds)-1 && ssTableAt(last).EndingKey().IsRawKeyEqualTo(ssTableAt(last+1).StartingKey()) {
+		last++
+	}
+
+	var ssTables []*table.SSTable
+	for index := first; index <= last; index++ {
+		ssTables = append(ssTables, ssTableAt(index))
+	}
+	compaction.cursors[level] = ssTableAt(last).EndingKey()
+
+	return meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: level, SSTableIds: ssTableIds[first : last+1]},
+			{Level: level + 1, SSTableIds: stateSnapshot.Levels[level].OverlappingSSTableIds(rawKeyRangeOf(ssTables))},
+		},
+		OutputLevel: level + 1,
+	}
+}
+
+// rawKeyRangeOf returns the kv.InclusiveKeyRange covering the raw keys of all the given SSTables.
+// The keys of the range have the same (zero) timestamp, the range is only meant for the comparison of raw keys
+// (refer to state.Level.OverlappingSSTableIds).
+func rawKeyRangeOf(ssTables []*table.SSTable) kv.InclusiveKeyRange[kv.Key] {
+	smallest, largest := ssTables[0].StartingKey(), ssTables[0].EndingKey()
+	for _, ssTable := range ssTables[1:] {
+		if ssTable.StartingKey().IsRawKeyLesserThan(smallest) {
+			smallest = ssTable.StartingKey()
+		}
+		if ssTable.EndingKey().IsRawKeyGreaterThan(largest) {
+			largest = ssTable.EndingKey()
+		}
+	}
+	return kv.NewInclusiveKeyRange(kv.NewKey(smallest.RawBytes(), 0), kv.NewKey(largest.RawBytes(), 0))
+}
@@ -0,0 +1,193 @@
+package compact
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go-lsm/kv"
+	"go-lsm/state"
+	"go-lsm/table"
+	"go-lsm/test_utility"
+	"go-lsm/txn"
+	"testing"
+)
+
+func buildSSTableAtLevel(t *testing.T, storageState *state.StorageState, level int, keys ...kv.Key) uint64 {
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	for _, key := range keys {
+		ssTableBuilder.Add(key, kv.NewStringValue("value"))
+	}
+	ssTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), storageState.Options().Path)
+	assert.Nil(t, err)
+
+	storageState.SetSSTableAtLevel(ssTable, level)
+	return ssTable.Id()
+}
+
+func TestLeveledCompactionWithNothingToCompact(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1 << 20,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("bolt", 6))
+
+	_, ok := NewLeveledCompaction(storageState.Options().CompactionOptions.LeveledOptions).CompactionDescription(storageState.Snapshot())
+	assert.False(t, ok)
+}
+
+func TestLeveledCompactionOfLevel0WithOnlyTheOverlappingFilesOfLevel1(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1 << 20,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	l0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringKeyWithTimestamp("distributed", 6))
+	anotherL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("etcd", 7))
+	buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("bolt", 5))
+	overlappingL1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("database", 5), kv.NewStringKeyWithTimestamp("raft", 5))
+
+	description, ok := NewLeveledCompaction(storageState.Options().CompactionOptions.LeveledOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 1, description.OutputLevel)
+	assert.Equal(t, []uint64{anotherL0SSTableId, l0SSTableId}, description.SSTableIdsAt(0))
+	assert.Equal(t, []uint64{overlappingL1SSTableId}, description.SSTableIdsAt(1))
+}
+
+func TestLeveledCompactionPicksAFileOfLevel1UsingARoundRobinCursor(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	firstL1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("bolt", 5))
+	secondL1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringKeyWithTimestamp("etcd", 5))
+	firstL2SSTableId := buildSSTableAtLevel(t, storageState, 2, kv.NewStringKeyWithTimestamp("algorithm", 3), kv.NewStringKeyWithTimestamp("b+tree", 3))
+	secondL2SSTableId := buildSSTableAtLevel(t, storageState, 2, kv.NewStringKeyWithTimestamp("bolt", 3), kv.NewStringKeyWithTimestamp("database", 3))
+	buildSSTableAtLevel(t, storageState, 2, kv.NewStringKeyWithTimestamp("raft", 3))
+
+	compaction := NewLeveledCompaction(storageState.Options().CompactionOptions.LeveledOptions)
+
+	description, ok := compaction.CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 2, description.OutputLevel)
+	assert.Equal(t, []uint64{firstL1SSTableId}, description.SSTableIdsAt(1))
+	assert.Equal(t, []uint64{firstL2SSTableId, secondL2SSTableId}, description.SSTableIdsAt(2))
+
+	description, ok = compaction.CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{secondL1SSTableId}, description.SSTableIdsAt(1))
+	assert.Equal(t, []uint64{secondL2SSTableId}, description.SSTableIdsAt(2))
+
+	description, ok = compaction.CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{firstL1SSTableId}, description.SSTableIdsAt(1))
+}
+
+func TestLeveledCompactionExpandsThePickedFileWithTheAdjacentFilesSharingARawKey(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	firstL1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("consensus", 7))
+	secondL1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringKeyWithTimestamp("etcd", 5))
+	buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("raft", 5))
+
+	description, ok := NewLeveledCompaction(storageState.Options().CompactionOptions.LeveledOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{firstL1SSTableId, secondL1SSTableId}, description.SSTableIdsAt(1))
+	assert.Equal(t, []uint64(nil), description.SSTableIdsAt(2))
+}
+
+func TestLeveledCompactionPicksTheLevelWithTheHighestScore(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 1,
+			BaseLevelSizeInBytes:         1,
+			LevelSizeMultiplier:          1 << 20,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("bolt", 6))
+	buildSSTableAtLevel(t, storageState, 2, kv.NewStringKeyWithTimestamp("raft", 6))
+
+	description, ok := NewLeveledCompaction(storageState.Options().CompactionOptions.LeveledOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 2, description.OutputLevel)
+}
+
+func TestStartLeveledCompactionAndApplyTheStorageStateChangeEvent(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1 << 20,
+		},
+	})
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+	nonOverlappingL1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("raft", 5))
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	event, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.True(t, event.HasAnyChanges())
+	assert.Nil(t, storageState.Apply(event, false))
+
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, append(event.NewSSTableIds, nonOverlappingL1SSTableId), storageState.Snapshot().SSTableIdsAt(1))
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("value"), value)
+}
@@ -1,14 +1,68 @@
 package meta
 
 // NothingToCompactDescription represents none compaction.
-var NothingToCompactDescription = SimpleLeveledCompactionDescription{}
+var NothingToCompactDescription = CompactionDescription{}
+
+// CompactionInput defines the table.SSTable ids of a level which are the inputs of a compaction (level0 is represented by 0).
+type CompactionInput struct {
+	Level      int
+	SSTableIds []uint64
+}
+
+// CompactionDescription describes a compaction independent of the compaction strategy which produced it.
+// Inputs contains the table.SSTable ids per level which undergo compaction, from the upper (newer) level to the lower
+// (older) level, and OutputLevel is the level which receives the new table.SSTable(s).
+// Between level0 and level1, Inputs would contain the SSTable ids of level0 and level1, and OutputLevel would be level1.
+// DropOnly is true if the inputs are dropped without being merged (refer to compact.FIFOCompaction), such a compaction has
+// no new SSTables. DropOnly is not recorded in the manifest, because a compaction without new SSTables is applied the same
+// way on recovery.
+type CompactionDescription struct {
+	Inputs      []CompactionInput
+	OutputLevel int
+	DropOnly    bool
+}
+
+// SSTableIdsAt returns the input SSTable ids of the given level.
+func (description CompactionDescription) SSTableIdsAt(level int) []uint64 {
+	for _, input := range description.Inputs {
+		if input.Level == level {
+			return input.SSTableIds
+		}
+	}
+	return nil
+}
+
+// AllSSTableIds returns the input SSTable ids of all the levels.
+func (description CompactionDescription) AllSSTableIds() []uint64 {
+	var ssTableIds []uint64
+	for _, input := range description.Inputs {
+		ssTableIds = append(ssTableIds, input.SSTableIds...)
+	}
+	return ssTableIds
+}
 
 // SimpleLeveledCompactionDescription defines the table.SSTable ids between adjacent levels which will undergo compaction.
-// Between level0 and level1, level0 would be UpperLevel, level1 would be LowerLevel.
+// Between level0 and level1, level0 would be UpperLevel (represented by -1), level1 would be LowerLevel.
 // Similarly, between level1 and level2, level1 would be UpperLevel, level2 would be LowerLevel.
+// It is the description recorded by the older manifests, and it is only decoded (refer to ToCompactionDescription).
 type SimpleLeveledCompactionDescription struct {
 	UpperLevel           int
 	LowerLevel           int
 	UpperLevelSSTableIds []uint64
 	LowerLevelSSTableIds []uint64
 }
+
+// ToCompactionDescription converts the SimpleLeveledCompactionDescription to CompactionDescription.
+func (description SimpleLeveledCompactionDescription) ToCompactionDescription() CompactionDescription {
+	upperLevel := description.UpperLevel
+	if upperLevel == -1 {
+		upperLevel = 0
+	}
+	return CompactionDescription{
+		Inputs: []CompactionInput{
+			{Level: upperLevel, SSTableIds: description.UpperLevelSSTableIds},
+			{Level: description.LowerLevel, SSTableIds: description.LowerLevelSSTableIds},
+		},
+		OutputLevel: description.LowerLevel,
+	}
+}
@@ -0,0 +1,27 @@
+package meta
+
+import "go-lsm/kv"
+
+// CompactionFilterDecision is the decision of a CompactionFilter for a key.
+type CompactionFilterDecision byte
+
+const (
+	// KeepValue retains the key with its value.
+	KeepValue CompactionFilterDecision = iota
+	// RemoveValue removes the key (along with its older versions).
+	RemoveValue
+	// ChangeValue retains the key with the value returned by the CompactionFilter.
+	ChangeValue
+)
+
+// CompactionFilter is a user hook invoked by compaction for the latest version of every (non-deleted) key, only if the
+// version is not visible to any running transaction (its commit-timestamp > txn.Oracle.MaxActiveBeginTimestamp), so that
+// the filter does not change the snapshot of a running transaction (refer to compact.Compaction.ssTablesFromIterator).
+// It allows removing the expired keys, or rewriting the values (e.g. trimming a value) without a write transaction.
+// Filter receives the output level of the compaction, and returns the CompactionFilterDecision along with the new value,
+// which is only used with ChangeValue.
+// CompactionFilter is declared in meta (instead of compact), so that it can be configured in state.CompactionOptions.
+// Filter is invoked concurrently by the subcompactions, so it must be safe for concurrent use.
+type CompactionFilter interface {
+	Filter(level int, key kv.Key, value kv.Value) (CompactionFilterDecision, kv.Value)
+}
@@ -31,18 +31,17 @@ func NewSimpleLeveledCompaction(options state.SimpleLeveledCompactionOptions) Si
 	}
 }
 
-// CompactionDescription returns the meta.SimpleLeveledCompactionDescription.
-// If UpperLevel in meta.SimpleLeveledCompactionDescription is -1, it denotes, level0.
-// It returns an instance of meta.SimpleLeveledCompactionDescription if any two levels are eligible for compaction, else
-// it returns meta.NothingToCompactDescription, false.
-func (compaction SimpleLeveledCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.SimpleLeveledCompactionDescription, bool) {
+// CompactionDescription returns the meta.CompactionDescription.
+// It returns an instance of meta.CompactionDescription (with the SSTable ids of both the levels as inputs, and the lower level
+// as the output level) if any two levels are eligible for compaction, else it returns meta.NothingToCompactDescription, false.
+func (compaction SimpleLeveledCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.CompactionDescription, bool) {
 	var ssTableCountByLevel []int
 	ssTableCountByLevel = append(ssTableCountByLevel, len(stateSnapshot.L0SSTableIds))
 
 	for _, level := range stateSnapshot.Levels {
 		ssTableCountByLevel = append(ssTableCountByLevel, len(level.SSTableIds))
 	}
-	for level := 0; level < int(compaction.options.MaxLevels); level++ {
+	for level := 0; level < len(stateSnapshot.Levels); level++ {
 		if level == 0 {
 			if ssTableCountByLevel[level] < int(compaction.options.Level0FilesCompactionTrigger) {
 				continue
@@ -51,18 +50,12 @@ func (compaction SimpleLeveledCompaction) CompactionDescription(stateSnapshot st
 		lowerLevel := level + 1
 		countRatioPercentage := (float64(ssTableCountByLevel[lowerLevel]) / float64(ssTableCountByLevel[level])) * 100
 		if countRatioPercentage < float64(compaction.options.NumberOfSSTablesRatioPercentage) {
-			println("Triggering simple leveled compaction between levels ", level, lowerLevel)
-			var upperLevel int
-			if level == 0 {
-				upperLevel = -1
-			} else {
-				upperLevel = level
-			}
-			return meta.SimpleLeveledCompactionDescription{
-				UpperLevel:           upperLevel,
-				LowerLevel:           lowerLevel,
-				UpperLevelSSTableIds: stateSnapshot.SSTableIdsAt(level),
-				LowerLevelSSTableIds: stateSnapshot.SSTableIdsAt(lowerLevel),
+			return meta.CompactionDescription{
+				Inputs: []meta.CompactionInput{
+					{Level: level, SSTableIds: stateSnapshot.SSTableIdsAt(level)},
+					{Level: lowerLevel, SSTableIds: stateSnapshot.SSTableIdsAt(lowerLevel)},
+				},
+				OutputLevel: lowerLevel,
 			}, true
 		}
 	}
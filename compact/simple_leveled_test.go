@@ -9,7 +9,6 @@ import (
 func TestGenerateCompactionTaskForSimpleLayeredCompactionWithNoCompaction(t *testing.T) {
 	compactionOptions := state.SimpleLeveledCompactionOptions{
 		NumberOfSSTablesRatioPercentage: 200,
-		MaxLevels:                       2,
 		Level0FilesCompactionTrigger:    2,
 	}
 	snapshot := state.StorageStateSnapshot{
@@ -29,7 +28,6 @@ func TestGenerateCompactionTaskForSimpleLayeredCompactionWithNoCompaction(t *tes
 func TestGenerateCompactionTaskForSimpleLayeredCompactionWithCompactionForLevel0And1(t *testing.T) {
 	compactionOptions := state.SimpleLeveledCompactionOptions{
 		NumberOfSSTablesRatioPercentage: 200,
-		MaxLevels:                       2,
 		Level0FilesCompactionTrigger:    2,
 	}
 	snapshot := state.StorageStateSnapshot{
@@ -43,16 +41,15 @@ func TestGenerateCompactionTaskForSimpleLayeredCompactionWithCompactionForLevel0
 	compactionDescription, ok := compaction.CompactionDescription(snapshot)
 
 	assert.True(t, ok)
-	assert.Equal(t, -1, compactionDescription.UpperLevel)
-	assert.Equal(t, 1, compactionDescription.LowerLevel)
-	assert.Equal(t, []uint64{1, 2}, compactionDescription.UpperLevelSSTableIds)
-	assert.Equal(t, []uint64(nil), compactionDescription.LowerLevelSSTableIds)
+	assert.Equal(t, 1, compactionDescription.OutputLevel)
+	assert.Equal(t, 0, compactionDescription.Inputs[0].Level)
+	assert.Equal(t, []uint64{1, 2}, compactionDescription.SSTableIdsAt(0))
+	assert.Equal(t, []uint64(nil), compactionDescription.SSTableIdsAt(1))
 }
 
 func TestGenerateCompactionTaskForSimpleLayeredCompactionWithCompactionForLevel1And2(t *testing.T) {
 	compactionOptions := state.SimpleLeveledCompactionOptions{
 		NumberOfSSTablesRatioPercentage: 200,
-		MaxLevels:                       2,
 		Level0FilesCompactionTrigger:    2,
 	}
 	snapshot := state.StorageStateSnapshot{
@@ -67,8 +64,7 @@ func TestGenerateCompactionTaskForSimpleLayeredCompactionWithCompactionForLevel1
 	compactionDescription, ok := compaction.CompactionDescription(snapshot)
 
 	assert.True(t, ok)
-	assert.Equal(t, 1, compactionDescription.UpperLevel)
-	assert.Equal(t, 2, compactionDescription.LowerLevel)
-	assert.Equal(t, []uint64{2, 3}, compactionDescription.UpperLevelSSTableIds)
-	assert.Equal(t, []uint64{4}, compactionDescription.LowerLevelSSTableIds)
+	assert.Equal(t, 2, compactionDescription.OutputLevel)
+	assert.Equal(t, []uint64{2, 3}, compactionDescription.SSTableIdsAt(1))
+	assert.Equal(t, []uint64{4}, compactionDescription.SSTableIdsAt(2))
 }
@@ -0,0 +1,32 @@
+package compact
+
+import (
+	"fmt"
+	"go-lsm/compact/meta"
+	"go-lsm/state"
+)
+
+// CompactionStrategy decides which table.SSTable files undergo compaction.
+// It returns a meta.CompactionDescription (the input SSTable ids per level and the output level) if there is anything to compact,
+// else it returns meta.NothingToCompactDescription, false.
+// The meta.CompactionDescription does not depend on the strategy, so state.StorageState and manifest.Manifest do not
+// change with a new strategy.
+type CompactionStrategy interface {
+	CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.CompactionDescription, bool)
+}
+
+// NewCompactionStrategy creates the CompactionStrategy identified by state.CompactionOptions.
+func NewCompactionStrategy(options state.CompactionOptions) (CompactionStrategy, error) {
+	switch options.Strategy {
+	case state.SimpleLeveledCompactionStrategy:
+		return NewSimpleLeveledCompaction(options.StrategyOptions), nil
+	case state.LeveledCompactionStrategy:
+		return NewLeveledCompaction(options.LeveledOptions), nil
+	case state.TieredCompactionStrategy:
+		return NewTieredCompaction(options.TieredOptions), nil
+	case state.FIFOCompactionStrategy:
+		return NewFIFOCompaction(options.FIFOOptions), nil
+	default:
+		return nil, fmt.Errorf("unsupported compaction strategy %d", options.Strategy)
+	}
+}
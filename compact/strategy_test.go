@@ -0,0 +1,66 @@
+package compact
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go-lsm/state"
+	"testing"
+)
+
+func TestNewCompactionStrategyForSimpleLeveledCompaction(t *testing.T) {
+	strategy, err := NewCompactionStrategy(state.CompactionOptions{
+		Strategy: state.SimpleLeveledCompactionStrategy,
+		StrategyOptions: state.SimpleLeveledCompactionOptions{
+			NumberOfSSTablesRatioPercentage: 200,
+			Level0FilesCompactionTrigger:    2,
+		},
+	})
+	assert.Nil(t, err)
+	_, isSimpleLeveled := strategy.(SimpleLeveledCompaction)
+	assert.True(t, isSimpleLeveled)
+
+	description, ok := strategy.CompactionDescription(state.StorageStateSnapshot{
+		L0SSTableIds: []uint64{1, 2},
+		Levels: []*state.Level{
+			{LevelNumber: 1, SSTableIds: nil},
+			{LevelNumber: 2, SSTableIds: nil},
+		},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 1, description.OutputLevel)
+	assert.Equal(t, []uint64{1, 2}, description.AllSSTableIds())
+}
+
+func TestNewCompactionStrategyForLeveledCompaction(t *testing.T) {
+	strategy, err := NewCompactionStrategy(state.CompactionOptions{
+		Strategy:       state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{BaseLevelSizeInBytes: 1 << 20},
+	})
+	assert.Nil(t, err)
+	_, isLeveled := strategy.(*LeveledCompaction)
+	assert.True(t, isLeveled)
+}
+
+func TestNewCompactionStrategyWithUnsupportedStrategy(t *testing.T) {
+	_, err := NewCompactionStrategy(state.CompactionOptions{Strategy: state.CompactionStrategyType(10)})
+	assert.Error(t, err)
+}
+
+func TestNewCompactionStrategyForTieredCompaction(t *testing.T) {
+	strategy, err := NewCompactionStrategy(state.CompactionOptions{
+		Strategy:      state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{MaxSortedRuns: 2},
+	})
+	assert.Nil(t, err)
+	_, isTiered := strategy.(TieredCompaction)
+	assert.True(t, isTiered)
+}
+
+func TestNewCompactionStrategyForFIFOCompaction(t *testing.T) {
+	strategy, err := NewCompactionStrategy(state.CompactionOptions{
+		Strategy:    state.FIFOCompactionStrategy,
+		FIFOOptions: state.FIFOCompactionOptions{MaxTotalSizeInBytes: 1 << 20},
+	})
+	assert.Nil(t, err)
+	_, isFIFO := strategy.(FIFOCompaction)
+	assert.True(t, isFIFO)
+}
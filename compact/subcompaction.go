@@ -0,0 +1,211 @@
+package compact
+
+import (
+	"go-lsm/compact/meta"
+	"go-lsm/iterator"
+	"go-lsm/kv"
+	"go-lsm/state"
+	"go-lsm/table"
+	"math"
+	"slices"
+	"sync"
+)
+
+// subcompaction represents a key-range shard of a compaction: it covers the raw keys >= startRawKey and < endRawKey.
+// An empty startRawKey means the shard starts from the first key of the inputs, and an empty endRawKey means the shard
+// ends at the last key of the inputs.
+// The boundaries are raw keys, so all the versions of a raw key belong to a single shard, which is needed for dropping the
+// older versions and the tombstones (refer to Compaction.ssTablesFromIterator).
+type subcompaction struct {
+	startRawKey kv.Key
+	endRawKey   kv.Key
+}
+
+// subcompactionResult is the outcome of merging a subcompaction.
+type subcompactionResult struct {
+	ssTables []*table.SSTable
+	err      error
+}
+
+// subcompactionsOf splits the compaction described by meta.CompactionDescription into at most MaxSubcompactions key-range
+// shards. The shard boundaries are the starting raw keys of the input SSTables, picked evenly from all the distinct starting
+// raw keys (other than the smallest one), so every shard gets a similar number of input SSTables.
+// It returns a single shard (covering all the keys) if MaxSubcompactions <= 1, or if the inputs have a single starting raw key.
+// A compaction into level0 is also a single shard, because it must produce a single SSTable (refer to
+// Compaction.ssTablesFromIterator).
+func (compaction *Compaction) subcompactionsOf(description meta.CompactionDescription, snapshot state.StorageStateSnapshot) []subcompaction {
+	maxSubcompactions := int(compaction.options.CompactionOptions.MaxSubcompactions)
+	if maxSubcompactions <= 1 || description.OutputLevel == 0 {
+		return []subcompaction{{startRawKey: kv.EmptyKey, endRawKey: kv.EmptyKey}}
+	}
+	var startingRawKeys []kv.Key
+	for _, ssTableId := range description.AllSSTableIds() {
+		startingRawKeys = append(startingRawKeys, kv.NewKey(snapshot.SSTables[ssTableId].StartingKey().RawBytes(), 0))
+	}
+	slices.SortFunc(startingRawKeys, func(key, other kv.Key) int {
+		return key.CompareKeysWithDescendingTimestamp(other)
+	})
+	startingRawKeys = slices.CompactFunc(startingRawKeys, kv.Key.IsRawKeyEqualTo)
+
+	boundaries := startingRawKeys[1:]
+	numberOfSubcompactions := min(maxSubcompactions, len(boundaries)+1)
+
+	subcompactions := make([]subcompaction, 0, numberOfSubcompactions)
+	startRawKey := kv.EmptyKey
+	for index := 1; index < numberOfSubcompactions; index++ {
+		endRawKey := boundaries[index*len(boundaries)/numberOfSubcompactions]
+		subcompactions = append(subcompactions, subcompaction{startRawKey: startRawKey, endRawKey: endRawKey})
+		startRawKey = endRawKey
+	}
+	return append(subcompactions, subcompaction{startRawKey: startRawKey, endRawKey: kv.EmptyKey})
+}
+
+// runSubcompactions merges all the subcompactions concurrently (one goroutine per subcompaction), and returns the new
+// SSTables of all the subcompactions in the order of their key ranges, so the new SSTables are disjoint and sorted.
+// If any subcompaction fails, the new SSTables of all the other subcompactions are removed, and the error is returned,
+// so a compaction is either installed completely or not at all.
+func (compaction *Compaction) runSubcompactions(
+	subcompactions []subcompaction,
+	description meta.CompactionDescription,
+	snapshot state.StorageStateSnapshot,
+	dropTombstones bool,
+) ([]*table.SSTable, error) {
+	if len(subcompactions) == 1 {
+		return compaction.runSubcompaction(subcompactions[0], description, snapshot, dropTombstones)
+	}
+	results := make([]subcompactionResult, len(subcompactions))
+
+	var waitGroup sync.WaitGroup
+	for index, shard := range subcompactions {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			ssTables, err := compaction.runSubcompaction(shard, description, snapshot, dropTombstones)
+			results[index] = subcompactionResult{ssTables: ssTables, err: err}
+		}()
+	}
+	waitGroup.Wait()
+
+	var newSSTables []*table.SSTable
+	var err error
+	for _, result := range results {
+		if result.err != nil && err == nil {
+			err = result.err
+		}
+		newSSTables = append(newSSTables, result.ssTables...)
+	}
+	if err != nil {
+		removeSSTables(newSSTables)
+		return nil, err
+	}
+	return newSSTables, nil
+}
+
+// runSubcompaction merges the input SSTables within the key range of the subcompaction, by creating an instance of
+// iterator.MergeIterator using the iterators of the input SSTables which overlap the key range (from the upper level to
+// the lower level).
+func (compaction *Compaction) runSubcompaction(
+	shard subcompaction,
+	description meta.CompactionDescription,
+	snapshot state.StorageStateSnapshot,
+	dropTombstones bool,
+) ([]*table.SSTable, error) {
+	closeAll := func(iterators []iterator.Iterator) {
+		for _, anIterator := range iterators {
+			anIterator.Close()
+		}
+	}
+	iterators := make([]iterator.Iterator, 0, len(description.AllSSTableIds()))
+	for _, ssTableId := range description.AllSSTableIds() {
+		ssTable := snapshot.SSTables[ssTableId]
+		if !shard.overlaps(ssTable) {
+			continue
+		}
+		ssTableIterator, err := shard.seek(ssTable)
+		if err != nil {
+			closeAll(iterators)
+			return nil, err
+		}
+		boundedIterator := newRawKeyBoundedIterator(ssTableIterator, shard.endRawKey)
+		if !boundedIterator.IsValid() {
+			boundedIterator.Close()
+			continue
+		}
+		iterators = append(iterators, boundedIterator)
+	}
+	mergeIterator := iterator.NewMergeIterator(iterators, iterator.NoOperationOnCloseCallback)
+	defer mergeIterator.Close()
+
+	return compaction.ssTablesFromIterator(mergeIterator, description.OutputLevel, dropTombstones)
+}
+
+// overlaps returns true if the raw key range of the SSTable overlaps the key range of the subcompaction.
+func (shard subcompaction) overlaps(ssTable *table.SSTable) bool {
+	if !shard.startRawKey.IsRawKeyEmpty() && ssTable.EndingKey().IsRawKeyLesserThan(shard.startRawKey) {
+		return false
+	}
+	if !shard.endRawKey.IsRawKeyEmpty() && !ssTable.StartingKey().IsRawKeyLesserThan(shard.endRawKey) {
+		return false
+	}
+	return true
+}
+
+// seek returns the table.Iterator positioned at the first version (the one with the largest timestamp) of the starting
+// raw key of the subcompaction.
+func (shard subcompaction) seek(ssTable *table.SSTable) (*table.Iterator, error) {
+	if shard.startRawKey.IsRawKeyEmpty() {
+		return ssTable.SeekToFirst()
+	}
+	return ssTable.SeekToKeyForCompaction(kv.NewKey(shard.startRawKey.RawBytes(), math.MaxUint64))
+}
+
+// rawKeyBoundedIterator wraps an iterator.Iterator, and becomes invalid at the first key with the raw key >= endRawKey.
+// An empty endRawKey does not bound the iterator.
+// iterator.MergeIterator drops an invalid iterator without closing it, so the inner iterator is closed as soon as it
+// goes beyond endRawKey.
+type rawKeyBoundedIterator struct {
+	inner     iterator.Iterator
+	endRawKey kv.Key
+}
+
+// newRawKeyBoundedIterator creates a new instance of rawKeyBoundedIterator.
+func newRawKeyBoundedIterator(inner iterator.Iterator, endRawKey kv.Key) *rawKeyBoundedIterator {
+	return &rawKeyBoundedIterator{
+		inner:     inner,
+		endRawKey: endRawKey,
+	}
+}
+
+// Key returns kv.Key.
+func (iterator *rawKeyBoundedIterator) Key() kv.Key {
+	return iterator.inner.Key()
+}
+
+// Value returns kv.Value.
+func (iterator *rawKeyBoundedIterator) Value() kv.Value {
+	return iterator.inner.Value()
+}
+
+// Next advances the inner iterator, and closes it if it goes beyond endRawKey.
+func (iterator *rawKeyBoundedIterator) Next() error {
+	if err := iterator.inner.Next(); err != nil {
+		return err
+	}
+	if iterator.inner.IsValid() && !iterator.IsValid() {
+		iterator.inner.Close()
+	}
+	return nil
+}
+
+// IsValid returns true if the inner iterator is valid, and its raw key is less than endRawKey.
+func (iterator *rawKeyBoundedIterator) IsValid() bool {
+	if !iterator.inner.IsValid() {
+		return false
+	}
+	return iterator.endRawKey.IsRawKeyEmpty() || iterator.inner.Key().IsRawKeyLesserThan(iterator.endRawKey)
+}
+
+// Close closes the inner iterator.
+func (iterator *rawKeyBoundedIterator) Close() {
+	iterator.inner.Close()
+}
@@ -0,0 +1,168 @@
+package compact
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go-lsm/compact/meta"
+	"go-lsm/kv"
+	"go-lsm/state"
+	"go-lsm/table"
+	"go-lsm/test_utility"
+	"go-lsm/txn"
+	"testing"
+)
+
+func TestSubcompactionsWithMaxSubcompactionsAsZero(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1 << 20,
+		},
+		MaxSubcompactions: 0,
+	})
+	storageOptions.SSTableSizeInBytes = 256
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	l0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	l1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("distributed", 5))
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	subcompactions := compaction.subcompactionsOf(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{l0SSTableId}},
+			{Level: 1, SSTableIds: []uint64{l1SSTableId}},
+		},
+		OutputLevel: 1,
+	}, storageState.Snapshot())
+
+	assert.Equal(t, 1, len(subcompactions))
+	assert.True(t, subcompactions[0].startRawKey.IsRawKeyEmpty())
+	assert.True(t, subcompactions[0].endRawKey.IsRawKeyEmpty())
+}
+
+func TestSubcompactionsAtTheStartingKeysOfTheInputSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1 << 20,
+		},
+		MaxSubcompactions: 3,
+	})
+	storageOptions.SSTableSizeInBytes = 256
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	l0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("bolt", 6), kv.NewStringKeyWithTimestamp("raft", 6))
+	anotherL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 7))
+	l1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("bolt", 5))
+	anotherL1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("etcd", 5))
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	subcompactions := compaction.subcompactionsOf(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{anotherL0SSTableId, l0SSTableId}},
+			{Level: 1, SSTableIds: []uint64{l1SSTableId, anotherL1SSTableId}},
+		},
+		OutputLevel: 1,
+	}, storageState.Snapshot())
+
+	assert.Equal(t, 3, len(subcompactions))
+	assert.True(t, subcompactions[0].startRawKey.IsRawKeyEmpty())
+	assert.Equal(t, "consensus", subcompactions[0].endRawKey.RawString())
+	assert.Equal(t, "consensus", subcompactions[1].startRawKey.RawString())
+	assert.Equal(t, "etcd", subcompactions[1].endRawKey.RawString())
+	assert.Equal(t, "etcd", subcompactions[2].startRawKey.RawString())
+	assert.True(t, subcompactions[2].endRawKey.IsRawKeyEmpty())
+}
+
+func TestStartCompactionWithSubcompactionsAndApplyTheStorageStateChangeEvent(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1 << 20,
+		},
+		MaxSubcompactions: 4,
+	})
+	storageOptions.SSTableSizeInBytes = 256
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	buildSSTable := func(level int, timestamp uint64, from, to int) {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		for count := from; count < to; count++ {
+			ssTableBuilder.Add(
+				kv.NewStringKeyWithTimestamp(fmt.Sprintf("key-%03d", count), timestamp),
+				kv.NewStringValue(fmt.Sprintf("value-%d-%d", count, timestamp)),
+			)
+		}
+		ssTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+		assert.Nil(t, err)
+		storageState.SetSSTableAtLevel(ssTable, level)
+	}
+	buildSSTable(1, 5, 0, 30)
+	buildSSTable(1, 5, 30, 60)
+	buildSSTable(1, 5, 60, 90)
+	buildSSTable(0, 6, 10, 70)
+	buildSSTable(0, 7, 25, 45)
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	event, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.True(t, len(event.NewSSTables) > 1)
+
+	var keys []string
+	for _, ssTable := range event.NewSSTables {
+		iterator, err := ssTable.SeekToFirst()
+		assert.Nil(t, err)
+		for iterator.IsValid() {
+			keys = append(keys, fmt.Sprintf("%s@%d", iterator.Key().RawString(), iterator.Key().Timestamp()))
+			assert.Nil(t, iterator.Next())
+		}
+		iterator.Close()
+	}
+	var expectedKeys []string
+	for count := 0; count < 90; count++ {
+		if count >= 25 && count < 45 {
+			expectedKeys = append(expectedKeys, fmt.Sprintf("key-%03d@7", count))
+		}
+		if count >= 10 && count < 70 {
+			expectedKeys = append(expectedKeys, fmt.Sprintf("key-%03d@6", count))
+		}
+		expectedKeys = append(expectedKeys, fmt.Sprintf("key-%03d@5", count))
+	}
+	assert.Equal(t, expectedKeys, keys)
+
+	assert.Nil(t, storageState.Apply(event, false))
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, event.NewSSTableIds, storageState.Snapshot().SSTableIdsAt(1))
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("key-030", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("value-30-7"), value)
+}
@@ -0,0 +1,130 @@
+package compact
+
+import (
+	"go-lsm/compact/meta"
+	"go-lsm/state"
+)
+
+// TieredCompaction represents a size-tiered (universal) compaction strategy, which merges the sorted runs of similar sizes.
+// A sorted run is a set of non-overlapping table.SSTable files: every level0 SSTable is a sorted run, and every non-empty
+// level is a sorted run. The sorted runs are ordered from the newest (level0 SSTables from the latest to the oldest) to the
+// oldest (levels from level1 to the last level).
+// Compaction is triggered if the number of sorted runs is greater than MaxSortedRuns, and it picks consecutive sorted runs:
+// 1) Size-ratio: starting from the newest sorted run, the next (older) sorted run is picked if its size <= the total size of
+// the sorted runs picked so far * (100 + SizeRatioPercentage) / 100. If this picks at least two sorted runs, they are merged,
+// else the same is tried starting from the next sorted run.
+// 2) Max-sorted-runs: if no sorted runs are picked by size-ratio, the newest sorted runs are merged to bring the number of
+// sorted runs down to MaxSortedRuns.
+// The merged sorted run is placed at the level of the oldest picked sorted run, which keeps the newer sorted runs above the
+// older ones (reads enquire level0 from the latest to the oldest SSTable, and then the levels from level1):
+// 1) If the oldest picked sorted run is a level, the output level is that level.
+// 2) If the oldest picked sorted run is a level0 SSTable which is followed by an older level0 SSTable, the output level is level0.
+// A merge into level0 is not split by SSTableSizeInBytes (refer to Compaction.ssTablesFromIterator), so the merged sorted
+// runs become a single level0 SSTable (/sorted run).
+// 3) Otherwise, the output level is the last empty level above the next older sorted run (or the last level). If level1 is
+// the next older sorted run, it is also picked, and the output level is level1.
+// A tiered compaction rewrites the data fewer times than a leveled compaction, at the cost of more sorted runs for the reads.
+type TieredCompaction struct {
+	options state.TieredCompactionOptions
+}
+
+// sortedRun represents a sorted run (a level0 SSTable, or a non-empty level) along with its size.
+type sortedRun struct {
+	level       int
+	ssTableIds  []uint64
+	sizeInBytes int64
+}
+
+// NewTieredCompaction creates a new instance of TieredCompaction.
+func NewTieredCompaction(options state.TieredCompactionOptions) TieredCompaction {
+	return TieredCompaction{
+		options: options,
+	}
+}
+
+// CompactionDescription returns the meta.CompactionDescription which merges the picked sorted runs.
+// It returns meta.NothingToCompactDescription, false if the number of sorted runs is not greater than MaxSortedRuns.
+func (compaction TieredCompaction) CompactionDescription(stateSnapshot state.StorageStateSnapshot) (meta.CompactionDescription, bool) {
+	sortedRuns := sortedRunsOf(stateSnapshot)
+	if len(sortedRuns) <= int(compaction.options.SortedRunsTrigger()) {
+		return meta.NothingToCompactDescription, false
+	}
+	start, end, ok := compaction.pickUsingSizeRatio(sortedRuns)
+	if !ok {
+		start, end = 0, len(sortedRuns)-int(compaction.options.SortedRunsTrigger())
+	}
+
+	var outputLevel int
+	switch {
+	case sortedRuns[end].level != 0:
+		outputLevel = sortedRuns[end].level
+	case end+1 < len(sortedRuns) && sortedRuns[end+1].level == 0:
+		outputLevel = 0
+	case end+1 < len(sortedRuns) && sortedRuns[end+1].level == 1:
+		end = end + 1
+		outputLevel = 1
+	case end+1 < len(sortedRuns):
+		outputLevel = sortedRuns[end+1].level - 1
+	default:
+		outputLevel = len(stateSnapshot.Levels)
+	}
+
+	var level0Input meta.CompactionInput
+	inputs := make([]meta.CompactionInput, 0, end-start+1)
+	for _, run := range sortedRuns[start : end+1] {
+		if run.level == 0 {
+			level0Input.SSTableIds = append(level0Input.SSTableIds, run.ssTableIds...)
+			continue
+		}
+		inputs = append(inputs, meta.CompactionInput{Level: run.level, SSTableIds: run.ssTableIds})
+	}
+	if len(level0Input.SSTableIds) > 0 {
+		inputs = append([]meta.CompactionInput{level0Input}, inputs...)
+	}
+	return meta.CompactionDescription{
+		Inputs:      inputs,
+		OutputLevel: outputLevel,
+	}, true
+}
+
+// pickUsingSizeRatio returns the start and the end (inclusive) index of the consecutive sorted runs picked using size-ratio.
+func (compaction TieredCompaction) pickUsingSizeRatio(sortedRuns []sortedRun) (int, int, bool) {
+	for start := 0; start < len(sortedRuns)-1; start++ {
+		totalSize, end := sortedRuns[start].sizeInBytes, start
+		for end+1 < len(sortedRuns) {
+			nextSize := sortedRuns[end+1].sizeInBytes
+			if nextSize*100 > totalSize*int64(100+compaction.options.SizeRatioPercentage) {
+				break
+			}
+			totalSize += nextSize
+			end++
+		}
+		if end > start {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// sortedRunsOf returns the sorted runs of the state.StorageStateSnapshot, from the newest to the oldest.
+func sortedRunsOf(stateSnapshot state.StorageStateSnapshot) []sortedRun {
+	var sortedRuns []sortedRun
+	for _, ssTableId := range stateSnapshot.L0SSTableIds {
+		sortedRuns = append(sortedRuns, sortedRun{
+			level:       0,
+			ssTableIds:  []uint64{ssTableId},
+			sizeInBytes: stateSnapshot.SSTables[ssTableId].SizeInBytes(),
+		})
+	}
+	for _, level := range stateSnapshot.Levels {
+		if len(level.SSTableIds) == 0 {
+			continue
+		}
+		run := sortedRun{level: level.LevelNumber, ssTableIds: level.SSTableIds}
+		for _, ssTableId := range level.SSTableIds {
+			run.sizeInBytes += stateSnapshot.SSTables[ssTableId].SizeInBytes()
+		}
+		sortedRuns = append(sortedRuns, run)
+	}
+	return sortedRuns
+}
@@ -0,0 +1,259 @@
+package compact
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go-lsm/kv"
+	"go-lsm/state"
+	"go-lsm/table"
+	"go-lsm/test_utility"
+	"go-lsm/txn"
+	"testing"
+)
+
+func keysWithTimestamp(numberOfKeys int, timestamp uint64) []kv.Key {
+	keys := make([]kv.Key, 0, numberOfKeys)
+	for count := 0; count < numberOfKeys; count++ {
+		keys = append(keys, kv.NewStringKeyWithTimestamp(fmt.Sprintf("key-%03d", count), timestamp))
+	}
+	return keys
+}
+
+func TestTieredCompactionWithNothingToCompact(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns: 2,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("bolt", 6))
+
+	_, ok := NewTieredCompaction(storageState.Options().CompactionOptions.TieredOptions).CompactionDescription(storageState.Snapshot())
+	assert.False(t, ok)
+}
+
+func TestTieredCompactionMergesTheSortedRunsOfSimilarSizesAtLevel0(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns:       2,
+			SizeRatioPercentage: 100,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, keysWithTimestamp(100, 5)...)
+	olderL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	newerL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+
+	description, ok := NewTieredCompaction(storageState.Options().CompactionOptions.TieredOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 0, description.OutputLevel)
+	assert.Equal(t, []uint64{newerL0SSTableId, olderL0SSTableId}, description.AllSSTableIds())
+}
+
+func TestTieredCompactionPlacesTheMergedSortedRunAboveTheNextOlderSortedRun(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns:       2,
+			SizeRatioPercentage: 100,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	olderL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	newerL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+	buildSSTableAtLevel(t, storageState, 3, keysWithTimestamp(100, 5)...)
+
+	description, ok := NewTieredCompaction(storageState.Options().CompactionOptions.TieredOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 2, description.OutputLevel)
+	assert.Equal(t, []uint64{newerL0SSTableId, olderL0SSTableId}, description.SSTableIdsAt(0))
+	assert.Equal(t, []uint64(nil), description.SSTableIdsAt(3))
+}
+
+func TestTieredCompactionIncludesLevel1IfItIsTheNextOlderSortedRun(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns:       2,
+			SizeRatioPercentage: 100,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	olderL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	newerL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+	l1SSTableId := buildSSTableAtLevel(t, storageState, 1, keysWithTimestamp(100, 5)...)
+
+	description, ok := NewTieredCompaction(storageState.Options().CompactionOptions.TieredOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 1, description.OutputLevel)
+	assert.Equal(t, []uint64{newerL0SSTableId, olderL0SSTableId}, description.SSTableIdsAt(0))
+	assert.Equal(t, []uint64{l1SSTableId}, description.SSTableIdsAt(1))
+}
+
+func TestTieredCompactionMovesTheMergedSortedRunToTheLastLevelWithoutAnyOlderSortedRun(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns:       1,
+			SizeRatioPercentage: 100,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+
+	description, ok := NewTieredCompaction(storageState.Options().CompactionOptions.TieredOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 3, description.OutputLevel)
+}
+
+func TestTieredCompactionReducesTheSortedRunsToMaxSortedRunsIfNoSortedRunsHaveSimilarSizes(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns: 2,
+		},
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	olderL0SSTableId := buildSSTableAtLevel(t, storageState, 0, keysWithTimestamp(10, 6)...)
+	newerL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 7))
+	buildSSTableAtLevel(t, storageState, 2, keysWithTimestamp(100, 5)...)
+
+	description, ok := NewTieredCompaction(storageState.Options().CompactionOptions.TieredOptions).CompactionDescription(storageState.Snapshot())
+	assert.True(t, ok)
+	assert.Equal(t, 1, description.OutputLevel)
+	assert.Equal(t, []uint64{newerL0SSTableId, olderL0SSTableId}, description.AllSSTableIds())
+}
+
+func TestStartTieredCompactionAndApplyTheStorageStateChangeEventRetainingTheTombstoneOverAnOlderSortedRun(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns:       2,
+			SizeRatioPercentage: 100,
+		},
+	})
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+	oracle.SetBeginTimestamp(11)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	buildSSTable := func(level int, keys []kv.Key, values []kv.Value) uint64 {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		for index, key := range keys {
+			ssTableBuilder.Add(key, values[index])
+		}
+		ssTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+		assert.Nil(t, err)
+
+		storageState.SetSSTableAtLevel(ssTable, level)
+		return ssTable.Id()
+	}
+
+	l3Keys := append([]kv.Key{kv.NewStringKeyWithTimestamp("consensus", 5)}, keysWithTimestamp(100, 5)...)
+	l3Values := make([]kv.Value, 0, len(l3Keys))
+	l3Values = append(l3Values, kv.NewStringValue("raft"))
+	for range l3Keys[1:] {
+		l3Values = append(l3Values, kv.NewStringValue("value"))
+	}
+	l3SSTableId := buildSSTable(3, l3Keys, l3Values)
+	buildSSTable(0, []kv.Key{kv.NewStringKeyWithTimestamp("distributed", 7)}, []kv.Value{kv.NewStringValue("etcd")})
+	buildSSTable(0, []kv.Key{kv.NewStringKeyWithTimestamp("consensus", 8)}, []kv.Value{kv.EmptyValue})
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	event, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, event.CompactionOutputLevel())
+	assert.Nil(t, storageState.Apply(event, false))
+
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, event.NewSSTableIds, storageState.Snapshot().SSTableIdsAt(2))
+	assert.Equal(t, []uint64{l3SSTableId}, storageState.Snapshot().SSTableIdsAt(3))
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, !ok || value.IsEmpty())
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("etcd"), value)
+}
+
+func TestStartTieredCompactionMergesLevel0SortedRunsLargerThanSSTableSizeIntoASingleSortedRun(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.TieredCompactionStrategy,
+		TieredOptions: state.TieredCompactionOptions{
+			MaxSortedRuns:       2,
+			SizeRatioPercentage: 100,
+		},
+	})
+	storageOptions.BlockSize = 256
+	storageOptions.SSTableSizeInBytes = 512
+	storageOptions.CompactionOptions.MaxSubcompactions = 4
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	buildSSTableAtLevel(t, storageState, 0, keysWithTimestamp(1000, 5)...)
+	buildSSTableAtLevel(t, storageState, 0, keysWithTimestamp(50, 6)...)
+	buildSSTableAtLevel(t, storageState, 0, keysWithTimestamp(50, 7)...)
+	assert.Equal(t, 3, len(sortedRunsOf(storageState.Snapshot())))
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	event, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, event.CompactionOutputLevel())
+	assert.Equal(t, 1, len(event.NewSSTableIds))
+	assert.True(t, event.NewSSTables[0].SizeInBytes() > storageOptions.SSTableSizeInBytes)
+	assert.Nil(t, storageState.Apply(event, false))
+
+	assert.Equal(t, 2, len(sortedRunsOf(storageState.Snapshot())))
+	_, ok := NewTieredCompaction(storageOptions.CompactionOptions.TieredOptions).CompactionDescription(storageState.Snapshot())
+	assert.False(t, ok)
+}
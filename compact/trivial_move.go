@@ -0,0 +1,51 @@
+package compact
+
+import (
+	"go-lsm/compact/meta"
+	"go-lsm/state"
+	"go-lsm/table"
+	"slices"
+)
+
+// trivialMoveOf returns the input SSTables of the meta.CompactionDescription (sorted by their starting keys), if they can
+// be moved to the output level without being rewritten. Such a move is only a change in the manifest, it involves no I/O.
+// The input SSTables can be moved if:
+// 1) The output level is not level0, and all the input SSTables belong to a single level above the output level.
+// 2) The input SSTables do not overlap each other (by raw keys), the SSTables of level0 may overlap.
+// 3) No SSTable of the output level overlaps (by raw keys) any of the input SSTables.
+// A trivial move does not drop the older versions or the tombstones (refer to Compaction.ssTablesFromIterator), and the
+// moved SSTables retain the table.SSTableBuilderOptions of the level which they were built for.
+func trivialMoveOf(description meta.CompactionDescription, snapshot state.StorageStateSnapshot) ([]*table.SSTable, bool) {
+	if description.OutputLevel == 0 || description.OutputLevel > len(snapshot.Levels) {
+		return nil, false
+	}
+	var inputs []meta.CompactionInput
+	for _, input := range description.Inputs {
+		if len(input.SSTableIds) > 0 {
+			inputs = append(inputs, input)
+		}
+	}
+	if len(inputs) != 1 || inputs[0].Level >= description.OutputLevel {
+		return nil, false
+	}
+
+	ssTables := make([]*table.SSTable, 0, len(inputs[0].SSTableIds))
+	for _, ssTableId := range inputs[0].SSTableIds {
+		ssTables = append(ssTables, snapshot.SSTables[ssTableId])
+	}
+	slices.SortFunc(ssTables, func(ssTable, other *table.SSTable) int {
+		return ssTable.StartingKey().CompareKeysWithDescendingTimestamp(other.StartingKey())
+	})
+	for index := 1; index < len(ssTables); index++ {
+		if !ssTables[index-1].EndingKey().IsRawKeyLesserThan(ssTables[index].StartingKey()) {
+			return nil, false
+		}
+	}
+	outputLevel := snapshot.Levels[description.OutputLevel-1]
+	for _, ssTable := range ssTables {
+		if len(outputLevel.OverlappingSSTableIds(rawKeyRangeOf([]*table.SSTable{ssTable}))) > 0 {
+			return nil, false
+		}
+	}
+	return ssTables, true
+}
@@ -0,0 +1,144 @@
+package compact
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go-lsm/compact/meta"
+	"go-lsm/kv"
+	"go-lsm/state"
+	"go-lsm/test_utility"
+	"go-lsm/txn"
+	"testing"
+)
+
+func TestTrivialMoveOfNonOverlappingSSTablesOfLevel0(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	l0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 6))
+	anotherL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 7))
+	buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("raft", 5))
+
+	ssTables, ok := trivialMoveOf(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{anotherL0SSTableId, l0SSTableId}},
+			{Level: 1, SSTableIds: nil},
+		},
+		OutputLevel: 1,
+	}, storageState.Snapshot())
+
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(ssTables))
+	assert.Equal(t, anotherL0SSTableId, ssTables[0].Id())
+	assert.Equal(t, l0SSTableId, ssTables[1].Id())
+}
+
+func TestNoTrivialMoveOfOverlappingSSTablesOfLevel0(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	l0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringKeyWithTimestamp("etcd", 6))
+	anotherL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+
+	_, ok := trivialMoveOf(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{anotherL0SSTableId, l0SSTableId}},
+		},
+		OutputLevel: 1,
+	}, storageState.Snapshot())
+	assert.False(t, ok)
+}
+
+func TestNoTrivialMoveOfSSTablesOverlappingTheOutputLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	l1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("consensus", 6))
+	buildSSTableAtLevel(t, storageState, 2, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("distributed", 5))
+
+	_, ok := trivialMoveOf(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 1, SSTableIds: []uint64{l1SSTableId}},
+		},
+		OutputLevel: 2,
+	}, storageState.Snapshot())
+	assert.False(t, ok)
+}
+
+func TestNoTrivialMoveOfSSTablesOfMultipleLevels(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+	}))
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	l0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	l1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("raft", 5))
+
+	_, ok := trivialMoveOf(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{l0SSTableId}},
+			{Level: 1, SSTableIds: []uint64{l1SSTableId}},
+		},
+		OutputLevel: 1,
+	}, storageState.Snapshot())
+	assert.False(t, ok)
+}
+
+func TestStartCompactionWithTrivialMoveAndApplyTheStorageStateChangeEvent(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := compactionStorageOptions(rootPath, state.CompactionOptions{
+		Strategy: state.LeveledCompactionStrategy,
+		LeveledOptions: state.LeveledCompactionOptions{
+			Level0FilesCompactionTrigger: 2,
+			BaseLevelSizeInBytes:         1 << 20,
+		},
+	})
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	l0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("consensus", 6))
+	anotherL0SSTableId := buildSSTableAtLevel(t, storageState, 0, kv.NewStringKeyWithTimestamp("distributed", 7))
+	l1SSTableId := buildSSTableAtLevel(t, storageState, 1, kv.NewStringKeyWithTimestamp("raft", 5))
+
+	compaction := NewCompaction(oracle, storageState.SSTableIdGenerator(), storageOptions)
+	event, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.True(t, event.IsTrivialMove())
+	assert.Equal(t, []uint64{l0SSTableId, anotherL0SSTableId}, event.NewSSTableIds)
+	assert.Nil(t, storageState.Apply(event, false))
+
+	assert.Equal(t, 0, storageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, []uint64{l0SSTableId, anotherL0SSTableId, l1SSTableId}, storageState.Snapshot().SSTableIdsAt(1))
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("value"), value)
+}
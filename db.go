@@ -3,24 +3,44 @@ package go_lsm
 import (
 	"errors"
 	"fmt"
+	"go-lsm/checksum"
 	"go-lsm/compact"
 	"go-lsm/future"
 	"go-lsm/kv"
 	"go-lsm/state"
+	"go-lsm/table/cache"
 	"go-lsm/txn"
 	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 var DbAlreadyStoppedErr = errors.New("db is stopped, can not perform the operation")
+var DbReadOnlyErr = errors.New("db is opened in read-only mode, can not perform the write operation")
+var DbDegradedErr = errors.New("db is degraded to read-only mode after persistent compaction failures, can not perform the write operation")
+
+// ErrCorruption is returned (wrapped in checksum.CorruptionError, which carries the file path and the offset) when a read
+// or VerifyChecksums detects a checksum mismatch.
+var ErrCorruption = checksum.ErrCorruption
 
 // Db represents the key/value database (/storage engine).
+// backgroundError holds the error of the last failed compaction (refer to BackgroundError), and degraded is set once
+// compaction has failed persistently, after which the Db rejects all the writes.
 type Db struct {
-	storageState *state.StorageState
-	oracle       *txn.Oracle
-	stopped      atomic.Bool
-	stopChannel  chan struct{}
+	directoryLock   *directoryLock
+	storageState    *state.StorageState
+	oracle          *txn.Oracle
+	stopped         atomic.Bool
+	degraded        atomic.Bool
+	backgroundError backgroundError
+	stopChannel     chan struct{}
+}
+
+// backgroundError is the error of a background operation (compaction), which is safe for concurrent use.
+type backgroundError struct {
+	lock sync.RWMutex
+	err  error
 }
 
 // KeyValue is an abstraction which contains a key/value pair.
@@ -31,17 +51,29 @@ type KeyValue struct {
 }
 
 // Open opens the database (either new or existing) and creates a new instance of key/value Db.
+// It acquires an exclusive lock over the directory (options.Path) which is held until the Db is closed.
+// It returns ErrDatabaseLocked if the directory is already opened, either by another process or by another Db in the same process.
+// If options.ReadOnly is set, the Db replays the manifest and WALs in memory, takes a shared lock over the directory (if the LOCK
+// file exists), rejects all the writes, does not run compaction, and never writes to the directory.
 func Open(options state.StorageOptions) (*Db, error) {
+	lock, err := lockDirectory(options)
+	if err != nil {
+		return nil, err
+	}
 	storageState, err := state.NewStorageStateWithOptions(options)
 	if err != nil {
+		_ = lock.release()
 		return nil, err
 	}
 	db := &Db{
-		storageState: storageState,
-		oracle:       txn.NewOracleWithLastCommitTimestamp(txn.NewExecutor(storageState), storageState.LastCommitTimestamp()),
-		stopChannel:  make(chan struct{}),
+		directoryLock: lock,
+		storageState:  storageState,
+		oracle:        txn.NewOracleWithLastCommitTimestamp(txn.NewExecutor(storageState), storageState.LastCommitTimestamp()),
+		stopChannel:   make(chan struct{}),
+	}
+	if !options.ReadOnly {
+		db.startCompaction()
 	}
-	db.startCompaction()
 	return db, nil
 }
 
@@ -60,10 +92,18 @@ func (db *Db) Read(callback func(transaction *txn.Transaction)) error {
 
 // Write supports writes operation by passing an instance of txn.Transaction via (txn.NewReadwriteTransaction) to the callback.
 // The passed transaction is a Readwrite txn.Transaction which supports both read and write operations.
+// It returns DbReadOnlyErr if the Db is opened in read-only mode, and DbDegradedErr (wrapping the compaction error) if the
+// Db is degraded to read-only mode.
 func (db *Db) Write(callback func(transaction *txn.Transaction)) (*future.Future, error) {
 	if db.stopped.Load() {
 		return nil, DbAlreadyStoppedErr
 	}
+	if db.storageState.Options().ReadOnly {
+		return nil, DbReadOnlyErr
+	}
+	if db.degraded.Load() {
+		return nil, fmt.Errorf("%w: %w", DbDegradedErr, db.BackgroundError())
+	}
 	transaction := txn.NewReadwriteTransaction(db.oracle, db.storageState)
 	defer db.oracle.FinishBeginTimestamp(transaction)
 
@@ -101,46 +141,143 @@ func (db *Db) Scan(keyRange kv.InclusiveKeyRange[kv.RawKey]) ([]KeyValue, error)
 	return keyValuePairs, nil
 }
 
+// VerifyChecksums verifies the checksums of all the live SSTables and WALs.
+// It returns an error that wraps ErrCorruption for every corrupted file, or nil if no corruption is detected.
+func (db *Db) VerifyChecksums() error {
+	if db.stopped.Load() {
+		return DbAlreadyStoppedErr
+	}
+	return db.storageState.VerifyChecksums()
+}
+
+// BackgroundError returns the error of the last failed compaction, or nil if compaction has not failed since it last
+// succeeded. Once the Db is degraded to read-only mode, it returns the error which caused the degradation.
+func (db *Db) BackgroundError() error {
+	return db.backgroundError.get()
+}
+
+// BlockCacheStats returns the statistics (hits, misses, evictions and size) of the block cache, and false if the block
+// cache is disabled (state.StorageOptions.BlockCacheSizeInBytes is 0).
+func (db *Db) BlockCacheStats() (cache.Stats, bool) {
+	return db.storageState.BlockCacheStats()
+}
+
+// LevelStats returns the statistics of every level (number of SSTables, size, and the aggregated table.Properties of
+// the SSTables), starting with level 0.
+func (db *Db) LevelStats() []state.LevelStats {
+	return db.storageState.LevelStats()
+}
+
 // Close closes the database.
 // It involves:
 // 1. Closing txn.Oracle.
 // 2. Closing state.StorageState.
+// 3. Releasing the lock over the directory.
 func (db *Db) Close() {
 	if db.stopped.CompareAndSwap(false, true) {
 		db.oracle.Close()
 		db.storageState.Close()
 		close(db.stopChannel)
+		if err := db.directoryLock.release(); err != nil {
+			slog.Error(fmt.Sprintf("error in releasing the directory lock %v", err))
+		}
 	}
 }
 
+// lockDirectory acquires an exclusive lock over the directory, or a shared lock if the Db is opened in read-only mode.
+func lockDirectory(options state.StorageOptions) (*directoryLock, error) {
+	if options.ReadOnly {
+		return acquireSharedDirectoryLock(options.Path)
+	}
+	return acquireDirectoryLock(options.Path)
+}
+
 // startCompaction start the compaction goroutine.
 // It attempts to perform compaction at fixed intervals.
 // If compaction happens between 2 levels, it returns a state.StorageStateChangeEvent,
 // which is then applied to state.StorageState.
+// A failed compaction is recorded as the background error (refer to BackgroundError), and it is retried with backoff
+// (refer to state.CompactionOptions). After state.CompactionOptions.MaxConsecutiveFailures consecutive failures, or a
+// failure in recording the state.StorageStateChangeEvent in the manifest, compaction stops and the Db is degraded to
+// read-only mode.
 func (db *Db) startCompaction() {
 	go func() {
-		compactionTimer := time.NewTimer(db.storageState.Options().CompactionOptions.Duration)
+		options := db.storageState.Options().CompactionOptions
+		compactionTimer := time.NewTimer(options.Duration)
 		defer compactionTimer.Stop()
 
 		compaction := compact.NewCompaction(db.oracle, db.storageState.SSTableIdGenerator(), db.storageState.Options())
+		var consecutiveFailures uint
 		for {
 			select {
 			case <-compactionTimer.C:
-				storageStateChangeEvent, err := compaction.Start(db.storageState.Snapshot())
+				retriable, err := db.runCompaction(compaction)
 				if err != nil {
-					slog.Error(fmt.Sprintf("error in starting compaction %v", err))
-					return
-				}
-				if storageStateChangeEvent.HasAnyChanges() {
-					if err := db.storageState.Apply(storageStateChangeEvent, false); err != nil {
-						slog.Error(fmt.Sprintf("error in apply state change event %v", err))
+					consecutiveFailures++
+					db.backgroundError.set(err)
+					slog.Error(fmt.Sprintf("error in compaction (consecutive failures %d) %v", consecutiveFailures, err))
+					if !retriable || consecutiveFailures >= options.MaxFailures() {
+						db.degraded.Store(true)
+						slog.Error("compaction is stopped, db is degraded to read-only mode")
 						return
 					}
+					compactionTimer.Reset(options.RetryBackoffAfter(consecutiveFailures))
+					continue
 				}
-				compactionTimer.Reset(db.storageState.Options().CompactionOptions.Duration)
+				consecutiveFailures = 0
+				db.backgroundError.set(nil)
+				compactionTimer.Reset(options.Duration)
 			case <-db.stopChannel:
 				return
 			}
 		}
 	}()
 }
+
+// runCompaction performs a single compaction, and applies the resulting state.StorageStateChangeEvent to state.StorageState.
+// It returns true along with the error if the compaction can be retried: a failed compaction removes its new SSTables,
+// and a state.StorageStateChangeEvent which could not be applied leaves state.StorageState unchanged, so its new SSTables
+// are removed here. A failure in recording the state.StorageStateChangeEvent in the manifest (state.ErrManifestWrite)
+// leaves the in-memory state ahead of the manifest, so it can not be retried.
+func (db *Db) runCompaction(compaction *compact.Compaction) (bool, error) {
+	storageStateChangeEvent, err := compaction.Start(db.storageState.Snapshot())
+	if err != nil {
+		return true, fmt.Errorf("error in starting compaction: %w", err)
+	}
+	if !storageStateChangeEvent.HasAnyChanges() {
+		return true, nil
+	}
+	return db.applyCompaction(storageStateChangeEvent)
+}
+
+// applyCompaction applies the state.StorageStateChangeEvent of a compaction to state.StorageState.
+// If the state.StorageStateChangeEvent could not be applied, its new SSTables are removed (the SSTables of a trivial move
+// are the existing SSTables, so they are retained), and true is returned along with the error.
+func (db *Db) applyCompaction(storageStateChangeEvent state.StorageStateChangeEvent) (bool, error) {
+	if err := db.storageState.Apply(storageStateChangeEvent, false); err != nil {
+		if errors.Is(err, state.ErrManifestWrite) {
+			return false, fmt.Errorf("error in apply state change event: %w", err)
+		}
+		if !storageStateChangeEvent.IsTrivialMove() {
+			for _, ssTable := range storageStateChangeEvent.NewSSTables {
+				_ = ssTable.Remove()
+			}
+		}
+		return true, fmt.Errorf("error in apply state change event: %w", err)
+	}
+	return true, nil
+}
+
+// get returns the error.
+func (backgroundError *backgroundError) get() error {
+	backgroundError.lock.RLock()
+	defer backgroundError.lock.RUnlock()
+	return backgroundError.err
+}
+
+// set sets the error, nil clears it.
+func (backgroundError *backgroundError) set(err error) {
+	backgroundError.lock.Lock()
+	defer backgroundError.lock.Unlock()
+	backgroundError.err = err
+}
@@ -8,3 +8,8 @@ import "go-lsm/state"
 func (db *Db) StorageState() *state.StorageState {
 	return db.storageState
 }
+
+// ApplyCompaction applies the state.StorageStateChangeEvent of a compaction, it is only for testing.
+func (db *Db) ApplyCompaction(storageStateChangeEvent state.StorageStateChangeEvent) (bool, error) {
+	return db.applyCompaction(storageStateChangeEvent)
+}
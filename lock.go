@@ -0,0 +1,154 @@
+package go_lsm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// ErrDatabaseLocked is returned by Open if the directory is already opened by another process, or by another Db in the
+// same process.
+var ErrDatabaseLocked = errors.New("db directory is locked, it is already opened by another process or Db instance")
+
+const lockFileName = "LOCK"
+
+// lockedDirectories keeps a track of all the directories locked by the current process.
+// flock(2) locks are associated with an open file description, and their behavior within a single process differs
+// across platforms. Hence, an in-process registry is used to reliably detect two Db instances opening the same directory.
+var lockedDirectories = struct {
+	sync.Mutex
+	paths map[string]struct{}
+}{paths: make(map[string]struct{})}
+
+// directoryLock represents an advisory lock over the Db directory.
+// The lock is held on the LOCK file (rootPath/LOCK) for the entire lifetime of the Db, and is released on Db.Close.
+// The LOCK file itself is never deleted.
+// A read-write Db holds an exclusive lock on the LOCK file and on the directory itself, whereas a read-only Db holds a
+// shared lock on the LOCK file, or on the directory if the LOCK file does not exist.
+type directoryLock struct {
+	file          *os.File
+	directory     *os.File
+	directoryPath string
+	exclusive     bool
+}
+
+// acquireDirectoryLock acquires an exclusive lock over the given directory.
+// It involves the following:
+// 1) Checking the in-process registry, to detect Db instances in the same process.
+// 2) Creating (if needed) the directory and the LOCK file.
+// 3) Acquiring a non-blocking exclusive flock on the LOCK file, to detect Db instances in other processes.
+// 4) Acquiring a non-blocking exclusive flock on the directory, to detect read-only Db instances which are opened over
+// the directory without the LOCK file (refer to acquireSharedDirectoryLock).
+// It returns ErrDatabaseLocked if the directory is already locked.
+func acquireDirectoryLock(directoryPath string) (*directoryLock, error) {
+	absolutePath, err := filepath.Abs(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lockedDirectories.Lock()
+	defer lockedDirectories.Unlock()
+
+	if _, ok := lockedDirectories.paths[absolutePath]; ok {
+		return nil, ErrDatabaseLocked
+	}
+	if err := os.MkdirAll(absolutePath, os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := flock(filepath.Join(absolutePath, lockFileName), func(path string) (*os.File, error) {
+		return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	}, syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	directory, err := flock(absolutePath, os.Open, syscall.LOCK_EX)
+	if err != nil {
+		_ = unlock(file)
+		return nil, err
+	}
+	lockedDirectories.paths[absolutePath] = struct{}{}
+	return &directoryLock{
+		file:          file,
+		directory:     directory,
+		directoryPath: absolutePath,
+		exclusive:     true,
+	}, nil
+}
+
+// acquireSharedDirectoryLock acquires a shared lock over the given directory, it is used by a read-only Db.
+// It never creates the directory or the LOCK file. If the LOCK file does not exist (say, a copy of a data directory),
+// the shared flock is taken on the directory itself, so that a read-write Db (which also locks the directory) can not
+// be opened, and compact (or delete) the SSTables under the read-only Db.
+// A shared lock conflicts with an exclusive lock, so a read-only Db can not be opened over a directory which is opened by
+// a read-write Db (and vice versa), whereas any number of read-only Db instances can share the directory.
+func acquireSharedDirectoryLock(directoryPath string) (*directoryLock, error) {
+	absolutePath, err := filepath.Abs(directoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lockedDirectories.Lock()
+	defer lockedDirectories.Unlock()
+
+	if _, ok := lockedDirectories.paths[absolutePath]; ok {
+		return nil, ErrDatabaseLocked
+	}
+	file, err := flock(filepath.Join(absolutePath, lockFileName), os.Open, syscall.LOCK_SH)
+	if err != nil {
+		if os.IsNotExist(err) {
+			directory, err := flock(absolutePath, os.Open, syscall.LOCK_SH)
+			if err != nil {
+				return nil, err
+			}
+			return &directoryLock{directory: directory, directoryPath: absolutePath}, nil
+		}
+		return nil, err
+	}
+	return &directoryLock{
+		file:          file,
+		directoryPath: absolutePath,
+	}, nil
+}
+
+// flock opens the file (or the directory) at the given path using the open function, and acquires a non-blocking
+// flock of the given type (syscall.LOCK_EX or syscall.LOCK_SH) on it.
+// It returns ErrDatabaseLocked if a conflicting flock is already held.
+func flock(path string, open func(path string) (*os.File, error), how int) (*os.File, error) {
+	file, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrDatabaseLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// release releases the lock over the directory.
+func (lock *directoryLock) release() error {
+	lockedDirectories.Lock()
+	defer lockedDirectories.Unlock()
+
+	if lock.exclusive {
+		delete(lockedDirectories.paths, lock.directoryPath)
+	}
+	return errors.Join(unlock(lock.file), unlock(lock.directory))
+}
+
+// unlock releases the flock on the given file (or directory) and closes it, it is a no-op if the file is nil.
+func unlock(file *os.File) error {
+	if file == nil {
+		return nil
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -2,18 +2,47 @@ package log
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"go-lsm/checksum"
 	"go-lsm/kv"
 	"go-lsm/table/block"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
+// WALMagic identifies a WAL file, it is stored in the first 4 bytes of every WAL.
+const WALMagic uint32 = 0x4c41574c // "LWAL"
+
+// WALFormatVersion identifies the encoding of the records of a WAL, it is stored after WALMagic.
+type WALFormatVersion uint32
+
+const (
+	// WALFormatVersionWithoutChecksums identifies the WALs written before the header was introduced, they have no header,
+	// and their records have no checksum. It is never written, the older WALs are only recovered.
+	WALFormatVersionWithoutChecksums WALFormatVersion = 0
+	// WALFormatVersionWithChecksums stores every record followed by the CRC32C of the record (refer to WAL.Append).
+	WALFormatVersionWithChecksums WALFormatVersion = 1
+	// LatestWALFormatVersion is the WALFormatVersion used by NewWAL.
+	LatestWALFormatVersion = WALFormatVersionWithChecksums
+)
+
+// walHeaderSize is the size of the header of a WAL: 4 bytes WALMagic + 4 bytes WALFormatVersion.
+const walHeaderSize = 4 + 4
+
+// ErrInvalidWAL is returned (wrapped) when a WAL has an unsupported format version.
+var ErrInvalidWAL = errors.New("invalid WAL")
+
 // WAL is a write-ahead log. It contains a pointer to the file on disk.
+// lock serializes Append, DeleteFile and VerifyChecksums, so that VerifyChecksums never observes a partially appended
+// record or a deleted file.
 type WAL struct {
-	file *os.File
+	file    *os.File
+	lock    sync.Mutex
+	deleted bool
 }
 
 // NewWAL creates a new instance of WAL for the specified memtable id and a directory path.
@@ -29,8 +58,14 @@ func NewWAL(id uint64, walDirectoryPath string) (*WAL, error) {
 // Recovery involves the following:
 // 1) Opening the file in READONLY & APPEND mode.
 // 2) Reading the whole file.
-// 3) Iterating through the file buffer (/bytes) and decoding the bytes to get kv.Key and kv.Value.
-// 4) Invoking the provided callback with kv.Key and kv.Value.
+// 3) Identifying the WALFormatVersion from the header (WALMagic and WALFormatVersion) of the file, a file without
+// WALMagic is a WAL in WALFormatVersionWithoutChecksums.
+// 4) Iterating through the file buffer (/bytes), verifying the checksum of every record (if the format has checksums)
+// and decoding the bytes to get kv.Key and kv.Value.
+// 5) Invoking the provided callback with kv.Key and kv.Value.
+// It returns ErrInvalidWAL if the format version is unsupported, and checksum.CorruptionError if any record is
+// truncated or its checksum does not match. An empty file (created, but not written) has no records.
+// The recovered WAL is opened in READONLY mode, so a WAL in an older format is never appended to.
 // There are a few approaches in terms of reading the WAL:
 //  1. Read the whole file.
 //  2. Implement a page-aligned WAL, which means the data in the WAL will be aligned to the page (say, 4KB application page).
@@ -45,31 +80,27 @@ func Recover(path string, callback func(key kv.Key, value kv.Value)) (*WAL, erro
 	}
 	bytes, err := io.ReadAll(file)
 	if err != nil {
+		_ = file.Close()
 		return nil, err
 	}
-	for len(bytes) > 0 {
-		keySize := binary.LittleEndian.Uint16(bytes)
-		key := bytes[block.ReservedKeySize : uint16(block.ReservedKeySize)+keySize]
-
-		valueSize := binary.LittleEndian.Uint16(bytes[uint16(block.ReservedKeySize)+keySize:])
-		value := bytes[uint16(block.ReservedKeySize)+keySize+uint16(block.ReservedValueSize) : uint16(block.ReservedKeySize)+keySize+uint16(block.ReservedValueSize)+valueSize]
-
-		callback(kv.DecodeFrom(key), kv.NewValue(value))
-		bytes = bytes[uint16(block.ReservedKeySize)+keySize+uint16(block.ReservedValueSize)+valueSize:]
+	if err := decodeRecords(path, bytes, callback); err != nil {
+		_ = file.Close()
+		return nil, err
 	}
 	return &WAL{file: file}, nil
 }
 
 // Append appends the kv.Key, kv.Value pair to WAL.
 // It is important to note that WAL contained versioned keys.
-// The encoding of a kv.Key, kv.Value pair in WAL looks like:
+// A WAL starts with a header of 4 bytes WALMagic and 4 bytes WALFormatVersion, followed by the records.
+// The encoding of a kv.Key, kv.Value pair (/record) in WAL (LatestWALFormatVersion) looks like:
 /*
- --------------------------------------------------------
-| 2 bytes key size | kv.Key | 2 bytes value size | Value |
- --------------------------------------------------------
+ ----------------------------------------------------------------------------------------
+| 2 bytes key size | kv.Key | 2 bytes value size | Value | 4 bytes CRC32C of the record |
+ ----------------------------------------------------------------------------------------
 */
 func (wal *WAL) Append(key kv.Key, value kv.Value) error {
-	buffer := make([]byte, key.EncodedSizeInBytes()+value.SizeInBytes()+block.ReservedKeySize+block.ReservedValueSize)
+	buffer := make([]byte, key.EncodedSizeInBytes()+value.SizeInBytes()+block.ReservedKeySize+block.ReservedValueSize, recordSize(key, value))
 
 	binary.LittleEndian.PutUint16(buffer, uint16(key.EncodedSizeInBytes()))
 	copy(buffer[block.ReservedKeySize:], key.EncodedBytes())
@@ -77,10 +108,30 @@ func (wal *WAL) Append(key kv.Key, value kv.Value) error {
 	binary.LittleEndian.PutUint16(buffer[block.ReservedKeySize+key.EncodedSizeInBytes():], uint16(value.SizeInBytes()))
 	copy(buffer[block.ReservedKeySize+key.EncodedSizeInBytes()+block.ReservedValueSize:], value.Bytes())
 
-	_, err := wal.file.Write(buffer)
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	_, err := wal.file.Write(checksum.Append(buffer))
 	return err
 }
 
+// VerifyChecksums reads the entire WAL file and verifies the checksum of every record.
+// It returns checksum.CorruptionError for the first record whose checksum does not match (or which is truncated).
+// A WAL which has been deleted (after its memtable is flushed) has nothing to verify.
+func (wal *WAL) VerifyChecksums() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.deleted {
+		return nil
+	}
+	bytes, err := os.ReadFile(wal.file.Name())
+	if err != nil {
+		return err
+	}
+	return decodeRecords(wal.file.Name(), bytes, func(key kv.Key, value kv.Value) {})
+}
+
 // Sync performs a fsync operation on WAL.
 // Any write to the file is not made durable immediately. Durability means the write much reach the underlying storage (/disk).
 // The file.Write operation writes the data to the OS page cache, which is flushed to disk at a later point in time.
@@ -94,6 +145,10 @@ func (wal *WAL) Sync() error {
 
 // DeleteFile deletes the WAL (/WAL file).
 func (wal *WAL) DeleteFile() {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	wal.deleted = true
 	err := os.Remove(wal.file.Name())
 	if err != nil {
 		log.Printf("failed to delete WAL log file %v: %v", wal.file.Name(), err)
@@ -115,8 +170,82 @@ func CreateWalPathFor(id uint64, walDirectoryPath string) string {
 	return filepath.Join(walDirectoryPath, fmt.Sprintf("%v.wal", id))
 }
 
+// decodeRecords identifies the format of the WAL from its header, decodes all the records in the buffer, verifies the
+// checksum of each record (if the format has checksums) and invokes the callback with the decoded kv.Key and kv.Value.
+// It returns ErrInvalidWAL if the format version is unsupported, and checksum.CorruptionError (with the offset of the
+// record in the file) if a record is truncated, or its checksum does not match.
+func decodeRecords(path string, bytes []byte, callback func(key kv.Key, value kv.Value)) error {
+	formatVersion, offset := formatVersionOf(bytes)
+	switch formatVersion {
+	case WALFormatVersionWithoutChecksums:
+		return decodeRecordsFrom(path, bytes, offset, 0, func(record []byte, offset int) ([]byte, error) {
+			return record, nil
+		}, callback)
+	case WALFormatVersionWithChecksums:
+		return decodeRecordsFrom(path, bytes, offset, checksum.Size, func(record []byte, offset int) ([]byte, error) {
+			return checksum.Verify(record, path, int64(offset))
+		}, callback)
+	default:
+		return fmt.Errorf("%w: file %v has an unsupported format version %v", ErrInvalidWAL, path, formatVersion)
+	}
+}
+
+// formatVersionOf returns the WALFormatVersion of the WAL along with the offset of its first record.
+// A WAL which does not start with WALMagic (including an empty WAL) has no header, it is in WALFormatVersionWithoutChecksums.
+// The first record of such a WAL starts with the 2 bytes key size, it can be mistaken for WALMagic only if the key is
+// larger than 22KB.
+func formatVersionOf(bytes []byte) (WALFormatVersion, int) {
+	if len(bytes) < walHeaderSize || binary.LittleEndian.Uint32(bytes) != WALMagic {
+		return WALFormatVersionWithoutChecksums, 0
+	}
+	return WALFormatVersion(binary.LittleEndian.Uint32(bytes[4:])), walHeaderSize
+}
+
+// decodeRecordsFrom decodes the records in the buffer starting at the given offset, every record is followed by
+// trailerSize bytes which are verified (and stripped) by the verify function.
+func decodeRecordsFrom(
+	path string,
+	bytes []byte,
+	offset int,
+	trailerSize int,
+	verify func(record []byte, offset int) ([]byte, error),
+	callback func(key kv.Key, value kv.Value),
+) error {
+	headerSize := block.ReservedKeySize + block.ReservedValueSize
+	for offset < len(bytes) {
+		remaining := bytes[offset:]
+		if len(remaining) < block.ReservedKeySize {
+			return checksum.NewCorruptionError(path, int64(offset), "truncated record")
+		}
+		keySize := int(binary.LittleEndian.Uint16(remaining))
+		if len(remaining) < headerSize+keySize {
+			return checksum.NewCorruptionError(path, int64(offset), "truncated record")
+		}
+		valueSize := int(binary.LittleEndian.Uint16(remaining[block.ReservedKeySize+keySize:]))
+		size := headerSize + keySize + valueSize + trailerSize
+		if len(remaining) < size {
+			return checksum.NewCorruptionError(path, int64(offset), "truncated record")
+		}
+		record, err := verify(remaining[:size], offset)
+		if err != nil {
+			return err
+		}
+		key := record[block.ReservedKeySize : block.ReservedKeySize+keySize]
+		value := record[block.ReservedKeySize+keySize+block.ReservedValueSize:]
+
+		callback(kv.DecodeFrom(key), kv.NewValue(value))
+		offset += size
+	}
+	return nil
+}
+
+// recordSize returns the size of the encoded record (including the checksum) of the key/value pair.
+func recordSize(key kv.Key, value kv.Value) int {
+	return block.ReservedKeySize + key.EncodedSizeInBytes() + block.ReservedValueSize + value.SizeInBytes() + checksum.Size
+}
+
 // newWAL creates a new instance of WAL.
-// WAL file is opened in READ-WRITE and APPEND mode.
+// WAL file is opened in READ-WRITE and APPEND mode, and the header (WALMagic and LatestWALFormatVersion) is written.
 func newWAL(path string) (*WAL, error) {
 	_, err := os.Create(path)
 	if err != nil {
@@ -126,5 +255,11 @@ func newWAL(path string) (*WAL, error) {
 	if err != nil {
 		return nil, err
 	}
+	header := binary.LittleEndian.AppendUint32(make([]byte, 0, walHeaderSize), WALMagic)
+	header = binary.LittleEndian.AppendUint32(header, uint32(LatestWALFormatVersion))
+	if _, err := file.Write(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
 	return &WAL{file: file}, nil
 }
@@ -20,3 +20,11 @@ func NewWALPath(rootPath string) WALPath {
 		DirectoryPath: walDirectoryPath,
 	}
 }
+
+// NewReadonlyWALPath creates a new instance of WALPath without creating the WAL directory.
+// It is used when the storage is opened in read-only mode.
+func NewReadonlyWALPath(rootPath string) WALPath {
+	return WALPath{
+		DirectoryPath: filepath.Join(rootPath, "wal"),
+	}
+}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"encoding/binary"
 	"errors"
+	"go-lsm/checksum"
 	"go-lsm/kv"
 	"os"
 	"path/filepath"
@@ -108,3 +110,156 @@ func TestWALPath(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, absolute, path)
 }
+
+func TestAppendToWALAndVerifyChecksums(t *testing.T) {
+	walPath := filepath.Join(".", "TestAppendToWALAndVerifyChecksums.log")
+	wal, err := newWAL(walPath)
+
+	assert.Nil(t, err)
+	defer func() {
+		wal.Close()
+		_ = os.Remove(walPath)
+	}()
+
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft")))
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("kv", 30), kv.NewStringValue("distributed")))
+	assert.Nil(t, wal.VerifyChecksums())
+}
+
+func TestRecoverFromACorruptedWAL(t *testing.T) {
+	walPath := filepath.Join(".", "TestRecoverFromACorruptedWAL.log")
+	wal, err := newWAL(walPath)
+	assert.Nil(t, err)
+
+	defer func() {
+		_ = os.Remove(walPath)
+	}()
+
+	key := kv.NewStringKeyWithTimestamp("consensus", 20)
+	value := kv.NewStringValue("raft")
+	assert.Nil(t, wal.Append(key, value))
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("kv", 30), kv.NewStringValue("distributed")))
+	wal.Close()
+
+	bytes, err := os.ReadFile(walPath)
+	assert.Nil(t, err)
+	secondRecordOffset := walHeaderSize + recordSize(key, value)
+	bytes[secondRecordOffset+4] = bytes[secondRecordOffset+4] ^ 0x01
+	assert.Nil(t, os.WriteFile(walPath, bytes, 0666))
+
+	_, err = Recover(walPath, func(key kv.Key, value kv.Value) {})
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+
+	var corruptionError *checksum.CorruptionError
+	assert.True(t, errors.As(err, &corruptionError))
+	assert.Equal(t, int64(secondRecordOffset), corruptionError.Offset)
+}
+
+func TestRecoverFromATruncatedWAL(t *testing.T) {
+	walPath := filepath.Join(".", "TestRecoverFromATruncatedWAL.log")
+	wal, err := newWAL(walPath)
+	assert.Nil(t, err)
+
+	defer func() {
+		_ = os.Remove(walPath)
+	}()
+
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft")))
+	wal.Close()
+
+	bytes, err := os.ReadFile(walPath)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(walPath, bytes[:len(bytes)-2], 0666))
+
+	_, err = Recover(walPath, func(key kv.Key, value kv.Value) {})
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+}
+
+func TestVerifyChecksumsOfADeletedWAL(t *testing.T) {
+	walPath := filepath.Join(".", "TestVerifyChecksumsOfADeletedWAL.log")
+	wal, err := newWAL(walPath)
+	assert.Nil(t, err)
+
+	assert.Nil(t, wal.Append(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft")))
+	wal.Close()
+	wal.DeleteFile()
+
+	assert.Nil(t, wal.VerifyChecksums())
+}
+
+func TestRecoverFromAWALWithoutTheHeader(t *testing.T) {
+	walPath := filepath.Join(".", "TestRecoverFromAWALWithoutTheHeader.log")
+	defer func() {
+		_ = os.Remove(walPath)
+	}()
+
+	var records []byte
+	for _, pair := range []struct {
+		key   kv.Key
+		value string
+	}{
+		{kv.NewStringKeyWithTimestamp("consensus", 20), "raft"},
+		{kv.NewStringKeyWithTimestamp("storage", 21), "NVMe"},
+	} {
+		records = binary.LittleEndian.AppendUint16(records, uint16(pair.key.EncodedSizeInBytes()))
+		records = append(records, pair.key.EncodedBytes()...)
+		records = binary.LittleEndian.AppendUint16(records, uint16(len(pair.value)))
+		records = append(records, pair.value...)
+	}
+	assert.Nil(t, os.WriteFile(walPath, records, 0666))
+
+	var recovered []string
+	wal, err := Recover(walPath, func(key kv.Key, value kv.Value) {
+		recovered = append(recovered, key.RawString()+"="+value.String())
+	})
+	assert.Nil(t, err)
+	defer wal.Close()
+
+	assert.Equal(t, []string{"consensus=raft", "storage=NVMe"}, recovered)
+	assert.Nil(t, wal.VerifyChecksums())
+}
+
+func TestRecoverFromATruncatedWALWithoutTheHeader(t *testing.T) {
+	walPath := filepath.Join(".", "TestRecoverFromATruncatedWALWithoutTheHeader.log")
+	defer func() {
+		_ = os.Remove(walPath)
+	}()
+
+	key := kv.NewStringKeyWithTimestamp("consensus", 20)
+	record := binary.LittleEndian.AppendUint16(nil, uint16(key.EncodedSizeInBytes()))
+	record = append(record, key.EncodedBytes()...)
+	record = binary.LittleEndian.AppendUint16(record, uint16(len("raft")))
+	record = append(record, "ra"...)
+	assert.Nil(t, os.WriteFile(walPath, record, 0666))
+
+	_, err := Recover(walPath, func(key kv.Key, value kv.Value) {})
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+}
+
+func TestRecoverFromAWALWithAnUnsupportedFormatVersion(t *testing.T) {
+	walPath := filepath.Join(".", "TestRecoverFromAWALWithAnUnsupportedFormatVersion.log")
+	defer func() {
+		_ = os.Remove(walPath)
+	}()
+
+	header := binary.LittleEndian.AppendUint32(nil, WALMagic)
+	header = binary.LittleEndian.AppendUint32(header, uint32(LatestWALFormatVersion)+1)
+	assert.Nil(t, os.WriteFile(walPath, header, 0666))
+
+	_, err := Recover(walPath, func(key kv.Key, value kv.Value) {})
+	assert.ErrorIs(t, err, ErrInvalidWAL)
+}
+
+func TestRecoverFromAnEmptyWAL(t *testing.T) {
+	walPath := filepath.Join(".", "TestRecoverFromAnEmptyWAL.log")
+	defer func() {
+		_ = os.Remove(walPath)
+	}()
+	assert.Nil(t, os.WriteFile(walPath, nil, 0666))
+
+	wal, err := Recover(walPath, func(key kv.Key, value kv.Value) {
+		assert.Fail(t, "an empty WAL has no records")
+	})
+	assert.Nil(t, err)
+	wal.Close()
+}
@@ -5,20 +5,24 @@ import (
 	"encoding/binary"
 	"encoding/gob"
 	"go-lsm/compact/meta"
-	"io"
 	"unsafe"
 )
 
 const (
 	idSize        = unsafe.Sizeof(uint64(0))
 	eventTypeSize = unsafe.Sizeof(uint8(0))
+	uint32Size    = unsafe.Sizeof(uint32(0))
 )
 
 // Event types.
+// legacyCompactionDoneEventType is the compaction done event (of simple-leveled compaction) recorded by the older manifests,
+// it is decoded as CompactionDone, and it is not recorded anymore.
 const (
-	MemtableCreatedEventType uint8 = iota
-	SSTableFlushedEventType  uint8 = 1
-	CompactionDoneEventType  uint8 = 2
+	MemtableCreatedEventType      uint8 = iota
+	SSTableFlushedEventType       uint8 = 1
+	legacyCompactionDoneEventType uint8 = 2
+	CompactionDoneEventType       uint8 = 3
+	SSTablesMovedEventType        uint8 = 4
 )
 
 // Event represents a manifest event.
@@ -38,7 +42,20 @@ type SSTableFlushed struct {
 }
 
 // CompactionDone defines a compaction done event.
+// The Description is independent of the compaction strategy which produced it (refer to meta.CompactionDescription).
 type CompactionDone struct {
+	NewSSTableIds []uint64
+	Description   meta.CompactionDescription
+}
+
+// SSTablesMoved defines a trivial move event: the input SSTables of the Description are moved (without being rewritten) to
+// the output level of the Description.
+type SSTablesMoved struct {
+	Description meta.CompactionDescription
+}
+
+// legacyCompactionDone defines the compaction done event recorded by the older manifests.
+type legacyCompactionDone struct {
 	NewSSTableIds []uint64
 	Description   meta.SimpleLeveledCompactionDescription
 }
@@ -102,7 +119,7 @@ func decodeSSTableFlushed(buffer []byte) (*SSTableFlushed, int) {
 }
 
 // NewCompactionDone creates a new CompactionDone event.
-func NewCompactionDone(newSSTableIds []uint64, description meta.SimpleLeveledCompactionDescription) *CompactionDone {
+func NewCompactionDone(newSSTableIds []uint64, description meta.CompactionDescription) *CompactionDone {
 	return &CompactionDone{
 		NewSSTableIds: newSSTableIds,
 		Description:   description,
@@ -110,18 +127,22 @@ func NewCompactionDone(newSSTableIds []uint64, description meta.SimpleLeveledCom
 }
 
 // encode encodes CompactionDone to byte slice.
+/*
+ ---------------------------------------------------------------------------------------------------------------
+| 1 byte event type | 4 bytes number of NewSSTableIds | 8 bytes NewSSTableId | ... | Description (refer to appendDescription) |
+ ---------------------------------------------------------------------------------------------------------------
+*/
 func (compactionDone *CompactionDone) encode() ([]byte, error) {
-	buffer := bytes.Buffer{}
-	err := gob.NewEncoder(&buffer).Encode(compactionDone)
-	if err != nil {
-		return nil, err
-	}
-	buffered := buffer.Bytes()
-	encoded := make([]byte, int(eventTypeSize)+len(buffered))
-	encoded[0] = CompactionDoneEventType
-	copy(encoded[1:], buffered)
+	buffer := make([]byte, 0, compactionDone.encodedSizeInBytes())
+	buffer = append(buffer, CompactionDoneEventType)
+	buffer = appendSSTableIds(buffer, compactionDone.NewSSTableIds)
+	return appendDescription(buffer, compactionDone.Description), nil
+}
 
-	return encoded, nil
+// encodedSizeInBytes returns the size of the encoded CompactionDone.
+func (compactionDone *CompactionDone) encodedSizeInBytes() int {
+	return int(eventTypeSize) + int(uint32Size) + len(compactionDone.NewSSTableIds)*int(idSize) +
+		descriptionSizeInBytes(compactionDone.Description)
 }
 
 // EventType returns the event type CompactionDoneEventType.
@@ -130,14 +151,116 @@ func (compactionDone *CompactionDone) EventType() uint8 {
 }
 
 // decodeCompactionDone decodes the CompactionDone event from the byte slice.
+// Please look at CompactionDone.encode() to understand the encoding of CompactionDone.
 func decodeCompactionDone(buffer []byte) (*CompactionDone, int) {
-	compactionDone := &CompactionDone{}
+	newSSTableIds, n := decodeSSTableIds(buffer)
+	description, descriptionSize := decodeDescription(buffer[n:])
+	return NewCompactionDone(newSSTableIds, description), n + descriptionSize
+}
+
+// NewSSTablesMoved creates a new SSTablesMoved event.
+func NewSSTablesMoved(description meta.CompactionDescription) *SSTablesMoved {
+	return &SSTablesMoved{Description: description}
+}
+
+// encode encodes SSTablesMoved to byte slice.
+/*
+ ------------------------------------------------------------------
+| 1 byte event type | Description (refer to appendDescription) |
+ ------------------------------------------------------------------
+*/
+func (ssTablesMoved *SSTablesMoved) encode() ([]byte, error) {
+	buffer := make([]byte, 0, int(eventTypeSize)+descriptionSizeInBytes(ssTablesMoved.Description))
+	buffer = append(buffer, SSTablesMovedEventType)
+	return appendDescription(buffer, ssTablesMoved.Description), nil
+}
+
+// EventType returns the event type SSTablesMovedEventType.
+func (ssTablesMoved *SSTablesMoved) EventType() uint8 {
+	return SSTablesMovedEventType
+}
+
+// decodeSSTablesMoved decodes the SSTablesMoved event from the byte slice.
+// Please look at SSTablesMoved.encode() to understand the encoding of SSTablesMoved.
+func decodeSSTablesMoved(buffer []byte) (*SSTablesMoved, int) {
+	description, n := decodeDescription(buffer)
+	return NewSSTablesMoved(description), n
+}
+
+// appendDescription appends the encoded meta.CompactionDescription to the buffer.
+/*
+ ----------------------------------------------------------------------------------------------------------------------
+| 4 bytes output level | 4 bytes number of inputs | 4 bytes level | 4 bytes number of SSTableIds | 8 bytes SSTableId | ... |
+ ----------------------------------------------------------------------------------------------------------------------
+                                                  <---------------------------- for each input ------------------------>
+*/
+func appendDescription(buffer []byte, description meta.CompactionDescription) []byte {
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(description.OutputLevel))
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(description.Inputs)))
+	for _, input := range description.Inputs {
+		buffer = binary.LittleEndian.AppendUint32(buffer, uint32(input.Level))
+		buffer = appendSSTableIds(buffer, input.SSTableIds)
+	}
+	return buffer
+}
+
+// descriptionSizeInBytes returns the size of the encoded meta.CompactionDescription.
+func descriptionSizeInBytes(description meta.CompactionDescription) int {
+	size := 2 * int(uint32Size)
+	for _, input := range description.Inputs {
+		size += 2*int(uint32Size) + len(input.SSTableIds)*int(idSize)
+	}
+	return size
+}
+
+// decodeDescription decodes the meta.CompactionDescription (refer to appendDescription) from the byte slice, and returns
+// the number of bytes read.
+func decodeDescription(buffer []byte) (meta.CompactionDescription, int) {
+	outputLevel := binary.LittleEndian.Uint32(buffer)
+	numberOfInputs := binary.LittleEndian.Uint32(buffer[uint32Size:])
+	n := 2 * int(uint32Size)
+
+	inputs := make([]meta.CompactionInput, 0, numberOfInputs)
+	for count := 0; count < int(numberOfInputs); count++ {
+		level := binary.LittleEndian.Uint32(buffer[n:])
+		ssTableIds, idsSize := decodeSSTableIds(buffer[n+int(uint32Size):])
+		inputs = append(inputs, meta.CompactionInput{Level: int(level), SSTableIds: ssTableIds})
+		n = n + int(uint32Size) + idsSize
+	}
+	return meta.CompactionDescription{Inputs: inputs, OutputLevel: int(outputLevel)}, n
+}
+
+// decodeLegacyCompactionDone decodes the (gob encoded) compaction done event of the older manifests from the byte slice,
+// and converts it to CompactionDone.
+func decodeLegacyCompactionDone(buffer []byte) (*CompactionDone, int) {
+	compactionDone := &legacyCompactionDone{}
 	reader := &byteCountingReader{reader: bytes.NewReader(buffer)}
 	err := gob.NewDecoder(reader).Decode(compactionDone)
 	if err != nil {
 		return nil, 0
 	}
-	return compactionDone, int(reader.count)
+	return NewCompactionDone(compactionDone.NewSSTableIds, compactionDone.Description.ToCompactionDescription()), int(reader.count)
+}
+
+// appendSSTableIds appends the number of ssTableIds followed by the ssTableIds to the buffer.
+func appendSSTableIds(buffer []byte, ssTableIds []uint64) []byte {
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(ssTableIds)))
+	for _, ssTableId := range ssTableIds {
+		buffer = binary.LittleEndian.AppendUint64(buffer, ssTableId)
+	}
+	return buffer
+}
+
+// decodeSSTableIds decodes the ssTableIds (refer to appendSSTableIds) from the byte slice, and returns the number of bytes read.
+func decodeSSTableIds(buffer []byte) ([]uint64, int) {
+	numberOfSSTableIds := binary.LittleEndian.Uint32(buffer)
+	var ssTableIds []uint64
+	n := int(uint32Size)
+	for count := 0; count < int(numberOfSSTableIds); count++ {
+		ssTableIds = append(ssTableIds, binary.LittleEndian.Uint64(buffer[n:]))
+		n += int(idSize)
+	}
+	return ssTableIds, n
 }
 
 // decodeEventsFrom decodes all the events from the Manifest file. The passed buffer is the whole file.
@@ -158,15 +281,24 @@ func decodeEventsFrom(buffer []byte) []Event {
 			compactionDone, n := decodeCompactionDone(buffer[eventTypeSize:])
 			events = append(events, compactionDone)
 			buffer = buffer[n+int(eventTypeSize):]
+		case SSTablesMovedEventType:
+			ssTablesMoved, n := decodeSSTablesMoved(buffer[eventTypeSize:])
+			events = append(events, ssTablesMoved)
+			buffer = buffer[n+int(eventTypeSize):]
+		case legacyCompactionDoneEventType:
+			compactionDone, n := decodeLegacyCompactionDone(buffer[eventTypeSize:])
+			events = append(events, compactionDone)
+			buffer = buffer[n+int(eventTypeSize):]
 		}
 	}
 	return events
 }
 
 // byteCountingReader counts the number of bytes read while encapsulates a reader.
-// It is mainly used in decoding of CompactionDoneEventType.
+// It is mainly used in decoding of legacyCompactionDoneEventType.
+// It implements io.ByteReader, otherwise gob.Decoder buffers the reader, and reads (and counts) beyond the decoded event.
 type byteCountingReader struct {
-	reader io.Reader
+	reader *bytes.Reader
 	count  int64
 }
 
@@ -176,3 +308,12 @@ func (reader *byteCountingReader) Read(p []byte) (n int, err error) {
 	reader.count += int64(n)
 	return
 }
+
+// ReadByte reads a single byte.
+func (reader *byteCountingReader) ReadByte() (byte, error) {
+	b, err := reader.reader.ReadByte()
+	if err == nil {
+		reader.count += 1
+	}
+	return b, err
+}
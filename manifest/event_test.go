@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"bytes"
+	"encoding/gob"
 	"github.com/stretchr/testify/assert"
 	"go-lsm/compact/meta"
 	"testing"
@@ -33,41 +35,52 @@ func TestNewSSTableFlushedEventType(t *testing.T) {
 }
 
 func TestNewCompactionDoneEventEncodeAndDecode(t *testing.T) {
-	upperLevel := -1
-	lowerLevel := 1
-	upperLevelSSTableIds := []uint64{20, 30}
-	lowerLevelSSTableIds := []uint64{50, 60}
-	compactionDone := NewCompactionDone([]uint64{10, 14}, meta.SimpleLeveledCompactionDescription{
-		UpperLevel:           upperLevel,
-		LowerLevel:           lowerLevel,
-		UpperLevelSSTableIds: upperLevelSSTableIds,
-		LowerLevelSSTableIds: lowerLevelSSTableIds,
+	compactionDone := NewCompactionDone([]uint64{10, 14}, meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{20, 30}},
+			{Level: 1, SSTableIds: []uint64{50, 60}},
+		},
+		OutputLevel: 1,
 	})
 	buffer, _ := compactionDone.encode()
 
 	decoded, _ := decodeCompactionDone(buffer[1:])
 	assert.Equal(t, []uint64{10, 14}, decoded.NewSSTableIds)
-	assert.Equal(t, -1, decoded.Description.UpperLevel)
-	assert.Equal(t, 1, decoded.Description.LowerLevel)
-	assert.Equal(t, []uint64{20, 30}, decoded.Description.UpperLevelSSTableIds)
-	assert.Equal(t, []uint64{50, 60}, decoded.Description.LowerLevelSSTableIds)
+	assert.Equal(t, 1, decoded.Description.OutputLevel)
+	assert.Equal(t, []uint64{20, 30}, decoded.Description.SSTableIdsAt(0))
+	assert.Equal(t, []uint64{50, 60}, decoded.Description.SSTableIdsAt(1))
 }
 
 func TestNewCompactionDoneEventType(t *testing.T) {
-	upperLevel := -1
-	lowerLevel := 1
-	upperLevelSSTableIds := []uint64{20, 30}
-	lowerLevelSSTableIds := []uint64{50, 60}
-
-	compactionDone := NewCompactionDone([]uint64{10, 14}, meta.SimpleLeveledCompactionDescription{
-		UpperLevel:           upperLevel,
-		LowerLevel:           lowerLevel,
-		UpperLevelSSTableIds: upperLevelSSTableIds,
-		LowerLevelSSTableIds: lowerLevelSSTableIds,
+	compactionDone := NewCompactionDone([]uint64{10, 14}, meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{20, 30}},
+			{Level: 1, SSTableIds: []uint64{50, 60}},
+		},
+		OutputLevel: 1,
 	})
 	assert.Equal(t, CompactionDoneEventType, compactionDone.EventType())
 }
 
+func TestNewSSTablesMovedEventEncodeAndDecode(t *testing.T) {
+	ssTablesMoved := NewSSTablesMoved(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 1, SSTableIds: []uint64{20, 30}},
+		},
+		OutputLevel: 2,
+	})
+	buffer, _ := ssTablesMoved.encode()
+
+	decoded, _ := decodeSSTablesMoved(buffer[1:])
+	assert.Equal(t, 2, decoded.Description.OutputLevel)
+	assert.Equal(t, []uint64{20, 30}, decoded.Description.SSTableIdsAt(1))
+}
+
+func TestNewSSTablesMovedEventType(t *testing.T) {
+	ssTablesMoved := NewSSTablesMoved(meta.CompactionDescription{OutputLevel: 1})
+	assert.Equal(t, SSTablesMovedEventType, ssTablesMoved.EventType())
+}
+
 func TestDecodeNewMemtableCreatedAndSSTableEventFlushedEvents(t *testing.T) {
 	memtableCreated := NewMemtableCreated(10)
 	ssTableFlushed := NewSSTableFlushed(20)
@@ -87,15 +100,12 @@ func TestDecodeNewMemtableCreatedAndSSTableEventFlushedEvents(t *testing.T) {
 
 func TestDecodeNewMemtableCreatedAndCompactionDoneEvents(t *testing.T) {
 	memtableCreated := NewMemtableCreated(10)
-	upperLevel := -1
-	lowerLevel := 1
-	upperLevelSSTableIds := []uint64{20, 30}
-	lowerLevelSSTableIds := []uint64{50, 60}
-	compactionDone := NewCompactionDone([]uint64{10, 11}, meta.SimpleLeveledCompactionDescription{
-		UpperLevel:           upperLevel,
-		LowerLevel:           lowerLevel,
-		UpperLevelSSTableIds: upperLevelSSTableIds,
-		LowerLevelSSTableIds: lowerLevelSSTableIds,
+	compactionDone := NewCompactionDone([]uint64{10, 11}, meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{20, 30}},
+			{Level: 1, SSTableIds: []uint64{50, 60}},
+		},
+		OutputLevel: 1,
 	})
 
 	memtableCreatedBuffer, _ := memtableCreated.encode()
@@ -110,3 +120,51 @@ func TestDecodeNewMemtableCreatedAndCompactionDoneEvents(t *testing.T) {
 	assert.Equal(t, uint64(10), events[0].(*MemtableCreated).MemtableId)
 	assert.Equal(t, []uint64{10, 11}, events[1].(*CompactionDone).NewSSTableIds)
 }
+
+func TestDecodeLegacyCompactionDoneEvent(t *testing.T) {
+	buffer := bytes.Buffer{}
+	buffer.WriteByte(legacyCompactionDoneEventType)
+	assert.Nil(t, gob.NewEncoder(&buffer).Encode(&legacyCompactionDone{
+		NewSSTableIds: []uint64{10, 11},
+		Description: meta.SimpleLeveledCompactionDescription{
+			UpperLevel:           -1,
+			LowerLevel:           1,
+			UpperLevelSSTableIds: []uint64{20, 30},
+			LowerLevelSSTableIds: []uint64{50, 60},
+		},
+	}))
+	memtableCreatedBuffer, _ := NewMemtableCreated(12).encode()
+	buffer.Write(memtableCreatedBuffer)
+
+	events := decodeEventsFrom(buffer.Bytes())
+	assert.Equal(t, 2, len(events))
+
+	compactionDone := events[0].(*CompactionDone)
+	assert.Equal(t, []uint64{10, 11}, compactionDone.NewSSTableIds)
+	assert.Equal(t, 1, compactionDone.Description.OutputLevel)
+	assert.Equal(t, []uint64{20, 30}, compactionDone.Description.SSTableIdsAt(0))
+	assert.Equal(t, []uint64{50, 60}, compactionDone.Description.SSTableIdsAt(1))
+	assert.Equal(t, uint64(12), events[1].(*MemtableCreated).MemtableId)
+}
+
+func TestDecodeSSTablesMovedAndSSTableFlushedEvents(t *testing.T) {
+	ssTablesMoved := NewSSTablesMoved(meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{20, 30}},
+		},
+		OutputLevel: 1,
+	})
+	ssTableFlushed := NewSSTableFlushed(40)
+
+	ssTablesMovedBuffer, _ := ssTablesMoved.encode()
+	ssTableFlushedBuffer, _ := ssTableFlushed.encode()
+
+	var buffer []byte
+	buffer = append(buffer, ssTablesMovedBuffer...)
+	buffer = append(buffer, ssTableFlushedBuffer...)
+
+	events := decodeEventsFrom(buffer)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, []uint64{20, 30}, events[0].(*SSTablesMoved).Description.SSTableIdsAt(0))
+	assert.Equal(t, uint64(40), events[1].(*SSTableFlushed).SsTableId)
+}
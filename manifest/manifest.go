@@ -19,7 +19,7 @@ type Manifest struct {
 
 // CreateNewOrRecoverFrom either creates a new Manifest or recovers from an existing manifest file.
 func CreateNewOrRecoverFrom(directoryPath string) (*Manifest, []Event, error) {
-	path := filepath.Join(directoryPath, "manifest")
+	path := filepathOf(directoryPath)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		_, err := os.Create(path)
 		if err != nil {
@@ -43,6 +43,28 @@ func CreateNewOrRecoverFrom(directoryPath string) (*Manifest, []Event, error) {
 	return manifest, events, nil
 }
 
+// RecoverReadonlyFrom recovers the events from an existing manifest file without opening it for writes.
+// It does not create the manifest file, if the file does not exist, it returns no events.
+// It is used when the storage is opened in read-only mode.
+func RecoverReadonlyFrom(directoryPath string) ([]Event, error) {
+	file, err := os.Open(filepathOf(directoryPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	bytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEventsFrom(bytes), nil
+}
+
 // Add adds the event to the manifest file.
 func (manifest *Manifest) Add(event Event) error {
 	manifest.writeLock.Lock()
@@ -71,3 +93,8 @@ func (manifest *Manifest) attemptRecovery() ([]Event, error) {
 	}
 	return decodeEventsFrom(bytes), nil
 }
+
+// filepathOf returns the path of the manifest file in the given directory.
+func filepathOf(directoryPath string) string {
+	return filepath.Join(directoryPath, "manifest")
+}
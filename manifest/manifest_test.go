@@ -49,16 +49,12 @@ func TestRecoversAnExistingManifest(t *testing.T) {
 	assert.Nil(t, manifest.Add(NewMemtableCreated(20)))
 	assert.Nil(t, manifest.Add(NewSSTableFlushed(10)))
 
-	upperLevel := -1
-	lowerLevel := 1
-	upperLevelSSTableIds := []uint64{20, 30}
-	lowerLevelSSTableIds := []uint64{50, 60}
-
-	compactionDone := NewCompactionDone([]uint64{10, 11}, meta.SimpleLeveledCompactionDescription{
-		UpperLevel:           upperLevel,
-		LowerLevel:           lowerLevel,
-		UpperLevelSSTableIds: upperLevelSSTableIds,
-		LowerLevelSSTableIds: lowerLevelSSTableIds,
+	compactionDone := NewCompactionDone([]uint64{10, 11}, meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{20, 30}},
+			{Level: 1, SSTableIds: []uint64{50, 60}},
+		},
+		OutputLevel: 1,
 	})
 	assert.Nil(t, manifest.Add(compactionDone))
 
@@ -71,8 +67,7 @@ func TestRecoversAnExistingManifest(t *testing.T) {
 	assert.Equal(t, uint64(10), events[2].(*SSTableFlushed).SsTableId)
 
 	assert.Equal(t, []uint64{10, 11}, events[3].(*CompactionDone).NewSSTableIds)
-	assert.Equal(t, -1, events[3].(*CompactionDone).Description.UpperLevel)
-	assert.Equal(t, 1, events[3].(*CompactionDone).Description.LowerLevel)
-	assert.Equal(t, []uint64{20, 30}, events[3].(*CompactionDone).Description.UpperLevelSSTableIds)
-	assert.Equal(t, []uint64{50, 60}, events[3].(*CompactionDone).Description.LowerLevelSSTableIds)
+	assert.Equal(t, 1, events[3].(*CompactionDone).Description.OutputLevel)
+	assert.Equal(t, []uint64{20, 30}, events[3].(*CompactionDone).Description.SSTableIdsAt(0))
+	assert.Equal(t, []uint64{50, 60}, events[3].(*CompactionDone).Description.SSTableIdsAt(1))
 }
@@ -25,9 +25,9 @@ func NewMemtable(id uint64, memTableSizeInBytes int64, walPath log.WALPath) *Mem
 	return newMemtableWithWAL(id, memTableSizeInBytes, walPath.DirectoryPath)
 }
 
-// newMemtableWithoutWAL creates a new instance of Memtable without WAL.
-// It is mainly used for testing.
-func newMemtableWithoutWAL(id uint64, memTableSizeInBytes int64) *Memtable {
+// NewMemtableWithoutWAL creates a new instance of Memtable without WAL.
+// It is used for testing, and by the read-only state.StorageState which never writes to the directory.
+func NewMemtableWithoutWAL(id uint64, memTableSizeInBytes int64) *Memtable {
 	return &Memtable{
 		id:                  id,
 		memTableSizeInBytes: memTableSizeInBytes,
@@ -75,14 +75,26 @@ func RecoverFromWAL(id uint64, memTableSizeInBytes int64, walDirectoryPath strin
 // Get returns the value for the key if found.
 // It accepts a versioned key (kv.Key) and returns the key such that the commit-timestamp of the key <= begin-timestamp of the
 // transaction.
+// A deleted key is not found.
 func (memtable *Memtable) Get(key kv.Key) (kv.Value, bool) {
-	value, ok := memtable.entries.Get(key)
+	value, ok := memtable.GetNewestVersion(key)
 	if !ok || value.IsEmpty() {
 		return kv.EmptyValue, false
 	}
 	return value, true
 }
 
+// GetNewestVersion returns the newest version of the key visible at the timestamp of the key.
+// Unlike Get, a deleted key is returned as kv.EmptyValue and true, so that the lookups across memtables and SSTables
+// (refer to state.StorageState.Get) stop at the deletion instead of looking up the older versions.
+func (memtable *Memtable) GetNewestVersion(key kv.Key) (kv.Value, bool) {
+	value, ok := memtable.entries.Get(key)
+	if !ok {
+		return kv.EmptyValue, false
+	}
+	return value, true
+}
+
 // Set sets the key/value pair in the system. It involves the following:
 // 1) Appending the key/value pair in the WAL, if WAL is present.
 // 2) Writing the key/value pair in the entries.
@@ -149,6 +161,14 @@ func (memtable *Memtable) DeleteWAL() {
 	}
 }
 
+// VerifyWALChecksums verifies the checksums of all the records in WAL, if WAL is enabled.
+func (memtable *Memtable) VerifyWALChecksums() error {
+	if memtable.wal != nil {
+		return memtable.wal.VerifyChecksums()
+	}
+	return nil
+}
+
 // IsEmpty returns true if the Memtable is empty.
 func (memtable *Memtable) IsEmpty() bool {
 	return memtable.entries.Empty()
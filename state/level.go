@@ -1,20 +1,147 @@
 package state
 
+import (
+	"errors"
+	"fmt"
+	"go-lsm/kv"
+	"go-lsm/table"
+	"sort"
+)
+
 const totalLevels = 6
 
+// ErrOverlappingSSTables is returned if the SSTables of a level (other than level0) would overlap.
+var ErrOverlappingSSTables = errors.New("overlapping SSTables in level")
+
 // Level represents a level in LSM.
 // It does not include level0. SSTableIds of level0 are represented by the field "l0SSTableIds" in StorageState.
+// The SSTables of a level do not overlap, SSTableIds are sorted by the smallest (starting) key of the SSTables, and
+// keyRanges holds the key range of the SSTable at the same index in SSTableIds.
+// The key range of an SSTable is not a kv.InclusiveKeyRange, because an SSTable with only the versions of a single raw key
+// starts with a key (newer version) which is not IsLessThanOrEqualTo its ending key (older version).
+// The sorted key ranges allow binary searching the SSTables which overlap a key range (refer to OverlappingSSTableIds).
+// Any change to a level allocates new slices, so a shallow copy of the Level (refer to StorageStateSnapshot) is
+// not affected by the later changes.
 type Level struct {
 	LevelNumber int
 	SSTableIds  []uint64
+	keyRanges   []ssTableKeyRange
 }
 
-// clearSSTableIds cleans the SSTableIds.
+// ssTableKeyRange represents the starting and the ending key of an SSTable.
+type ssTableKeyRange struct {
+	start kv.Key
+	end   kv.Key
+}
+
+// clearSSTableIds cleans the SSTableIds (and their key ranges).
 func (level *Level) clearSSTableIds() {
 	level.SSTableIds = nil
+	level.keyRanges = nil
+}
+
+// addSSTables adds the given SSTables to the level, keeping the SSTableIds sorted by the starting keys of the SSTables.
+// It returns ErrOverlappingSSTables if the key range of any SSTable overlaps the key range of its adjacent SSTable, and
+// the level is left unchanged.
+func (level *Level) addSSTables(ssTables []*table.SSTable) error {
+	if len(ssTables) == 0 {
+		return nil
+	}
+	ssTableIds := make([]uint64, 0, len(level.SSTableIds)+len(ssTables))
+	keyRanges := make([]ssTableKeyRange, 0, len(level.SSTableIds)+len(ssTables))
+
+	ssTableIds = append(ssTableIds, level.SSTableIds...)
+	keyRanges = append(keyRanges, level.keyRanges...)
+	for _, ssTable := range ssTables {
+		ssTableIds = append(ssTableIds, ssTable.Id())
+		keyRanges = append(keyRanges, ssTableKeyRange{start: ssTable.StartingKey(), end: ssTable.EndingKey()})
+	}
+	sort.Sort(byStartingKey{ssTableIds: ssTableIds, keyRanges: keyRanges})
+
+	for index := 1; index < len(keyRanges); index++ {
+		if keyRanges[index-1].end.CompareKeysWithDescendingTimestamp(keyRanges[index].start) >= 0 {
+			return fmt.Errorf(
+				"%w: level %d, SSTable %d ending at %s and SSTable %d starting at %s",
+				ErrOverlappingSSTables,
+				level.LevelNumber,
+				ssTableIds[index-1],
+				keyRanges[index-1].end.RawString(),
+				ssTableIds[index],
+				keyRanges[index].start.RawString(),
+			)
+		}
+	}
+	level.SSTableIds = ssTableIds
+	level.keyRanges = keyRanges
+	return nil
+}
+
+// removeSSTableIds removes the given ssTableIds (and their key ranges) from the existing ssTableIds.
+func (level *Level) removeSSTableIds(ssTableIds []uint64) {
+	if len(ssTableIds) == 0 {
+		return
+	}
+	toRemove := make(map[uint64]struct{}, len(ssTableIds))
+	for _, ssTableId := range ssTableIds {
+		toRemove[ssTableId] = struct{}{}
+	}
+	var remaining []uint64
+	var remainingKeyRanges []ssTableKeyRange
+	for index, ssTableId := range level.SSTableIds {
+		if _, ok := toRemove[ssTableId]; !ok {
+			remaining = append(remaining, ssTableId)
+			remainingKeyRanges = append(remainingKeyRanges, level.keyRanges[index])
+		}
+	}
+	level.SSTableIds = remaining
+	level.keyRanges = remainingKeyRanges
+}
+
+// OverlappingSSTableIds returns the SSTableIds of the level whose key ranges overlap the raw keys of the given key range,
+// in the order of their key ranges. It binary searches for the first SSTable whose ending key is not smaller than the
+// start of the range, and collects the SSTables till the starting key of an SSTable is greater than the end of the range.
+// It is used by reads (Get and Scan) and compaction, to only consider the SSTables that can contain the keys of the range.
+func (level *Level) OverlappingSSTableIds(inclusiveRange kv.InclusiveKeyRange[kv.Key]) []uint64 {
+	first := sort.Search(len(level.keyRanges), func(index int) bool {
+		return !level.keyRanges[index].end.IsRawKeyLesserThan(inclusiveRange.Start())
+	})
+	var overlapping []uint64
+	for index := first; index < len(level.keyRanges); index++ {
+		if level.keyRanges[index].start.IsRawKeyGreaterThan(inclusiveRange.End()) {
+			break
+		}
+		overlapping = append(overlapping, level.SSTableIds[index])
+	}
+	return overlapping
+}
+
+// ssTablesContaining returns the SSTables of the level whose key ranges contain the raw key of the given key, in the order
+// of their key ranges (refer to OverlappingSSTableIds).
+// The SSTables of a level do not overlap, so there is typically a single such SSTable, however, compaction may split
+// the versions of a raw key across two adjacent SSTables.
+func (level *Level) ssTablesContaining(key kv.Key, ssTables map[uint64]*table.SSTable) []*table.SSTable {
+	var containing []*table.SSTable
+	for _, ssTableId := range level.OverlappingSSTableIds(kv.NewInclusiveKeyRange(key, key)) {
+		containing = append(containing, ssTables[ssTableId])
+	}
+	return containing
+}
+
+// byStartingKey sorts the SSTableIds and their key ranges of a level by the starting keys.
+type byStartingKey struct {
+	ssTableIds []uint64
+	keyRanges  []ssTableKeyRange
+}
+
+func (sorter byStartingKey) Len() int {
+	return len(sorter.ssTableIds)
+}
+
+func (sorter byStartingKey) Less(i, j int) bool {
+	return sorter.keyRanges[i].start.CompareKeysWithDescendingTimestamp(sorter.keyRanges[j].start) < 0
 }
 
-// appendSSTableIds appends the new ssTableIds to the existing ssTableIds.
-func (level *Level) appendSSTableIds(ssTableIds []uint64) {
-	level.SSTableIds = append(level.SSTableIds, ssTableIds...)
+func (sorter byStartingKey) Swap(i, j int) {
+	sorter.ssTableIds[i], sorter.ssTableIds[j] = sorter.ssTableIds[j], sorter.ssTableIds[i]
+	sorter.keyRanges[i], sorter.keyRanges[j] = sorter.keyRanges[j], sorter.keyRanges[i]
 }
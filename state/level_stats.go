@@ -0,0 +1,26 @@
+package state
+
+import "go-lsm/table"
+
+// LevelStats represents the statistics of a level, aggregated from the table.Properties of all the SSTables in the level.
+type LevelStats struct {
+	Level            int
+	NumberOfSSTables int
+	SizeInBytes      int64
+	Properties       table.Properties
+}
+
+// newLevelStats creates LevelStats for the given level from the SSTables in the level.
+func newLevelStats(level int, ssTableIds []uint64, ssTables map[uint64]*table.SSTable) LevelStats {
+	stats := LevelStats{Level: level}
+	for _, ssTableId := range ssTableIds {
+		ssTable, ok := ssTables[ssTableId]
+		if !ok {
+			continue
+		}
+		stats.NumberOfSSTables++
+		stats.SizeInBytes += ssTable.SizeInBytes()
+		stats.Properties = stats.Properties.Merge(ssTable.Properties())
+	}
+	return stats
+}
@@ -2,19 +2,176 @@ package state
 
 import (
 	"github.com/stretchr/testify/assert"
+	"go-lsm/kv"
+	"go-lsm/table"
+	"go-lsm/test_utility"
 	"testing"
 )
 
+func buildSSTableForLevel(t *testing.T, rootPath string, id uint64, keys ...kv.Key) *table.SSTable {
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	for _, key := range keys {
+		ssTableBuilder.Add(key, kv.NewStringValue("value"))
+	}
+	ssTable, err := ssTableBuilder.Build(id, rootPath)
+	assert.Nil(t, err)
+	return ssTable
+}
+
 func TestClearSStableIds(t *testing.T) {
 	level := &Level{LevelNumber: 1, SSTableIds: []uint64{1, 2, 3}}
 	level.clearSSTableIds()
 
 	assert.Equal(t, []uint64(nil), level.SSTableIds)
+	assert.Nil(t, level.keyRanges)
 }
 
-func TestAppendSStableIds(t *testing.T) {
-	level := &Level{LevelNumber: 1, SSTableIds: []uint64{1, 2, 3}}
-	level.appendSSTableIds([]uint64{4, 5})
+func TestRemoveSStableIds(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	level := &Level{LevelNumber: 1}
+	assert.Nil(t, level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 1, kv.NewStringKeyWithTimestamp("accurate", 5)),
+		buildSSTableForLevel(t, rootPath, 2, kv.NewStringKeyWithTimestamp("bolt", 5)),
+		buildSSTableForLevel(t, rootPath, 3, kv.NewStringKeyWithTimestamp("consensus", 5)),
+		buildSSTableForLevel(t, rootPath, 4, kv.NewStringKeyWithTimestamp("distributed", 5)),
+	}))
+	level.removeSSTableIds([]uint64{2, 4})
+
+	assert.Equal(t, []uint64{1, 3}, level.SSTableIds)
+	assert.Equal(t, 2, len(level.keyRanges))
+	assert.Equal(t, "consensus", level.keyRanges[1].start.RawString())
+}
+
+func TestAddSSTablesKeepsTheLevelSortedByStartingKey(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	level := &Level{LevelNumber: 1}
+	assert.Nil(t, level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 1, kv.NewStringKeyWithTimestamp("raft", 5), kv.NewStringKeyWithTimestamp("storage", 5)),
+		buildSSTableForLevel(t, rootPath, 2, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("bolt", 5)),
+	}))
+	assert.Nil(t, level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 3, kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringKeyWithTimestamp("etcd", 5)),
+	}))
+
+	assert.Equal(t, []uint64{2, 3, 1}, level.SSTableIds)
+	assert.Equal(t, "accurate", level.keyRanges[0].start.RawString())
+	assert.Equal(t, "etcd", level.keyRanges[1].end.RawString())
+	assert.Equal(t, "storage", level.keyRanges[2].end.RawString())
+}
+
+func TestAddSSTablesWithTheVersionsOfARawKeySplitAcrossAdjacentSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	level := &Level{LevelNumber: 1}
+	assert.Nil(t, level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 1, kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringKeyWithTimestamp("distributed", 7)),
+		buildSSTableForLevel(t, rootPath, 2, kv.NewStringKeyWithTimestamp("distributed", 4), kv.NewStringKeyWithTimestamp("etcd", 5)),
+	}))
+	assert.Equal(t, []uint64{1, 2}, level.SSTableIds)
+}
+
+func TestAddSSTableWithOnlyTheVersionsOfASingleRawKey(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	level := &Level{LevelNumber: 1}
+	assert.Nil(t, level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 1, kv.NewStringKeyWithTimestamp("consensus", 7), kv.NewStringKeyWithTimestamp("consensus", 5)),
+	}))
+	assert.Equal(t, []uint64{1}, level.OverlappingSSTableIds(
+		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("consensus", 10), kv.NewStringKeyWithTimestamp("consensus", 10)),
+	))
+}
+
+func TestAddOverlappingSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	level := &Level{LevelNumber: 1}
+	assert.Nil(t, level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 1, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("consensus", 5)),
+	}))
+	err := level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 2, kv.NewStringKeyWithTimestamp("bolt", 5), kv.NewStringKeyWithTimestamp("distributed", 5)),
+	})
+	assert.ErrorIs(t, err, ErrOverlappingSSTables)
+	assert.Equal(t, []uint64{1}, level.SSTableIds)
+	assert.Equal(t, 1, len(level.keyRanges))
+}
+
+func TestOverlappingSSTableIdsInALevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	level := &Level{LevelNumber: 1}
+	assert.Nil(t, level.addSSTables([]*table.SSTable{
+		buildSSTableForLevel(t, rootPath, 1, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("bolt", 5)),
+		buildSSTableForLevel(t, rootPath, 2, kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringKeyWithTimestamp("distributed", 5)),
+		buildSSTableForLevel(t, rootPath, 3, kv.NewStringKeyWithTimestamp("raft", 5), kv.NewStringKeyWithTimestamp("storage", 5)),
+	}))
+
+	overlapping := func(start, end string) []uint64 {
+		return level.OverlappingSSTableIds(kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp(start, 10), kv.NewStringKeyWithTimestamp(end, 10)))
+	}
+	assert.Equal(t, []uint64{1, 2}, overlapping("algorithm", "consensus"))
+	assert.Equal(t, []uint64{2}, overlapping("database", "etcd"))
+	assert.Equal(t, []uint64{1, 2, 3}, overlapping("bolt", "raft"))
+	assert.Equal(t, []uint64{3}, overlapping("storage", "zookeeper"))
+	assert.Nil(t, overlapping("etcd", "quorum"))
+	assert.Nil(t, overlapping("tikv", "zookeeper"))
+}
+
+func TestSSTablesContainingAKeyInALevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTables := make(map[uint64]*table.SSTable)
+	buildSSTable := func(id uint64, keys ...kv.Key) {
+		ssTables[id] = buildSSTableForLevel(t, rootPath, id, keys...)
+	}
+	buildSSTable(1, kv.NewStringKeyWithTimestamp("accurate", 5), kv.NewStringKeyWithTimestamp("bolt", 5))
+	buildSSTable(2, kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringKeyWithTimestamp("distributed", 7))
+	buildSSTable(3, kv.NewStringKeyWithTimestamp("distributed", 4), kv.NewStringKeyWithTimestamp("etcd", 5))
+	buildSSTable(4, kv.NewStringKeyWithTimestamp("raft", 5), kv.NewStringKeyWithTimestamp("storage", 5))
+	defer func() {
+		for _, ssTable := range ssTables {
+			_ = ssTable.Close()
+		}
+	}()
+
+	level := &Level{LevelNumber: 1}
+	assert.Nil(t, level.addSSTables([]*table.SSTable{ssTables[1], ssTables[2], ssTables[3], ssTables[4]}))
+	idsOf := func(ssTables []*table.SSTable) []uint64 {
+		var ids []uint64
+		for _, ssTable := range ssTables {
+			ids = append(ids, ssTable.Id())
+		}
+		return ids
+	}
 
-	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, level.SSTableIds)
+	assert.Equal(t, []uint64{1}, idsOf(level.ssTablesContaining(kv.NewStringKeyWithTimestamp("accurate", 10), ssTables)))
+	assert.Equal(t, []uint64{2}, idsOf(level.ssTablesContaining(kv.NewStringKeyWithTimestamp("consensus", 10), ssTables)))
+	assert.Equal(t, []uint64{2, 3}, idsOf(level.ssTablesContaining(kv.NewStringKeyWithTimestamp("distributed", 10), ssTables)))
+	assert.Equal(t, []uint64{4}, idsOf(level.ssTablesContaining(kv.NewStringKeyWithTimestamp("storage", 10), ssTables)))
+	assert.Nil(t, level.ssTablesContaining(kv.NewStringKeyWithTimestamp("foundation", 10), ssTables))
+	assert.Nil(t, level.ssTablesContaining(kv.NewStringKeyWithTimestamp("zookeeper", 10), ssTables))
 }
@@ -3,23 +3,26 @@ package state
 import (
 	"go-lsm/compact/meta"
 	"go-lsm/table"
-	"go-lsm/table/block"
 	"slices"
 )
 
 var NoStorageStateChanges = StorageStateChangeEvent{anyChanges: false}
 
 // StorageStateChangeEvent represents a state change event for StorageState.
-// It is generated after compaction runs, and it compacts table.SSTable files from adjacent levels.
+// It is generated after compaction runs, and it replaces the input table.SSTable files (of one or more levels) described
+// by meta.CompactionDescription with the NewSSTables at the output level.
+// A trivial move is a StorageStateChangeEvent whose NewSSTables are the input SSTables themselves: they are moved to the
+// output level without being rewritten, and they are not removed.
 type StorageStateChangeEvent struct {
 	NewSSTables   []*table.SSTable
 	NewSSTableIds []uint64
-	description   meta.SimpleLeveledCompactionDescription
+	description   meta.CompactionDescription
 	anyChanges    bool
+	trivialMove   bool
 }
 
 // NewStorageStateChangeEvent creates a new instance of StorageStateChangeEvent.
-func NewStorageStateChangeEvent(newSSTables []*table.SSTable, description meta.SimpleLeveledCompactionDescription) StorageStateChangeEvent {
+func NewStorageStateChangeEvent(newSSTables []*table.SSTable, description meta.CompactionDescription) StorageStateChangeEvent {
 	newSSTableIds := make([]uint64, 0, len(newSSTables))
 	for _, ssTable := range newSSTables {
 		newSSTableIds = append(newSSTableIds, ssTable.Id())
@@ -32,11 +35,20 @@ func NewStorageStateChangeEvent(newSSTables []*table.SSTable, description meta.S
 	}
 }
 
-// NewStorageStateChangeEventByOpeningSSTables creates a new instance of StorageStateChangeEvent, by opening the newSSTableIds.
-func NewStorageStateChangeEventByOpeningSSTables(newSSTableIds []uint64, description meta.SimpleLeveledCompactionDescription, rootPath string) (StorageStateChangeEvent, error) {
+// NewStorageStateChangeEventForTrivialMove creates a new instance of StorageStateChangeEvent which moves the given input
+// ssTables (of meta.CompactionDescription) to the output level.
+func NewStorageStateChangeEventForTrivialMove(ssTables []*table.SSTable, description meta.CompactionDescription) StorageStateChangeEvent {
+	event := NewStorageStateChangeEvent(ssTables, description)
+	event.trivialMove = true
+	return event
+}
+
+// NewStorageStateChangeEventByOpeningSSTables creates a new instance of StorageStateChangeEvent, by opening the newSSTableIds
+// with the given table.ReadOptions.
+func NewStorageStateChangeEventByOpeningSSTables(newSSTableIds []uint64, description meta.CompactionDescription, rootPath string, readOptions table.ReadOptions) (StorageStateChangeEvent, error) {
 	newSSTables := make([]*table.SSTable, 0, len(newSSTableIds))
 	for _, ssTableId := range newSSTableIds {
-		ssTable, err := table.Load(ssTableId, rootPath, block.DefaultBlockSize)
+		ssTable, err := table.LoadWithReadOptions(ssTableId, rootPath, readOptions)
 		if err != nil {
 			return NoStorageStateChanges, err
 		}
@@ -50,59 +62,82 @@ func NewStorageStateChangeEventByOpeningSSTables(newSSTableIds []uint64, descrip
 	}, nil
 }
 
-// CompactionUpperLevel returns the upper level present in meta.SimpleLeveledCompactionDescription.
-func (event StorageStateChangeEvent) CompactionUpperLevel() int {
-	return event.description.UpperLevel
+// CompactionOutputLevel returns the output level present in meta.CompactionDescription.
+func (event StorageStateChangeEvent) CompactionOutputLevel() int {
+	return event.description.OutputLevel
 }
 
-// CompactionLowerLevel returns the lower level present in meta.SimpleLeveledCompactionDescription.
-func (event StorageStateChangeEvent) CompactionLowerLevel() int {
-	return event.description.LowerLevel
+// CompactionSSTableIdsAt returns the input SSTableIds of the given level present in meta.CompactionDescription.
+func (event StorageStateChangeEvent) CompactionSSTableIdsAt(level int) []uint64 {
+	return event.description.SSTableIdsAt(level)
 }
 
-// CompactionUpperLevelSSTableIds returns the SSTableIds present in upper level of meta.SimpleLeveledCompactionDescription.
-func (event StorageStateChangeEvent) CompactionUpperLevelSSTableIds() []uint64 {
-	return event.description.UpperLevelSSTableIds
-}
-
-// CompactionLowerLevelSSTableIds returns the SSTableIds present in lower level of meta.SimpleLeveledCompactionDescription.
-func (event StorageStateChangeEvent) CompactionLowerLevelSSTableIds() []uint64 {
-	return event.description.LowerLevelSSTableIds
-}
-
-// CompactionDescription returns the instance of meta.SimpleLeveledCompactionDescription.
-func (event StorageStateChangeEvent) CompactionDescription() meta.SimpleLeveledCompactionDescription {
+// CompactionDescription returns the instance of meta.CompactionDescription.
+func (event StorageStateChangeEvent) CompactionDescription() meta.CompactionDescription {
 	return event.description
 }
 
-// MaxSSTableId returns the max SSTableId from NewSSTableIds.
+// MaxSSTableId returns the max SSTableId from NewSSTableIds, and 0 if there are no NewSSTableIds (a compaction which only
+// drops the input SSTables, refer to meta.CompactionDescription).
 func (event StorageStateChangeEvent) MaxSSTableId() uint64 {
+	if len(event.NewSSTableIds) == 0 {
+		return 0
+	}
 	return slices.Max(event.NewSSTableIds)
 }
 
+// IsTrivialMove returns true if the StorageStateChangeEvent moves the input SSTables to the output level without rewriting them.
+func (event StorageStateChangeEvent) IsTrivialMove() bool {
+	return event.trivialMove
+}
+
 // HasAnyChanges returns true if StorageStateChangeEvent has any changes, meaning if the compaction ran between two levels.
 func (event StorageStateChangeEvent) HasAnyChanges() bool {
 	return event.anyChanges
 }
 
-// allSSTableIdsExcludingTheOnesPresentInUpperLevelSSTableIds returns all the SSTableIds from the upper level, excluding the
-// given SSTableIds.
-func (event StorageStateChangeEvent) allSSTableIdsExcludingTheOnesPresentInUpperLevelSSTableIds(ssTableIds []uint64) []uint64 {
+// allSSTableIdsExcludingTheOnesPresentInLevel0SSTableIds returns all the given SSTableIds, excluding the input SSTableIds
+// of level0.
+func (event StorageStateChangeEvent) allSSTableIdsExcludingTheOnesPresentInLevel0SSTableIds(ssTableIds []uint64) []uint64 {
 	var excludedSSTableIds []uint64
 
-	upperLevelSSTableIdsCompacted := event.upperLevelSSTableIdsAsMap()
+	level0SSTableIdsCompacted := event.level0SSTableIdsAsMap()
 	for _, ssTableId := range ssTableIds {
-		if _, ok := upperLevelSSTableIdsCompacted[ssTableId]; !ok {
+		if _, ok := level0SSTableIdsCompacted[ssTableId]; !ok {
 			excludedSSTableIds = append(excludedSSTableIds, ssTableId)
 		}
 	}
 	return excludedSSTableIds
 }
 
-// upperLevelSSTableIdsAsMap returns the SSTableIds present in upper level as a map.
-func (event StorageStateChangeEvent) upperLevelSSTableIdsAsMap() map[uint64]struct{} {
-	ssTableIds := make(map[uint64]struct{}, len(event.CompactionUpperLevelSSTableIds()))
-	for _, ssTableId := range event.CompactionUpperLevelSSTableIds() {
+// level0SSTableIdsAfterCompaction returns the given level0 SSTableIds (oldest to latest), excluding the input SSTableIds of level0.
+// If level0 is the output level, the NewSSTableIds take the position of the oldest input SSTableId of level0. The input
+// SSTableIds of level0 are consecutive, so level0 remains ordered from the oldest to the latest SSTable.
+func (event StorageStateChangeEvent) level0SSTableIdsAfterCompaction(ssTableIds []uint64) []uint64 {
+	if event.CompactionOutputLevel() != 0 {
+		return event.allSSTableIdsExcludingTheOnesPresentInLevel0SSTableIds(ssTableIds)
+	}
+	var resultingSSTableIds []uint64
+
+	level0SSTableIdsCompacted := event.level0SSTableIdsAsMap()
+	newSSTableIdsAdded := false
+	for _, ssTableId := range ssTableIds {
+		if _, ok := level0SSTableIdsCompacted[ssTableId]; !ok {
+			resultingSSTableIds = append(resultingSSTableIds, ssTableId)
+			continue
+		}
+		if !newSSTableIdsAdded {
+			resultingSSTableIds = append(resultingSSTableIds, event.NewSSTableIds...)
+			newSSTableIdsAdded = true
+		}
+	}
+	return resultingSSTableIds
+}
+
+// level0SSTableIdsAsMap returns the input SSTableIds of level0 as a map.
+func (event StorageStateChangeEvent) level0SSTableIdsAsMap() map[uint64]struct{} {
+	ssTableIds := make(map[uint64]struct{}, len(event.CompactionSSTableIdsAt(0)))
+	for _, ssTableId := range event.CompactionSSTableIdsAt(0) {
 		ssTableIds[ssTableId] = struct{}{}
 	}
 	return ssTableIds
@@ -10,16 +10,54 @@ import (
 	"testing"
 )
 
-func TestAllSSTableIdsExcludingTheOnesPresentInUpperLevelSSTableIds(t *testing.T) {
+func TestAllSSTableIdsExcludingTheOnesPresentInLevel0SSTableIds(t *testing.T) {
 	event := StorageStateChangeEvent{
-		description: meta.SimpleLeveledCompactionDescription{
-			UpperLevelSSTableIds: []uint64{1, 2, 3, 4},
+		description: meta.CompactionDescription{
+			Inputs: []meta.CompactionInput{
+				{Level: 0, SSTableIds: []uint64{1, 2, 3, 4}},
+				{Level: 1, SSTableIds: []uint64{5}},
+			},
+			OutputLevel: 1,
 		},
 	}
-	excludedSSTableIds := event.allSSTableIdsExcludingTheOnesPresentInUpperLevelSSTableIds([]uint64{1, 2, 3, 4, 5, 6})
+	excludedSSTableIds := event.allSSTableIdsExcludingTheOnesPresentInLevel0SSTableIds([]uint64{1, 2, 3, 4, 5, 6})
 	assert.Equal(t, []uint64{5, 6}, excludedSSTableIds)
 }
 
+func TestLevel0SSTableIdsAfterCompactionWithLevel0AsTheOutputLevel(t *testing.T) {
+	event := StorageStateChangeEvent{
+		NewSSTableIds: []uint64{10, 11},
+		description: meta.CompactionDescription{
+			Inputs: []meta.CompactionInput{
+				{Level: 0, SSTableIds: []uint64{4, 3}},
+			},
+			OutputLevel: 0,
+		},
+	}
+	assert.Equal(t, []uint64{1, 2, 10, 11, 5}, event.level0SSTableIdsAfterCompaction([]uint64{1, 2, 3, 4, 5}))
+}
+
+func TestLevel0SSTableIdsAfterACompactionWhichOnlyDropsTheInputs(t *testing.T) {
+	event := NewStorageStateChangeEvent(nil, meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{2, 1}},
+		},
+		OutputLevel: 0,
+		DropOnly:    true,
+	})
+	assert.Equal(t, []uint64{3, 4}, event.level0SSTableIdsAfterCompaction([]uint64{1, 2, 3, 4}))
+}
+
+func TestMaxSSTableIdWithoutNewSSTableIds(t *testing.T) {
+	event := NewStorageStateChangeEvent(nil, meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{2, 1}},
+		},
+		DropOnly: true,
+	})
+	assert.Equal(t, uint64(0), event.MaxSSTableId())
+}
+
 func TestStorageStateChangeEventByOpeningSSTables(t *testing.T) {
 	rootPath := test_utility.SetupADirectoryWithTestName(t)
 	defer func() {
@@ -33,8 +71,9 @@ func TestStorageStateChangeEventByOpeningSSTables(t *testing.T) {
 
 	storageStateChangeEvent, err := NewStorageStateChangeEventByOpeningSSTables(
 		[]uint64{ssTable.Id()},
-		meta.SimpleLeveledCompactionDescription{},
+		meta.CompactionDescription{},
 		rootPath,
+		table.ReadOptions{},
 	)
 	assert.Nil(t, err)
 	assert.Equal(t, []uint64{ssTable.Id()}, storageStateChangeEvent.NewSSTableIds)
@@ -49,8 +88,9 @@ func TestStorageStateChangeEventByOpeningSSTablesForASSTableIdWhichDoesNotExist(
 
 	_, err := NewStorageStateChangeEventByOpeningSSTables(
 		[]uint64{2},
-		meta.SimpleLeveledCompactionDescription{},
+		meta.CompactionDescription{},
 		rootPath,
+		table.ReadOptions{},
 	)
 	assert.Error(t, err)
 }
@@ -1,7 +1,10 @@
 package state
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"go-lsm/compact/meta"
 	"go-lsm/iterator"
 	"go-lsm/kv"
 	"go-lsm/log"
@@ -9,6 +12,9 @@ import (
 	"go-lsm/memory"
 	"go-lsm/table"
 	"go-lsm/table/block"
+	"go-lsm/table/bloom"
+	"go-lsm/table/cache"
+	"go-lsm/table/compress"
 	"log/slog"
 	"os"
 	"sort"
@@ -16,22 +22,217 @@ import (
 	"time"
 )
 
-// CompactionOptions represents a combination of SimpleLeveledCompactionOptions and
+var ReadOnlyStorageStateErr = errors.New("storage state is opened in read-only mode, can not perform the write operation")
+
+// ErrManifestWrite is returned from StorageState.Apply if the StorageStateChangeEvent is applied to the in-memory state,
+// but it could not be recorded in the manifest.
+var ErrManifestWrite = errors.New("state change event is applied, but could not be recorded in the manifest")
+
+// CompactionStrategyType identifies the compaction strategy (refer to compact.CompactionStrategy).
+type CompactionStrategyType uint8
+
+const (
+	// SimpleLeveledCompactionStrategy compacts all the table.SSTable files between two adjacent levels (refer to compact.SimpleLeveledCompaction).
+	SimpleLeveledCompactionStrategy CompactionStrategyType = iota
+	// LeveledCompactionStrategy compacts a single table.SSTable file with the overlapping files of the next level, based on
+	// the target size of every level (refer to compact.LeveledCompaction).
+	LeveledCompactionStrategy CompactionStrategyType = 1
+	// TieredCompactionStrategy merges the sorted runs of similar sizes (refer to compact.TieredCompaction), it suits
+	// write-heavy workloads because it rewrites the data fewer times than the leveled strategies.
+	TieredCompactionStrategy CompactionStrategyType = 2
+	// FIFOCompactionStrategy never merges the table.SSTable files, it drops the oldest files once the total size or the age
+	// limit is exceeded (refer to compact.FIFOCompaction), it suits time-series data which is only queried for a recent window.
+	FIFOCompactionStrategy CompactionStrategyType = 3
+)
+
+const (
+	DefaultLevel0FilesCompactionTrigger = 4
+	DefaultBaseLevelSizeInBytes         = int64(256 << 20)
+	DefaultLevelSizeMultiplier          = 10
+	DefaultMaxSortedRuns                = 4
+	DefaultMaxCompactionFailures        = 5
+	DefaultMaxCompactionRetryBackoff    = 1 * time.Minute
+)
+
+// CompactionOptions represents a combination of the compaction strategy, its options and
 // the duration at which compaction goroutine should run.
+// Strategy selects the compaction strategy, 0 means SimpleLeveledCompactionStrategy.
+// MaxLevels is the number of levels (excluding level0), it is used by all the strategies.
+// StrategyOptions are the options of SimpleLeveledCompactionStrategy.
+// LeveledOptions are the options of LeveledCompactionStrategy, TieredOptions are the options of TieredCompactionStrategy,
+// and FIFOOptions are the options of FIFOCompactionStrategy.
+// MaxSubcompactions is the maximum number of key-range shards of a compaction which are merged concurrently
+// (refer to compact.Compaction), 0 and 1 mean that a compaction is merged on a single goroutine.
+// Filter (optional) is invoked by compaction to keep, remove or change the values of the keys (refer to meta.CompactionFilter).
+// A failed compaction is retried after a backoff which doubles with every consecutive failure (starting at 2 * Duration),
+// up to MaxRetryBackoff, 0 means DefaultMaxCompactionRetryBackoff. After MaxConsecutiveFailures consecutive failures,
+// 0 means DefaultMaxCompactionFailures, compaction stops and the Db is degraded to read-only (refer to go_lsm.Db).
 type CompactionOptions struct {
-	StrategyOptions SimpleLeveledCompactionOptions
-	Duration        time.Duration
+	Strategy               CompactionStrategyType
+	MaxLevels              uint
+	StrategyOptions        SimpleLeveledCompactionOptions
+	LeveledOptions         LeveledCompactionOptions
+	TieredOptions          TieredCompactionOptions
+	FIFOOptions            FIFOCompactionOptions
+	MaxSubcompactions      uint
+	Filter                 meta.CompactionFilter
+	MaxConsecutiveFailures uint
+	MaxRetryBackoff        time.Duration
+	Duration               time.Duration
+}
+
+// MaxFailures returns the number of consecutive compaction failures after which compaction stops.
+func (options CompactionOptions) MaxFailures() uint {
+	if options.MaxConsecutiveFailures == 0 {
+		return DefaultMaxCompactionFailures
+	}
+	return options.MaxConsecutiveFailures
+}
+
+// RetryBackoffAfter returns the duration to wait before retrying compaction after the given number of consecutive failures
+// (failures >= 1): Duration * 2^failures, limited to MaxRetryBackoff.
+func (options CompactionOptions) RetryBackoffAfter(failures uint) time.Duration {
+	maxBackoff := options.MaxRetryBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxCompactionRetryBackoff
+	}
+	backoff := options.Duration
+	for count := uint(0); count < failures && backoff < maxBackoff; count++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// validate returns an error if the compaction strategy is not supported, or if FIFOCompactionStrategy has no limit.
+func (options CompactionOptions) validate() error {
+	switch options.Strategy {
+	case SimpleLeveledCompactionStrategy, LeveledCompactionStrategy, TieredCompactionStrategy:
+		return nil
+	case FIFOCompactionStrategy:
+		if options.FIFOOptions.MaxTotalSizeInBytes <= 0 && options.FIFOOptions.MaxAge <= 0 {
+			return errors.New("fifo compaction strategy requires a total size or an age limit")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported compaction strategy %d", options.Strategy)
+	}
 }
 
 // SimpleLeveledCompactionOptions represents the configurable options for simple-leveled compaction.
 // Read more about the logic behind simple-leveled compaction in compact.SimpleLeveledCompaction.
 type SimpleLeveledCompactionOptions struct {
 	NumberOfSSTablesRatioPercentage uint
-	MaxLevels                       uint
 	Level0FilesCompactionTrigger    uint
 }
 
+// LeveledCompactionOptions represents the configurable options for size-based leveled compaction.
+// Read more about the logic behind leveled compaction in compact.LeveledCompaction.
+// Level0FilesCompactionTrigger is the number of table.SSTable files at level0 which triggers compaction, 0 means
+// DefaultLevel0FilesCompactionTrigger.
+// BaseLevelSizeInBytes is the target size of level1, 0 means DefaultBaseLevelSizeInBytes.
+// LevelSizeMultiplier is the ratio between the target sizes of two adjacent levels, 0 means DefaultLevelSizeMultiplier.
+type LeveledCompactionOptions struct {
+	Level0FilesCompactionTrigger uint
+	BaseLevelSizeInBytes         int64
+	LevelSizeMultiplier          uint
+}
+
+// Level0FilesTrigger returns the number of table.SSTable files at level0 which triggers compaction.
+func (options LeveledCompactionOptions) Level0FilesTrigger() uint {
+	if options.Level0FilesCompactionTrigger == 0 {
+		return DefaultLevel0FilesCompactionTrigger
+	}
+	return options.Level0FilesCompactionTrigger
+}
+
+// TargetSizeInBytesAt returns the target size of the given level (level >= 1):
+// BaseLevelSizeInBytes * LevelSizeMultiplier^(level-1).
+func (options LeveledCompactionOptions) TargetSizeInBytesAt(level int) int64 {
+	targetSize := options.BaseLevelSizeInBytes
+	if targetSize == 0 {
+		targetSize = DefaultBaseLevelSizeInBytes
+	}
+	multiplier := int64(options.LevelSizeMultiplier)
+	if multiplier == 0 {
+		multiplier = DefaultLevelSizeMultiplier
+	}
+	for count := 1; count < level; count++ {
+		targetSize *= multiplier
+	}
+	return targetSize
+}
+
+// TieredCompactionOptions represents the configurable options for tiered (universal) compaction.
+// Read more about the logic behind tiered compaction in compact.TieredCompaction.
+// MaxSortedRuns is the number of sorted runs (every level0 SSTable and every non-empty level) beyond which compaction is
+// triggered, 0 means DefaultMaxSortedRuns.
+// SizeRatioPercentage is the flexibility in comparing the size of the next sorted run with the total size of the sorted runs
+// picked so far: the next sorted run is picked if its size <= total size * (100 + SizeRatioPercentage) / 100.
+type TieredCompactionOptions struct {
+	MaxSortedRuns       uint
+	SizeRatioPercentage uint
+}
+
+// SortedRunsTrigger returns the number of sorted runs beyond which compaction is triggered.
+func (options TieredCompactionOptions) SortedRunsTrigger() uint {
+	if options.MaxSortedRuns == 0 {
+		return DefaultMaxSortedRuns
+	}
+	return options.MaxSortedRuns
+}
+
+// FIFOCompactionOptions represents the configurable options for FIFO compaction.
+// Read more about the logic behind FIFO compaction in compact.FIFOCompaction.
+// MaxTotalSizeInBytes is the limit on the total size of the table.SSTable files, 0 means no size limit.
+// MaxAge is the limit on the age of a table.SSTable file (measured from its creation time), 0 means no age limit.
+// At least one of the limits must be set.
+type FIFOCompactionOptions struct {
+	MaxTotalSizeInBytes int64
+	MaxAge              time.Duration
+}
+
+// CompressionOptions represents the compression codecs used for the blocks of the SSTables.
+// Codec is used for all the levels, unless the level has an entry in CodecPerLevel (level0 is represented by 0).
+// The zero value of CompressionOptions does not compress the blocks.
+// A typical configuration keeps the upper levels (which are rewritten frequently) on a fast codec like compress.Snappy,
+// and the lower levels (which hold most of the data) on a denser codec like compress.Zlib.
+type CompressionOptions struct {
+	Codec         compress.CodecType
+	CodecPerLevel map[int]compress.CodecType
+}
+
+// BloomFilterOptions represents the configuration of the bloom filters of the SSTables.
+// FalsePositiveRate is used for all the levels, unless the level has an entry in FalsePositiveRatePerLevel (level0 is
+// represented by 0), 0 means bloom.FalsePositiveRate. A lower false positive rate costs more bits per key.
+// FilterType is the implementation of the bloom filters, 0 means bloom.StandardFilterType. bloom.BlockedFilterType
+// makes each probe touch a single cache line, at the cost of a slightly higher false positive rate.
+// PrefixExtractor (optional) builds a prefix bloom filter in every SSTable, which allows StorageState.Scan over a range
+// whose start and end keys have the same prefix to skip the SSTables which do not contain the prefix.
+type BloomFilterOptions struct {
+	FalsePositiveRate         float64
+	FalsePositiveRatePerLevel map[int]float64
+	FilterType                bloom.FilterType
+	PrefixExtractor           bloom.PrefixExtractor
+}
+
 // StorageOptions represents the configuration options for StorageState.
+// BlockSize limits the (uncompressed) size of the data blocks of the SSTables at all the levels, unless the level has an
+// entry in BlockSizePerLevel (level0 is represented by 0), 0 means block.DefaultBlockSize. Larger blocks suit scan-heavy
+// workloads (fewer block reads and better compression), smaller blocks suit point lookups (less data read per Get).
+// The block size is stored in every SSTable, so changing it affects only the new SSTables.
+// BlockRestartInterval is the number of keys between two restart points (keys stored without prefix compression) in a block,
+// 0 means block.DefaultRestartInterval. A smaller interval makes seeks within a block faster, at the cost of space.
+// BlockCacheSizeInBytes is the capacity of the cache.BlockCache shared by all the SSTables, 0 disables the block cache.
+// IndexPartitionSize builds the SSTables with a two-level (partitioned) block index, whose partitions are limited to
+// IndexPartitionSize bytes, 0 builds a flat block index. Only the top-level index of a partitioned index stays in memory,
+// and the partitions are read through the block cache, which suits large SSTables with a block cache.
+// DataBlockHashIndex builds a hash index in every data block of the SSTables, which allows Get to jump to the restart point
+// of the key within a block instead of a binary search (it costs a few bytes per key in every block).
+// MemoryMappedSSTables memory-maps the SSTable files, which avoids copying the blocks on every read (useful for
+// read-heavy workloads).
+// MaxOpenSSTableFiles bounds the number of open SSTable files using the table.TableCache, 0 keeps all the SSTable files open.
+// ReadOnly opens an existing StorageState without writing to the directory (Path): manifest and WALs are replayed in memory,
+// no files are created and no memtable flushes happen.
 type StorageOptions struct {
 	MemTableSizeInBytes   int64
 	SSTableSizeInBytes    int64
@@ -39,6 +240,141 @@ type StorageOptions struct {
 	MaximumMemtables      uint
 	FlushMemtableDuration time.Duration
 	CompactionOptions     CompactionOptions
+	CompressionOptions    CompressionOptions
+	BloomFilterOptions    BloomFilterOptions
+	BlockSize             uint
+	BlockSizePerLevel     map[int]uint
+	BlockRestartInterval  uint
+	BlockCacheSizeInBytes int64
+	IndexPartitionSize    uint
+	DataBlockHashIndex    bool
+	MemoryMappedSSTables  bool
+	MaxOpenSSTableFiles   int
+	ReadOnly              bool
+	blockCache            *cache.BlockCache
+	tableCache            *table.TableCache
+}
+
+// CodecTypeAt returns the compress.CodecType for the given level.
+func (options CompressionOptions) CodecTypeAt(level int) compress.CodecType {
+	if codecType, ok := options.CodecPerLevel[level]; ok {
+		return codecType
+	}
+	return options.Codec
+}
+
+// validate returns an error if any of the configured codecs is not supported.
+func (options CompressionOptions) validate() error {
+	if _, err := compress.CodecFor(options.Codec); err != nil {
+		return err
+	}
+	for _, codecType := range options.CodecPerLevel {
+		if _, err := compress.CodecFor(codecType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FalsePositiveRateAt returns the false positive rate of the bloom filters for the given level.
+func (options BloomFilterOptions) FalsePositiveRateAt(level int) float64 {
+	if falsePositiveRate, ok := options.FalsePositiveRatePerLevel[level]; ok && falsePositiveRate != 0 {
+		return falsePositiveRate
+	}
+	if options.FalsePositiveRate != 0 {
+		return options.FalsePositiveRate
+	}
+	return bloom.FalsePositiveRate
+}
+
+// validate returns an error if any of the configured false positive rates is not within (0, 1), or the FilterType
+// is not supported. A zero false positive rate is allowed, it means bloom.FalsePositiveRate.
+func (options BloomFilterOptions) validate() error {
+	if options.FilterType != 0 {
+		if err := bloom.ValidateFilterType(options.FilterType); err != nil {
+			return err
+		}
+	}
+	isValid := func(falsePositiveRate float64) bool {
+		return falsePositiveRate >= 0 && falsePositiveRate < 1
+	}
+	if !isValid(options.FalsePositiveRate) {
+		return fmt.Errorf("bloom filter false positive rate %v must be within (0, 1)", options.FalsePositiveRate)
+	}
+	for level, falsePositiveRate := range options.FalsePositiveRatePerLevel {
+		if !isValid(falsePositiveRate) {
+			return fmt.Errorf("bloom filter false positive rate %v at level %v must be within (0, 1)", falsePositiveRate, level)
+		}
+	}
+	return nil
+}
+
+// commonPrefixOf returns the prefix shared by all the keys in the inclusiveRange, and false if there is no
+// bloom.PrefixExtractor, or the start and the end keys of the range do not have the same prefix.
+// Because a bloom.PrefixExtractor is order-preserving, all the keys between two keys with the same prefix share the prefix.
+func (options BloomFilterOptions) commonPrefixOf(inclusiveRange kv.InclusiveKeyRange[kv.Key]) ([]byte, bool) {
+	if options.PrefixExtractor == nil {
+		return nil, false
+	}
+	startPrefix, ok := options.PrefixExtractor.Prefix(inclusiveRange.Start().RawBytes())
+	if !ok {
+		return nil, false
+	}
+	endPrefix, ok := options.PrefixExtractor.Prefix(inclusiveRange.End().RawBytes())
+	if !ok || !bytes.Equal(startPrefix, endPrefix) {
+		return nil, false
+	}
+	return startPrefix, true
+}
+
+// BlockSizeAt returns the block size for building the SSTables at the given level.
+func (options StorageOptions) BlockSizeAt(level int) uint {
+	if blockSize, ok := options.BlockSizePerLevel[level]; ok && blockSize != 0 {
+		return blockSize
+	}
+	if options.BlockSize != 0 {
+		return options.BlockSize
+	}
+	return block.DefaultBlockSize
+}
+
+// validateBlockSizes returns an error if any of the configured block sizes is larger than block.MaxBlockSize.
+func (options StorageOptions) validateBlockSizes() error {
+	if options.BlockSize > block.MaxBlockSize {
+		return fmt.Errorf("block size %v is larger than the maximum block size %v", options.BlockSize, block.MaxBlockSize)
+	}
+	for level, blockSize := range options.BlockSizePerLevel {
+		if blockSize > block.MaxBlockSize {
+			return fmt.Errorf("block size %v at level %v is larger than the maximum block size %v", blockSize, level, block.MaxBlockSize)
+		}
+	}
+	return nil
+}
+
+// SSTableBuilderOptionsAt returns the table.SSTableBuilderOptions for building the SSTables at the given level.
+// Memtable flush builds SSTables at level0, and compaction builds SSTables at its output (/lower) level.
+func (options StorageOptions) SSTableBuilderOptionsAt(level int) table.SSTableBuilderOptions {
+	return table.SSTableBuilderOptions{
+		BlockSize:              options.BlockSizeAt(level),
+		Compression:            options.CompressionOptions.CodecTypeAt(level),
+		RestartInterval:        options.BlockRestartInterval,
+		BloomFalsePositiveRate: options.BloomFilterOptions.FalsePositiveRateAt(level),
+		FilterType:             options.BloomFilterOptions.FilterType,
+		PrefixExtractor:        options.BloomFilterOptions.PrefixExtractor,
+		IndexPartitionSize:     options.IndexPartitionSize,
+		DataBlockHashIndex:     options.DataBlockHashIndex,
+	}
+}
+
+// SSTableReadOptions returns the table.ReadOptions for reading the SSTables.
+// The cache.BlockCache and the table.TableCache are created by NewStorageStateWithOptions, so the options returned from
+// StorageState.Options() carry them.
+func (options StorageOptions) SSTableReadOptions() table.ReadOptions {
+	return table.ReadOptions{
+		BlockCache:   options.blockCache,
+		MemoryMapped: options.MemoryMappedSSTables,
+		TableCache:   options.tableCache,
+	}
 }
 
 // StorageState represents the core abstraction to manage the in-memory state of the key/value storage engine.
@@ -66,15 +402,39 @@ type StorageState struct {
 }
 
 // NewStorageStateWithOptions creates new instance of StorageState, or loads the existing state from manifest.Manifest.
+// If the options specify ReadOnly, the directory must exist, and the StorageState neither creates any files
+// nor spawns the memtable flush goroutine.
 func NewStorageStateWithOptions(options StorageOptions) (*StorageState, error) {
-	if _, err := os.Stat(options.Path); os.IsNotExist(err) {
+	if err := options.CompressionOptions.validate(); err != nil {
+		return nil, err
+	}
+	if err := options.BloomFilterOptions.validate(); err != nil {
+		return nil, err
+	}
+	if err := options.validateBlockSizes(); err != nil {
+		return nil, err
+	}
+	if err := options.CompactionOptions.validate(); err != nil {
+		return nil, err
+	}
+	if options.ReadOnly {
+		if _, err := os.Stat(options.Path); err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(options.Path); os.IsNotExist(err) {
 		_ = os.MkdirAll(options.Path, os.ModePerm)
 	}
-	levels := make([]*Level, options.CompactionOptions.StrategyOptions.MaxLevels)
-	for level := 1; level <= int(options.CompactionOptions.StrategyOptions.MaxLevels); level++ {
+	if options.BlockCacheSizeInBytes > 0 {
+		options.blockCache = cache.NewBlockCache(options.BlockCacheSizeInBytes)
+	}
+	if options.MaxOpenSSTableFiles > 0 {
+		options.tableCache = table.NewTableCache(options.MaxOpenSSTableFiles)
+	}
+	levels := make([]*Level, options.CompactionOptions.MaxLevels)
+	for level := 1; level <= int(options.CompactionOptions.MaxLevels); level++ {
 		levels[level-1] = &Level{LevelNumber: level}
 	}
-	manifestRecorder, events, err := manifest.CreateNewOrRecoverFrom(options.Path)
+	manifestRecorder, events, err := openManifest(options)
 	if err != nil {
 		return nil, err
 	}
@@ -88,86 +448,125 @@ func NewStorageStateWithOptions(options StorageOptions) (*StorageState, error) {
 		closeChannel:                   make(chan struct{}),
 		flushMemtableCompletionChannel: make(chan struct{}),
 		options:                        options,
-		walPath:                        log.NewWALPath(options.Path),
+		walPath:                        walPath(options),
 		lastCommitTimestamp:            0,
 	}
 	if err := storageState.mayBeLoadExisting(events); err != nil {
 		return nil, err
 	}
-	storageState.spawnMemtableFlush()
-	storageState.ssTableCleaner.Start()
+	if !options.ReadOnly {
+		storageState.spawnMemtableFlush()
+		storageState.ssTableCleaner.Start()
+	}
 	return storageState, nil
 }
 
 // Get gets the value of the given key from the current memtable, followed by immutable memtables,
 // level0 SSTables and then finally SSTables from different levels.
+// Get stops at the first source which has a version of the key visible at the timestamp of the key (the newest visible version):
+// 1) Memtables are enquired from the current (newest) to the oldest.
+// 2) Level0 SSTables may overlap, so they are enquired from the newest to the oldest.
+// 3) SSTables of the other levels do not overlap, so Get binary searches each level for the SSTable whose key range contains
+// the key (refer to Level.ssTablesContaining), and enquires only that SSTable (if its bloom filter may contain the key).
+// The SSTables of a newer source only contain the versions which are newer than the versions in the older sources,
+// hence the first visible version is the newest visible version.
+// If the newest visible version in a memtable or an SSTable is a deletion, Get returns false. An error in reading an SSTable
+// (e.g. checksum.ErrCorruption) is returned.
 // An important point in Get and Scan is decrementing the references for the SSTables in use.
 // It is quite possible that at time T1 SSTables A and B are used for performing a Scan operation.
 // At time T2 (T2 > T1), compaction runs and the outcome of compaction is to clean SSTable A and B.
 // However, SSTables A and B are still being referred by some transaction which involves Scan operation.
 // Unless the reference count of SSTables A and B drops to zero, these tables can not be cleaned.
 // Refer to: table.SSTable, table.SSTableCleaner.
-func (storageState *StorageState) Get(key kv.Key) (kv.Value, bool) {
+func (storageState *StorageState) Get(key kv.Key) (kv.Value, bool, error) {
 	storageState.stateLock.RLock()
 	defer storageState.stateLock.RUnlock()
 
 	enquireMemtables := func() (kv.Value, bool) {
-		value, ok := storageState.currentMemtable.Get(key)
+		value, ok := storageState.currentMemtable.GetNewestVersion(key)
 		if ok {
 			return value, ok
 		}
 		for index := len(storageState.immutableMemtables) - 1; index >= 0; index-- {
 			memTable := storageState.immutableMemtables[index]
-			if value, ok := memTable.Get(key); ok {
+			if value, ok := memTable.GetNewestVersion(key); ok {
 				return value, ok
 			}
 		}
 		return kv.EmptyValue, false
 	}
-	enquireL0SSTables := func() (kv.Value, bool) {
-		l0SSTableIterators, ssTablesInUse := storageState.l0SSTableIterators(key, func(ssTable *table.SSTable) bool {
-			return ssTable.ContainsInclusive(kv.NewInclusiveKeyRange(key, key)) && ssTable.MayContain(key)
-		})
-		boundedIterator := iterator.NewInclusiveBoundedIterator(iterator.NewMergeIterator(l0SSTableIterators, func() {
-			table.DecrementReferenceFor(ssTablesInUse)
-		}), key)
-		defer boundedIterator.Close()
-
-		if boundedIterator.IsValid() && boundedIterator.Key().IsRawKeyEqualTo(key) {
-			return boundedIterator.Value(), true
+	enquireL0SSTables := func() (kv.Value, bool, error) {
+		for l0SSTableIndex := len(storageState.l0SSTableIds) - 1; l0SSTableIndex >= 0; l0SSTableIndex-- {
+			ssTable := storageState.ssTables[storageState.l0SSTableIds[l0SSTableIndex]]
+			if value, ok, err := getFromSSTable(ssTable, key); err != nil || ok {
+				return value, ok, err
+			}
 		}
-		return kv.EmptyValue, false
+		return kv.EmptyValue, false, nil
+	}
+	enquireOtherLevelSSTables := func() (kv.Value, bool, error) {
+		for _, level := range storageState.levels {
+			for _, ssTable := range level.ssTablesContaining(key, storageState.ssTables) {
+				if value, ok, err := getFromSSTable(ssTable, key); err != nil || ok {
+					return value, ok, err
+				}
+			}
+		}
+		return kv.EmptyValue, false, nil
 	}
-	enquireOtherLevelSSTables := func() (kv.Value, bool) {
-		otherSSTableIterators, ssTablesInUse := storageState.otherLevelSSTableIterators(key, func(ssTable *table.SSTable) bool {
-			return ssTable.ContainsInclusive(kv.NewInclusiveKeyRange(key, key)) && ssTable.MayContain(key)
-		})
-		boundedIterator := iterator.NewInclusiveBoundedIterator(iterator.NewMergeIterator(otherSSTableIterators, func() {
-			table.DecrementReferenceFor(ssTablesInUse)
-		}), key)
-		defer boundedIterator.Close()
 
-		if boundedIterator.IsValid() && boundedIterator.Key().IsRawKeyEqualTo(key) {
-			return boundedIterator.Value(), true
+	visibleValue := func(value kv.Value, err error) (kv.Value, bool, error) {
+		if err != nil {
+			return kv.EmptyValue, false, err
 		}
-		return kv.EmptyValue, false
+		if value.IsEmpty() {
+			return kv.EmptyValue, false, nil
+		}
+		return value, true, nil
 	}
 
 	if value, ok := enquireMemtables(); ok {
-		return value, true
+		return visibleValue(value, nil)
 	}
-	if value, ok := enquireL0SSTables(); ok {
-		return value, true
+	if value, ok, err := enquireL0SSTables(); err != nil || ok {
+		return visibleValue(value, err)
 	}
-	if value, ok := enquireOtherLevelSSTables(); ok {
-		return value, true
+	if value, ok, err := enquireOtherLevelSSTables(); err != nil || ok {
+		return visibleValue(value, err)
 	}
-	return kv.EmptyValue, false
+	return kv.EmptyValue, false, nil
+}
+
+// getFromSSTable gets the newest version of the given key visible at the timestamp of the key from the table.SSTable.
+// It returns false if the key range of the SSTable does not contain the key, or its bloom filter does not contain the key.
+// A deleted key is returned as kv.EmptyValue and true, so that Get stops at the deletion instead of looking up the older
+// SSTables.
+// The SSTable is referenced while it is being read (refer to table.SSTable.SeekToKeyForPointLookup).
+func getFromSSTable(ssTable *table.SSTable, key kv.Key) (kv.Value, bool, error) {
+	if !ssTable.ContainsInclusive(kv.NewInclusiveKeyRange(key, key)) || !ssTable.MayContain(key) {
+		return kv.EmptyValue, false, nil
+	}
+	ssTableIterator, err := ssTable.SeekToKeyForPointLookup(key)
+	if err != nil {
+		return kv.EmptyValue, false, err
+	}
+	defer func() {
+		ssTableIterator.Close()
+		table.DecrementReferenceFor([]*table.SSTable{ssTable})
+	}()
+	if ssTableIterator.IsValid() && ssTableIterator.Key().IsRawKeyEqualTo(key) {
+		return ssTableIterator.Value(), true, nil
+	}
+	return kv.EmptyValue, false, nil
 }
 
 // Set sets the kv.TimestampedBatch in the memtable.
 // If the current memtable can not accommodate the incoming batch, it is frozen and a new memtable is created.
+// It returns ReadOnlyStorageStateErr if the StorageState is opened in read-only mode.
 func (storageState *StorageState) Set(timestampedBatch kv.TimestampedBatch) error {
+	if storageState.options.ReadOnly {
+		return ReadOnlyStorageStateErr
+	}
 	if err := storageState.mayBeFreezeCurrentMemtable(int64(timestampedBatch.SizeInBytes())); err != nil {
 		return err
 	}
@@ -187,14 +586,18 @@ func (storageState *StorageState) Set(timestampedBatch kv.TimestampedBatch) erro
 // Scan performs a forward scan for the kv.InclusiveKeyRange.
 // It involves creating iterators from the current memtable, followed by immutable memtables,
 // level0 SSTables and then finally SSTables from different levels.
+// If the start and the end keys of the range have the same prefix (refer to BloomFilterOptions.PrefixExtractor), the SSTables
+// whose prefix bloom filter rules out the prefix are skipped.
 // It finally returns an instance of iterator.NewInclusiveBoundedIterator which returns the latest version (/timestamp) of any key.
+// An error in seeking an SSTable (e.g. checksum.ErrCorruption) is returned, after closing the SSTable iterators which are
+// already created and decrementing the references of their SSTables.
 // An important point in Get and Scan is decrementing the references for the SSTables in use.
 // It is quite possible that at time T1 SSTables A and B are used for performing a Scan operation.
 // At time T2 (T2 > T1), compaction runs and the outcome of compaction is to clean SSTable A and B.
 // However, SSTables A and B are still being referred by some transaction which involves Scan operation.
 // Unless the reference count of SSTables A and B drops to zero, these tables can not be cleaned.
 // Refer to: table.SSTable, table.SSTableCleaner.
-func (storageState *StorageState) Scan(inclusiveRange kv.InclusiveKeyRange[kv.Key]) iterator.Iterator {
+func (storageState *StorageState) Scan(inclusiveRange kv.InclusiveKeyRange[kv.Key]) (iterator.Iterator, error) {
 	storageState.stateLock.RLock()
 	defer storageState.stateLock.RUnlock()
 
@@ -210,32 +613,65 @@ func (storageState *StorageState) Scan(inclusiveRange kv.InclusiveKeyRange[kv.Ke
 		}
 		return iterators
 	}
-	ssTableIteratorsAtAllLevels := func() ([]iterator.Iterator, []*table.SSTable) {
-		l0SSTableIterators, ssTablesFromLevel0InUse := storageState.l0SSTableIterators(inclusiveRange.Start(), func(ssTable *table.SSTable) bool {
-			return ssTable.ContainsInclusive(inclusiveRange)
-		})
-		otherSSTableIterators, ssTablesFromOtherLevelsInUse := storageState.otherLevelSSTableIterators(inclusiveRange.Start(), func(ssTable *table.SSTable) bool {
-			return ssTable.ContainsInclusive(inclusiveRange)
-		})
-		return append(l0SSTableIterators, otherSSTableIterators...), append(ssTablesFromLevel0InUse, ssTablesFromOtherLevelsInUse...)
+	prefix, hasCommonPrefix := storageState.options.BloomFilterOptions.commonPrefixOf(inclusiveRange)
+	ssTableSelector := func(ssTable *table.SSTable) bool {
+		if !ssTable.ContainsInclusive(inclusiveRange) {
+			return false
+		}
+		return !hasCommonPrefix || ssTable.MayContainPrefix(storageState.options.BloomFilterOptions.PrefixExtractor, prefix)
+	}
+	ssTableIteratorsAtAllLevels := func() ([]iterator.Iterator, []*table.SSTable, error) {
+		l0SSTableIterators, ssTablesFromLevel0InUse, err := storageState.l0SSTableIterators(inclusiveRange.Start(), ssTableSelector)
+		if err != nil {
+			return nil, nil, err
+		}
+		otherSSTableIterators, ssTablesFromOtherLevelsInUse, err := storageState.otherLevelSSTableIterators(inclusiveRange, ssTableSelector)
+		if err != nil {
+			closeSSTableIterators(l0SSTableIterators, ssTablesFromLevel0InUse)
+			return nil, nil, err
+		}
+		return append(l0SSTableIterators, otherSSTableIterators...), append(ssTablesFromLevel0InUse, ssTablesFromOtherLevelsInUse...), nil
 	}
 
-	ssTableIterators, ssTablesInUse := ssTableIteratorsAtAllLevels()
+	ssTableIterators, ssTablesInUse, err := ssTableIteratorsAtAllLevels()
+	if err != nil {
+		return nil, err
+	}
 	return iterator.NewInclusiveBoundedIterator(iterator.NewMergeIterator(append(memtableIterators(), ssTableIterators...), func() {
 		table.DecrementReferenceFor(ssTablesInUse)
-	}), inclusiveRange.End())
+	}), inclusiveRange.End()), nil
 }
 
 // Apply applies the StorageStateChangeEvent to the StorageState.
-// It is called if compaction runs between two adjacent levels.
+// It is called after compaction runs, the StorageStateChangeEvent carries the meta.CompactionDescription of the compaction strategy.
 // Applying StorageStateChangeEvent is exclusive, as it requires a write-lock.
+// It returns ErrOverlappingSSTables (without changing the StorageState) if the new SSTables would overlap the other SSTables
+// of the output level, both during compaction and recovery. Any error, other than ErrManifestWrite, leaves the StorageState
+// unchanged. An error wrapping ErrManifestWrite means that the in-memory state is ahead of the manifest.
 // As a part of applying the StorageStateChangeEvent, all the table.SSTable(s) which are to be removed are submitted to
 // table.SSTableCleaner.
+// In read-only mode, StorageStateChangeEvent is only applied during recovery, and the table.SSTable(s) which are to be removed
+// are closed, not deleted.
 func (storageState *StorageState) Apply(event StorageStateChangeEvent, recovery bool) error {
-	ssTablesToRemove := storageState.apply(event)
+	ssTablesToRemove, err := storageState.apply(event)
+	if err != nil {
+		return err
+	}
+	if storageState.options.ReadOnly {
+		for _, ssTable := range ssTablesToRemove {
+			if err := ssTable.Close(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	if !recovery {
-		if err := storageState.manifest.Add(manifest.NewCompactionDone(event.NewSSTableIds, event.CompactionDescription())); err != nil {
-			return err
+		var manifestEvent manifest.Event = manifest.NewCompactionDone(event.NewSSTableIds, event.CompactionDescription())
+		if event.IsTrivialMove() {
+			manifestEvent = manifest.NewSSTablesMoved(event.CompactionDescription())
+		}
+		if err := storageState.manifest.Add(manifestEvent); err != nil {
+			return fmt.Errorf("%w: %w", ErrManifestWrite, err)
 		}
 	}
 	storageState.ssTableCleaner.Submit(ssTablesToRemove)
@@ -252,6 +688,27 @@ func (storageState *StorageState) Options() StorageOptions {
 	return storageState.options
 }
 
+// BlockCacheStats returns the statistics of the cache.BlockCache, and false if the block cache is disabled.
+func (storageState *StorageState) BlockCacheStats() (cache.Stats, bool) {
+	if storageState.options.blockCache == nil {
+		return cache.Stats{}, false
+	}
+	return storageState.options.blockCache.Stats(), true
+}
+
+// LevelStats returns the LevelStats of all the levels, starting with level 0.
+func (storageState *StorageState) LevelStats() []LevelStats {
+	storageState.stateLock.RLock()
+	defer storageState.stateLock.RUnlock()
+
+	stats := make([]LevelStats, 0, len(storageState.levels)+1)
+	stats = append(stats, newLevelStats(0, storageState.l0SSTableIds, storageState.ssTables))
+	for _, level := range storageState.levels {
+		stats = append(stats, newLevelStats(level.LevelNumber, level.SSTableIds, storageState.ssTables))
+	}
+	return stats
+}
+
 // WALDirectoryPath returns the directory path of WAL.
 func (storageState *StorageState) WALDirectoryPath() string {
 	return storageState.walPath.DirectoryPath
@@ -263,20 +720,67 @@ func (storageState *StorageState) LastCommitTimestamp() uint64 {
 }
 
 // Snapshot returns the point-in-time state of StorageState.
+// The levels are copied, changes to a Level allocate new slices, so the snapshot is not affected by the later changes.
 func (storageState *StorageState) Snapshot() StorageStateSnapshot {
 	storageState.stateLock.RLock()
 	defer storageState.stateLock.RUnlock()
 
+	levels := make([]*Level, 0, len(storageState.levels))
+	for _, level := range storageState.levels {
+		levelCopy := *level
+		levels = append(levels, &levelCopy)
+	}
 	return StorageStateSnapshot{
 		L0SSTableIds: storageState.orderedLevel0SSTableIds(),
-		Levels:       storageState.levels,
+		Levels:       levels,
 		SSTables:     storageState.ssTables,
 	}
 }
 
+// VerifyChecksums verifies the checksums of all the live SSTables and the WALs of the current and immutable memtables.
+// The SSTables are referenced for the duration of the verification, so that compaction does not remove them.
+// It returns all the checksum.CorruptionError(s) joined using errors.Join, or nil if no corruption is detected.
+func (storageState *StorageState) VerifyChecksums() error {
+	memtables, ssTables := func() ([]*memory.Memtable, []*table.SSTable) {
+		storageState.stateLock.RLock()
+		defer storageState.stateLock.RUnlock()
+
+		memtables := make([]*memory.Memtable, 0, len(storageState.immutableMemtables)+1)
+		memtables = append(memtables, storageState.immutableMemtables...)
+		memtables = append(memtables, storageState.currentMemtable)
+
+		ssTables := make([]*table.SSTable, 0, len(storageState.ssTables))
+		for _, ssTable := range storageState.ssTables {
+			ssTables = append(ssTables, ssTable)
+		}
+		sort.Slice(ssTables, func(i, j int) bool {
+			return ssTables[i].Id() < ssTables[j].Id()
+		})
+		table.IncrementReferenceFor(ssTables)
+		return memtables, ssTables
+	}()
+	defer table.DecrementReferenceFor(ssTables)
+
+	var errs []error
+	for _, memtable := range memtables {
+		if err := memtable.VerifyWALChecksums(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, ssTable := range ssTables {
+		if err := ssTable.VerifyChecksums(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Close closes the StorageState.
 func (storageState *StorageState) Close() {
 	close(storageState.closeChannel)
+	if storageState.options.ReadOnly {
+		return
+	}
 	//Wait for flush immutable tables goroutine to return
 	<-storageState.flushMemtableCompletionChannel
 	//Wait for ssTableCleaner to return
@@ -300,13 +804,14 @@ func (storageState *StorageState) forceFlushNextImmutableMemtable() error {
 		return memtable
 	}
 	buildSSTable := func(memtableToFlush *memory.Memtable) (*table.SSTable, error) {
-		ssTableBuilder := table.NewSSTableBuilderWithDefaultBlockSize()
+		ssTableBuilder := table.NewSSTableBuilderWithOptions(storageState.options.SSTableBuilderOptionsAt(0))
 		memtableToFlush.AllEntries(func(key kv.Key, value kv.Value) {
 			ssTableBuilder.Add(key, value)
 		})
-		ssTable, err := ssTableBuilder.Build(
+		ssTable, err := ssTableBuilder.BuildWithReadOptions(
 			memtableToFlush.Id(),
 			storageState.options.Path,
+			storageState.options.SSTableReadOptions(),
 		)
 		if err != nil {
 			return nil, err
@@ -354,7 +859,8 @@ func (storageState *StorageState) mayBeFreezeCurrentMemtable(requiredSizeInBytes
 // l0SSTableIterators returns all a slice of iterator.Iterator from level0 table.SSTable(s), along with a slice of
 // all the table.SSTable(s) in use.
 // Iterators are created from the latest memtable to the oldest (from index = len(storageState.l0SSTableIds) to index = 0).
-func (storageState *StorageState) l0SSTableIterators(seekTo kv.Key, ssTableSelector func(ssTable *table.SSTable) bool) ([]iterator.Iterator, []*table.SSTable) {
+// If seeking any SSTable fails, the iterators which are already created are closed and the error is returned.
+func (storageState *StorageState) l0SSTableIterators(seekTo kv.Key, ssTableSelector func(ssTable *table.SSTable) bool) ([]iterator.Iterator, []*table.SSTable, error) {
 	iterators := make([]iterator.Iterator, len(storageState.l0SSTableIds))
 	index := 0
 
@@ -364,36 +870,49 @@ func (storageState *StorageState) l0SSTableIterators(seekTo kv.Key, ssTableSelec
 		if ssTableSelector(ssTable) {
 			ssTableIterator, err := ssTable.SeekToKey(seekTo)
 			if err != nil {
-				return nil, nil
+				closeSSTableIterators(iterators[:index], ssTablesInUse)
+				return nil, nil, err
 			}
 			ssTablesInUse = append(ssTablesInUse, ssTable)
 			iterators[index] = ssTableIterator
 			index += 1
 		}
 	}
-	return iterators, ssTablesInUse
+	return iterators[:index], ssTablesInUse, nil
 }
 
 // otherLevelSSTableIterators returns all a slice of iterator.Iterator from table.SSTable(s) present in every level other than level0,
 // along with a slice of all the table.SSTable(s) in use.
-func (storageState *StorageState) otherLevelSSTableIterators(seekTo kv.Key, ssTableSelector func(ssTable *table.SSTable) bool) ([]iterator.Iterator, []*table.SSTable) {
+// Only the SSTables whose key ranges overlap the inclusiveRange are considered (refer to Level.OverlappingSSTableIds).
+// If seeking any SSTable fails, the iterators which are already created are closed and the error is returned.
+func (storageState *StorageState) otherLevelSSTableIterators(inclusiveRange kv.InclusiveKeyRange[kv.Key], ssTableSelector func(ssTable *table.SSTable) bool) ([]iterator.Iterator, []*table.SSTable, error) {
 	var ssTablesInUse []*table.SSTable
 	var iterators []iterator.Iterator
 
 	for _, level := range storageState.levels {
-		for _, ssTableId := range level.SSTableIds {
+		for _, ssTableId := range level.OverlappingSSTableIds(inclusiveRange) {
 			ssTable := storageState.ssTables[ssTableId]
 			if ssTableSelector(ssTable) {
-				ssTableIterator, err := ssTable.SeekToKey(seekTo)
+				ssTableIterator, err := ssTable.SeekToKey(inclusiveRange.Start())
 				if err != nil {
-					return nil, nil
+					closeSSTableIterators(iterators, ssTablesInUse)
+					return nil, nil, err
 				}
 				ssTablesInUse = append(ssTablesInUse, ssTable)
 				iterators = append(iterators, ssTableIterator)
 			}
 		}
 	}
-	return iterators, ssTablesInUse
+	return iterators, ssTablesInUse, nil
+}
+
+// closeSSTableIterators closes the iterators (releasing their pins in the cache.BlockCache and the TableCache) and
+// decrements the references of the ssTablesInUse, it is used when Scan fails after creating some of the SSTable iterators.
+func closeSSTableIterators(iterators []iterator.Iterator, ssTablesInUse []*table.SSTable) {
+	for _, ssTableIterator := range iterators {
+		ssTableIterator.Close()
+	}
+	table.DecrementReferenceFor(ssTablesInUse)
 }
 
 // spawnMemtableFlush creates a goroutine which flushes the oldest immutable to level0 table.SSTable, if the number of
@@ -431,6 +950,10 @@ func (storageState *StorageState) spawnMemtableFlush() {
 // If the event is manifest.MemtableCreatedEventType -> it collects the id of the memtable.
 // If the event is manifest.SSTableFlushedEventType -> it removes the id from the collection of memtable, stores the id in l0SSTableIds field.
 // If the event is manifest.CompactionDoneEventType -> it creates StorageStateChangeEvent and applies it to the StorageState.
+// If the event is manifest.SSTablesMovedEventType -> it creates StorageStateChangeEvent (for the trivial move) of the input
+// SSTables (loading the level0 SSTables, which are not loaded yet) and applies it to the StorageState.
+// It finally creates a new current memtable and records manifest.MemtableCreatedEventType. In read-only mode, the current memtable
+// is created without WAL, and nothing is recorded in manifest.Manifest.
 func (storageState *StorageState) mayBeLoadExisting(events []manifest.Event) error {
 	if len(events) > 0 {
 		memtableIds := make(map[uint64]struct{})
@@ -451,12 +974,10 @@ func (storageState *StorageState) mayBeLoadExisting(events []manifest.Event) err
 					compactionDone.NewSSTableIds,
 					compactionDone.Description,
 					storageState.options.Path,
+					storageState.options.SSTableReadOptions(),
 				)
-				oldSSTableIds := compactionDone.Description.UpperLevelSSTableIds
-				oldSSTableIds = append(oldSSTableIds, compactionDone.Description.LowerLevelSSTableIds...)
-
-				for _, ssTableId := range oldSSTableIds {
-					ssTable, err := table.Load(ssTableId, storageState.options.Path, block.DefaultBlockSize)
+				for _, ssTableId := range compactionDone.Description.AllSSTableIds() {
+					ssTable, err := table.LoadWithReadOptions(ssTableId, storageState.options.Path, storageState.options.SSTableReadOptions())
 					if err == nil {
 						storageState.ssTables[ssTable.Id()] = ssTable
 					}
@@ -468,6 +989,22 @@ func (storageState *StorageState) mayBeLoadExisting(events []manifest.Event) err
 					return err
 				}
 				storageState.idGenerator.setIdIfGreaterThanExisting(storageChangeEvent.MaxSSTableId())
+			case manifest.SSTablesMovedEventType:
+				ssTablesMoved := event.(*manifest.SSTablesMoved)
+				var ssTables []*table.SSTable
+				for _, ssTableId := range ssTablesMoved.Description.AllSSTableIds() {
+					ssTable, ok := storageState.ssTables[ssTableId]
+					if !ok {
+						var err error
+						if ssTable, err = table.LoadWithReadOptions(ssTableId, storageState.options.Path, storageState.options.SSTableReadOptions()); err != nil {
+							return err
+						}
+					}
+					ssTables = append(ssTables, ssTable)
+				}
+				if err := storageState.Apply(NewStorageStateChangeEventForTrivialMove(ssTables, ssTablesMoved.Description), true); err != nil {
+					return err
+				}
 			}
 		}
 		if err := storageState.recoverL0SSTables(); err != nil {
@@ -477,6 +1014,13 @@ func (storageState *StorageState) mayBeLoadExisting(events []manifest.Event) err
 			return err
 		}
 	}
+	if storageState.options.ReadOnly {
+		storageState.currentMemtable = memory.NewMemtableWithoutWAL(
+			storageState.idGenerator.NextId(),
+			storageState.options.MemTableSizeInBytes,
+		)
+		return nil
+	}
 	storageState.currentMemtable = memory.NewMemtable(
 		storageState.idGenerator.NextId(),
 		storageState.options.MemTableSizeInBytes,
@@ -518,9 +1062,14 @@ func (storageState *StorageState) recoverMemtables(memtableIds map[uint64]struct
 // recoverL0SSTables recovers all the level0 SSTables.
 // Loading an instance of table.SSTable is all about creating an in-memory representation of table.SSTable with a pointer to the
 // actual file which contains the data.
+// The level0 SSTables already opened while replaying a CompactionDone event (with level0 as the output level) are not loaded
+// again, loading them again would leak the file handles of the SSTables opened during the replay.
 func (storageState *StorageState) recoverL0SSTables() error {
 	for _, ssTableId := range storageState.l0SSTableIds {
-		ssTable, err := table.Load(ssTableId, storageState.options.Path, block.DefaultBlockSize)
+		if _, ok := storageState.ssTables[ssTableId]; ok {
+			continue
+		}
+		ssTable, err := table.LoadWithReadOptions(ssTableId, storageState.options.Path, storageState.options.SSTableReadOptions())
 		if err != nil {
 			return err
 		}
@@ -532,33 +1081,58 @@ func (storageState *StorageState) recoverL0SSTables() error {
 // apply applies the StorageStateChangeEvent to the StorageState.
 // It involves the following:
 // 1) Getting an exclusive lock.
-// 2) Setting the mapping between ssTableId and the corresponding ssTable.
-// 3) Identifying all the ssTableIds to be removed.
-// 4) Updating either l0SSTableIds or the level field.
-// 5) Deleting the mapping from ssTables fields for the ssTableIds to be removed.
-func (storageState *StorageState) apply(event StorageStateChangeEvent) []*table.SSTable {
+// 2) Removing the input ssTableIds of every level in meta.CompactionDescription, and adding the new ssTables to the output level.
+// Level0 can be the output level (refer to compact.TieredCompaction), the new ssTables then take the position of the
+// level0 input ssTables (refer to StorageStateChangeEvent.level0SSTableIdsAfterCompaction).
+// 3) Setting the mapping between ssTableId and the corresponding ssTable.
+// 4) Updating l0SSTableIds and the changed levels.
+// 5) Deleting the mapping from ssTables fields for the input ssTableIds, which are to be removed. A trivial move does not
+// remove any SSTable, its new ssTables are the input ssTables.
+// The levels are changed on copies, which are validated for the non-overlapping invariant (refer to Level.addSSTables)
+// before anything in the StorageState is changed.
+func (storageState *StorageState) apply(event StorageStateChangeEvent) ([]*table.SSTable, error) {
 	storageState.stateLock.Lock()
 	defer storageState.stateLock.Unlock()
 
 	type SSTablesToRemove = []*table.SSTable
+	description := event.CompactionDescription()
+	if description.OutputLevel < 0 || description.OutputLevel > len(storageState.levels) {
+		return nil, fmt.Errorf("unsupported compaction output level %d", description.OutputLevel)
+	}
+	changedLevels := make(map[int]*Level)
+	changedLevel := func(levelNumber int) *Level {
+		if level, ok := changedLevels[levelNumber]; ok {
+			return level
+		}
+		level := *storageState.levels[levelNumber-1]
+		changedLevels[levelNumber] = &level
+		return &level
+	}
+	for _, input := range description.Inputs {
+		if input.Level < 0 || input.Level > len(storageState.levels) {
+			return nil, fmt.Errorf("unsupported compaction input level %d", input.Level)
+		}
+		if input.Level != 0 {
+			changedLevel(input.Level).removeSSTableIds(input.SSTableIds)
+		}
+	}
+	if description.OutputLevel != 0 {
+		if err := changedLevel(description.OutputLevel).addSSTables(event.NewSSTables); err != nil {
+			return nil, err
+		}
+	}
+
 	setSSTableMapping := func() {
 		for _, ssTable := range event.NewSSTables {
 			storageState.ssTables[ssTable.Id()] = ssTable
 		}
 	}
 	updateLevels := func() []uint64 {
-		var ssTableIdsToRemove []uint64
-		if event.CompactionUpperLevel() == -1 {
-			ssTableIdsToRemove = append(ssTableIdsToRemove, event.CompactionUpperLevelSSTableIds()...)
-			storageState.l0SSTableIds = event.allSSTableIdsExcludingTheOnesPresentInUpperLevelSSTableIds(storageState.l0SSTableIds)
-		} else {
-			ssTableIdsToRemove = append(ssTableIdsToRemove, storageState.levels[event.CompactionUpperLevel()-1].SSTableIds...)
-			storageState.levels[event.CompactionUpperLevel()-1].clearSSTableIds()
+		storageState.l0SSTableIds = event.level0SSTableIdsAfterCompaction(storageState.l0SSTableIds)
+		for levelNumber, level := range changedLevels {
+			storageState.levels[levelNumber-1] = level
 		}
-		ssTableIdsToRemove = append(ssTableIdsToRemove, event.CompactionLowerLevelSSTableIds()...)
-		storageState.levels[event.CompactionLowerLevel()-1].appendSSTableIds(event.NewSSTableIds)
-
-		return ssTableIdsToRemove
+		return description.AllSSTableIds()
 	}
 	unsetSSTableMapping := func(ssTableIdsToRemove []uint64) SSTablesToRemove {
 		var ssTables = make(SSTablesToRemove, 0, len(ssTableIdsToRemove))
@@ -572,7 +1146,30 @@ func (storageState *StorageState) apply(event StorageStateChangeEvent) []*table.
 		return ssTables
 	}
 	setSSTableMapping()
-	return unsetSSTableMapping(updateLevels())
+	if event.IsTrivialMove() {
+		updateLevels()
+		return nil, nil
+	}
+	return unsetSSTableMapping(updateLevels()), nil
+}
+
+// openManifest opens the manifest.Manifest and returns all the recovered events.
+// In read-only mode, the events are recovered without opening (or creating) the manifest file for writes,
+// and no instance of manifest.Manifest is returned.
+func openManifest(options StorageOptions) (*manifest.Manifest, []manifest.Event, error) {
+	if options.ReadOnly {
+		events, err := manifest.RecoverReadonlyFrom(options.Path)
+		return nil, events, err
+	}
+	return manifest.CreateNewOrRecoverFrom(options.Path)
+}
+
+// walPath returns the log.WALPath, it does not create the WAL directory in read-only mode.
+func walPath(options StorageOptions) log.WALPath {
+	if options.ReadOnly {
+		return log.NewReadonlyWALPath(options.Path)
+	}
+	return log.NewWALPath(options.Path)
 }
 
 // orderedLevel0SSTableIds returns a slice of level0 SSTableIds from latest to the oldest level0 SSTable.
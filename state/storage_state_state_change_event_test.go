@@ -49,11 +49,12 @@ func TestApplyStorageStateChangeEventWhichCompactsAllTheTablesAtLevel0(t *testin
 	newSSTable := buildNewSSTable(storageState.SSTableIdGenerator().NextId())
 
 	event := StorageStateChangeEvent{
-		description: meta.SimpleLeveledCompactionDescription{
-			UpperLevel:           -1,
-			UpperLevelSSTableIds: []uint64{ssTable.Id(), anotherSSTable.Id()},
-			LowerLevel:           1,
-			LowerLevelSSTableIds: []uint64{},
+		description: meta.CompactionDescription{
+			Inputs: []meta.CompactionInput{
+				{Level: 0, SSTableIds: []uint64{ssTable.Id(), anotherSSTable.Id()}},
+				{Level: 1, SSTableIds: []uint64{}},
+			},
+			OutputLevel: 1,
 		},
 		NewSSTables:   []*table.SSTable{newSSTable},
 		NewSSTableIds: []uint64{newSSTable.Id()},
@@ -104,11 +105,12 @@ func TestApplyStorageStateChangeEventWhichCompactsAllTheTablesAtLevel0ButAnother
 	newSSTable := buildNewSSTable(storageState.SSTableIdGenerator().NextId())
 
 	event := StorageStateChangeEvent{
-		description: meta.SimpleLeveledCompactionDescription{
-			UpperLevel:           -1,
-			UpperLevelSSTableIds: []uint64{ssTable.Id()},
-			LowerLevel:           1,
-			LowerLevelSSTableIds: []uint64{},
+		description: meta.CompactionDescription{
+			Inputs: []meta.CompactionInput{
+				{Level: 0, SSTableIds: []uint64{ssTable.Id()}},
+				{Level: 1, SSTableIds: []uint64{}},
+			},
+			OutputLevel: 1,
 		},
 		NewSSTables:   []*table.SSTable{newSSTable},
 		NewSSTableIds: []uint64{newSSTable.Id()},
@@ -160,11 +162,12 @@ func TestApplyStorageStateChangeEventWhichCompactsAllTheTablesAtLevel1(t *testin
 	newSSTable := buildNewSSTable(storageState.SSTableIdGenerator().NextId())
 
 	event := StorageStateChangeEvent{
-		description: meta.SimpleLeveledCompactionDescription{
-			UpperLevel:           1,
-			UpperLevelSSTableIds: []uint64{ssTable.Id(), anotherSSTable.Id()},
-			LowerLevel:           2,
-			LowerLevelSSTableIds: []uint64{},
+		description: meta.CompactionDescription{
+			Inputs: []meta.CompactionInput{
+				{Level: 1, SSTableIds: []uint64{ssTable.Id(), anotherSSTable.Id()}},
+				{Level: 2, SSTableIds: []uint64{}},
+			},
+			OutputLevel: 2,
 		},
 		NewSSTables:   []*table.SSTable{newSSTable},
 		NewSSTableIds: []uint64{newSSTable.Id()},
@@ -179,3 +182,142 @@ func TestApplyStorageStateChangeEventWhichCompactsAllTheTablesAtLevel1(t *testin
 	assert.Equal(t, 0, len(storageState.levels[level1-1].SSTableIds))
 	assert.Equal(t, newSSTable.Id(), storageState.levels[level2-1].SSTableIds[0])
 }
+
+func TestApplyStorageStateChangeEventWhichCompactsTheTablesAtLevel1AndLevel2(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTable := func(id uint64) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+		return ssTable
+	}
+
+	upperLevelSSTable := buildSSTable(storageState.SSTableIdGenerator().NextId())
+	lowerLevelSSTable := buildSSTable(storageState.SSTableIdGenerator().NextId())
+	newSSTable := buildSSTable(storageState.SSTableIdGenerator().NextId())
+	storageState.SetSSTableAtLevel(upperLevelSSTable, level1)
+	storageState.SetSSTableAtLevel(lowerLevelSSTable, level2)
+
+	event := StorageStateChangeEvent{
+		description: meta.CompactionDescription{
+			Inputs: []meta.CompactionInput{
+				{Level: 1, SSTableIds: []uint64{upperLevelSSTable.Id()}},
+				{Level: 2, SSTableIds: []uint64{lowerLevelSSTable.Id()}},
+			},
+			OutputLevel: 2,
+		},
+		NewSSTables:   []*table.SSTable{newSSTable},
+		NewSSTableIds: []uint64{newSSTable.Id()},
+	}
+	err := storageState.Apply(event, false)
+
+	assert.Nil(t, err)
+	assert.False(t, storageState.hasSSTableWithId(upperLevelSSTable.Id()))
+	assert.False(t, storageState.hasSSTableWithId(lowerLevelSSTable.Id()))
+	assert.Equal(t, 0, len(storageState.levels[level1-1].SSTableIds))
+	assert.Equal(t, []uint64{newSSTable.Id()}, storageState.levels[level2-1].SSTableIds)
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("paxos"), value)
+}
+
+func TestApplyStorageStateChangeEventWhichOverlapsAnSSTableAtTheLowerLevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTable := func(id uint64, startingKey, endingKey string) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(startingKey, 6), kv.NewStringValue("paxos"))
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(endingKey, 6), kv.NewStringValue("raft"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+		return ssTable
+	}
+
+	l0SSTable := buildSSTable(storageState.SSTableIdGenerator().NextId(), "consensus", "etcd")
+	lowerLevelSSTable := buildSSTable(storageState.SSTableIdGenerator().NextId(), "distributed", "storage")
+	newSSTable := buildSSTable(storageState.SSTableIdGenerator().NextId(), "consensus", "etcd")
+	storageState.SetSSTableAtLevel(l0SSTable, level0)
+	storageState.SetSSTableAtLevel(lowerLevelSSTable, level1)
+
+	event := StorageStateChangeEvent{
+		description: meta.CompactionDescription{
+			Inputs: []meta.CompactionInput{
+				{Level: 0, SSTableIds: []uint64{l0SSTable.Id()}},
+				{Level: 1, SSTableIds: []uint64{}},
+			},
+			OutputLevel: 1,
+		},
+		NewSSTables:   []*table.SSTable{newSSTable},
+		NewSSTableIds: []uint64{newSSTable.Id()},
+	}
+	err := storageState.Apply(event, false)
+
+	assert.ErrorIs(t, err, ErrOverlappingSSTables)
+	assert.True(t, storageState.hasSSTableWithId(l0SSTable.Id()))
+	assert.False(t, storageState.hasSSTableWithId(newSSTable.Id()))
+	assert.Equal(t, []uint64{l0SSTable.Id()}, storageState.l0SSTableIds)
+	assert.Equal(t, []uint64{lowerLevelSSTable.Id()}, storageState.levels[level1-1].SSTableIds)
+	_ = newSSTable.Close()
+}
+
+func TestApplyStorageStateChangeEventWhichMovesTheTablesAtLevel0ToLevel1(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildL0SSTable := func(id uint64, key string) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(key, 6), kv.NewStringValue("paxos"))
+		ssTable, err := ssTableBuilder.Build(id, rootPath)
+		assert.Nil(t, err)
+
+		storageState.l0SSTableIds = append(storageState.l0SSTableIds, id)
+		storageState.ssTables[id] = ssTable
+
+		return ssTable
+	}
+
+	ssTable := buildL0SSTable(storageState.SSTableIdGenerator().NextId(), "distributed")
+	anotherSSTable := buildL0SSTable(storageState.SSTableIdGenerator().NextId(), "consensus")
+
+	event := NewStorageStateChangeEventForTrivialMove([]*table.SSTable{anotherSSTable, ssTable}, meta.CompactionDescription{
+		Inputs: []meta.CompactionInput{
+			{Level: 0, SSTableIds: []uint64{anotherSSTable.Id(), ssTable.Id()}},
+		},
+		OutputLevel: 1,
+	})
+	assert.True(t, event.IsTrivialMove())
+
+	err := storageState.Apply(event, false)
+
+	assert.Nil(t, err)
+	assert.True(t, storageState.hasSSTableWithId(ssTable.Id()))
+	assert.True(t, storageState.hasSSTableWithId(anotherSSTable.Id()))
+	assert.Equal(t, 0, len(storageState.l0SSTableIds))
+	assert.Equal(t, []uint64{anotherSSTable.Id(), ssTable.Id()}, storageState.levels[level1-1].SSTableIds)
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("paxos"), value)
+}
@@ -2,8 +2,12 @@ package state
 
 import (
 	"errors"
+	"go-lsm/checksum"
 	"go-lsm/kv"
 	"go-lsm/table"
+	"go-lsm/table/block"
+	"go-lsm/table/bloom"
+	"go-lsm/table/compress"
 	"go-lsm/test_utility"
 	"os"
 	"path/filepath"
@@ -22,15 +26,13 @@ func testStorageStateOptionsWithMemTableSizeAndDirectory(memtableSizeInBytes int
 	}
 }
 
-func testStorageStateOptionsWithDirectoryAndCompactionOptions(directory string, compactionStrategyOptions SimpleLeveledCompactionOptions) StorageOptions {
+func testStorageStateOptionsWithDirectoryAndCompactionOptions(directory string, compactionOptions CompactionOptions) StorageOptions {
 	return StorageOptions{
 		MemTableSizeInBytes:   1 << 10,
 		Path:                  directory,
 		MaximumMemtables:      10,
 		FlushMemtableDuration: 1 * time.Minute,
-		CompactionOptions: CompactionOptions{
-			StrategyOptions: compactionStrategyOptions,
-		},
+		CompactionOptions:     compactionOptions,
 	}
 }
 
@@ -64,7 +66,8 @@ func TestStorageStateWithASinglePutAndGet(t *testing.T) {
 
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 10)))
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("raft"), value)
 }
@@ -90,15 +93,18 @@ func TestStorageStateWithAMultiplePutsAndGets(t *testing.T) {
 	_ = batch.Put([]byte("data-structure"), []byte("LSM"))
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 6))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 6))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("raft"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("storage", 8))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("storage", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("NVMe"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 9))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 9))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("LSM"), value)
 }
@@ -123,15 +129,18 @@ func TestStorageStateWithSSTablesOnlyAtLevel0(t *testing.T) {
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 10))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 10))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("bbolt"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("paxos"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 12))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 12))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("TiKV"), value)
 }
@@ -168,15 +177,18 @@ func TestStorageStateWithAMultiplePutsAndGetsUsingMemtablesAndSSTables1(t *testi
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("bbolt"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("raft"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("TiKV"), value)
 }
@@ -213,7 +225,8 @@ func TestStorageStateWithAMultiplePutsAndGetsUsingMemtablesAndSSTables2(t *testi
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 10))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 10))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("LSM"), value)
 }
@@ -250,7 +263,8 @@ func TestStorageStateWithAMultiplePutsAndGetsUsingMemtablesAndSSTables3(t *testi
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("paxos", 10))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("paxos", 10))
+	assert.Nil(t, err)
 	assert.False(t, ok)
 	assert.Equal(t, kv.EmptyValue, value)
 }
@@ -274,15 +288,18 @@ func TestStorageStateWithAMultiplePutsAndGetsUsingOnlySSTablesAtLevel1(t *testin
 
 	storageState.SetSSTableAtLevel(ssTable, level1)
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("bbolt"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("paxos"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("TiKV"), value)
 }
@@ -314,15 +331,18 @@ func TestStorageStateWithAMultiplePutsAndGetsUsingOnlySSTablesAtLevel0andLevel1(
 
 	storageState.SetSSTableAtLevel(ssTable, level1)
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("bbolt"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("paxos"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("TiKV"), value)
 }
@@ -353,15 +373,18 @@ func TestStorageStateWithAMultiplePutsAndGetsUsingOnlySSTablesAtLevel1andLevel2(
 
 	storageState.SetSSTableAtLevel(ssTable, level2)
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("KV"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 9))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("paxos"), value)
 
-	value, ok = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("TiKV"), value)
 }
@@ -383,10 +406,106 @@ func TestStorageStateWithASinglePutAndDelete(t *testing.T) {
 	batch.Delete([]byte("consensus"))
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
+
+	assert.False(t, ok)
+	assert.Equal(t, kv.EmptyValue, value)
+}
+
+func TestStorageStateWithAPutAndDeleteFlushedToSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	batch.Delete([]byte("consensus"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	storageState.forceFreezeCurrentMemtable()
+	assert.Nil(t, storageState.forceFlushNextImmutableMemtable())
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, kv.EmptyValue, value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 8))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
+
+func TestStorageStateWithADeleteInTheCurrentMemtableAndAnOlderVersionInSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	storageState.forceFreezeCurrentMemtable()
+	assert.Nil(t, storageState.forceFlushNextImmutableMemtable())
+
+	batch = kv.NewBatch()
+	batch.Delete([]byte("consensus"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
 
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
 	assert.False(t, ok)
 	assert.Equal(t, kv.EmptyValue, value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 8))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
+
+func TestStorageStateGetWithADeleteInLevel0AndAnOlderVersionInLevel1(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithDirectoryAndCompactionOptions(rootPath, CompactionOptions{
+		MaxLevels: 2,
+	}))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 8), kv.NewStringValue("raft"))
+	ssTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+	assert.Nil(t, err)
+	storageState.SetSSTableAtLevel(ssTable, 1)
+
+	ssTableBuilder = table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 9), kv.EmptyValue)
+	ssTable, err = ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+	assert.Nil(t, err)
+	storageState.SetSSTableAtLevel(ssTable, 0)
+
+	_, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 8))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
 }
 
 func TestStorageStateWithAMultiplePutsInvolvingFreezeOfCurrentMemtable(t *testing.T) {
@@ -440,7 +559,8 @@ func TestStorageStateWithAMultiplePutsAndGetsInvolvingFreezeOfCurrentMemtable(t
 	_ = batch.Put([]byte("data-structure"), []byte("B+Tree"))
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
 
-	value, ok := storageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 10))
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 10))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.True(t, storageState.HasImmutableMemtables())
 	assert.Equal(t, kv.NewStringValue("B+Tree"), value)
@@ -467,7 +587,8 @@ func TestStorageStateScanWithMemtable(t *testing.T) {
 	_ = batch.Put([]byte("data-structure"), []byte("LSM"))
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
 
-	iterator := storageState.Scan(kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("accurate", 10), kv.NewStringKeyWithTimestamp("etcd", 10)))
+	iterator, err := storageState.Scan(kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("accurate", 10), kv.NewStringKeyWithTimestamp("etcd", 10)))
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.True(t, iterator.IsValid())
@@ -508,9 +629,10 @@ func TestStorageStateScanWithMultipleIteratorsAndMemtableOnly(t *testing.T) {
 	_ = batch.Put([]byte("data-structure"), []byte("LSM"))
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
 
-	iterator := storageState.Scan(kv.NewInclusiveKeyRange(
+	iterator, err := storageState.Scan(kv.NewInclusiveKeyRange(
 		kv.NewStringKeyWithTimestamp("accurate", 10), kv.NewStringKeyWithTimestamp("etcd", 10)),
 	)
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.True(t, iterator.IsValid())
@@ -565,9 +687,10 @@ func TestStorageStateScanWithImmutableMemtablesAndSSTables1(t *testing.T) {
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("consensus", 14), kv.NewStringKeyWithTimestamp("distributed", 14)),
 	)
+	assert.Nil(t, err)
 	iterator.Close()
 
 	assert.True(t, iterator.IsValid())
@@ -622,9 +745,10 @@ func TestStorageStateScanWithImmutableMemtablesAndSSTables2(t *testing.T) {
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("distributed", 23), kv.NewStringKeyWithTimestamp("etcd", 23)),
 	)
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.True(t, iterator.IsValid())
@@ -673,9 +797,10 @@ func TestStorageStateScanWithImmutableMemtablesAndSSTables3(t *testing.T) {
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("consensus", 11), kv.NewStringKeyWithTimestamp("elegant", 11)),
 	)
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.True(t, iterator.IsValid())
@@ -731,9 +856,10 @@ func TestStorageStateScanWithImmutableMemtablesAndSSTables4(t *testing.T) {
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("paxos", 11), kv.NewStringKeyWithTimestamp("quotient", 11)),
 	)
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.False(t, iterator.IsValid())
@@ -741,10 +867,12 @@ func TestStorageStateScanWithImmutableMemtablesAndSSTables4(t *testing.T) {
 
 func TestStorageStateScanWithSSTablesAtLevel1AndLevel2(t *testing.T) {
 	rootPath := test_utility.SetupADirectoryWithTestName(t)
-	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithDirectoryAndCompactionOptions(rootPath, SimpleLeveledCompactionOptions{
-		NumberOfSSTablesRatioPercentage: 200,
-		MaxLevels:                       4,
-		Level0FilesCompactionTrigger:    5,
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithDirectoryAndCompactionOptions(rootPath, CompactionOptions{
+		MaxLevels: 4,
+		StrategyOptions: SimpleLeveledCompactionOptions{
+			NumberOfSSTablesRatioPercentage: 200,
+			Level0FilesCompactionTrigger:    5,
+		},
 	}))
 
 	defer func() {
@@ -770,9 +898,10 @@ func TestStorageStateScanWithSSTablesAtLevel1AndLevel2(t *testing.T) {
 
 	storageState.SetSSTableAtLevel(ssTable, level2)
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("consensus", 11), kv.NewStringKeyWithTimestamp("quotient", 11)),
 	)
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.True(t, iterator.IsValid())
@@ -798,10 +927,12 @@ func TestStorageStateScanWithSSTablesAtLevel1AndLevel2(t *testing.T) {
 
 func TestStorageStateScanWithSSTablesAtLevel0AndLevel1(t *testing.T) {
 	rootPath := test_utility.SetupADirectoryWithTestName(t)
-	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithDirectoryAndCompactionOptions(rootPath, SimpleLeveledCompactionOptions{
-		NumberOfSSTablesRatioPercentage: 200,
-		MaxLevels:                       4,
-		Level0FilesCompactionTrigger:    5,
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithDirectoryAndCompactionOptions(rootPath, CompactionOptions{
+		MaxLevels: 4,
+		StrategyOptions: SimpleLeveledCompactionOptions{
+			NumberOfSSTablesRatioPercentage: 200,
+			Level0FilesCompactionTrigger:    5,
+		},
 	}))
 
 	defer func() {
@@ -836,9 +967,10 @@ func TestStorageStateScanWithSSTablesAtLevel0AndLevel1(t *testing.T) {
 
 	storageState.SetSSTableAtLevel(ssTable, level1)
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("consensus", 11), kv.NewStringKeyWithTimestamp("quotient", 11)),
 	)
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.True(t, iterator.IsValid())
@@ -888,9 +1020,10 @@ func TestStorageStateScanSSTableAndReferencesToSSTable(t *testing.T) {
 	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1)
 	storageState.ssTables[1] = ssTable
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("paxos", 11), kv.NewStringKeyWithTimestamp("quotient", 11)),
 	)
+	assert.Nil(t, err)
 	iterator.Close()
 
 	assert.Equal(t, int64(0), ssTable.TotalReferences())
@@ -919,9 +1052,10 @@ func TestStorageStateScanWithMultipleInvalidIterators(t *testing.T) {
 	_ = batch.Put([]byte("data-structure"), []byte("LSM"))
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
 
-	iterator := storageState.Scan(
+	iterator, err := storageState.Scan(
 		kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("zen", 10), kv.NewStringKeyWithTimestamp("zen", 10)),
 	)
+	assert.Nil(t, err)
 	defer iterator.Close()
 
 	assert.False(t, iterator.IsValid())
@@ -1010,7 +1144,7 @@ func TestStorageStateWithForceFlushNextImmutableMemtableAndReadFromSSTable(t *te
 	err := storageState.forceFlushNextImmutableMemtable()
 	assert.Nil(t, err)
 
-	ssTable, err := table.Load(1, rootPath, 4096)
+	ssTable, err := table.Load(1, rootPath)
 	assert.Nil(t, err)
 
 	iterator, err := ssTable.SeekToFirst()
@@ -1054,7 +1188,7 @@ func TestStorageStateWithForceFlushNextImmutableMemtableAndReadFromSSTableAtFixe
 
 	time.Sleep(100 * time.Millisecond)
 
-	ssTable, err := table.Load(1, rootPath, 4096)
+	ssTable, err := table.Load(1, rootPath)
 	assert.Nil(t, err)
 
 	iterator, err := ssTable.SeekToFirst()
@@ -1068,3 +1202,639 @@ func TestStorageStateWithForceFlushNextImmutableMemtableAndReadFromSSTableAtFixe
 	_ = iterator.Next()
 	assert.False(t, iterator.IsValid())
 }
+
+func TestCompressionOptionsCodecTypeAtLevel(t *testing.T) {
+	compressionOptions := CompressionOptions{
+		Codec: compress.Snappy,
+		CodecPerLevel: map[int]compress.CodecType{
+			0: compress.None,
+			3: compress.Zlib,
+		},
+	}
+	assert.Equal(t, compress.None, compressionOptions.CodecTypeAt(0))
+	assert.Equal(t, compress.Snappy, compressionOptions.CodecTypeAt(1))
+	assert.Equal(t, compress.Snappy, compressionOptions.CodecTypeAt(2))
+	assert.Equal(t, compress.Zlib, compressionOptions.CodecTypeAt(3))
+}
+
+func TestStorageStateWithUnsupportedCompressionCodec(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.CompressionOptions = CompressionOptions{
+		Codec:         compress.Snappy,
+		CodecPerLevel: map[int]compress.CodecType{1: compress.CodecType(100)},
+	}
+	_, err := NewStorageStateWithOptions(storageOptions)
+	assert.Error(t, err)
+}
+
+func TestStorageStateWithForceFlushNextImmutableMemtableWithCompressionAndReadFromSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(50, rootPath)
+	storageOptions.CompressionOptions = CompressionOptions{
+		CodecPerLevel: map[int]compress.CodecType{0: compress.Flate},
+	}
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+
+	//a single block of 4Kb with a single key/value pair is compressed to a few bytes.
+	stat, err := os.Stat(table.SSTableFilePath(1, rootPath))
+	assert.Nil(t, err)
+	assert.True(t, stat.Size() < 1024)
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
+
+func TestStorageStateWithBlockCacheAndReadFromSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(50, rootPath)
+	storageOptions.BlockCacheSizeInBytes = 1 << 20
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, kv.NewStringValue("raft"), value)
+	}
+
+	stats, ok := storageState.BlockCacheStats()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Hits)
+}
+
+func TestStorageStateWithoutBlockCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	_, ok := storageState.BlockCacheStats()
+	assert.False(t, ok)
+}
+
+func TestStorageStateWithMemoryMappedSSTablesAndReadFromSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(50, rootPath)
+	storageOptions.MemoryMappedSSTables = true
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
+
+func TestStorageStateWithMaxOpenSSTableFilesAndReadFromSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(50, rootPath)
+	storageOptions.MaxOpenSSTableFiles = 1
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("diskType"), []byte("SSD"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 10)))
+
+	assert.Nil(t, storageState.forceFlushNextImmutableMemtable())
+	assert.Nil(t, storageState.forceFlushNextImmutableMemtable())
+	assert.Equal(t, 1, storageState.options.tableCache.OpenFiles())
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("storage", 11))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("NVMe"), value)
+	assert.Equal(t, 1, storageState.options.tableCache.OpenFiles())
+}
+
+func TestStorageStateLevelStats(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageStateWithOptions(testStorageStateOptionsWithMemTableSizeAndDirectory(50, rootPath))
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+
+	levelStats := storageState.LevelStats()
+	assert.Equal(t, len(storageState.levels)+1, len(levelStats))
+
+	level0Stats := levelStats[0]
+	assert.Equal(t, 0, level0Stats.Level)
+	assert.Equal(t, 1, level0Stats.NumberOfSSTables)
+	assert.True(t, level0Stats.SizeInBytes > 0)
+	assert.Equal(t, uint64(1), level0Stats.Properties.NumberOfEntries)
+	assert.Equal(t, uint64(8), level0Stats.Properties.MinimumTimestamp)
+	assert.Equal(t, uint64(8), level0Stats.Properties.MaximumTimestamp)
+
+	for _, stats := range levelStats[1:] {
+		assert.Equal(t, 0, stats.NumberOfSSTables)
+		assert.Equal(t, table.Properties{}, stats.Properties)
+	}
+}
+
+func TestBloomFilterOptionsFalsePositiveRateAtLevel(t *testing.T) {
+	bloomFilterOptions := BloomFilterOptions{
+		FalsePositiveRate: 0.001,
+		FalsePositiveRatePerLevel: map[int]float64{
+			0: 0.05,
+		},
+	}
+	assert.Equal(t, 0.05, bloomFilterOptions.FalsePositiveRateAt(0))
+	assert.Equal(t, 0.001, bloomFilterOptions.FalsePositiveRateAt(1))
+	assert.Equal(t, bloom.FalsePositiveRate, BloomFilterOptions{}.FalsePositiveRateAt(1))
+}
+
+func TestStorageStateWithInvalidBloomFalsePositiveRate(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.BloomFilterOptions = BloomFilterOptions{
+		FalsePositiveRatePerLevel: map[int]float64{1: 1.5},
+	}
+	_, err := NewStorageStateWithOptions(storageOptions)
+	assert.Error(t, err)
+}
+
+func TestStorageStateScanWithPrefixSkipsTheSSTablesWithoutThePrefix(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.BloomFilterOptions = BloomFilterOptions{
+		PrefixExtractor: bloom.NewFixedLengthPrefixExtractor(5),
+	}
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("apple:1"), []byte("fruit"))
+	_ = batch.Put([]byte("zebra:1"), []byte("animal"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+	storageState.forceFreezeCurrentMemtable()
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("mango:1"), []byte("fruit"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+	ssTable := storageState.ssTables[1]
+
+	iterator, err := storageState.Scan(kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("mango:0", 10), kv.NewStringKeyWithTimestamp("mango:9", 10)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), ssTable.TotalReferences())
+
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("mango:1", 9), iterator.Key())
+	iterator.Close()
+
+	iterator, err = storageState.Scan(kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("apple:0", 10), kv.NewStringKeyWithTimestamp("apple:9", 10)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ssTable.TotalReferences())
+
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("apple:1", 8), iterator.Key())
+	iterator.Close()
+}
+
+func TestStorageStateWithUnsupportedBloomFilterType(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.BloomFilterOptions = BloomFilterOptions{FilterType: bloom.FilterType(10)}
+	_, err := NewStorageStateWithOptions(storageOptions)
+	assert.Error(t, err)
+}
+
+func TestStorageStateWithBlockedBloomFilterAndReadFromSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(50, rootPath)
+	storageOptions.BloomFilterOptions = BloomFilterOptions{FilterType: bloom.BlockedFilterType}
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+	assert.Equal(t, bloom.BlockedFilterType, storageState.ssTables[1].FilterType())
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
+
+func TestStorageOptionsBlockSizeAtLevel(t *testing.T) {
+	storageOptions := StorageOptions{
+		BlockSize:         8192,
+		BlockSizePerLevel: map[int]uint{0: 1024},
+	}
+	assert.Equal(t, uint(1024), storageOptions.BlockSizeAt(0))
+	assert.Equal(t, uint(8192), storageOptions.BlockSizeAt(1))
+	assert.Equal(t, block.DefaultBlockSize, StorageOptions{}.BlockSizeAt(1))
+}
+
+func TestStorageStateWithABlockSizeLargerThanTheMaximumBlockSize(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.BlockSizePerLevel = map[int]uint{2: block.MaxBlockSize + 1}
+	_, err := NewStorageStateWithOptions(storageOptions)
+	assert.Error(t, err)
+}
+
+func TestStorageStateWithBlockSizeAndReadFromSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(50, rootPath)
+	storageOptions.BlockSizePerLevel = map[int]uint{0: 1024}
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1024), storageState.ssTables[1].BlockSize())
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
+
+func TestStorageStateWithPartitionedIndexAndReadFromSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.BlockSize = 50
+	storageOptions.IndexPartitionSize = 40
+	storageOptions.BlockCacheSizeInBytes = 4096
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	_ = batch.Put([]byte("distributed"), []byte("etcd"))
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	storageState.forceFreezeCurrentMemtable()
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+	assert.Equal(t, table.PartitionedIndexType, storageState.ssTables[1].IndexType())
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("storage", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("NVMe"), value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+}
+
+func TestStorageStateWithDataBlockHashIndexAndReadFromSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.DataBlockHashIndex = true
+	storageState, _ := NewStorageStateWithOptions(storageOptions)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	_ = batch.Put([]byte("storage"), []byte("NVMe"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("paxos"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	storageState.forceFreezeCurrentMemtable()
+	err := storageState.forceFlushNextImmutableMemtable()
+	assert.Nil(t, err)
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("paxos"), value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 8))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	_, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestStorageStateRecoverL0SSTablesDoesNotLoadTheSSTablesAlreadyOpened(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("paxos"))
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	ssTableBuilder = table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("storage", 7), kv.NewStringValue("NVMe"))
+	_, err = ssTableBuilder.Build(2, rootPath)
+	assert.Nil(t, err)
+
+	storageState.l0SSTableIds = append(storageState.l0SSTableIds, 1, 2)
+	storageState.ssTables[1] = ssTable
+
+	assert.Nil(t, storageState.recoverL0SSTables())
+	assert.Same(t, ssTable, storageState.ssTables[1])
+	assert.True(t, storageState.hasSSTableWithId(2))
+}
+
+func TestStorageStateGetAndScanWithACorruptedSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	ssTableBuilder := table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("paxos"))
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	assert.Nil(t, ssTable.Close())
+
+	bytes, err := os.ReadFile(table.SSTableFilePath(1, rootPath))
+	assert.Nil(t, err)
+	bytes[1] = bytes[1] ^ 0x01
+	assert.Nil(t, os.WriteFile(table.SSTableFilePath(1, rootPath), bytes, 0666))
+
+	corruptedSSTable, err := table.Load(1, rootPath)
+	assert.Nil(t, err)
+
+	ssTableBuilder = table.NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 7), kv.NewStringValue("raft"))
+	ssTable, err = ssTableBuilder.Build(2, rootPath)
+	assert.Nil(t, err)
+
+	storageState.SetSSTableAtLevel(corruptedSSTable, 0)
+	storageState.SetSSTableAtLevel(ssTable, 0)
+
+	_, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 6))
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+
+	_, err = storageState.Scan(kv.NewInclusiveKeyRange(kv.NewStringKeyWithTimestamp("consensus", 10), kv.NewStringKeyWithTimestamp("consensus", 10)))
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+
+	references, _ := storageState.SSTableReferenceCountAtLevel(0)
+	assert.Equal(t, []int64{0, 0}, references)
+}
+
+func TestStorageStateGetFromNonOverlappingSSTablesOfALevel(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := NewStorageState(rootPath)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+	}()
+
+	buildSSTable := func(keyValues ...kv.Entry) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		for _, keyValue := range keyValues {
+			ssTableBuilder.Add(keyValue.Key, keyValue.Value)
+		}
+		ssTable, err := ssTableBuilder.Build(storageState.SSTableIdGenerator().NextId(), rootPath)
+		assert.Nil(t, err)
+		return ssTable
+	}
+	storageState.SetSSTableAtLevel(buildSSTable(
+		kv.Entry{Key: kv.NewStringKeyWithTimestamp("consensus", 5), Value: kv.NewStringValue("raft")},
+		kv.Entry{Key: kv.NewStringKeyWithTimestamp("distributed", 9), Value: kv.NewStringValue("TiKV")},
+	), 1)
+	storageState.SetSSTableAtLevel(buildSSTable(
+		kv.Entry{Key: kv.NewStringKeyWithTimestamp("distributed", 4), Value: kv.NewStringValue("etcd")},
+		kv.Entry{Key: kv.NewStringKeyWithTimestamp("storage", 5), Value: kv.NewStringValue("NVMe")},
+	), 1)
+	storageState.SetSSTableAtLevel(buildSSTable(
+		kv.Entry{Key: kv.NewStringKeyWithTimestamp("consensus", 8), Value: kv.NewStringValue("paxos")},
+	), 0)
+
+	value, ok, err := storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("paxos"), value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 6))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("TiKV"), value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("distributed", 5))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("etcd"), value)
+
+	value, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("storage", 10))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("NVMe"), value)
+
+	_, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("etcd", 10))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = storageState.Get(kv.NewStringKeyWithTimestamp("consensus", 4))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	references, _ := storageState.SSTableReferenceCountAtLevel(1)
+	assert.Equal(t, []int64{0, 0}, references)
+}
+
+func TestStorageStateWithUnsupportedCompactionStrategy(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.CompactionOptions.Strategy = CompactionStrategyType(10)
+	_, err := NewStorageStateWithOptions(storageOptions)
+	assert.Error(t, err)
+}
+
+func TestStorageStateWithFIFOCompactionStrategyWithoutAnyLimit(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	storageOptions := testStorageStateOptionsWithMemTableSizeAndDirectory(250, rootPath)
+	storageOptions.CompactionOptions.Strategy = FIFOCompactionStrategy
+	_, err := NewStorageStateWithOptions(storageOptions)
+	assert.Error(t, err)
+}
+
+func TestLeveledCompactionOptionsTargetSizeInBytesAtLevel(t *testing.T) {
+	options := LeveledCompactionOptions{BaseLevelSizeInBytes: 1 << 20, LevelSizeMultiplier: 8}
+	assert.Equal(t, int64(1<<20), options.TargetSizeInBytesAt(1))
+	assert.Equal(t, int64(8<<20), options.TargetSizeInBytesAt(2))
+	assert.Equal(t, int64(64<<20), options.TargetSizeInBytesAt(3))
+	assert.Equal(t, uint(DefaultLevel0FilesCompactionTrigger), options.Level0FilesTrigger())
+
+	defaultOptions := LeveledCompactionOptions{}
+	assert.Equal(t, DefaultBaseLevelSizeInBytes*DefaultLevelSizeMultiplier, defaultOptions.TargetSizeInBytesAt(2))
+}
+
+func TestCompactionOptionsRetryBackoffAfterFailures(t *testing.T) {
+	options := CompactionOptions{Duration: 10 * time.Millisecond, MaxRetryBackoff: 100 * time.Millisecond}
+	assert.Equal(t, 20*time.Millisecond, options.RetryBackoffAfter(1))
+	assert.Equal(t, 40*time.Millisecond, options.RetryBackoffAfter(2))
+	assert.Equal(t, 80*time.Millisecond, options.RetryBackoffAfter(3))
+	assert.Equal(t, 100*time.Millisecond, options.RetryBackoffAfter(4))
+	assert.Equal(t, 100*time.Millisecond, options.RetryBackoffAfter(64))
+	assert.Equal(t, uint(DefaultMaxCompactionFailures), options.MaxFailures())
+
+	defaultOptions := CompactionOptions{Duration: 40 * time.Second}
+	assert.Equal(t, DefaultMaxCompactionRetryBackoff, defaultOptions.RetryBackoffAfter(1))
+}
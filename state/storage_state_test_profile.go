@@ -20,9 +20,9 @@ func NewStorageState(rootPath string) (*StorageState, error) {
 		MaximumMemtables:      5,
 		FlushMemtableDuration: 50 * time.Millisecond,
 		CompactionOptions: CompactionOptions{
+			MaxLevels: totalLevels,
 			StrategyOptions: SimpleLeveledCompactionOptions{
 				Level0FilesCompactionTrigger:    6,
-				MaxLevels:                       totalLevels,
 				NumberOfSSTablesRatioPercentage: 200,
 			},
 		},
@@ -83,7 +83,9 @@ func (storageState *StorageState) SetSSTableAtLevel(ssTable *table.SSTable, leve
 		if existingLevel == nil {
 			existingLevel = &Level{LevelNumber: level}
 		}
-		existingLevel.SSTableIds = append(existingLevel.SSTableIds, ssTable.Id())
+		if err := existingLevel.addSSTables([]*table.SSTable{ssTable}); err != nil {
+			panic(err)
+		}
 		storageState.levels[level-1] = existingLevel
 	}
 	storageState.ssTables[ssTable.Id()] = ssTable
@@ -2,73 +2,139 @@ package block
 
 import (
 	"encoding/binary"
+	"fmt"
 	"go-lsm/kv"
 )
 
+// FormatVersion identifies the encoding of a Block.
+// The FormatVersion is not a part of the encoded Block, it is stored alongside the block by the table.SSTableBuilder.
+type FormatVersion uint8
+
+const (
+	// FormatVersionFullKeys stores every key/value pair with its full key, and the begin-offset of every key/value pair.
+	// It is the block format of the SSTables written before the SSTable footer (table.FooterFormatVersionWithoutFooter).
+	FormatVersionFullKeys FormatVersion = 1
+	// FormatVersionPrefixCompressedKeys stores every key as a (shared prefix length with the previous key, unshared suffix)
+	// pair, with a restart point (a key stored in full) every restartInterval keys.
+	FormatVersionPrefixCompressedKeys FormatVersion = 2
+	// FormatVersionPrefixCompressedKeysWithHashIndex is FormatVersionPrefixCompressedKeys followed by a hash index which maps
+	// the hashes of the raw keys to the restart points (refer to Block.SeekToKeyUsingHashIndex).
+	FormatVersionPrefixCompressedKeysWithHashIndex FormatVersion = 3
+	// LatestFormatVersion is the FormatVersion used by the Builder (without the hash index).
+	LatestFormatVersion = FormatVersionPrefixCompressedKeys
+)
+
 // Block represents the in-memory representation of Block.
 //
-// Each block contains encoded key/value pairs, and keyValueBeginOffsets. The reason for storing keyValueBeginOffsets is to allow
-// binary search for a key within a block.
+// Each block contains encoded key/value pairs, and restartOffsets.
+// A restart point is a key/value pair whose key is stored in full (without sharing a prefix with the previous key).
+// The reason for storing restartOffsets is to allow binary search for a key within a block: binary search runs over the
+// restart points, followed by a linear scan of at most restartInterval keys.
+// In FormatVersionFullKeys, every key/value pair is a restart point.
+// hashBuckets (optional) is the hash index of the block, which maps the hashes of the raw keys to the restart points.
 type Block struct {
-	data                 []byte
-	keyValueBeginOffsets []uint16
-	lastDataIndex        int
+	data           []byte
+	restartOffsets []uint16
+	hashBuckets    []uint16
+	formatVersion  FormatVersion
 }
 
 // newBlock creates a new instance of Block.
-// data is the encoded key/value pairs generated by block.Builder.
-func newBlock(data []byte, lastDataIndex int, keyValueBeginOffsets []uint16) Block {
+// data is the encoded key/value pairs generated by block.Builder, and hashBuckets is the (optional) hash index.
+func newBlock(data []byte, restartOffsets []uint16, hashBuckets []uint16) Block {
+	formatVersion := LatestFormatVersion
+	if hashBuckets != nil {
+		formatVersion = FormatVersionPrefixCompressedKeysWithHashIndex
+	}
 	return Block{
-		data:                 data,
-		keyValueBeginOffsets: keyValueBeginOffsets,
-		lastDataIndex:        lastDataIndex,
+		data:           data,
+		restartOffsets: restartOffsets,
+		hashBuckets:    hashBuckets,
+		formatVersion:  formatVersion,
 	}
 }
 
-// Encode encodes the block to byte slice.
+// Encode encodes the block to byte slice in the LatestFormatVersion, followed by the hash index
+// (in FormatVersionPrefixCompressedKeysWithHashIndex, refer to encodeHashBuckets).
 /*
-// blocking encoding looks like the following:
-  -------------------------------------------------------------------------------------------------------------------------------------------------
- | encoded key/value  | encoded key/value  |....| encoded key/value  | 0 | 48 | 120 | ...... |3088|      2 bytes          |          2 bytes       |
-  -------------------------------------------------------------------------------------------------------------------------------------------------
-  <--------------------------Encoded data---------------------------><-- Begin offsets of keys --><-- Start of offsets --><-Number of begin offsets->
+// block encoding looks like the following:
+  ------------------------------------------------------------------------------------------------------
+ | encoded key/value  | encoded key/value  |....| encoded key/value  | 0 | 270 | 531 | ... |   2 bytes   |
+  ------------------------------------------------------------------------------------------------------
+  <--------------------------Encoded data---------------------------><-- Restart offsets --><-Number of restart offsets->
 */
+// Each key/value pair is encoded as:
+/*
+  -----------------------------------------------------------------------------------------------------------------------
+ | 2 bytes shared key size | 2 bytes unshared key size | 2 bytes value size | unshared key bytes | value bytes |
+  -----------------------------------------------------------------------------------------------------------------------
+*/
+// The key is the encoded kv.Key (raw key followed by the timestamp), and the shared key size is the length of the prefix
+// it shares with the previous key in the block. The shared key size is always 0 for a restart point.
 func (block Block) Encode() []byte {
-	data := block.data
-	copy(data[block.lastDataIndex:], block.encodeKeyValueBeginOffsets())
+	buffer := make([]byte, 0, len(block.data)+Uint16Size*len(block.restartOffsets)+Uint16Size)
+	buffer = append(buffer, block.data...)
+	for _, offset := range block.restartOffsets {
+		buffer = binary.LittleEndian.AppendUint16(buffer, offset)
+	}
+	buffer = binary.LittleEndian.AppendUint16(buffer, uint16(len(block.restartOffsets)))
+	if block.hashBuckets != nil {
+		buffer = encodeHashBuckets(buffer, block.hashBuckets)
+	}
+	return buffer
+}
 
-	binary.LittleEndian.PutUint16(data[len(data)-Uint16Size:], uint16(len(block.keyValueBeginOffsets)))
-	binary.LittleEndian.PutUint16(data[len(data)-Uint16Size-Uint16Size:], uint16(block.lastDataIndex))
+// FormatVersion returns the FormatVersion of the block.
+func (block Block) FormatVersion() FormatVersion {
+	return block.formatVersion
+}
 
-	return data
+// SizeInBytes returns the (approximate) in-memory size of the block, it is used by the cache.BlockCache.
+func (block Block) SizeInBytes() int {
+	return len(block.data) + Uint16Size*len(block.restartOffsets) + Uint16Size*len(block.hashBuckets)
 }
 
-// DecodeToBlock decodes the given byte slice to the Block.
-//
-// The last 2 bytes denote the number of keyValueBeginOffsets.
-// The 2 bytes prior to the last 2 bytes denote the start offset of keyValueBeginOffsets.
+// HasHashIndex returns true if the block has a hash index.
+func (block Block) HasHashIndex() bool {
+	return len(block.hashBuckets) > 0
+}
+
+// DecodeToBlock decodes the given byte slice (in the LatestFormatVersion) to the Block.
+// The last 2 bytes denote the number of restart offsets, which precede the last 2 bytes.
 func DecodeToBlock(data []byte) Block {
-	numberOfOffsets := binary.LittleEndian.Uint16(data[len(data)-Uint16Size:])
-	startOfOffsets := binary.LittleEndian.Uint16(data[len(data)-Uint16Size-Uint16Size:])
-	offsetsBuffer := data[startOfOffsets : startOfOffsets+numberOfOffsets*uint16(Uint16Size)]
+	numberOfRestarts := int(binary.LittleEndian.Uint16(data[len(data)-Uint16Size:]))
+	startOfRestarts := len(data) - Uint16Size - numberOfRestarts*Uint16Size
+	return Block{
+		data:           data[:startOfRestarts],
+		restartOffsets: decodeOffsets(data[startOfRestarts:len(data)-Uint16Size], numberOfRestarts),
+		formatVersion:  LatestFormatVersion,
+	}
+}
 
-	keyValueBeginOffsets := make([]uint16, 0, numberOfOffsets)
-	for index := 0; index < len(offsetsBuffer); index += Uint16Size {
-		keyValueBeginOffsets = append(keyValueBeginOffsets, binary.LittleEndian.Uint16(offsetsBuffer[index:]))
+// DecodeToBlockOfFormatVersion decodes the given byte slice to the Block, using the encoding identified by formatVersion.
+// It returns an error if the formatVersion is not supported, or the data is too small to be a block.
+func DecodeToBlockOfFormatVersion(data []byte, formatVersion FormatVersion) (Block, error) {
+	if len(data) < Uint16Size+Uint16Size {
+		return Block{}, fmt.Errorf("block of size %v is too small", len(data))
 	}
-	return Block{
-		data:                 data[:startOfOffsets],
-		keyValueBeginOffsets: keyValueBeginOffsets,
+	switch formatVersion {
+	case FormatVersionFullKeys:
+		return decodeToFullKeysBlock(data), nil
+	case FormatVersionPrefixCompressedKeys:
+		return DecodeToBlock(data), nil
+	case FormatVersionPrefixCompressedKeysWithHashIndex:
+		return decodeToBlockWithHashIndex(data)
+	default:
+		return Block{}, fmt.Errorf("unsupported block format version %v", formatVersion)
 	}
 }
 
-// SeekToFirst creates an iterator (/block iterator) that is positioned at the first offset in the block.
+// SeekToFirst creates an iterator (/block iterator) that is positioned at the first key in the block.
 func (block Block) SeekToFirst() *Iterator {
 	iterator := &Iterator{
-		block:       block,
-		offsetIndex: 0,
+		block: block,
 	}
-	iterator.seekToOffsetIndex(iterator.offsetIndex)
+	iterator.seekToRestartPoint(0)
 	return iterator
 }
 
@@ -81,13 +147,61 @@ func (block Block) SeekToKey(key kv.Key) *Iterator {
 	return iterator
 }
 
-// encodeKeyValueBeginOffsets encodes all the keyValueBeginOffsets to byte slice using LittleEndian encoding.
-func (block Block) encodeKeyValueBeginOffsets() []byte {
-	offsetBuffer := make([]byte, Uint16Size*len(block.keyValueBeginOffsets))
-	offsetIndex := 0
-	for _, offset := range block.keyValueBeginOffsets {
-		binary.LittleEndian.PutUint16(offsetBuffer[offsetIndex:], offset)
-		offsetIndex += Uint16Size
+// SeekToKeyUsingHashIndex creates an iterator (/block iterator) for a point lookup of the given key. It uses the hash index
+// (if any) to jump to the restart point of the raw key, and falls back to the binary search (SeekToKey) on collisions.
+// The iterator is positioned at the key greater or equal to the given key only if the raw key is present in the block,
+// otherwise it may be positioned at any key greater than the given key (or be invalid), hence the caller must compare the
+// raw key of the iterator with the raw key of the given key.
+func (block Block) SeekToKeyUsingHashIndex(key kv.Key) *Iterator {
+	iterator := &Iterator{
+		block: block,
+	}
+	iterator.seekToGreaterOrEqualUsingHashIndex(key)
+	return iterator
+}
+
+// decodeToBlockWithHashIndex decodes the given byte slice in FormatVersionPrefixCompressedKeysWithHashIndex to the Block.
+// The last 2 bytes denote the number of hash buckets, which precede the last 2 bytes, and the rest of the byte slice is
+// the block in FormatVersionPrefixCompressedKeys.
+func decodeToBlockWithHashIndex(data []byte) (Block, error) {
+	numberOfBuckets := int(binary.LittleEndian.Uint16(data[len(data)-Uint16Size:]))
+	startOfBuckets := len(data) - Uint16Size - numberOfBuckets*Uint16Size
+	if startOfBuckets < Uint16Size {
+		return Block{}, fmt.Errorf("block of size %v is too small for %v hash buckets", len(data), numberOfBuckets)
+	}
+	block := DecodeToBlock(data[:startOfBuckets])
+	block.hashBuckets = decodeOffsets(data[startOfBuckets:len(data)-Uint16Size], numberOfBuckets)
+	block.formatVersion = FormatVersionPrefixCompressedKeysWithHashIndex
+	return block, nil
+}
+
+// decodeToFullKeysBlock decodes the given byte slice in FormatVersionFullKeys to the Block.
+// FormatVersionFullKeys encoding looks like the following:
+/*
+  -------------------------------------------------------------------------------------------------------------------------------------------------
+ | encoded key/value  | encoded key/value  |....| encoded key/value  | 0 | 48 | 120 | ...... |3088|      2 bytes          |          2 bytes       |
+  -------------------------------------------------------------------------------------------------------------------------------------------------
+  <--------------------------Encoded data---------------------------><-- Begin offsets of keys --><-- Start of offsets --><-Number of begin offsets->
+*/
+// Each key/value pair is encoded as: |2 bytes key size | key bytes | 2 bytes value size | value bytes|.
+// The last 2 bytes denote the number of begin offsets.
+// The 2 bytes prior to the last 2 bytes denote the start offset of begin offsets.
+// Every begin offset is treated as a restart point.
+func decodeToFullKeysBlock(data []byte) Block {
+	numberOfOffsets := int(binary.LittleEndian.Uint16(data[len(data)-Uint16Size:]))
+	startOfOffsets := int(binary.LittleEndian.Uint16(data[len(data)-Uint16Size-Uint16Size:]))
+	return Block{
+		data:           data[:startOfOffsets],
+		restartOffsets: decodeOffsets(data[startOfOffsets:], numberOfOffsets),
+		formatVersion:  FormatVersionFullKeys,
+	}
+}
+
+// decodeOffsets decodes numberOfOffsets uint16 offsets from the buffer using LittleEndian encoding.
+func decodeOffsets(buffer []byte, numberOfOffsets int) []uint16 {
+	offsets := make([]uint16, 0, numberOfOffsets)
+	for index := 0; index < numberOfOffsets*Uint16Size; index += Uint16Size {
+		offsets = append(offsets, binary.LittleEndian.Uint16(buffer[index:]))
 	}
-	return offsetBuffer
+	return offsets
 }
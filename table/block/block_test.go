@@ -1,6 +1,8 @@
 package block
 
 import (
+	"encoding/binary"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"go-lsm/kv"
 	"testing"
@@ -46,3 +48,94 @@ func TestEncodeAndDecodeBlockWithTwoKeyValues(t *testing.T) {
 	_ = iterator.Next()
 	assert.False(t, iterator.IsValid())
 }
+
+func TestEncodeAndDecodeBlockWithKeysSharingPrefixAcrossMultipleRestartPoints(t *testing.T) {
+	blockBuilder := NewBlockBuilderWithRestartInterval(4096, 4)
+	for count := 0; count < 30; count++ {
+		blockBuilder.Add(kv.NewStringKeyWithTimestamp(fmt.Sprintf("tenant/123/order/%03d", count), 5), kv.NewStringValue(fmt.Sprintf("value-%d", count)))
+	}
+
+	block := blockBuilder.Build()
+	assert.Equal(t, 8, len(block.restartOffsets))
+
+	decodedBlock := DecodeToBlock(block.Encode())
+	iterator := decodedBlock.SeekToFirst()
+	defer iterator.Close()
+
+	for count := 0; count < 30; count++ {
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, kv.NewStringKeyWithTimestamp(fmt.Sprintf("tenant/123/order/%03d", count), 5), iterator.Key())
+		assert.Equal(t, kv.NewStringValue(fmt.Sprintf("value-%d", count)), iterator.Value())
+		_ = iterator.Next()
+	}
+	assert.False(t, iterator.IsValid())
+}
+
+func TestPrefixCompressedBlockIsSmallerThanABlockWithFullKeys(t *testing.T) {
+	prefixCompressedBlockBuilder := NewBlockBuilderWithRestartInterval(4096, 16)
+	fullKeysBlockBuilder := NewBlockBuilderWithRestartInterval(4096, 1)
+	for count := 0; count < 50; count++ {
+		key := kv.NewStringKeyWithTimestamp(fmt.Sprintf("tenant/123/order/%03d", count), 5)
+		prefixCompressedBlockBuilder.Add(key, kv.NewStringValue("raft"))
+		fullKeysBlockBuilder.Add(key, kv.NewStringValue("raft"))
+	}
+	prefixCompressedSize := len(prefixCompressedBlockBuilder.Build().Encode())
+	fullKeysSize := len(fullKeysBlockBuilder.Build().Encode())
+
+	assert.True(t, prefixCompressedSize < fullKeysSize*2/3)
+}
+
+func TestDecodeBlockOfFormatVersionWithFullKeys(t *testing.T) {
+	buffer := encodeBlockWithFullKeys(
+		[]kv.Key{kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringKeyWithTimestamp("etcd", 6)},
+		[]kv.Value{kv.NewStringValue("raft"), kv.NewStringValue("kv")},
+	)
+	decodedBlock, err := DecodeToBlockOfFormatVersion(buffer, FormatVersionFullKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatVersionFullKeys, decodedBlock.FormatVersion())
+
+	iterator := decodedBlock.SeekToFirst()
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 5), iterator.Key())
+	assert.Equal(t, kv.NewStringValue("raft"), iterator.Value())
+
+	_ = iterator.Next()
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("etcd", 6), iterator.Key())
+	assert.Equal(t, kv.NewStringValue("kv"), iterator.Value())
+
+	_ = iterator.Next()
+	assert.False(t, iterator.IsValid())
+
+	iterator = decodedBlock.SeekToKey(kv.NewStringKeyWithTimestamp("distributed", 6))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringKeyWithTimestamp("etcd", 6), iterator.Key())
+}
+
+func TestDecodeBlockOfUnsupportedFormatVersion(t *testing.T) {
+	blockBuilder := NewBlockBuilder(1024)
+	blockBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+
+	_, err := DecodeToBlockOfFormatVersion(blockBuilder.Build().Encode(), FormatVersion(100))
+	assert.Error(t, err)
+}
+
+// encodeBlockWithFullKeys encodes the key/value pairs in FormatVersionFullKeys, the way block.Builder encoded them
+// before the introduction of FormatVersionPrefixCompressedKeys.
+func encodeBlockWithFullKeys(keys []kv.Key, values []kv.Value) []byte {
+	var data []byte
+	var offsets []uint16
+	for index, key := range keys {
+		offsets = append(offsets, uint16(len(data)))
+		data = binary.LittleEndian.AppendUint16(data, uint16(key.EncodedSizeInBytes()))
+		data = append(data, key.EncodedBytes()...)
+		data = binary.LittleEndian.AppendUint16(data, uint16(values[index].SizeInBytes()))
+		data = append(data, values[index].Bytes()...)
+	}
+	startOfOffsets := len(data)
+	for _, offset := range offsets {
+		data = binary.LittleEndian.AppendUint16(data, offset)
+	}
+	data = binary.LittleEndian.AppendUint16(data, uint16(startOfOffsets))
+	return binary.LittleEndian.AppendUint16(data, uint16(len(offsets)))
+}
@@ -1,6 +1,7 @@
 package block
 
 import (
+	"bytes"
 	"encoding/binary"
 	"go-lsm/kv"
 	"unsafe"
@@ -16,78 +17,160 @@ var Uint32Size = int(unsafe.Sizeof(uint32(0)))
 const kb uint = 1024
 const DefaultBlockSize = 4 * kb
 
+// MaxBlockSize is the largest supported block size, the offsets within a block are encoded as uint16.
+const MaxBlockSize = 64 * kb
+
+// DefaultRestartInterval is the number of keys between two restart points, it is the same as the default in LevelDB.
+const DefaultRestartInterval uint = 16
+
 // Builder represents a block builder.
-// keyValueBeginOffsets contain the begin-offsets of each of the keys that a part of the block.
+// restartOffsets contain the begin-offsets of the restart points (keys stored in full) of the block.
 // firstKey is the first key of the block.
+// previousKey is the encoded last key added to the block, it is used to compute the shared prefix of the next key.
 // data contains the encoded key/value pairs.
 //
-// Each block contains encoded key/value pairs, and keyValueBeginOffsets. The reason for storing keyValueBeginOffsets is to allow
-// binary search for a key within a block. The keyValueBeginOffsets are always in increasing order, hence binary search can be used.
+// Each block contains prefix-compressed key/value pairs, and restartOffsets. Every restartInterval-th key is stored in full
+// (a restart point), and other keys only store the suffix that they do not share with the previous key.
+// The reason for storing restartOffsets is to allow binary search for a key within a block.
+// The restartOffsets are always in increasing order, hence binary search can be used.
 // Please check Block.SeekToKey().
+//
+// If hashIndex is true, the builder collects the hash and the restart index of every distinct raw key (hashIndexEntries)
+// to build the hash index of the block. Please check Block.SeekToKeyUsingHashIndex().
 type Builder struct {
-	keyValueBeginOffsets []uint16
-	firstKey             kv.Key
-	blockSize            uint
-	data                 []byte
-	latestDataIndex      int
+	restartOffsets    []uint16
+	firstKey          kv.Key
+	previousKey       []byte
+	keysSinceRestart  uint
+	restartInterval   uint
+	blockSize         uint
+	data              []byte
+	numberOfKeyValues int
+	hashIndex         bool
+	hashIndexEntries  []hashIndexEntry
 }
 
-// NewBlockBuilder creates a new instance of block builder.
+// NewBlockBuilder creates a new instance of block builder with DefaultRestartInterval.
 func NewBlockBuilder(blockSize uint) *Builder {
+	return NewBlockBuilderWithRestartInterval(blockSize, DefaultRestartInterval)
+}
+
+// NewBlockBuilderWithRestartInterval creates a new instance of block builder with the given restart interval.
+// A restartInterval of 1 stores every key in full, a restartInterval of 0 is treated as DefaultRestartInterval.
+func NewBlockBuilderWithRestartInterval(blockSize uint, restartInterval uint) *Builder {
+	if restartInterval == 0 {
+		restartInterval = DefaultRestartInterval
+	}
 	return &Builder{
 		blockSize:       blockSize,
-		data:            make([]byte, blockSize),
-		latestDataIndex: 0,
+		restartInterval: restartInterval,
+		data:            make([]byte, 0, blockSize),
 	}
 }
 
-// Add adds the key/value pair, along with the begin-offset of the pair in the builder.
+// NewBlockBuilderWithHashIndex creates a new instance of block builder with the given restart interval, which builds the
+// hash index of the block (refer to FormatVersionPrefixCompressedKeysWithHashIndex).
+func NewBlockBuilderWithHashIndex(blockSize uint, restartInterval uint) *Builder {
+	builder := NewBlockBuilderWithRestartInterval(blockSize, restartInterval)
+	builder.hashIndex = true
+	return builder
+}
+
+// Add adds the key/value pair, along with the begin-offset of the pair (if it is a restart point) in the builder.
 // This involves:
 // 1) Keeping a track of the first key in the block builder.
-// 2) Storing the begin-offset of the key/value pair in keyValueBeginOffsets.
-// 3) Storing the key/value pair.
+// 2) Identifying the length of the prefix shared with the previous key (0 for a restart point).
+// 3) Storing the begin-offset of the key/value pair in restartOffsets, if the key/value pair is a restart point.
+// 4) Storing the key/value pair.
+// 5) Collecting the hash of the raw key, if the builder has a hash index and the raw key is different from the previous raw key.
+// It returns false if the key/value pair can not be accommodated in the block.
 func (builder *Builder) Add(key kv.Key, value kv.Value) bool {
-	if uint(builder.size()+key.EncodedSizeInBytes()+value.SizeInBytes()+ReservedKeySize+ReservedValueSize+KeyValueOffsetSize) > builder.blockSize {
+	encodedKey := key.EncodedBytes()
+	isRestartPoint := builder.keysSinceRestart%builder.restartInterval == 0
+
+	sharedKeySize := 0
+	if !isRestartPoint {
+		sharedKeySize = sharedPrefixLength(builder.previousKey, encodedKey)
+	}
+	unsharedKeySize := len(encodedKey) - sharedKeySize
+
+	requiredSize := ReservedKeySize + ReservedKeySize + ReservedValueSize + unsharedKeySize + value.SizeInBytes()
+	if isRestartPoint {
+		requiredSize += KeyValueOffsetSize
+	}
+	isNewRawKey := builder.hashIndex &&
+		(builder.numberOfKeyValues == 0 || !bytes.Equal(key.RawBytes(), kv.DecodeFrom(builder.previousKey).RawBytes()))
+	if isNewRawKey {
+		requiredSize += hashIndexSizeFor(len(builder.hashIndexEntries)+1) - hashIndexSizeFor(len(builder.hashIndexEntries))
+	}
+	if uint(builder.size()+requiredSize) > builder.blockSize {
 		return false
 	}
 
 	if builder.firstKey.IsRawKeyEmpty() {
 		builder.firstKey = key
 	}
+	if isRestartPoint {
+		builder.restartOffsets = append(builder.restartOffsets, uint16(len(builder.data)))
+		builder.keysSinceRestart = 0
+	}
 
-	builder.keyValueBeginOffsets = append(builder.keyValueBeginOffsets, uint16(builder.latestDataIndex))
-	keyValueBuffer := make([]byte, ReservedKeySize+ReservedValueSize+key.EncodedSizeInBytes()+value.SizeInBytes())
-
-	binary.LittleEndian.PutUint16(keyValueBuffer[:], uint16(key.EncodedSizeInBytes()))
-	copy(keyValueBuffer[ReservedKeySize:], key.EncodedBytes())
-
-	binary.LittleEndian.PutUint16(keyValueBuffer[ReservedKeySize+key.EncodedSizeInBytes():], uint16(value.SizeInBytes()))
-	copy(keyValueBuffer[ReservedKeySize+key.EncodedSizeInBytes()+ReservedValueSize:], value.Bytes())
-
-	n := copy(builder.data[builder.latestDataIndex:], keyValueBuffer)
-	builder.latestDataIndex += n
-
+	builder.data = binary.LittleEndian.AppendUint16(builder.data, uint16(sharedKeySize))
+	builder.data = binary.LittleEndian.AppendUint16(builder.data, uint16(unsharedKeySize))
+	builder.data = binary.LittleEndian.AppendUint16(builder.data, uint16(value.SizeInBytes()))
+	builder.data = append(builder.data, encodedKey[sharedKeySize:]...)
+	builder.data = append(builder.data, value.Bytes()...)
+
+	if isNewRawKey {
+		builder.hashIndexEntries = append(builder.hashIndexEntries, hashIndexEntry{
+			hash:         hashOf(key.RawBytes()),
+			restartIndex: uint16(len(builder.restartOffsets) - 1),
+		})
+	}
+	builder.previousKey = encodedKey
+	builder.keysSinceRestart++
+	builder.numberOfKeyValues++
 	return true
 }
 
 // isEmpty returns true if the builder has not stored any key/value pair.
 func (builder *Builder) isEmpty() bool {
-	return len(builder.keyValueBeginOffsets) == 0
+	return builder.numberOfKeyValues == 0
 }
 
 // Build creates a new instance of Block.
+// The hash index is built only if the number of restart points fits in a hash bucket (maxHashIndexRestarts).
 func (builder *Builder) Build() Block {
 	if builder.isEmpty() {
 		panic("cannot build an empty Block")
 	}
-	return newBlock(builder.data, builder.latestDataIndex, builder.keyValueBeginOffsets)
+	var hashBuckets []uint16
+	if builder.hashIndex && len(builder.restartOffsets) <= maxHashIndexRestarts {
+		hashBuckets = buildHashBuckets(builder.hashIndexEntries)
+	}
+	return newBlock(builder.data, builder.restartOffsets, hashBuckets)
 }
 
 // size returns the size of the builder.
-// The size includes: the size of encoded key/values (builder.data) + size of N keyValueBeginOffsets + Reserved bytes.
+// The size includes: the size of encoded key/values (builder.data) + size of N restartOffsets + Reserved bytes
+// + size of the hash index (if any).
 func (builder *Builder) size() int {
-	return len(builder.data[:builder.latestDataIndex]) +
-		len(builder.keyValueBeginOffsets)*Uint16Size +
-		Uint16Size + //block uses last 2 bytes for the number of begin offsets
-		Uint16Size //block uses 2 bytes before the last 2 bytes for the start offset of begin offsets
+	size := len(builder.data) +
+		len(builder.restartOffsets)*Uint16Size +
+		Uint16Size //block uses last 2 bytes for the number of restart offsets
+	if builder.hashIndex {
+		size += hashIndexSizeFor(len(builder.hashIndexEntries))
+	}
+	return size
+}
+
+// sharedPrefixLength returns the length of the common prefix of the two byte slices.
+func sharedPrefixLength(first, second []byte) int {
+	length := min(len(first), len(second))
+	for index := 0; index < length; index++ {
+		if first[index] != second[index] {
+			return index
+		}
+	}
+	return length
 }
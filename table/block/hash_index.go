@@ -0,0 +1,105 @@
+package block
+
+import (
+	"encoding/binary"
+	"go-lsm/kv"
+
+	"github.com/spaolacci/murmur3"
+)
+
+const (
+	// hashBucketEmpty marks a bucket without any raw key, a raw key which hashes to an empty bucket is not present in the block.
+	hashBucketEmpty uint16 = 0xFFFF
+	// hashBucketCollision marks a bucket with raw keys from different restart intervals, the lookup falls back to binary search.
+	hashBucketCollision uint16 = 0xFFFE
+	// maxHashIndexRestarts is the largest number of restart points which can be stored in a bucket, a block with more
+	// restart points is built without the hash index.
+	maxHashIndexRestarts = int(hashBucketCollision)
+	// hashIndexUtilizationRatio is the ratio of the number of (distinct) raw keys to the number of buckets.
+	hashIndexUtilizationRatio = 0.75
+)
+
+// hashIndexEntry represents a distinct raw key of the block, which is added to the hash index when the block is built.
+// restartIndex is the index of the restart point which precedes (or is) the newest version of the raw key.
+type hashIndexEntry struct {
+	hash         uint32
+	restartIndex uint16
+}
+
+// hashOf returns the hash of the raw key, it is used to identify the bucket of the raw key.
+func hashOf(rawKey []byte) uint32 {
+	return murmur3.Sum32(rawKey)
+}
+
+// numberOfHashBuckets returns the number of buckets for the given number of distinct raw keys.
+func numberOfHashBuckets(numberOfRawKeys int) int {
+	return int(float64(numberOfRawKeys)/hashIndexUtilizationRatio) + 1
+}
+
+// hashIndexSizeFor returns the size of the encoded hash index for the given number of distinct raw keys.
+func hashIndexSizeFor(numberOfRawKeys int) int {
+	return numberOfHashBuckets(numberOfRawKeys)*Uint16Size + Uint16Size
+}
+
+// buildHashBuckets builds the buckets of the hash index from the entries.
+// Each bucket contains the restart index of the raw keys which hash to the bucket. Multiple raw keys in the same restart
+// interval can share a bucket, whereas the raw keys in different restart intervals mark the bucket as hashBucketCollision.
+func buildHashBuckets(entries []hashIndexEntry) []uint16 {
+	buckets := make([]uint16, numberOfHashBuckets(len(entries)))
+	for index := range buckets {
+		buckets[index] = hashBucketEmpty
+	}
+	for _, entry := range entries {
+		bucket := entry.hash % uint32(len(buckets))
+		switch buckets[bucket] {
+		case hashBucketEmpty:
+			buckets[bucket] = entry.restartIndex
+		case entry.restartIndex, hashBucketCollision:
+		default:
+			buckets[bucket] = hashBucketCollision
+		}
+	}
+	return buckets
+}
+
+// encodeHashBuckets appends the hash index to the buffer.
+/*
+  --------------------------------------------------------------
+ | 2 bytes bucket | 2 bytes bucket | ... | 2 bytes number of buckets |
+  --------------------------------------------------------------
+*/
+func encodeHashBuckets(buffer []byte, buckets []uint16) []byte {
+	for _, bucket := range buckets {
+		buffer = binary.LittleEndian.AppendUint16(buffer, bucket)
+	}
+	return binary.LittleEndian.AppendUint16(buffer, uint16(len(buckets)))
+}
+
+// seekToGreaterOrEqualUsingHashIndex seeks to the key greater than or equal to the given key, if the raw key of the given
+// key is present in the block. It is meant for point lookups, and it involves the following:
+// 1) If the bucket of the raw key is empty, the raw key is not present in the block, and the iterator is marked invalid.
+// 2) If the bucket has a collision, it falls back to seekToGreaterOrEqual (binary search over the restart points).
+// 3) Otherwise, linear scan from the restart point of the bucket till a key greater than or equal to the given key is found.
+// The restart point of the bucket precedes the newest version of the raw key, so the scan finds the newest version of the
+// raw key which is visible at the timestamp of the given key.
+// If the raw key is not present in the block, the iterator may be positioned anywhere after the given key, hence the
+// caller must check the raw key of the iterator.
+func (iterator *Iterator) seekToGreaterOrEqualUsingHashIndex(key kv.Key) {
+	buckets := iterator.block.hashBuckets
+	if len(buckets) == 0 {
+		iterator.seekToGreaterOrEqual(key)
+		return
+	}
+	bucket := buckets[hashOf(key.RawBytes())%uint32(len(buckets))]
+	switch bucket {
+	case hashBucketEmpty:
+		iterator.markInvalid()
+	case hashBucketCollision:
+		iterator.seekToGreaterOrEqual(key)
+	default:
+		iterator.seekToRestartPoint(int(bucket))
+		for iterator.IsValid() && key.CompareKeysWithDescendingTimestamp(iterator.key) > 0 {
+			_ = iterator.Next()
+		}
+	}
+}
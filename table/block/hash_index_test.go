@@ -0,0 +1,111 @@
+package block
+
+import (
+	"fmt"
+	"go-lsm/kv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildBlockWithHashIndex(numberOfKeys int) Block {
+	blockBuilder := NewBlockBuilderWithHashIndex(4096, 4)
+	for count := 0; count < numberOfKeys; count++ {
+		blockBuilder.Add(kv.NewStringKeyWithTimestamp(fmt.Sprintf("key-%03d", count), 5), kv.NewStringValue(fmt.Sprintf("value-%d", count)))
+	}
+	return blockBuilder.Build()
+}
+
+func TestEncodeAndDecodeBlockWithHashIndex(t *testing.T) {
+	block := buildBlockWithHashIndex(30)
+	assert.True(t, block.HasHashIndex())
+	assert.Equal(t, FormatVersionPrefixCompressedKeysWithHashIndex, block.FormatVersion())
+
+	decodedBlock, err := DecodeToBlockOfFormatVersion(block.Encode(), block.FormatVersion())
+	assert.Nil(t, err)
+	assert.Equal(t, block.hashBuckets, decodedBlock.hashBuckets)
+	assert.Equal(t, block.restartOffsets, decodedBlock.restartOffsets)
+
+	iterator := decodedBlock.SeekToFirst()
+	for count := 0; count < 30; count++ {
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, kv.NewStringValue(fmt.Sprintf("value-%d", count)), iterator.Value())
+		_ = iterator.Next()
+	}
+	assert.False(t, iterator.IsValid())
+}
+
+func TestBlockSeekToKeyUsingHashIndex(t *testing.T) {
+	block := buildBlockWithHashIndex(30)
+
+	for count := 0; count < 30; count++ {
+		iterator := block.SeekToKeyUsingHashIndex(kv.NewStringKeyWithTimestamp(fmt.Sprintf("key-%03d", count), 10))
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, fmt.Sprintf("key-%03d", count), iterator.Key().RawString())
+		assert.Equal(t, kv.NewStringValue(fmt.Sprintf("value-%d", count)), iterator.Value())
+	}
+}
+
+func TestBlockSeekToKeyUsingHashIndexForANonExistingKey(t *testing.T) {
+	block := buildBlockWithHashIndex(30)
+
+	iterator := block.SeekToKeyUsingHashIndex(kv.NewStringKeyWithTimestamp("key-500", 10))
+	assert.False(t, iterator.IsValid() && iterator.Key().RawString() == "key-500")
+}
+
+func TestBlockSeekToKeyUsingHashIndexWithMultipleVersionsAcrossRestartPoints(t *testing.T) {
+	blockBuilder := NewBlockBuilderWithHashIndex(4096, 2)
+	blockBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 9), kv.NewStringValue("raft"))
+	blockBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 12), kv.NewStringValue("etcd"))
+	blockBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 10), kv.NewStringValue("TiKV"))
+	blockBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 8), kv.NewStringValue("foundationDB"))
+	blockBuilder.Add(kv.NewStringKeyWithTimestamp("storage", 7), kv.NewStringValue("NVMe"))
+	block := blockBuilder.Build()
+
+	iterator := block.SeekToKeyUsingHashIndex(kv.NewStringKeyWithTimestamp("distributed", 11))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("TiKV"), iterator.Value())
+
+	iterator = block.SeekToKeyUsingHashIndex(kv.NewStringKeyWithTimestamp("distributed", 9))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("foundationDB"), iterator.Value())
+
+	iterator = block.SeekToKeyUsingHashIndex(kv.NewStringKeyWithTimestamp("distributed", 20))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("etcd"), iterator.Value())
+}
+
+func TestBlockSeekToKeyUsingHashIndexFallsBackToBinarySearchOnCollision(t *testing.T) {
+	block := buildBlockWithHashIndex(30)
+	for index := range block.hashBuckets {
+		block.hashBuckets[index] = hashBucketCollision
+	}
+
+	iterator := block.SeekToKeyUsingHashIndex(kv.NewStringKeyWithTimestamp("key-017", 10))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("value-17"), iterator.Value())
+}
+
+func TestBlockSeekToKeyUsingHashIndexWithoutHashIndex(t *testing.T) {
+	blockBuilder := NewBlockBuilder(4096)
+	blockBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 9), kv.NewStringValue("raft"))
+	block := blockBuilder.Build()
+	assert.False(t, block.HasHashIndex())
+
+	iterator := block.SeekToKeyUsingHashIndex(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("raft"), iterator.Value())
+}
+
+func TestBuildHashBucketsWithACollision(t *testing.T) {
+	buckets := buildHashBuckets([]hashIndexEntry{
+		{hash: 0, restartIndex: 0},
+		{hash: 0, restartIndex: 0},
+		{hash: 1, restartIndex: 1},
+		{hash: 1, restartIndex: 2},
+	})
+	assert.Equal(t, numberOfHashBuckets(4), len(buckets))
+	assert.Equal(t, uint16(0), buckets[0])
+	assert.Equal(t, hashBucketCollision, buckets[1])
+	assert.Equal(t, hashBucketEmpty, buckets[2])
+}
@@ -6,11 +6,16 @@ import (
 )
 
 // Iterator represents the block iterator.
+// encodedKey is the encoded current key, it is used to reconstruct the next key which shares a prefix with it.
+// nextEntryOffset is the offset of the key/value pair following the current one.
+// Keys in FormatVersionPrefixCompressedKeys share a prefix with the previous key, so the iterator always moves forward
+// from a restart point, decoding the keys one after the other.
 type Iterator struct {
-	key         kv.Key
-	value       kv.Value
-	offsetIndex uint16
-	block       Block
+	key             kv.Key
+	value           kv.Value
+	encodedKey      []byte
+	nextEntryOffset int
+	block           Block
 	//the entire value is kept in the iterator. If memory optimization needs to be done,
 	//only value range can be key here and the value can be returned from the Value method.
 }
@@ -31,40 +36,41 @@ func (iterator *Iterator) IsValid() bool {
 	return !iterator.key.IsRawKeyEmpty()
 }
 
-// Next increments the offsetIndex by one and seeks to the incremented offset.
+// Next moves the iterator to the next key/value pair in the block.
 func (iterator *Iterator) Next() error {
-	iterator.offsetIndex++
-	iterator.seekToOffsetIndex(iterator.offsetIndex)
-
+	if !iterator.IsValid() {
+		return nil
+	}
+	iterator.seekToOffset(iterator.nextEntryOffset)
 	return nil
 }
 
 // Close does nothing.
 func (iterator *Iterator) Close() {}
 
-// seekToOffsetIndex seeks to the offset identify by the index of keyValueBeginOffsets slice.
-// If index >= len(iterator.block.keyValueBeginOffsets), iterator is marked invalid.
-func (iterator *Iterator) seekToOffsetIndex(index uint16) {
-	if index >= uint16(len(iterator.block.keyValueBeginOffsets)) {
+// seekToRestartPoint seeks to the key/value pair at the restart point identified by the index of restartOffsets slice.
+// If index >= len(iterator.block.restartOffsets), iterator is marked invalid.
+func (iterator *Iterator) seekToRestartPoint(index int) {
+	if index >= len(iterator.block.restartOffsets) {
 		iterator.markInvalid()
 		return
 	}
-	keyValueBeginOffset := iterator.block.keyValueBeginOffsets[index]
-
-	iterator.offsetIndex = index
-	iterator.seekToOffset(keyValueBeginOffset)
+	iterator.encodedKey = nil
+	iterator.seekToOffset(int(iterator.block.restartOffsets[index]))
 }
 
 // seekToGreaterOrEqual seeks to the key greater than or equal to the given key.
-// It leverages binary search within keyValueBeginOffsets to perform seek.
+// It involves the following:
+// 1) Binary search over the restart points to find the last restart point with a key smaller than the given key.
+// 2) Linear scan from that restart point till a key greater than or equal to the given key is found.
+// If all the keys in the block are smaller than the given key, the iterator is marked invalid.
 func (iterator *Iterator) seekToGreaterOrEqual(key kv.Key) {
-	low := 0
-	high := len(iterator.block.keyValueBeginOffsets) - 1
-	possibleIndex := low
+	low, high := 0, len(iterator.block.restartOffsets)-1
+	restartIndex := 0
 
 	for low <= high {
-		mid := (low + high) / 2
-		iterator.seekToOffsetIndex(uint16(mid))
+		mid := low + (high-low)/2
+		iterator.seekToRestartPoint(mid)
 
 		if !iterator.IsValid() {
 			panic("invalid iterator")
@@ -73,35 +79,57 @@ func (iterator *Iterator) seekToGreaterOrEqual(key kv.Key) {
 		case -1:
 			high = mid - 1
 		case 0:
-			iterator.seekToOffsetIndex(uint16(possibleIndex))
 			return
 		case 1:
+			restartIndex = mid
 			low = mid + 1
-			possibleIndex = low
 		}
 	}
-	iterator.seekToOffsetIndex(uint16(possibleIndex))
+	iterator.seekToRestartPoint(restartIndex)
+	for iterator.IsValid() && key.CompareKeysWithDescendingTimestamp(iterator.key) > 0 {
+		_ = iterator.Next()
+	}
 }
 
-// seekToOffset sets the key and value from the offset identified by keyValueBeginOffset.
-// Technically, it does not seek to anywhere, it uses the keyValueBeginOffset and decodes
-// the key and value.
-func (iterator *Iterator) seekToOffset(keyValueBeginOffset uint16) {
-	data := iterator.block.data[keyValueBeginOffset:]
-
-	keySize := binary.LittleEndian.Uint16(data[:])
-	key := kv.DecodeFrom(data[ReservedKeySize : uint16(ReservedKeySize)+keySize])
+// seekToOffset sets the key and value from the key/value pair which begins at the given offset.
+// Technically, it does not seek to anywhere, it uses the offset and decodes the key and value.
+// The key is reconstructed using the shared prefix of the previous key (iterator.encodedKey).
+// If the offset is beyond the encoded data, the iterator is marked invalid.
+func (iterator *Iterator) seekToOffset(offset int) {
+	if offset >= len(iterator.block.data) {
+		iterator.markInvalid()
+		return
+	}
+	data := iterator.block.data[offset:]
+
+	var sharedKeySize, unsharedKeySize, keyOffsetStart, valueSize, valueOffsetStart int
+	switch iterator.block.formatVersion {
+	case FormatVersionFullKeys:
+		unsharedKeySize = int(binary.LittleEndian.Uint16(data))
+		keyOffsetStart = ReservedKeySize
+		valueSize = int(binary.LittleEndian.Uint16(data[keyOffsetStart+unsharedKeySize:]))
+		valueOffsetStart = keyOffsetStart + unsharedKeySize + ReservedValueSize
+	default:
+		sharedKeySize = int(binary.LittleEndian.Uint16(data))
+		unsharedKeySize = int(binary.LittleEndian.Uint16(data[ReservedKeySize:]))
+		valueSize = int(binary.LittleEndian.Uint16(data[ReservedKeySize+ReservedKeySize:]))
+		keyOffsetStart = ReservedKeySize + ReservedKeySize + ReservedValueSize
+		valueOffsetStart = keyOffsetStart + unsharedKeySize
+	}
 
-	valueSize := binary.LittleEndian.Uint16(data[ReservedKeySize+key.EncodedSizeInBytes():])
-	valueOffsetStart := uint16(ReservedKeySize) + keySize + uint16(ReservedValueSize)
-	value := kv.NewValue(data[valueOffsetStart : valueOffsetStart+valueSize])
+	encodedKey := make([]byte, sharedKeySize+unsharedKeySize)
+	copy(encodedKey, iterator.encodedKey[:sharedKeySize])
+	copy(encodedKey[sharedKeySize:], data[keyOffsetStart:keyOffsetStart+unsharedKeySize])
 
-	iterator.key = key
-	iterator.value = value
+	iterator.encodedKey = encodedKey
+	iterator.key = kv.DecodeFrom(encodedKey)
+	iterator.value = kv.NewValue(data[valueOffsetStart : valueOffsetStart+valueSize])
+	iterator.nextEntryOffset = offset + valueOffsetStart + valueSize
 }
 
 // markInvalid marks the iterator invalid by setting the key and value as empty.
 func (iterator *Iterator) markInvalid() {
 	iterator.value = kv.EmptyValue
 	iterator.key = kv.EmptyKey
+	iterator.encodedKey = nil
 }
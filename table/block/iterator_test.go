@@ -1,6 +1,7 @@
 package block
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"go-lsm/kv"
 	"testing"
@@ -149,3 +150,22 @@ func TestBlockSeekToTheNonExistingKey(t *testing.T) {
 
 	assert.False(t, iterator.IsValid())
 }
+
+func TestBlockSeekToKeysAcrossRestartPoints(t *testing.T) {
+	blockBuilder := NewBlockBuilderWithRestartInterval(4096, 3)
+	for count := 0; count < 20; count += 2 {
+		blockBuilder.Add(kv.NewStringKeyWithTimestamp(fmt.Sprintf("order/%03d", count), 5), kv.NewStringValue(fmt.Sprintf("value-%d", count)))
+	}
+	block := blockBuilder.Build()
+
+	for count := 0; count < 19; count++ {
+		iterator := block.SeekToKey(kv.NewStringKeyWithTimestamp(fmt.Sprintf("order/%03d", count), 5))
+		expected := count + count%2
+
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, kv.NewStringKeyWithTimestamp(fmt.Sprintf("order/%03d", expected), 5), iterator.Key())
+		assert.Equal(t, kv.NewStringValue(fmt.Sprintf("value-%d", expected)), iterator.Value())
+	}
+	iterator := block.SeekToKey(kv.NewStringKeyWithTimestamp("order/019", 5))
+	assert.False(t, iterator.IsValid())
+}
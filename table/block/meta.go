@@ -14,6 +14,11 @@ type Meta struct {
 	EndingKey           kv.Key
 }
 
+// EncodedSizeInBytes returns the size of the encoded Meta (refer to MetaList.Encode).
+func (meta Meta) EncodedSizeInBytes() int {
+	return Uint32Size + ReservedKeySize + meta.StartingKey.EncodedSizeInBytes() + ReservedKeySize + meta.EndingKey.EncodedSizeInBytes()
+}
+
 // MetaList is a collection of metadata about multiple blocks.
 type MetaList struct {
 	list []Meta
@@ -81,6 +86,15 @@ func (metaList *MetaList) GetAt(index int) (Meta, bool) {
 	return Meta{}, false
 }
 
+// SizeInBytes returns the (approximate) in-memory size of the meta-list, it is used by the cache.BlockCache.
+func (metaList *MetaList) SizeInBytes() int {
+	size := 0
+	for _, meta := range metaList.list {
+		size += meta.EncodedSizeInBytes()
+	}
+	return size
+}
+
 // Length returns the length of meta-list.
 func (metaList *MetaList) Length() int {
 	return len(metaList.list)
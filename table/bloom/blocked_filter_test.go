@@ -0,0 +1,88 @@
+package bloom
+
+import (
+	"fmt"
+	"go-lsm/kv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAKeyWithBlockedBloomFilterAndChecksForItsPositiveExistence(t *testing.T) {
+	bloomFilterBuilder := NewBloomFilterBuilder()
+
+	key := kv.NewStringKeyWithTimestamp("consensus", 10)
+	bloomFilterBuilder.Add(key)
+
+	bloomFilter := bloomFilterBuilder.BuildBlocked(0.001)
+	assert.True(t, bloomFilter.MayContain(key))
+	assert.Equal(t, BlockedFilterType, bloomFilter.Type())
+}
+
+func TestAddAKeyWithBlockedBloomFilterAndChecksForTheExistenceOfANonExistingKey(t *testing.T) {
+	bloomFilterBuilder := NewBloomFilterBuilder()
+
+	bloomFilterBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 20))
+
+	bloomFilter := bloomFilterBuilder.BuildBlocked(0.001)
+	assert.False(t, bloomFilter.MayContain(kv.NewStringKeyWithTimestamp("missing", 20)))
+}
+
+func TestBlockedBloomFilterWithManyKeys(t *testing.T) {
+	bloomFilterBuilder := NewBloomFilterBuilder()
+	for count := 0; count < 1000; count++ {
+		bloomFilterBuilder.AddBytes([]byte(fmt.Sprintf("key-%d", count)))
+	}
+
+	bloomFilter := bloomFilterBuilder.BuildBlocked(0.01)
+	assert.True(t, len(bloomFilter.words)/wordsPerBlock > 1)
+	for count := 0; count < 1000; count++ {
+		assert.True(t, bloomFilter.MayContainBytes([]byte(fmt.Sprintf("key-%d", count))))
+	}
+}
+
+func TestEncodeBlockedBloomFilter(t *testing.T) {
+	keys := []kv.Key{
+		kv.NewStringKeyWithTimestamp("consensus", 5),
+		kv.NewStringKeyWithTimestamp("paxos", 6),
+		kv.NewStringKeyWithTimestamp("distributed", 7),
+	}
+	bloomFilterBuilder := NewBloomFilterBuilder()
+	for _, key := range keys {
+		bloomFilterBuilder.Add(key)
+	}
+
+	bloomFilter := bloomFilterBuilder.BuildBlocked(0.001)
+	encoded, err := bloomFilter.Encode()
+	assert.Nil(t, err)
+
+	decodedBloomFilter, err := DecodeFilter(BlockedFilterType, encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, bloomFilter, decodedBloomFilter)
+	for _, key := range keys {
+		assert.True(t, decodedBloomFilter.MayContain(key))
+	}
+	assert.False(t, decodedBloomFilter.MayContain(kv.NewStringKeyWithTimestamp("etcd", 5)))
+}
+
+func TestDecodeBlockedBloomFilterWithAnInvalidSize(t *testing.T) {
+	_, err := DecodeToBlockedBloomFilter(make([]byte, 10))
+	assert.Error(t, err)
+}
+
+func TestDecodeBlockedBloomFilterWithNoBlocks(t *testing.T) {
+	_, err := DecodeToBlockedBloomFilter(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestBuildBloomFilterOfType(t *testing.T) {
+	bloomFilterBuilder := NewBloomFilterBuilder()
+	bloomFilterBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5))
+
+	assert.Equal(t, StandardFilterType, bloomFilterBuilder.BuildOfType(StandardFilterType, 0.01).Type())
+	assert.Equal(t, BlockedFilterType, bloomFilterBuilder.BuildOfType(BlockedFilterType, 0.01).Type())
+	assert.Error(t, ValidateFilterType(FilterType(10)))
+
+	_, err := DecodeFilter(FilterType(10), nil)
+	assert.Error(t, err)
+}
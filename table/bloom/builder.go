@@ -1,13 +1,15 @@
 package bloom
 
 import (
+	"fmt"
 	"github.com/bits-and-blooms/bitset"
 	"go-lsm/kv"
 )
 
 // FilterBuilder represents bloom filter builder.
+// The keys are the raw keys (without timestamps), or the prefixes of the raw keys (refer to PrefixExtractor).
 type FilterBuilder struct {
-	keys []kv.Key
+	keys [][]byte
 }
 
 // NewBloomFilterBuilder creates a new instance of bloom filter builder.
@@ -15,21 +17,39 @@ func NewBloomFilterBuilder() *FilterBuilder {
 	return &FilterBuilder{}
 }
 
-// Add adds the given key to its collection.
+// Add adds the raw key of the given key to its collection.
 func (builder *FilterBuilder) Add(key kv.Key) {
+	builder.AddBytes(key.RawBytes())
+}
+
+// AddBytes adds the given byte slice (a raw key or a prefix) to its collection.
+func (builder *FilterBuilder) AddBytes(key []byte) {
 	builder.keys = append(builder.keys, key)
 }
 
-// Build builds a new bloom filter.
+// BuildOfType builds a new bloom filter of the given FilterType.
+// It panics if the FilterType is not supported, the FilterType is expected to be validated by the caller
+// (refer to ValidateFilterType).
+func (builder *FilterBuilder) BuildOfType(filterType FilterType, falsePositiveRate float64) Filter {
+	switch filterType {
+	case StandardFilterType:
+		return builder.Build(falsePositiveRate)
+	case BlockedFilterType:
+		return builder.BuildBlocked(falsePositiveRate)
+	default:
+		panic(fmt.Errorf("unsupported bloom filter type %v", filterType))
+	}
+}
+
+// Build builds a new (standard) bloom filter.
 // It involves the following:
 // 1) Determining the bit vector size.
 // 2) Creating a new instance of bloom filter.
 // 3) Adding all the keys in the bloom filter.
-func (builder *FilterBuilder) Build(falsePositiveRate float64) Filter {
+func (builder *FilterBuilder) Build(falsePositiveRate float64) StandardFilter {
 	vectorSize := bitVectorSize(len(builder.keys), falsePositiveRate)
-	filter := Filter{
+	filter := StandardFilter{
 		numberOfHashFunctions: numberOfHashFunctions(falsePositiveRate),
-		falsePositiveRate:     falsePositiveRate,
 		bitVector:             bitset.New(uint(vectorSize)),
 	}
 	for _, key := range builder.keys {
@@ -37,3 +57,19 @@ func (builder *FilterBuilder) Build(falsePositiveRate float64) Filter {
 	}
 	return filter
 }
+
+// BuildBlocked builds a new BlockedFilter.
+// It involves the following:
+// 1) Determining the number of blocks from the bit vector size.
+// 2) Creating a new instance of BlockedFilter.
+// 3) Adding all the keys in the BlockedFilter.
+func (builder *FilterBuilder) BuildBlocked(falsePositiveRate float64) BlockedFilter {
+	filter := newBlockedFilter(
+		numberOfBlocks(bitVectorSize(len(builder.keys), falsePositiveRate)),
+		numberOfHashFunctions(falsePositiveRate),
+	)
+	for _, key := range builder.keys {
+		filter.add(key)
+	}
+	return filter
+}
@@ -1,6 +1,7 @@
 package bloom
 
 import (
+	"fmt"
 	"github.com/bits-and-blooms/bitset"
 	"github.com/spaolacci/murmur3"
 	"go-lsm/kv"
@@ -10,30 +11,77 @@ import (
 
 const uin8Size = int(unsafe.Sizeof(uint8(0)))
 
+// FalsePositiveRate is the default false positive rate of the bloom filters of the SSTables.
 const FalsePositiveRate = 0.01
 
+// FilterType identifies the implementation of the Filter, it is stored in the SSTable along with the encoded Filter.
+type FilterType uint8
+
+const (
+	// StandardFilterType identifies StandardFilter.
+	StandardFilterType FilterType = 1
+	// BlockedFilterType identifies BlockedFilter.
+	BlockedFilterType FilterType = 2
+)
+
 // Filter represents Bloom filter.
 // Bloom filter is a probabilistic data structure used to test whether an element maybe present in the dataset.
 // A bloom filter can query against large amounts of data and return either “possibly in the set” or “definitely not in the set”.
-// It depends on M-sized bit vector and K-hash functions.
-type Filter struct {
+// There are two implementations: StandardFilter and BlockedFilter.
+type Filter interface {
+	// MayContain returns true if the raw key of the given key maybe present, false if it is definitely not present.
+	MayContain(key kv.Key) bool
+	// MayContainBytes returns true if the given byte slice (a raw key or a prefix) maybe present, false if it is
+	// definitely not present.
+	MayContainBytes(key []byte) bool
+	// Encode encodes the filter to a byte slice.
+	Encode() ([]byte, error)
+	// Type returns the FilterType of the filter.
+	Type() FilterType
+}
+
+// StandardFilter is a Filter which depends on M-sized bit vector and K-hash functions, every key sets (and checks)
+// K independent positions spread across the bit vector.
+type StandardFilter struct {
 	numberOfHashFunctions uint8
-	falsePositiveRate     float64
 	bitVector             *bitset.BitSet
 }
 
-// DecodeToBloomFilter decodes the byte slice to the bloom filter.
-// It relies on bitset.BitSet for decoding.
-func DecodeToBloomFilter(buffer []byte, falsePositiveRate float64) (Filter, error) {
+// DecodeFilter decodes the byte slice to the Filter of the given FilterType.
+func DecodeFilter(filterType FilterType, buffer []byte) (Filter, error) {
+	switch filterType {
+	case StandardFilterType:
+		return DecodeToBloomFilter(buffer)
+	case BlockedFilterType:
+		return DecodeToBlockedBloomFilter(buffer)
+	default:
+		return nil, fmt.Errorf("unsupported bloom filter type %v", filterType)
+	}
+}
+
+// ValidateFilterType returns an error if the FilterType is not supported.
+func ValidateFilterType(filterType FilterType) error {
+	if filterType != StandardFilterType && filterType != BlockedFilterType {
+		return fmt.Errorf("unsupported bloom filter type %v", filterType)
+	}
+	return nil
+}
+
+// DecodeToBloomFilter decodes the byte slice to the StandardFilter.
+// It relies on bitset.BitSet for decoding. The number of hash functions is decoded from the last byte, so a filter
+// can be decoded without knowing the false positive rate it was built with.
+func DecodeToBloomFilter(buffer []byte) (StandardFilter, error) {
+	if len(buffer) < uin8Size {
+		return StandardFilter{}, fmt.Errorf("bloom filter of size %v is too small", len(buffer))
+	}
 	bitVector := new(bitset.BitSet)
 	filter := buffer[:len(buffer)-uin8Size]
 
 	if err := bitVector.UnmarshalBinary(filter); err != nil {
-		return Filter{}, err
+		return StandardFilter{}, err
 	}
-	return Filter{
-		numberOfHashFunctions: numberOfHashFunctions(falsePositiveRate),
-		falsePositiveRate:     falsePositiveRate,
+	return StandardFilter{
+		numberOfHashFunctions: buffer[len(buffer)-uin8Size],
 		bitVector:             bitVector,
 	}, nil
 }
@@ -45,7 +93,7 @@ func DecodeToBloomFilter(buffer []byte, falsePositiveRate float64) (Filter, erro
  | bit vector | 1 byte for numberOfHashFunctions  |
   ------------------------------------------------
 */
-func (filter Filter) Encode() ([]byte, error) {
+func (filter StandardFilter) Encode() ([]byte, error) {
 	buffer, err := filter.bitVector.MarshalBinary()
 	if err != nil {
 		return nil, err
@@ -53,8 +101,13 @@ func (filter Filter) Encode() ([]byte, error) {
 	return append(buffer, filter.numberOfHashFunctions), nil
 }
 
+// Type returns StandardFilterType.
+func (filter StandardFilter) Type() FilterType {
+	return StandardFilterType
+}
+
 // add adds the given key in the bloom filter by setting the positions (/indices) of the key in the bit vector.
-func (filter Filter) add(key kv.Key) {
+func (filter StandardFilter) add(key []byte) {
 	positions := filter.bitPositionsFor(key)
 	for index := 0; index < len(positions); index++ {
 		position := positions[index]
@@ -66,7 +119,12 @@ func (filter Filter) add(key kv.Key) {
 // True indicates that the key MAYBE present in the system.
 // Returns false, if any of the bits identified by the positions (/indices) for the key are not add.
 // False indicates that the key is definitely NOT present in the system.
-func (filter Filter) MayContain(key kv.Key) bool {
+func (filter StandardFilter) MayContain(key kv.Key) bool {
+	return filter.MayContainBytes(key.RawBytes())
+}
+
+// MayContainBytes is similar to MayContain, it checks the given byte slice (a raw key or a prefix) in the bloom filter.
+func (filter StandardFilter) MayContainBytes(key []byte) bool {
 	positions := filter.bitPositionsFor(key)
 	for index := 0; index < len(positions); index++ {
 		position := positions[index]
@@ -78,11 +136,11 @@ func (filter Filter) MayContain(key kv.Key) bool {
 }
 
 // bitPositionsFor returns the bit vector positions (/indices) for the key which must either be added or checked.
-func (filter Filter) bitPositionsFor(key kv.Key) []uint32 {
+func (filter StandardFilter) bitPositionsFor(key []byte) []uint32 {
 	indices := make([]uint32, 0, filter.numberOfHashFunctions)
 
 	for index := uint8(0); index < filter.numberOfHashFunctions; index++ {
-		hash := murmur3.Sum32WithSeed(key.RawBytes(), uint32(index))
+		hash := murmur3.Sum32WithSeed(key, uint32(index))
 		indices = append(indices, hash%uint32(filter.bitVector.Len()))
 	}
 	return indices
@@ -93,9 +151,9 @@ func numberOfHashFunctions(falsePositiveRate float64) uint8 {
 	return uint8(math.Ceil(math.Log2(1.0 / falsePositiveRate)))
 }
 
-// bitVectorSize returns the bit vector size.
+// bitVectorSize returns the bit vector size, which is at least 1 bit (a filter without keys does not contain any key).
 func bitVectorSize(capacity int, falsePositiveRate float64) int {
 	//ln22 = ln2^2
 	ln22 := math.Pow(math.Ln2, 2)
-	return int(float64(capacity) * math.Abs(math.Log(falsePositiveRate)) / ln22)
+	return max(int(float64(capacity)*math.Abs(math.Log(falsePositiveRate))/ln22), 1)
 }
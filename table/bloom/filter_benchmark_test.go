@@ -0,0 +1,44 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+const benchmarkNumberOfKeys = 100_000
+
+// buildBenchmarkFilter builds the Filter of the given FilterType with benchmarkNumberOfKeys keys.
+func buildBenchmarkFilter(filterType FilterType) Filter {
+	bloomFilterBuilder := NewBloomFilterBuilder()
+	for count := 0; count < benchmarkNumberOfKeys; count++ {
+		bloomFilterBuilder.AddBytes([]byte(fmt.Sprintf("key-%d", count)))
+	}
+	return bloomFilterBuilder.BuildOfType(filterType, FalsePositiveRate)
+}
+
+// benchmarkMayContain measures the probe latency (for the keys which are not present), and reports the observed
+// false positive rate as the "fp-rate" metric.
+func benchmarkMayContain(b *testing.B, filterType FilterType) {
+	filter := buildBenchmarkFilter(filterType)
+	missingKeys := make([][]byte, benchmarkNumberOfKeys)
+	for count := range missingKeys {
+		missingKeys[count] = []byte(fmt.Sprintf("missing-%d", count))
+	}
+
+	falsePositives := 0
+	b.ResetTimer()
+	for index := 0; index < b.N; index++ {
+		if filter.MayContainBytes(missingKeys[index%len(missingKeys)]) {
+			falsePositives++
+		}
+	}
+	b.ReportMetric(float64(falsePositives)/float64(b.N), "fp-rate")
+}
+
+func BenchmarkStandardFilterMayContain(b *testing.B) {
+	benchmarkMayContain(b, StandardFilterType)
+}
+
+func BenchmarkBlockedFilterMayContain(b *testing.B) {
+	benchmarkMayContain(b, BlockedFilterType)
+}
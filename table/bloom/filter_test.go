@@ -36,7 +36,7 @@ func TestEncodeBloomFilter(t *testing.T) {
 	encoded, err := bloomFilter.Encode()
 	assert.Nil(t, err)
 
-	decodedBloomFilter, err := DecodeToBloomFilter(encoded, 0.001)
+	decodedBloomFilter, err := DecodeToBloomFilter(encoded)
 	assert.Nil(t, err)
 
 	assert.True(t, decodedBloomFilter.MayContain(kv.NewStringKeyWithTimestamp("consensus", 6)))
@@ -62,7 +62,7 @@ func TestEncodeBloomFilterContainingAFewKeys(t *testing.T) {
 	encoded, err := bloomFilter.Encode()
 	assert.Nil(t, err)
 
-	decodedBloomFilter, err := DecodeToBloomFilter(encoded, 0.001)
+	decodedBloomFilter, err := DecodeToBloomFilter(encoded)
 	assert.Nil(t, err)
 
 	queryKeysWithDifferentTimestamps := []kv.Key{
@@ -89,8 +89,21 @@ func TestEncodeBloomFilterAndCheckForNonExistingKey(t *testing.T) {
 	encoded, err := bloomFilter.Encode()
 	assert.Nil(t, err)
 
-	decodedBloomFilter, err := DecodeToBloomFilter(encoded, 0.001)
+	decodedBloomFilter, err := DecodeToBloomFilter(encoded)
 	assert.Nil(t, err)
 
 	assert.False(t, decodedBloomFilter.MayContain(kv.NewStringKeyWithTimestamp("missing", 5)))
 }
+
+func TestDecodeBloomFilterWithTheNumberOfHashFunctionsItWasBuiltWith(t *testing.T) {
+	bloomFilterBuilder := NewBloomFilterBuilder()
+	bloomFilterBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5))
+
+	bloomFilter := bloomFilterBuilder.Build(0.0001)
+	encoded, err := bloomFilter.Encode()
+	assert.Nil(t, err)
+
+	decodedBloomFilter, err := DecodeToBloomFilter(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, bloomFilter.numberOfHashFunctions, decodedBloomFilter.numberOfHashFunctions)
+}
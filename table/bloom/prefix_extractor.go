@@ -0,0 +1,43 @@
+package bloom
+
+import "fmt"
+
+// PrefixExtractor extracts the prefix of a raw key. The prefixes of the keys of an SSTable are added to a separate
+// (prefix) bloom filter, which allows a Scan over a prefix to skip the SSTables that do not contain the prefix.
+//
+// A PrefixExtractor must be order-preserving: if two keys have the same prefix, all the keys between them must have
+// the same prefix. Name identifies the PrefixExtractor, it is stored in the SSTable along with the prefix filter, and
+// the prefix filter of an SSTable is used only if the name matches the configured PrefixExtractor.
+type PrefixExtractor interface {
+	// Name returns the name of the PrefixExtractor.
+	Name() string
+	// Prefix returns the prefix of the raw key, and false if the key is not in the domain of the PrefixExtractor.
+	Prefix(rawKey []byte) ([]byte, bool)
+}
+
+// FixedLengthPrefixExtractor extracts the first Length bytes of a raw key as its prefix.
+// The keys shorter than Length are not in its domain.
+type FixedLengthPrefixExtractor struct {
+	Length int
+}
+
+// NewFixedLengthPrefixExtractor creates a new instance of FixedLengthPrefixExtractor.
+func NewFixedLengthPrefixExtractor(length int) FixedLengthPrefixExtractor {
+	if length <= 0 {
+		panic("prefix length must be greater than 0")
+	}
+	return FixedLengthPrefixExtractor{Length: length}
+}
+
+// Name returns the name of the FixedLengthPrefixExtractor, which includes the Length.
+func (extractor FixedLengthPrefixExtractor) Name() string {
+	return fmt.Sprintf("fixed-length-prefix:%v", extractor.Length)
+}
+
+// Prefix returns the first Length bytes of the raw key, and false if the key is shorter than Length.
+func (extractor FixedLengthPrefixExtractor) Prefix(rawKey []byte) ([]byte, bool) {
+	if len(rawKey) < extractor.Length {
+		return nil, false
+	}
+	return rawKey[:extractor.Length], true
+}
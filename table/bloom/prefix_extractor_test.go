@@ -0,0 +1,44 @@
+package bloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedLengthPrefixExtractor(t *testing.T) {
+	extractor := NewFixedLengthPrefixExtractor(4)
+
+	prefix, ok := extractor.Prefix([]byte("user:100"))
+	assert.True(t, ok)
+	assert.Equal(t, []byte("user"), prefix)
+	assert.Equal(t, "fixed-length-prefix:4", extractor.Name())
+}
+
+func TestFixedLengthPrefixExtractorWithAKeyOutsideItsDomain(t *testing.T) {
+	extractor := NewFixedLengthPrefixExtractor(4)
+
+	_, ok := extractor.Prefix([]byte("abc"))
+	assert.False(t, ok)
+}
+
+func TestBloomFilterWithPrefixes(t *testing.T) {
+	bloomFilterBuilder := NewBloomFilterBuilder()
+	bloomFilterBuilder.AddBytes([]byte("user"))
+	bloomFilterBuilder.AddBytes([]byte("item"))
+
+	bloomFilter := bloomFilterBuilder.Build(0.001)
+	assert.True(t, bloomFilter.MayContainBytes([]byte("user")))
+	assert.True(t, bloomFilter.MayContainBytes([]byte("item")))
+	assert.False(t, bloomFilter.MayContainBytes([]byte("cart")))
+}
+
+func TestEmptyBloomFilter(t *testing.T) {
+	bloomFilter := NewBloomFilterBuilder().Build(0.01)
+	encoded, err := bloomFilter.Encode()
+	assert.Nil(t, err)
+
+	decodedBloomFilter, err := DecodeToBloomFilter(encoded)
+	assert.Nil(t, err)
+	assert.False(t, decodedBloomFilter.MayContainBytes([]byte("user")))
+}
@@ -2,23 +2,59 @@ package table
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
+	"go-lsm/checksum"
 	"go-lsm/kv"
 	"go-lsm/table/block"
 	"go-lsm/table/bloom"
+	"go-lsm/table/compress"
+	"os"
 	"path/filepath"
+	"time"
 )
 
+// SSTableBuilderOptions represents the options for building an SSTable.
+// BlockSize limits the (uncompressed) size of each block, and Compression is the codec used to compress each block.
+// RestartInterval is the number of keys between two restart points in a block (refer to block.Builder),
+// 0 means block.DefaultRestartInterval.
+// BloomFalsePositiveRate is the false positive rate of the bloom filters, 0 means bloom.FalsePositiveRate.
+// FilterType is the implementation of the bloom filters, 0 means bloom.StandardFilterType.
+// PrefixExtractor (optional) builds a prefix bloom filter over the prefixes of the keys, in addition to the bloom filter
+// over the keys.
+// IndexPartitionSize limits the (encoded) size of each index partition of a partitioned index (refer to
+// PartitionedIndexType), 0 means a flat index (FlatIndexType).
+// DataBlockHashIndex builds a hash index in every data block, which speeds up the point lookups (refer to
+// SSTable.SeekToKeyForPointLookup).
+type SSTableBuilderOptions struct {
+	BlockSize              uint
+	Compression            compress.CodecType
+	RestartInterval        uint
+	BloomFalsePositiveRate float64
+	FilterType             bloom.FilterType
+	PrefixExtractor        bloom.PrefixExtractor
+	IndexPartitionSize     uint
+	DataBlockHashIndex     bool
+}
+
 // SSTableBuilder allows building SSTable in a step-by-step manner.
 type SSTableBuilder struct {
 	blockBuilder       *block.Builder
 	blockMetaList      *block.MetaList
 	bloomFilterBuilder *bloom.FilterBuilder
+	falsePositiveRate  float64
+	filterType         bloom.FilterType
+	prefixExtractor    bloom.PrefixExtractor
+	prefixFilter       *bloom.FilterBuilder
+	lastPrefix         []byte
 	startingKey        kv.Key
 	endingKey          kv.Key
 	allBlocksData      []byte
 	blockSize          uint
+	restartInterval    uint
+	indexPartitionSize uint
+	dataBlockHashIndex bool
+	codec              compress.Codec
+	properties         Properties
 }
 
 // NewSSTableBuilderWithDefaultBlockSize creates a new instance of SSTableBuilder with block.DefaultBlockSize = 4Kb.
@@ -28,27 +64,66 @@ func NewSSTableBuilderWithDefaultBlockSize() *SSTableBuilder {
 
 // NewSSTableBuilder creates a new instance of SSTableBuilder with the given block size.
 // The specified block size will be used to limit the size of each block that will be a part of the final SSTable.
+// The blocks are not compressed.
 func NewSSTableBuilder(blockSize uint) *SSTableBuilder {
-	return &SSTableBuilder{
-		blockBuilder:       block.NewBlockBuilder(blockSize),
+	return NewSSTableBuilderWithOptions(SSTableBuilderOptions{BlockSize: blockSize, Compression: compress.None})
+}
+
+// NewSSTableBuilderWithOptions creates a new instance of SSTableBuilder with the given SSTableBuilderOptions.
+// It panics if the compression codec or the filter type is not supported, the options are expected to be validated by the caller.
+func NewSSTableBuilderWithOptions(options SSTableBuilderOptions) *SSTableBuilder {
+	codec, err := compress.CodecFor(options.Compression)
+	if err != nil {
+		panic(err)
+	}
+	falsePositiveRate := options.BloomFalsePositiveRate
+	if falsePositiveRate == 0 {
+		falsePositiveRate = bloom.FalsePositiveRate
+	}
+	filterType := options.FilterType
+	if filterType == 0 {
+		filterType = bloom.StandardFilterType
+	}
+	if err := bloom.ValidateFilterType(filterType); err != nil {
+		panic(err)
+	}
+	var prefixFilter *bloom.FilterBuilder
+	if options.PrefixExtractor != nil {
+		prefixFilter = bloom.NewBloomFilterBuilder()
+	}
+	builder := &SSTableBuilder{
 		blockMetaList:      block.NewBlockMetaList(),
 		bloomFilterBuilder: bloom.NewBloomFilterBuilder(),
-		blockSize:          blockSize,
+		falsePositiveRate:  falsePositiveRate,
+		filterType:         filterType,
+		prefixExtractor:    options.PrefixExtractor,
+		prefixFilter:       prefixFilter,
+		blockSize:          options.BlockSize,
+		restartInterval:    options.RestartInterval,
+		indexPartitionSize: options.IndexPartitionSize,
+		dataBlockHashIndex: options.DataBlockHashIndex,
+		codec:              codec,
+		properties:         Properties{BlockSizeInBytes: uint64(options.BlockSize)},
 	}
+	builder.blockBuilder = builder.newBlockBuilder()
+	return builder
 }
 
 // Add adds the key/value pair in the current block builder.
 // Add involves:
 // 1) Keeping a track of the starting key and ending key of the current block.
-// 2) Adding the key to the bloom.FilterBuilder
-// 3) Adding the key/value pair to the current block.Builder.
-// 4) Finishing the current block, if it is full and starting a new block (or block.Builder).
+// 2) Adding the key to the bloom.FilterBuilder, and its prefix to the prefix bloom.FilterBuilder (if there is a bloom.PrefixExtractor).
+// 3) Collecting the Properties of the key/value pair.
+// 4) Adding the key/value pair to the current block.Builder.
+// 5) Finishing the current block, if it is full and starting a new block (or block.Builder).
 func (builder *SSTableBuilder) Add(key kv.Key, value kv.Value) {
 	if builder.startingKey.IsRawKeyEmpty() {
 		builder.startingKey = key
 	}
 	builder.endingKey = key
 	builder.bloomFilterBuilder.Add(key)
+	builder.mayBeAddPrefix(key)
+	builder.properties.addEntry(key, value)
 	if builder.blockBuilder.Add(key, value) {
 		return
 	}
@@ -60,70 +135,185 @@ func (builder *SSTableBuilder) Add(key kv.Key, value kv.Value) {
 // Build builds the SSTable using the given id and rootPath.
 // It involves encoding the SSTable, writing the entire table to persistent storage and creating an in-memory representation
 // in the form of SSTable with a reference to its File.
+// Each data block is compressed using the codec of the builder, and carries the compress.CodecType as its 1-byte trailer
+// (refer to compress.Compress). Each data block, the metadata section and the bloom filter section are followed by
+// a 4-byte CRC32C checksum (refer to checksum.Append). The properties section (refer to Properties) follows the bloom
+// filter section. The bloom filter section (and the prefix filter section) starts with the bloom.FilterType of the filter
+// (refer to encodeFilter). The properties section is followed by the prefix filter section (refer to prefixFilter), only if the builder has a
+// bloom.PrefixExtractor. With a partitioned index, the index partitions (each an encoded block.MetaList with checksum)
+// follow the data blocks, and the metadata section contains the top-level index (refer to partitionedIndex.encode).
+// The metadata section starts with the IndexType (refer to encodeBlockIndex). The SSTable ends with a fixed-size Footer which contains the offsets and sizes of the metadata,
+// the bloom filter, the properties and the prefix filter sections, the format version and the magic number.
 // The encoding looks like:
 /**
-  ----------------------------------------------------------------------------------------------------------------------------------------------------------
-| data block | data block |...| data block | metadata section | 4 bytes for meta starting offset | bloom filter section | 4 bytes for bloom starting offset |
-|										   |				  |									 |		                |                                   |			                                        |
- ----------------------------------------------------------------------------------------------------------------------------------------------------------
+  ---------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------
+| data block |...| data block | (optional) index partitions | metadata section | checksum | bloom filter section | checksum | properties section | checksum | (optional) prefix filter section | checksum | Footer (FooterSize) |
+  ---------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------
 */
+// A data block looks like:
+/**
+  ---------------------------------------------------------------------------------------------------
+| compressed (or raw) block.Block | 1 byte compress.CodecType | 1 byte block.FormatVersion | checksum |
+  ---------------------------------------------------------------------------------------------------
+*/
+// block.FormatVersion allows reading the blocks written in an older format (block.FormatVersionFullKeys).
+// Every SSTable with a Footer has the block trailer (compress.CodecType, block.FormatVersion and checksum) after every data
+// block. The SSTables written before the Footer was introduced are not supported, loading them fails with ErrInvalidSSTable.
 func (builder *SSTableBuilder) Build(id uint64, rootPath string) (*SSTable, error) {
-	blockMetaStartingOffset := func() []byte {
-		blockMetaStartingOffset := make([]byte, block.Uint32Size)
-		binary.LittleEndian.PutUint32(blockMetaStartingOffset, uint32(len(builder.allBlocksData)))
-		return blockMetaStartingOffset
-	}
-	bloomStartingOffset := func(buffer *bytes.Buffer) []byte {
-		bloomStartingOffset := make([]byte, block.Uint32Size)
-		binary.LittleEndian.PutUint32(bloomStartingOffset, uint32(buffer.Len()))
-		return bloomStartingOffset
-	}
+	return builder.BuildWithReadOptions(id, rootPath, ReadOptions{})
+}
 
+// BuildWithReadOptions builds the SSTable using the given id and rootPath (refer to Build), and the built SSTable uses
+// the given ReadOptions for reading its blocks.
+func (builder *SSTableBuilder) BuildWithReadOptions(id uint64, rootPath string, readOptions ReadOptions) (*SSTable, error) {
 	builder.finishBlock()
 	buffer := new(bytes.Buffer)
-	buffer.Write(builder.allBlocksData)          //data blocks
-	buffer.Write(builder.blockMetaList.Encode()) //metadata section block.MetaList.Encode()
-	buffer.Write(blockMetaStartingOffset())      //4 bytes to indicate where the meta section starts from
-	filter := builder.bloomFilterBuilder.Build(bloom.FalsePositiveRate)
-	encodedFilter, err := filter.Encode()
+	buffer.Write(builder.allBlocksData) //data blocks
+	index := builder.buildBlockIndex(buffer)
+	//metadata section (IndexType and the block index) with checksum
+	metaSection := writeSection(buffer, checksum.Append(encodeBlockIndex(index)))
+
+	filter := builder.bloomFilterBuilder.BuildOfType(builder.filterType, builder.falsePositiveRate)
+	encodedFilter, err := encodeFilter(filter)
 	if err != nil {
 		return nil, err
 	}
+	//bloom filter section (bloom.FilterType and bloom.Filter.Encode()) with checksum
+	bloomSection := writeSection(buffer, checksum.Append(encodedFilter))
+	//properties section Properties.encode() with checksum
+	builder.properties.CreationTimeInUnixSeconds = uint64(time.Now().Unix())
+	propertiesSection := writeSection(buffer, checksum.Append(builder.properties.encode()))
 
-	bloomFilterStartingOffset := bloomStartingOffset(buffer)
-	buffer.Write(encodedFilter)             //bloom filter section bloom.Filter.Encode()
-	buffer.Write(bloomFilterStartingOffset) //4 bytes to indicate where the bloom filter section starts from
+	var prefixFilter *prefixFilter
+	var prefixFilterSection SectionHandle
+	if builder.prefixExtractor != nil {
+		prefixFilter = builder.buildPrefixFilter()
+		encodedPrefixFilter, err := prefixFilter.encode()
+		if err != nil {
+			return nil, err
+		}
+		//prefix filter section prefixFilter.encode() with checksum
+		prefixFilterSection = writeSection(buffer, checksum.Append(encodedPrefixFilter))
+	}
+	buffer.Write(Footer{
+		MetaSection:         metaSection,
+		BloomSection:        bloomSection,
+		PropertiesSection:   propertiesSection,
+		PrefixFilterSection: prefixFilterSection,
+		FormatVersion:       LatestFooterFormatVersion,
+	}.encode())
 
 	file, err := CreateAndWrite(SSTableFilePath(id, rootPath), buffer.Bytes())
 	if err != nil {
 		return nil, err
 	}
+	if readOptions.MemoryMapped {
+		if err := file.memoryMap(); err != nil {
+			_ = file.Close()
+			_ = os.Remove(SSTableFilePath(id, rootPath))
+			return nil, err
+		}
+	}
 
-	startingKey, _ := builder.blockMetaList.StartingKeyOfFirstBlock()
-	endingKey, _ := builder.blockMetaList.EndingKeyOfLastBlock()
-	return &SSTable{
-		id:                      id,
-		file:                    file,
-		blockMetaList:           builder.blockMetaList,
-		bloomFilter:             filter,
-		blockMetaStartingOffset: uint32(len(builder.allBlocksData)),
-		blockSize:               builder.blockSize,
-		startingKey:             startingKey,
-		endingKey:               endingKey,
-	}, nil
+	return newSSTable(&SSTable{
+		id:            id,
+		formatVersion: LatestFooterFormatVersion,
+		index:         index,
+		bloomFilter:   filter,
+		prefixFilter:  prefixFilter,
+		blockSize:     builder.blockSize,
+		startingKey:   index.startingKey(),
+		endingKey:     index.endingKey(),
+		properties:    builder.properties,
+	}, file, readOptions), nil
 }
 
-// EstimatedSize returns an estimate of the size of the encoded data of all the blocks.
+// buildBlockIndex builds the blockIndex over the block meta-list.
+// If the builder has an indexPartitionSize, the block meta-list is split into index partitions, each of which is written
+// (with checksum) to the buffer, and the returned partitionedIndex only keeps the top-level index. Each partition
+// contains at least one block.Meta, so a partition may go beyond indexPartitionSize if a single block.Meta is larger.
+// Otherwise, it returns a flatIndex over the entire block meta-list.
+func (builder *SSTableBuilder) buildBlockIndex(buffer *bytes.Buffer) blockIndex {
+	endOfDataBlocks := uint32(len(builder.allBlocksData))
+	if builder.indexPartitionSize == 0 {
+		return &flatIndex{metaList: builder.blockMetaList, endOfDataBlocks: endOfDataBlocks}
+	}
+
+	index := &partitionedIndex{totalBlocks: builder.blockMetaList.Length(), endOfDataBlocks: endOfDataBlocks}
+	partition, firstBlockIndex, partitionSize := block.NewBlockMetaList(), 0, 0
+	writePartition := func() {
+		firstBlockMeta, _ := partition.GetAt(0)
+		startingKey, _ := partition.StartingKeyOfFirstBlock()
+		endingKey, _ := partition.EndingKeyOfLastBlock()
+		index.partitions = append(index.partitions, indexPartition{
+			section:          writeSection(buffer, checksum.Append(partition.Encode())),
+			firstBlockIndex:  uint32(firstBlockIndex),
+			firstBlockOffset: firstBlockMeta.BlockStartingOffset,
+			startingKey:      startingKey,
+			endingKey:        endingKey,
+		})
+	}
+	for blockIndex := 0; blockIndex < builder.blockMetaList.Length(); blockIndex++ {
+		blockMeta, _ := builder.blockMetaList.GetAt(blockIndex)
+		if partition.Length() > 0 && partitionSize+blockMeta.EncodedSizeInBytes() > int(builder.indexPartitionSize) {
+			writePartition()
+			partition, firstBlockIndex, partitionSize = block.NewBlockMetaList(), blockIndex, 0
+		}
+		partition.Add(blockMeta)
+		partitionSize += blockMeta.EncodedSizeInBytes()
+	}
+	if partition.Length() > 0 {
+		writePartition()
+	}
+	return index
+}
+
+// writeSection writes the section to the buffer, and returns its SectionHandle.
+func writeSection(buffer *bytes.Buffer, section []byte) SectionHandle {
+	handle := SectionHandle{Offset: uint32(buffer.Len()), Size: uint32(len(section))}
+	buffer.Write(section)
+	return handle
+}
+
+// mayBeAddPrefix adds the prefix of the key to the prefix bloom.FilterBuilder, if the builder has a bloom.PrefixExtractor
+// and the key is in the domain of the bloom.PrefixExtractor.
+// The keys are added in the sorted order, so a prefix is added only if it is different from the previous prefix.
+func (builder *SSTableBuilder) mayBeAddPrefix(key kv.Key) {
+	if builder.prefixExtractor == nil {
+		return
+	}
+	prefix, ok := builder.prefixExtractor.Prefix(key.RawBytes())
+	if !ok || (builder.lastPrefix != nil && bytes.Equal(prefix, builder.lastPrefix)) {
+		return
+	}
+	builder.prefixFilter.AddBytes(prefix)
+	builder.lastPrefix = prefix
+}
+
+// buildPrefixFilter builds the prefixFilter using the prefix bloom.FilterBuilder.
+func (builder *SSTableBuilder) buildPrefixFilter() *prefixFilter {
+	return &prefixFilter{
+		extractorName: builder.prefixExtractor.Name(),
+		filter:        builder.prefixFilter.BuildOfType(builder.filterType, builder.falsePositiveRate),
+	}
+}
+
+// EstimatedSize returns an estimate of the size of the encoded (and compressed) data of all the blocks.
 func (builder SSTableBuilder) EstimatedSize() int {
 	return len(builder.allBlocksData)
 }
 
 // finishBlock finishes the current block. It involves:
-// 1) Encoding the current block.
+// 1) Encoding and compressing the current block, followed by appending its block.FormatVersion and checksum.
 // 2) Storing the block.Meta in the block meta-list.
 // 3) Collecting the encoded data of the current block in allBlocksData.
+// 4) Collecting the Properties of the block.
 func (builder *SSTableBuilder) finishBlock() {
-	encodedBlock := builder.blockBuilder.Build().Encode()
+	currentBlock := builder.blockBuilder.Build()
+	uncompressedBlock := currentBlock.Encode()
+	encodedBlock := compress.Compress(builder.codec, uncompressedBlock)
+	encodedBlock = checksum.Append(append(encodedBlock, byte(currentBlock.FormatVersion())))
+	builder.properties.addDataBlock(len(uncompressedBlock), len(encodedBlock))
 	builder.blockMetaList.Add(block.Meta{
 		BlockStartingOffset: uint32(len(builder.allBlocksData)),
 		StartingKey:         builder.startingKey,
@@ -134,11 +324,19 @@ func (builder *SSTableBuilder) finishBlock() {
 
 // startNewBlockBuilder creates a new instance of SSTableBuilder.
 func (builder *SSTableBuilder) startNewBlockBuilder(key kv.Key) {
-	builder.blockBuilder = block.NewBlockBuilder(builder.blockSize)
+	builder.blockBuilder = builder.newBlockBuilder()
 	builder.startingKey = key
 	builder.endingKey = key
 }
 
+// newBlockBuilder creates a new instance of block.Builder, with the hash index if the builder has dataBlockHashIndex.
+func (builder *SSTableBuilder) newBlockBuilder() *block.Builder {
+	if builder.dataBlockHashIndex {
+		return block.NewBlockBuilderWithHashIndex(builder.blockSize, builder.restartInterval)
+	}
+	return block.NewBlockBuilderWithRestartInterval(builder.blockSize, builder.restartInterval)
+}
+
 // SSTableFilePath returns the SSTable filepath which consists of rootPath/id.sst.
 func SSTableFilePath(id uint64, rootPath string) string {
 	return filepath.Join(rootPath, fmt.Sprintf("%v.sst", id))
@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+const DefaultNumberOfShards = 16
+
+// BlockKind identifies the kind of the cached block, the data blocks and the index blocks (/partitions) of an SSTable
+// are indexed independently.
+type BlockKind uint8
+
+const (
+	// DataBlock identifies a data block (block.Block).
+	DataBlock BlockKind = iota
+	// IndexBlock identifies an index partition (block.MetaList) of a partitioned index.
+	IndexBlock
+)
+
+// BlockId identifies a block in the BlockCache: the id of the SSTable, the index of the block within the SSTable and the
+// kind of the block. The zero value of Kind is DataBlock.
+type BlockId struct {
+	SSTableId  uint64
+	BlockIndex int
+	Kind       BlockKind
+}
+
+// Value represents a value stored in the BlockCache, typically a decoded block.Block.
+type Value interface {
+	SizeInBytes() int
+}
+
+// Stats represents the statistics of the BlockCache.
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	SizeInBytes int64
+	Entries     int
+}
+
+// BlockCache is a sharded LRU cache of decoded blocks, keyed by BlockId, with a capacity in bytes.
+// The capacity is split equally across the shards, and each shard is protected by its own lock, which reduces lock
+// contention between concurrent readers.
+//
+// An entry is pinned while it is in use (typically by a table.Iterator), a pinned entry is never evicted.
+// Pinned entries count towards the size of the cache, so the cache may temporarily grow beyond its capacity if a lot of
+// entries are pinned. Unpinned entries are evicted in the least-recently-used order, once the size goes beyond capacity.
+// Every GetAndPin/PutAndPin must be followed by Handle.Release.
+//
+// SSTable ids are unique within a single StorageState, hence a BlockCache must not be shared between two instances of
+// StorageState.
+type BlockCache struct {
+	shards    []*shard
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// Handle represents a pinned entry of the BlockCache.
+type Handle struct {
+	entry    *entry
+	shard    *shard
+	released bool
+}
+
+// shard is an LRU cache with its own capacity.
+// entries contains all the entries of the shard (pinned and unpinned), whereas lru only contains the unpinned entries
+// with the most recently used entry at the front.
+type shard struct {
+	lock            sync.Mutex
+	capacityInBytes int64
+	sizeInBytes     int64
+	entries         map[BlockId]*entry
+	lru             *list.List
+	evictions       *atomic.Uint64
+}
+
+// entry represents a cached Value.
+// element is non-nil only if the entry is unpinned (present in the lru list).
+// detached is true if the entry was removed from the cache (EvictSSTable) while it was pinned, such an entry is dropped
+// when its last pin is released.
+type entry struct {
+	id       BlockId
+	value    Value
+	size     int64
+	pins     int
+	element  *list.Element
+	detached bool
+}
+
+// NewBlockCache creates a new instance of BlockCache with the given capacity and DefaultNumberOfShards.
+func NewBlockCache(capacityInBytes int64) *BlockCache {
+	return NewBlockCacheWithShards(capacityInBytes, DefaultNumberOfShards)
+}
+
+// NewBlockCacheWithShards creates a new instance of BlockCache with the given capacity and number of shards.
+func NewBlockCacheWithShards(capacityInBytes int64, numberOfShards int) *BlockCache {
+	if numberOfShards <= 0 {
+		numberOfShards = DefaultNumberOfShards
+	}
+	cache := &BlockCache{shards: make([]*shard, numberOfShards)}
+	for index := 0; index < numberOfShards; index++ {
+		cache.shards[index] = &shard{
+			capacityInBytes: capacityInBytes / int64(numberOfShards),
+			entries:         make(map[BlockId]*entry),
+			lru:             list.New(),
+			evictions:       &cache.evictions,
+		}
+	}
+	return cache
+}
+
+// GetAndPin returns the pinned Handle of the entry identified by the id, if the entry exists.
+// It counts a hit if the entry exists, a miss otherwise.
+func (cache *BlockCache) GetAndPin(id BlockId) (*Handle, bool) {
+	shard := cache.shardFor(id)
+	handle, ok := shard.getAndPin(id)
+	if ok {
+		cache.hits.Add(1)
+	} else {
+		cache.misses.Add(1)
+	}
+	return handle, ok
+}
+
+// PutAndPin puts the value in the cache and returns its pinned Handle.
+// If an entry with the same id exists (put concurrently by another reader), the existing entry is pinned and returned.
+func (cache *BlockCache) PutAndPin(id BlockId, value Value) *Handle {
+	return cache.shardFor(id).putAndPin(id, value)
+}
+
+// EvictSSTable removes all the entries of the given SSTable from the cache.
+// It is invoked when the SSTable is removed (after compaction) by the table.SSTableCleaner.
+// The entries that are pinned are dropped when they are released.
+func (cache *BlockCache) EvictSSTable(ssTableId uint64) {
+	for _, shard := range cache.shards {
+		shard.evictSSTable(ssTableId)
+	}
+}
+
+// Stats returns the statistics of the BlockCache.
+func (cache *BlockCache) Stats() Stats {
+	stats := Stats{
+		Hits:      cache.hits.Load(),
+		Misses:    cache.misses.Load(),
+		Evictions: cache.evictions.Load(),
+	}
+	for _, shard := range cache.shards {
+		shard.lock.Lock()
+		stats.SizeInBytes += shard.sizeInBytes
+		stats.Entries += len(shard.entries)
+		shard.lock.Unlock()
+	}
+	return stats
+}
+
+// shardFor returns the shard for the given id.
+func (cache *BlockCache) shardFor(id BlockId) *shard {
+	hash := id.SSTableId*0x9e3779b97f4a7c15 ^ uint64(id.BlockIndex)*0xc2b2ae3d27d4eb4f ^ uint64(id.Kind)
+	hash ^= hash >> 29
+	return cache.shards[hash%uint64(len(cache.shards))]
+}
+
+// Value returns the Value of the pinned entry.
+func (handle *Handle) Value() Value {
+	return handle.entry.value
+}
+
+// Release unpins the entry. It is safe to invoke Release on a nil Handle, and more than once.
+func (handle *Handle) Release() {
+	if handle == nil {
+		return
+	}
+	handle.shard.release(handle)
+}
+
+// getAndPin returns the pinned Handle of the entry identified by the id, if the entry exists.
+func (shard *shard) getAndPin(id BlockId) (*Handle, bool) {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	existing, ok := shard.entries[id]
+	if !ok {
+		return nil, false
+	}
+	return shard.pin(existing), true
+}
+
+// putAndPin puts the value in the shard, and returns its pinned Handle.
+func (shard *shard) putAndPin(id BlockId, value Value) *Handle {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	if existing, ok := shard.entries[id]; ok {
+		return shard.pin(existing)
+	}
+	newEntry := &entry{id: id, value: value, size: int64(value.SizeInBytes())}
+	shard.entries[id] = newEntry
+	shard.sizeInBytes += newEntry.size
+
+	handle := shard.pin(newEntry)
+	shard.mayBeEvict()
+	return handle
+}
+
+// release unpins the entry of the handle. An unpinned entry is moved to the front of the lru list, or dropped if it is detached.
+func (shard *shard) release(handle *Handle) {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	if handle.released {
+		return
+	}
+	handle.released = true
+
+	releasedEntry := handle.entry
+	releasedEntry.pins--
+	if releasedEntry.pins > 0 {
+		return
+	}
+	if releasedEntry.detached {
+		shard.sizeInBytes -= releasedEntry.size
+		return
+	}
+	releasedEntry.element = shard.lru.PushFront(releasedEntry)
+	shard.mayBeEvict()
+}
+
+// evictSSTable removes all the entries of the given SSTable from the shard.
+func (shard *shard) evictSSTable(ssTableId uint64) {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	for id, existing := range shard.entries {
+		if id.SSTableId != ssTableId {
+			continue
+		}
+		delete(shard.entries, id)
+		if existing.pins > 0 {
+			existing.detached = true
+			continue
+		}
+		shard.lru.Remove(existing.element)
+		existing.element = nil
+		shard.sizeInBytes -= existing.size
+	}
+}
+
+// pin pins the entry, and removes it from the lru list (if it is present), so that it is not evicted.
+func (shard *shard) pin(existing *entry) *Handle {
+	if existing.element != nil {
+		shard.lru.Remove(existing.element)
+		existing.element = nil
+	}
+	existing.pins++
+	return &Handle{entry: existing, shard: shard}
+}
+
+// mayBeEvict evicts the least-recently-used unpinned entries till the size of the shard is within its capacity.
+func (shard *shard) mayBeEvict() {
+	for shard.sizeInBytes > shard.capacityInBytes && shard.lru.Len() > 0 {
+		evicted := shard.lru.Remove(shard.lru.Back()).(*entry)
+		evicted.element = nil
+		delete(shard.entries, evicted.id)
+		shard.sizeInBytes -= evicted.size
+		shard.evictions.Add(1)
+	}
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sizedValue struct {
+	size int
+}
+
+func (value sizedValue) SizeInBytes() int {
+	return value.size
+}
+
+func TestBlockCacheGetOfAMissingBlock(t *testing.T) {
+	cache := NewBlockCacheWithShards(1024, 1)
+
+	handle, ok := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0})
+	assert.False(t, ok)
+	assert.Nil(t, handle)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(0), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+}
+
+func TestBlockCachePutAndGet(t *testing.T) {
+	cache := NewBlockCacheWithShards(1024, 1)
+
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100}).Release()
+
+	handle, ok := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0})
+	assert.True(t, ok)
+	assert.Equal(t, sizedValue{size: 100}, handle.Value())
+	handle.Release()
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, int64(100), stats.SizeInBytes)
+	assert.Equal(t, 1, stats.Entries)
+}
+
+func TestBlockCachePutOfAnExistingBlockReturnsTheExistingValue(t *testing.T) {
+	cache := NewBlockCacheWithShards(1024, 1)
+
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100}).Release()
+	handle := cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 200})
+	defer handle.Release()
+
+	assert.Equal(t, sizedValue{size: 100}, handle.Value())
+	assert.Equal(t, int64(100), cache.Stats().SizeInBytes)
+}
+
+func TestBlockCacheEvictsTheLeastRecentlyUsedBlock(t *testing.T) {
+	cache := NewBlockCacheWithShards(300, 1)
+
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100}).Release()
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 1}, sizedValue{size: 100}).Release()
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 2}, sizedValue{size: 100}).Release()
+
+	handle, _ := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0})
+	handle.Release()
+
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 3}, sizedValue{size: 100}).Release()
+
+	_, ok := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 1})
+	assert.False(t, ok)
+
+	handle, ok = cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0})
+	assert.True(t, ok)
+	handle.Release()
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, int64(300), stats.SizeInBytes)
+	assert.Equal(t, 3, stats.Entries)
+}
+
+func TestBlockCacheDoesNotEvictAPinnedBlock(t *testing.T) {
+	cache := NewBlockCacheWithShards(200, 1)
+
+	pinned := cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100})
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 1}, sizedValue{size: 100}).Release()
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 2}, sizedValue{size: 100}).Release()
+
+	handle, ok := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0})
+	assert.True(t, ok)
+	handle.Release()
+
+	_, ok = cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 1})
+	assert.False(t, ok)
+
+	pinned.Release()
+	assert.Equal(t, int64(200), cache.Stats().SizeInBytes)
+}
+
+func TestBlockCacheGrowsBeyondCapacityWithPinnedBlocks(t *testing.T) {
+	cache := NewBlockCacheWithShards(100, 1)
+
+	first := cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100})
+	second := cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 1}, sizedValue{size: 100})
+	assert.Equal(t, int64(200), cache.Stats().SizeInBytes)
+
+	first.Release()
+	second.Release()
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(100), stats.SizeInBytes)
+	assert.Equal(t, 1, stats.Entries)
+}
+
+func TestBlockCacheReleaseIsIdempotent(t *testing.T) {
+	cache := NewBlockCacheWithShards(1024, 1)
+
+	first := cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100})
+	second, _ := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0})
+
+	first.Release()
+	first.Release()
+
+	cache.EvictSSTable(1)
+	assert.Equal(t, int64(100), cache.Stats().SizeInBytes)
+
+	second.Release()
+	assert.Equal(t, int64(0), cache.Stats().SizeInBytes)
+
+	var nilHandle *Handle
+	nilHandle.Release()
+}
+
+func TestBlockCacheEvictSSTable(t *testing.T) {
+	cache := NewBlockCache(4096)
+
+	for blockIndex := 0; blockIndex < 10; blockIndex++ {
+		cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: blockIndex}, sizedValue{size: 10}).Release()
+		cache.PutAndPin(BlockId{SSTableId: 2, BlockIndex: blockIndex}, sizedValue{size: 10}).Release()
+	}
+	cache.EvictSSTable(1)
+
+	for blockIndex := 0; blockIndex < 10; blockIndex++ {
+		_, ok := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: blockIndex})
+		assert.False(t, ok)
+
+		handle, ok := cache.GetAndPin(BlockId{SSTableId: 2, BlockIndex: blockIndex})
+		assert.True(t, ok)
+		handle.Release()
+	}
+	stats := cache.Stats()
+	assert.Equal(t, 10, stats.Entries)
+	assert.Equal(t, int64(100), stats.SizeInBytes)
+}
+
+func TestBlockCacheEvictSSTableWithAPinnedBlock(t *testing.T) {
+	cache := NewBlockCacheWithShards(1024, 1)
+
+	pinned := cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100})
+	cache.EvictSSTable(1)
+
+	_, ok := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0})
+	assert.False(t, ok)
+	assert.Equal(t, sizedValue{size: 100}, pinned.Value())
+	assert.Equal(t, int64(100), cache.Stats().SizeInBytes)
+
+	pinned.Release()
+	stats := cache.Stats()
+	assert.Equal(t, int64(0), stats.SizeInBytes)
+	assert.Equal(t, 0, stats.Entries)
+}
+
+func TestBlockCacheIndexesBlocksOfDifferentKindsIndependently(t *testing.T) {
+	cache := NewBlockCacheWithShards(1024, 1)
+
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0}, sizedValue{size: 100}).Release()
+	cache.PutAndPin(BlockId{SSTableId: 1, BlockIndex: 0, Kind: IndexBlock}, sizedValue{size: 50}).Release()
+
+	handle, ok := cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0, Kind: DataBlock})
+	assert.True(t, ok)
+	assert.Equal(t, sizedValue{size: 100}, handle.Value())
+	handle.Release()
+
+	handle, ok = cache.GetAndPin(BlockId{SSTableId: 1, BlockIndex: 0, Kind: IndexBlock})
+	assert.True(t, ok)
+	assert.Equal(t, sizedValue{size: 50}, handle.Value())
+	handle.Release()
+
+	cache.EvictSSTable(1)
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
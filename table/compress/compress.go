@@ -0,0 +1,121 @@
+package compress
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CodecType identifies a Codec. CodecType is stored as the last byte (/trailer) of every block in the SSTable, so the
+// values of the existing codec types must never change.
+type CodecType uint8
+
+const (
+	None   CodecType = 0
+	Flate  CodecType = 1
+	Zlib   CodecType = 2
+	Snappy CodecType = 3
+)
+
+const CodecTypeSize = 1
+
+// minimumSavingsFraction defines the minimum savings that a codec must provide, for the compressed data to be stored.
+// The compressed data is stored only if it is smaller than (len(data) - len(data)/minimumSavingsFraction), that is,
+// compression must save at least 12.5%. Otherwise, the cost of decompressing the data on every read is not worth it.
+const minimumSavingsFraction = 8
+
+var CorruptInputErr = errors.New("compressed input is corrupt")
+
+// Codec represents a compression algorithm.
+type Codec interface {
+	// Type returns the CodecType of the Codec.
+	Type() CodecType
+	// Encode compresses the source.
+	Encode(source []byte) ([]byte, error)
+	// Decode decompresses the source which was compressed using Encode.
+	Decode(source []byte) ([]byte, error)
+}
+
+var codecs = map[CodecType]Codec{
+	None:   noCompressionCodec{},
+	Flate:  flateCodec{},
+	Zlib:   zlibCodec{},
+	Snappy: snappyCodec{},
+}
+
+// CodecFor returns the Codec for the given CodecType.
+// It returns an error if the CodecType is not supported.
+func CodecFor(codecType CodecType) (Codec, error) {
+	codec, ok := codecs[codecType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression codec type %v", codecType)
+	}
+	return codec, nil
+}
+
+// Compress compresses the data using the given codec and appends the CodecType as a 1-byte trailer.
+/*
+  ------------------------------------------------
+ | compressed (or raw) data | 1 byte CodecType  |
+  ------------------------------------------------
+*/
+// It falls back to storing the raw data (with None as the trailer), if the codec fails to encode the data, or
+// the codec does not save at least 1/minimumSavingsFraction of the size of the data.
+func Compress(codec Codec, data []byte) []byte {
+	raw := func() []byte {
+		buffer := make([]byte, 0, len(data)+CodecTypeSize)
+		buffer = append(buffer, data...)
+		return append(buffer, byte(None))
+	}
+	if codec.Type() == None {
+		return raw()
+	}
+	compressed, err := codec.Encode(data)
+	if err != nil || len(compressed) >= len(data)-len(data)/minimumSavingsFraction {
+		return raw()
+	}
+	return append(compressed, byte(codec.Type()))
+}
+
+// Decompress decompresses the buffer which was created using Compress.
+// It reads the CodecType from the last byte of the buffer and decodes the rest of the buffer using the corresponding Codec.
+func Decompress(buffer []byte) ([]byte, error) {
+	if len(buffer) < CodecTypeSize {
+		return nil, CorruptInputErr
+	}
+	codec, err := CodecFor(CodecType(buffer[len(buffer)-CodecTypeSize]))
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(buffer[:len(buffer)-CodecTypeSize])
+}
+
+// String returns the name of the CodecType.
+func (codecType CodecType) String() string {
+	switch codecType {
+	case None:
+		return "none"
+	case Flate:
+		return "flate"
+	case Zlib:
+		return "zlib"
+	case Snappy:
+		return "snappy"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(codecType))
+	}
+}
+
+// noCompressionCodec stores the data as-is.
+type noCompressionCodec struct{}
+
+func (noCompressionCodec) Type() CodecType {
+	return None
+}
+
+func (noCompressionCodec) Encode(source []byte) ([]byte, error) {
+	return source, nil
+}
+
+func (noCompressionCodec) Decode(source []byte) ([]byte, error) {
+	return source, nil
+}
@@ -0,0 +1,100 @@
+package compress
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compressibleData() []byte {
+	buffer := new(bytes.Buffer)
+	for count := 0; count < 200; count++ {
+		buffer.WriteString("consensus:raft,storage:LSM,distributed:TiKV;")
+	}
+	return buffer.Bytes()
+}
+
+func incompressibleData() []byte {
+	data := make([]byte, 4096)
+	random := rand.New(rand.NewSource(7))
+	random.Read(data)
+	return data
+}
+
+func TestEncodeAndDecodeWithAllCodecs(t *testing.T) {
+	for _, codecType := range []CodecType{None, Flate, Zlib, Snappy} {
+		t.Run(codecType.String(), func(t *testing.T) {
+			codec, err := CodecFor(codecType)
+			assert.NoError(t, err)
+			assert.Equal(t, codecType, codec.Type())
+
+			data := compressibleData()
+			encoded, err := codec.Encode(data)
+			assert.NoError(t, err)
+
+			decoded, err := codec.Decode(encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, data, decoded)
+		})
+	}
+}
+
+func TestCodecForUnsupportedCodecType(t *testing.T) {
+	_, err := CodecFor(CodecType(100))
+	assert.Error(t, err)
+}
+
+func TestCompressWithTheCodecTypeInTrailer(t *testing.T) {
+	for _, codecType := range []CodecType{Flate, Zlib, Snappy} {
+		t.Run(codecType.String(), func(t *testing.T) {
+			codec, _ := CodecFor(codecType)
+			data := compressibleData()
+
+			compressed := Compress(codec, data)
+			assert.Equal(t, byte(codecType), compressed[len(compressed)-1])
+			assert.True(t, len(compressed) < len(data)/4)
+
+			decompressed, err := Decompress(compressed)
+			assert.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+		})
+	}
+}
+
+func TestCompressFallsBackToRawDataIfCompressionDoesNotHelp(t *testing.T) {
+	for _, codecType := range []CodecType{Flate, Zlib, Snappy} {
+		t.Run(codecType.String(), func(t *testing.T) {
+			codec, _ := CodecFor(codecType)
+			data := incompressibleData()
+
+			compressed := Compress(codec, data)
+			assert.Equal(t, byte(None), compressed[len(compressed)-1])
+			assert.Equal(t, data, compressed[:len(compressed)-1])
+
+			decompressed, err := Decompress(compressed)
+			assert.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+		})
+	}
+}
+
+func TestCompressWithNoCompressionCodec(t *testing.T) {
+	codec, _ := CodecFor(None)
+	data := compressibleData()
+
+	compressed := Compress(codec, data)
+	assert.Equal(t, len(data)+CodecTypeSize, len(compressed))
+	assert.Equal(t, byte(None), compressed[len(compressed)-1])
+}
+
+func TestDecompressWithUnsupportedCodecTypeInTrailer(t *testing.T) {
+	_, err := Decompress([]byte{1, 2, 3, 100})
+	assert.Error(t, err)
+}
+
+func TestDecompressAnEmptyBuffer(t *testing.T) {
+	_, err := Decompress(nil)
+	assert.ErrorIs(t, err, CorruptInputErr)
+}
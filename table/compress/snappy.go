@@ -0,0 +1,200 @@
+package compress
+
+import (
+	"encoding/binary"
+)
+
+const (
+	snappyTagLiteral = 0x00
+	snappyTagCopy1   = 0x01
+	snappyTagCopy2   = 0x02
+	snappyTagCopy4   = 0x03
+
+	snappyMinMatchLength   = 4
+	snappyMaxCopy2Offset   = 1<<16 - 1
+	snappyMaxCopy1Offset   = 1<<11 - 1
+	snappyMaxCopy1Length   = 11
+	snappyMaxCopy2Length   = 64
+	snappyHashTableBits    = 14
+	snappyMaxExpansionRate = 22
+)
+
+// snappyCodec is a pure-Go implementation of the Snappy block format
+// (https://github.com/google/snappy/blob/main/format_description.txt).
+// The encoding looks like:
+/*
+  ------------------------------------------------------------------
+ | uvarint uncompressed length | element | element | ... | element |
+  ------------------------------------------------------------------
+*/
+// Each element is either a literal (a run of bytes copied as-is) or a copy (a back-reference of length and offset into
+// the already decompressed data). The lower 2 bits of the first byte of every element (the tag) identify its type.
+//
+// The encoder is a greedy LZ77 matcher: it hashes every 4-byte sequence, and uses a hash table to find the previous
+// position of the same sequence. It favors speed over compression ratio, which suits block compression where every read
+// decompresses a block.
+type snappyCodec struct{}
+
+func (snappyCodec) Type() CodecType {
+	return Snappy
+}
+
+// Encode compresses the source in the Snappy block format.
+// Copies are only emitted with offsets < 64Kb, so the encoder never emits 4-byte offset copies (the decoder understands them).
+func (snappyCodec) Encode(source []byte) ([]byte, error) {
+	destination := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(source)+len(source)/6+1), uint64(len(source)))
+
+	var hashTable [1 << snappyHashTableBits]int32
+	literalStart, position := 0, 0
+	for position+snappyMinMatchLength <= len(source) {
+		sequence := binary.LittleEndian.Uint32(source[position:])
+		hash := snappyHash(sequence)
+		//hashTable stores position + 1, so that 0 represents an empty slot.
+		candidate := int(hashTable[hash]) - 1
+		hashTable[hash] = int32(position + 1)
+
+		if candidate < 0 ||
+			position-candidate > snappyMaxCopy2Offset ||
+			binary.LittleEndian.Uint32(source[candidate:]) != sequence {
+			position++
+			continue
+		}
+		matchLength := snappyMinMatchLength
+		for position+matchLength < len(source) && source[candidate+matchLength] == source[position+matchLength] {
+			matchLength++
+		}
+		destination = appendSnappyLiteral(destination, source[literalStart:position])
+		destination = appendSnappyCopy(destination, position-candidate, matchLength)
+
+		position += matchLength
+		literalStart = position
+	}
+	return appendSnappyLiteral(destination, source[literalStart:]), nil
+}
+
+// Decode decompresses the source which is encoded in the Snappy block format.
+// It returns CorruptInputErr if the source is not a valid encoding.
+func (snappyCodec) Decode(source []byte) ([]byte, error) {
+	decodedLength, n := binary.Uvarint(source)
+	if n <= 0 {
+		return nil, CorruptInputErr
+	}
+	source = source[n:]
+	if decodedLength > uint64(len(source))*snappyMaxExpansionRate {
+		return nil, CorruptInputErr
+	}
+
+	destination := make([]byte, 0, decodedLength)
+	for len(source) > 0 {
+		tag := source[0]
+		var length, offset int
+
+		switch tag & 0x03 {
+		case snappyTagLiteral:
+			length = int(tag >> 2)
+			source = source[1:]
+			if length >= 60 {
+				lengthBytes := length - 59
+				if len(source) < lengthBytes {
+					return nil, CorruptInputErr
+				}
+				length = 0
+				for index := 0; index < lengthBytes; index++ {
+					length |= int(source[index]) << (8 * index)
+				}
+				source = source[lengthBytes:]
+			}
+			length = length + 1
+			if length > len(source) || uint64(len(destination)+length) > decodedLength {
+				return nil, CorruptInputErr
+			}
+			destination = append(destination, source[:length]...)
+			source = source[length:]
+			continue
+		case snappyTagCopy1:
+			if len(source) < 2 {
+				return nil, CorruptInputErr
+			}
+			length = snappyMinMatchLength + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(source[1])
+			source = source[2:]
+		case snappyTagCopy2:
+			if len(source) < 3 {
+				return nil, CorruptInputErr
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(source[1:]))
+			source = source[3:]
+		case snappyTagCopy4:
+			if len(source) < 5 {
+				return nil, CorruptInputErr
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(source[1:]))
+			source = source[5:]
+		}
+		if offset <= 0 || offset > len(destination) || uint64(len(destination)+length) > decodedLength {
+			return nil, CorruptInputErr
+		}
+		//byte-by-byte copy, because the source and the destination ranges may overlap (offset < length).
+		for index := 0; index < length; index++ {
+			destination = append(destination, destination[len(destination)-offset])
+		}
+	}
+	if uint64(len(destination)) != decodedLength {
+		return nil, CorruptInputErr
+	}
+	return destination, nil
+}
+
+// appendSnappyLiteral appends a literal element.
+// The length-1 is stored in the upper 6 bits of the tag if it is < 60, else the upper 6 bits (60..63) denote the number
+// of bytes (1..4) following the tag that store the length-1.
+func appendSnappyLiteral(destination []byte, literal []byte) []byte {
+	if len(literal) == 0 {
+		return destination
+	}
+	length := len(literal) - 1
+	switch {
+	case length < 60:
+		destination = append(destination, byte(length<<2)|snappyTagLiteral)
+	case length < 1<<8:
+		destination = append(destination, 60<<2|snappyTagLiteral, byte(length))
+	case length < 1<<16:
+		destination = append(destination, 61<<2|snappyTagLiteral, byte(length), byte(length>>8))
+	case length < 1<<24:
+		destination = append(destination, 62<<2|snappyTagLiteral, byte(length), byte(length>>8), byte(length>>16))
+	default:
+		destination = append(destination, 63<<2|snappyTagLiteral, byte(length), byte(length>>8), byte(length>>16), byte(length>>24))
+	}
+	return append(destination, literal...)
+}
+
+// appendSnappyCopy appends one or more copy elements for a match of the given length at the given offset.
+// A copy element can encode at most 64 bytes, so a longer match is split into multiple copy elements.
+// The split always leaves at least snappyMinMatchLength bytes for the last element, which allows it to use the shorter
+// 1-byte offset copy when possible.
+func appendSnappyCopy(destination []byte, offset int, length int) []byte {
+	for length >= snappyMaxCopy2Length+snappyMinMatchLength {
+		destination = appendSnappyCopy2(destination, offset, snappyMaxCopy2Length)
+		length -= snappyMaxCopy2Length
+	}
+	if length > snappyMaxCopy2Length {
+		destination = appendSnappyCopy2(destination, offset, snappyMaxCopy2Length-snappyMinMatchLength)
+		length -= snappyMaxCopy2Length - snappyMinMatchLength
+	}
+	if length > snappyMaxCopy1Length || offset > snappyMaxCopy1Offset {
+		return appendSnappyCopy2(destination, offset, length)
+	}
+	return append(destination, byte(offset>>8)<<5|byte(length-snappyMinMatchLength)<<2|snappyTagCopy1, byte(offset))
+}
+
+// appendSnappyCopy2 appends a copy element with 2-byte offset.
+func appendSnappyCopy2(destination []byte, offset int, length int) []byte {
+	return append(destination, byte(length-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+}
+
+// snappyHash returns the hash of the 4-byte sequence, which is used as an index in the hash table of the encoder.
+func snappyHash(sequence uint32) uint32 {
+	return (sequence * 0x1e35a7bd) >> (32 - snappyHashTableBits)
+}
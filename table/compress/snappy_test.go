@@ -0,0 +1,108 @@
+package compress
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnappyEncodeAndDecodeAnEmptySource(t *testing.T) {
+	codec := snappyCodec{}
+	encoded, err := codec.Encode(nil)
+	assert.NoError(t, err)
+
+	decoded, err := codec.Decode(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(decoded))
+}
+
+func TestSnappyEncodeAndDecodeASourceSmallerThanTheMinimumMatchLength(t *testing.T) {
+	codec := snappyCodec{}
+	encoded, err := codec.Encode([]byte("raf"))
+	assert.NoError(t, err)
+
+	decoded, err := codec.Decode(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("raf"), decoded)
+}
+
+func TestSnappyEncodeAndDecodeALongRunOfTheSameByte(t *testing.T) {
+	codec := snappyCodec{}
+	source := bytes.Repeat([]byte{'a'}, 10_000)
+
+	encoded, err := codec.Encode(source)
+	assert.NoError(t, err)
+	assert.True(t, len(encoded) < 600)
+
+	decoded, err := codec.Decode(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, source, decoded)
+}
+
+func TestSnappyEncodeAndDecodeWithLongLiteralsAndFarOffsets(t *testing.T) {
+	codec := snappyCodec{}
+	random := rand.New(rand.NewSource(11))
+	randomPart := make([]byte, 70_000)
+	random.Read(randomPart)
+
+	//the repeated part is at an offset of 70_000 which is more than the largest offset that the encoder emits.
+	source := append(append([]byte{}, randomPart...), randomPart[:3000]...)
+	source = append(source, randomPart[:3000]...)
+
+	encoded, err := codec.Encode(source)
+	assert.NoError(t, err)
+
+	decoded, err := codec.Decode(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, source, decoded)
+}
+
+func TestSnappyEncodeAndDecodeWithMixedMatchLengthsAndOffsets(t *testing.T) {
+	codec := snappyCodec{}
+	random := rand.New(rand.NewSource(13))
+	source := make([]byte, 0, 100_000)
+	for len(source) < 100_000 {
+		if len(source) > 100 && random.Intn(2) == 0 {
+			offset := 1 + random.Intn(min(len(source), 5000))
+			length := 4 + random.Intn(100)
+			for index := 0; index < length; index++ {
+				source = append(source, source[len(source)-offset])
+			}
+			continue
+		}
+		literal := make([]byte, 1+random.Intn(20))
+		random.Read(literal)
+		source = append(source, literal...)
+	}
+
+	encoded, err := codec.Encode(source)
+	assert.NoError(t, err)
+	assert.True(t, len(encoded) < len(source))
+
+	decoded, err := codec.Decode(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, source, decoded)
+}
+
+func TestSnappyDecodeACopyWithOffsetBeyondTheDecodedData(t *testing.T) {
+	codec := snappyCodec{}
+	//uncompressed length 8, a literal "ab", followed by copy1 of length 4 at offset 5.
+	_, err := codec.Decode([]byte{8, 1 << 2, 'a', 'b', snappyTagCopy1, 5})
+	assert.ErrorIs(t, err, CorruptInputErr)
+}
+
+func TestSnappyDecodeWithDecodedLengthMismatch(t *testing.T) {
+	codec := snappyCodec{}
+	//uncompressed length 4, a literal "ab".
+	_, err := codec.Decode([]byte{4, 1 << 2, 'a', 'b'})
+	assert.ErrorIs(t, err, CorruptInputErr)
+}
+
+func TestSnappyDecodeATruncatedLiteral(t *testing.T) {
+	codec := snappyCodec{}
+	//uncompressed length 3, a literal of length 3 with only 2 bytes.
+	_, err := codec.Decode([]byte{3, 2 << 2, 'a', 'b'})
+	assert.ErrorIs(t, err, CorruptInputErr)
+}
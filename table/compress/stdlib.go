@@ -0,0 +1,71 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+)
+
+// flateCodec compresses using DEFLATE (RFC 1951) from the standard library.
+type flateCodec struct{}
+
+func (flateCodec) Type() CodecType {
+	return Flate
+}
+
+func (flateCodec) Encode(source []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer, err := flate.NewWriter(buffer, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	return finishWriting(writer, buffer, source)
+}
+
+func (flateCodec) Decode(source []byte) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(source))
+	defer func() {
+		_ = reader.Close()
+	}()
+	return io.ReadAll(reader)
+}
+
+// zlibCodec compresses using zlib (RFC 1950) from the standard library.
+// zlib wraps DEFLATE with a small header and an Adler-32 checksum of the uncompressed data.
+type zlibCodec struct{}
+
+func (zlibCodec) Type() CodecType {
+	return Zlib
+}
+
+func (zlibCodec) Encode(source []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer, err := zlib.NewWriterLevel(buffer, zlib.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	return finishWriting(writer, buffer, source)
+}
+
+func (zlibCodec) Decode(source []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	return io.ReadAll(reader)
+}
+
+// finishWriting writes the source to the writer, and closes the writer to flush the compressed data in the buffer.
+func finishWriting(writer io.WriteCloser, buffer *bytes.Buffer, source []byte) ([]byte, error) {
+	if _, err := writer.Write(source); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
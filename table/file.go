@@ -1,14 +1,19 @@
 package table
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"syscall"
 )
 
 // File represents SSTable file.
+// mapped is the read-only memory-mapped region of the entire file, it is nil unless memoryMap is called.
+// A memory-mapped File serves reads from the mapped region, without copying the data into a fresh buffer (refer to readView).
 type File struct {
-	file *os.File
-	size int64
+	file   *os.File
+	size   int64
+	mapped []byte
 }
 
 // CreateAndWrite creates a new SSTable file and writes the given data.
@@ -51,20 +56,79 @@ func (file *File) Read(offset int64, buffer []byte) (int, error) {
 	return n, nil
 }
 
+// Close closes the file, after unmapping the memory-mapped region (if any).
+// Any slice returned from readView must not be used after Close.
+func (file *File) Close() error {
+	if file.mapped != nil {
+		if err := syscall.Munmap(file.mapped); err != nil {
+			return err
+		}
+		file.mapped = nil
+	}
+	return file.file.Close()
+}
+
+// Path returns the path of the file.
+func (file *File) Path() string {
+	return file.file.Name()
+}
+
 // Size returns the file size.
 func (file *File) Size() int64 {
 	return file.size
 }
 
+// IsMemoryMapped returns true if the file is memory-mapped.
+func (file *File) IsMemoryMapped() bool {
+	return file.mapped != nil
+}
+
+// memoryMap maps the entire file in memory (read-only, shared). An empty file is not mapped.
+func (file *File) memoryMap() error {
+	if file.size == 0 || file.mapped != nil {
+		return nil
+	}
+	mapped, err := syscall.Mmap(int(file.file.Fd()), 0, int(file.size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("failed to memory-map file %v: %w", file.Path(), err)
+	}
+	file.mapped = mapped
+	return nil
+}
+
+// readView returns size bytes of the file starting at the given offset.
+// If the file is memory-mapped, the returned slice is a view over the mapped region (no copy is made), and it remains
+// valid only till the file is closed. Otherwise, the bytes are read into a fresh buffer.
+// The returned slice may be smaller than size, if the file ends before offset + size.
+func (file *File) readView(offset int64, size int) ([]byte, error) {
+	if file.mapped != nil {
+		if offset > int64(len(file.mapped)) {
+			return nil, io.EOF
+		}
+		end := min(offset+int64(size), int64(len(file.mapped)))
+		return file.mapped[offset:end:end], nil
+	}
+	buffer := make([]byte, size)
+	n, err := file.Read(offset, buffer)
+	if err != nil {
+		return nil, err
+	}
+	return buffer[:n], nil
+}
+
 // syncWrite performs fsync operation after writing the data to the file.
-// The file is closed after syncWrite.
-func syncWrite(path string, data []byte) error {
+// The file is closed after syncWrite, and it is removed if the data could not be written (or synced) completely, so that
+// a failed write does not leave a partial SSTable file behind.
+func syncWrite(path string, data []byte) (err error) {
 	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		_ = file.Close()
+		if err != nil {
+			_ = os.Remove(path)
+		}
 	}()
 
 	n, err := file.Write(data)
@@ -74,8 +138,7 @@ func syncWrite(path string, data []byte) error {
 	if n < len(data) {
 		return io.ErrShortWrite
 	}
-	_ = file.Sync()
-	return nil
+	return file.Sync()
 }
 
 // openReadonly opens the file in readonly mode.
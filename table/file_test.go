@@ -44,3 +44,51 @@ func TestReadMoreChunkThanAvailableFromFile(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, value, buffer[:n])
 }
+
+func TestReadViewFromMemoryMappedFile(t *testing.T) {
+	directory := "."
+	filePath := filepath.Join(directory, "TestReadViewFromMemoryMappedFile.log")
+	defer func() {
+		_ = os.Remove(filePath)
+	}()
+
+	value := []byte("LSM Tree: Log storage merge tree")
+	file, err := CreateAndWrite(filePath, value)
+	assert.Nil(t, err)
+	assert.Nil(t, file.memoryMap())
+	assert.True(t, file.IsMemoryMapped())
+
+	view, err := file.readView(10, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("Log"), view)
+
+	view, err = file.readView(10, 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, value[10:], view)
+
+	buffer := make([]byte, 3)
+	n, err := file.Read(0, buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("LSM"), buffer[:n])
+
+	assert.Nil(t, file.Close())
+	assert.False(t, file.IsMemoryMapped())
+}
+
+func TestReadViewFromFile(t *testing.T) {
+	directory := "."
+	filePath := filepath.Join(directory, "TestReadViewFromFile.log")
+	defer func() {
+		_ = os.Remove(filePath)
+	}()
+
+	value := []byte("LSM Tree: Log storage merge tree")
+	file, err := CreateAndWrite(filePath, value)
+	assert.Nil(t, err)
+	assert.False(t, file.IsMemoryMapped())
+
+	view, err := file.readView(10, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("Log"), view)
+	assert.Nil(t, file.Close())
+}
@@ -0,0 +1,300 @@
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-lsm/checksum"
+	"go-lsm/table/block"
+)
+
+// FooterFormatVersion identifies the layout of the SSTable (the sections and the Footer).
+type FooterFormatVersion uint32
+
+const (
+	// FooterFormatVersionWithoutFooter identifies the SSTables written before the Footer was introduced, it is never
+	// written. Such an SSTable has no checksums, and its data blocks are in block.FormatVersionFullKeys without the block
+	// trailer (refer to SSTableBuilder), the layout looks like:
+	/**
+	  ------------------------------------------------------------------------------------------------------------------
+	| data blocks | block meta section | 4 bytes meta starting offset | bloom filter section | 4 bytes bloom starting offset |
+	  ------------------------------------------------------------------------------------------------------------------
+	*/
+	// The Footer of such an SSTable is derived from the two offsets (refer to readFooterOfSSTableWithoutFooter).
+	FooterFormatVersionWithoutFooter FooterFormatVersion = 0
+	// FooterFormatVersionInitial is the first SSTable layout with a Footer: data blocks, block meta section,
+	// bloom filter section and the Footer.
+	FooterFormatVersionInitial FooterFormatVersion = 1
+	// FooterFormatVersionWithProperties adds the properties section (refer to Properties) after the bloom filter section.
+	FooterFormatVersionWithProperties FooterFormatVersion = 2
+	// FooterFormatVersionWithPrefixFilter adds the (optional) prefix filter section (refer to prefixFilter) after the
+	// properties section.
+	FooterFormatVersionWithPrefixFilter FooterFormatVersion = 3
+	// FooterFormatVersionWithFilterType stores the bloom.FilterType before the encoded filter in the bloom filter section
+	// and in the prefix filter section, the Footer itself is the same as FooterFormatVersionWithPrefixFilter.
+	// The filters of the older versions are of type bloom.StandardFilterType.
+	FooterFormatVersionWithFilterType FooterFormatVersion = 4
+	// FooterFormatVersionWithIndexType stores the IndexType before the block index in the meta section, the Footer itself
+	// is the same as FooterFormatVersionWithPrefixFilter. The block index of the older versions is of type FlatIndexType.
+	FooterFormatVersionWithIndexType FooterFormatVersion = 5
+	// LatestFooterFormatVersion is the FooterFormatVersion used by the SSTableBuilder.
+	LatestFooterFormatVersion = FooterFormatVersionWithIndexType
+)
+
+// FooterMagic identifies an SSTable file, it is stored in the last 8 bytes of every SSTable.
+const FooterMagic uint64 = 0x4c534d5353544142 // "LSMSSTAB"
+
+// footerTrailerSize is the size of the fields which are present at the end of the Footer in every FooterFormatVersion:
+// 4 bytes format version + 4 bytes checksum + 8 bytes magic.
+const footerTrailerSize = 4 + checksum.Size + 8
+
+// sectionHandleSize is the size of an encoded SectionHandle: 4 bytes offset + 4 bytes size.
+const sectionHandleSize = 4 + 4
+
+// FooterSize is the fixed size of the encoded Footer in the LatestFooterFormatVersion:
+// 4 section handles + footerTrailerSize.
+const FooterSize = 4*sectionHandleSize + footerTrailerSize
+
+// ErrInvalidSSTable is returned (wrapped) when a file is not an SSTable (neither it has the magic number, nor it is an
+// SSTable in FooterFormatVersionWithoutFooter), or it is an SSTable with an unsupported format version.
+var ErrInvalidSSTable = errors.New("invalid SSTable")
+
+// SectionHandle represents the offset and the size (including its checksum) of a section in the SSTable file.
+type SectionHandle struct {
+	Offset uint32
+	Size   uint32
+}
+
+// Footer represents the fixed-size footer of the SSTable, it is the entry point to read an SSTable.
+// The encoded Footer (in FooterFormatVersionWithPrefixFilter and later) looks like:
+/**
+  -----------------------------------------------------------------------------------------------------------------------------------------------------------------
+| meta section handle | bloom section handle | properties section handle | prefix filter section handle | 4 bytes format version | checksum | 8 bytes magic |
+  -----------------------------------------------------------------------------------------------------------------------------------------------------------------
+*/
+// Each section handle is encoded as: | 4 bytes offset | 4 bytes size |, and the checksum covers all the fields which precede it.
+// FooterFormatVersionInitial does not have the properties and the prefix filter section handles, and
+// FooterFormatVersionWithProperties does not have the prefix filter section handle. The prefix filter section handle
+// is zero if the SSTable was built without a bloom.PrefixExtractor.
+// Every FooterFormatVersion keeps the magic at the end of the file, and the format version at the same position
+// (relative to the end of the file), so that the version (and hence the size of the Footer) can be identified before
+// decoding the rest of the Footer.
+type Footer struct {
+	MetaSection         SectionHandle
+	BloomSection        SectionHandle
+	PropertiesSection   SectionHandle
+	PrefixFilterSection SectionHandle
+	FormatVersion       FooterFormatVersion
+}
+
+// footerSizeOf returns the size of the encoded Footer in the given FooterFormatVersion, and false if the version is not supported.
+func footerSizeOf(formatVersion FooterFormatVersion) (int, bool) {
+	switch formatVersion {
+	case FooterFormatVersionInitial:
+		return 2*sectionHandleSize + footerTrailerSize, true
+	case FooterFormatVersionWithProperties:
+		return 3*sectionHandleSize + footerTrailerSize, true
+	case FooterFormatVersionWithPrefixFilter, FooterFormatVersionWithFilterType, FooterFormatVersionWithIndexType:
+		return 4*sectionHandleSize + footerTrailerSize, true
+	default:
+		return 0, false
+	}
+}
+
+// HasChecksums returns true if the sections and the data blocks of the SSTable have checksums, and the data blocks
+// have the block trailer (compress.CodecType and block.FormatVersion).
+func (footer Footer) HasChecksums() bool {
+	return footer.FormatVersion >= FooterFormatVersionInitial
+}
+
+// HasProperties returns true if the SSTable has the properties section.
+func (footer Footer) HasProperties() bool {
+	return footer.FormatVersion >= FooterFormatVersionWithProperties
+}
+
+// HasPrefixFilter returns true if the SSTable has the prefix filter section.
+func (footer Footer) HasPrefixFilter() bool {
+	return footer.FormatVersion >= FooterFormatVersionWithPrefixFilter && footer.PrefixFilterSection.Size > 0
+}
+
+// HasFilterType returns true if the filter sections start with the bloom.FilterType.
+func (footer Footer) HasFilterType() bool {
+	return footer.FormatVersion >= FooterFormatVersionWithFilterType
+}
+
+// HasIndexType returns true if the meta section starts with the IndexType.
+func (footer Footer) HasIndexType() bool {
+	return footer.FormatVersion >= FooterFormatVersionWithIndexType
+}
+
+// sections returns the section handles which are present in the FormatVersion of the Footer, in their encoded order.
+func (footer Footer) sections() []SectionHandle {
+	sections := []SectionHandle{footer.MetaSection, footer.BloomSection}
+	if footer.FormatVersion >= FooterFormatVersionWithProperties {
+		sections = append(sections, footer.PropertiesSection)
+	}
+	if footer.FormatVersion >= FooterFormatVersionWithPrefixFilter {
+		sections = append(sections, footer.PrefixFilterSection)
+	}
+	return sections
+}
+
+// encode encodes the Footer in its FormatVersion.
+func (footer Footer) encode() []byte {
+	size, ok := footerSizeOf(footer.FormatVersion)
+	if !ok {
+		panic(fmt.Errorf("unsupported footer format version %v", footer.FormatVersion))
+	}
+	buffer := make([]byte, 0, size)
+	for _, section := range footer.sections() {
+		buffer = binary.LittleEndian.AppendUint32(buffer, section.Offset)
+		buffer = binary.LittleEndian.AppendUint32(buffer, section.Size)
+	}
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(footer.FormatVersion))
+	buffer = checksum.Append(buffer)
+	return binary.LittleEndian.AppendUint64(buffer, FooterMagic)
+}
+
+// readFooter reads and decodes the Footer from the end of the file. It involves the following:
+// 1) Read the footer trailer (format version, checksum and magic) from the end of the file.
+// 2) Verify the magic and identify the size of the Footer from the format version. A file without the magic is read as
+// an SSTable in FooterFormatVersionWithoutFooter.
+// 3) Read the entire Footer, verify its checksum and decode the section handles.
+// It returns ErrInvalidSSTable if the file is smaller than the Footer, the format version is not supported, or the file
+// has neither the magic nor the layout of FooterFormatVersionWithoutFooter. It returns checksum.CorruptionError if the checksum of the Footer does not match, or the sections
+// referred by the Footer are not within the file.
+func readFooter(file *File) (Footer, error) {
+	fileSize := file.Size()
+	if fileSize < int64(footerTrailerSize) {
+		return readFooterOfSSTableWithoutFooter(file)
+	}
+	trailer := make([]byte, footerTrailerSize)
+	n, err := file.Read(fileSize-int64(footerTrailerSize), trailer)
+	if err != nil {
+		return Footer{}, err
+	}
+	if n < footerTrailerSize {
+		return Footer{}, fmt.Errorf("%w: file %v has a truncated footer", ErrInvalidSSTable, file.Path())
+	}
+	if magic := binary.LittleEndian.Uint64(trailer[footerTrailerSize-8:]); magic != FooterMagic {
+		return readFooterOfSSTableWithoutFooter(file)
+	}
+	formatVersion := FooterFormatVersion(binary.LittleEndian.Uint32(trailer))
+	footerSize, ok := footerSizeOf(formatVersion)
+	if !ok {
+		return Footer{}, fmt.Errorf("%w: file %v has an unsupported format version %v", ErrInvalidSSTable, file.Path(), formatVersion)
+	}
+	if fileSize < int64(footerSize) {
+		return Footer{}, fmt.Errorf("%w: file %v of size %v is too small to be an SSTable", ErrInvalidSSTable, file.Path(), fileSize)
+	}
+
+	footerOffset := fileSize - int64(footerSize)
+	buffer := make([]byte, footerSize)
+	if _, err := file.Read(footerOffset, buffer); err != nil {
+		return Footer{}, err
+	}
+	fields, err := checksum.Verify(buffer[:footerSize-8], file.Path(), footerOffset)
+	if err != nil {
+		return Footer{}, err
+	}
+	decodeSectionHandle := func(index int) SectionHandle {
+		return SectionHandle{
+			Offset: binary.LittleEndian.Uint32(fields[index*sectionHandleSize:]),
+			Size:   binary.LittleEndian.Uint32(fields[index*sectionHandleSize+4:]),
+		}
+	}
+	footer := Footer{
+		MetaSection:   decodeSectionHandle(0),
+		BloomSection:  decodeSectionHandle(1),
+		FormatVersion: formatVersion,
+	}
+	if footer.FormatVersion >= FooterFormatVersionWithProperties {
+		footer.PropertiesSection = decodeSectionHandle(2)
+	}
+	if footer.FormatVersion >= FooterFormatVersionWithPrefixFilter {
+		footer.PrefixFilterSection = decodeSectionHandle(3)
+	}
+	for _, section := range footer.sections() {
+		if int64(section.Offset)+int64(section.Size) > footerOffset {
+			return Footer{}, checksum.NewCorruptionError(
+				file.Path(),
+				footerOffset,
+				fmt.Sprintf("section at offset %v of size %v is beyond the footer", section.Offset, section.Size),
+			)
+		}
+	}
+	return footer, nil
+}
+
+// readFooterOfSSTableWithoutFooter derives the Footer of an SSTable in FooterFormatVersionWithoutFooter. It involves the following:
+// 1) Read the last 4 bytes to get the starting offset of the bloom filter section.
+// 2) Read the 4 bytes before the bloom filter section to get the starting offset of the block meta section.
+// The block meta section ends at the 4 bytes which contain its starting offset, and the bloom filter section ends at the
+// last 4 bytes of the file.
+// It returns ErrInvalidSSTable if the offsets do not fit the file.
+func readFooterOfSSTableWithoutFooter(file *File) (Footer, error) {
+	invalid := fmt.Errorf(
+		"%w: file %v neither ends with the SSTable magic number, nor has the layout of an SSTable without the footer",
+		ErrInvalidSSTable,
+		file.Path(),
+	)
+	fileSize := file.Size()
+	if fileSize < 2*int64(block.Uint32Size) {
+		return Footer{}, invalid
+	}
+	readOffset := func(offset int64) (uint32, error) {
+		buffer := make([]byte, block.Uint32Size)
+		n, err := file.Read(offset, buffer)
+		if err != nil {
+			return 0, err
+		}
+		if n < block.Uint32Size {
+			return 0, invalid
+		}
+		return binary.LittleEndian.Uint32(buffer), nil
+	}
+	bloomOffset, err := readOffset(fileSize - int64(block.Uint32Size))
+	if err != nil {
+		return Footer{}, err
+	}
+	if bloomOffset < uint32(block.Uint32Size) || int64(bloomOffset) > fileSize-int64(block.Uint32Size) {
+		return Footer{}, invalid
+	}
+	metaOffset, err := readOffset(int64(bloomOffset) - int64(block.Uint32Size))
+	if err != nil {
+		return Footer{}, err
+	}
+	if metaOffset > bloomOffset-uint32(block.Uint32Size) {
+		return Footer{}, invalid
+	}
+	return Footer{
+		MetaSection:   SectionHandle{Offset: metaOffset, Size: bloomOffset - uint32(block.Uint32Size) - metaOffset},
+		BloomSection:  SectionHandle{Offset: bloomOffset, Size: uint32(fileSize) - uint32(block.Uint32Size) - bloomOffset},
+		FormatVersion: FooterFormatVersionWithoutFooter,
+	}, nil
+}
+
+// readSection reads the section identified by the SectionHandle, verifies its checksum and returns the section
+// without checksum.
+func readSection(file *File, section SectionHandle) ([]byte, error) {
+	buffer := make([]byte, section.Size)
+	n, err := file.Read(int64(section.Offset), buffer)
+	if err != nil {
+		return nil, err
+	}
+	return checksum.Verify(buffer[:n], file.Path(), int64(section.Offset))
+}
+
+// readSectionOf reads the section of the SSTable identified by the SectionHandle, and verifies its checksum only if the
+// Footer has checksums.
+func (footer Footer) readSectionOf(file *File, section SectionHandle) ([]byte, error) {
+	if footer.HasChecksums() {
+		return readSection(file, section)
+	}
+	buffer := make([]byte, section.Size)
+	n, err := file.Read(int64(section.Offset), buffer)
+	if err != nil {
+		return nil, err
+	}
+	return buffer[:n], nil
+}
@@ -0,0 +1,143 @@
+package table
+
+import (
+	"encoding/binary"
+	"go-lsm/checksum"
+	"go-lsm/kv"
+	"go-lsm/test_utility"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSSTableForFooter(t *testing.T, rootPath string) int64 {
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft"))
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	fileSize := ssTable.file.Size()
+	assert.Nil(t, ssTable.Close())
+	return fileSize
+}
+
+func TestEncodeAndReadFooter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	footer := Footer{
+		MetaSection:         SectionHandle{Offset: 0, Size: 10},
+		BloomSection:        SectionHandle{Offset: 10, Size: 20},
+		PropertiesSection:   SectionHandle{Offset: 30, Size: 5},
+		PrefixFilterSection: SectionHandle{Offset: 35, Size: 5},
+		FormatVersion:       LatestFooterFormatVersion,
+	}
+	encoded := append(make([]byte, 40), footer.encode()...)
+	assert.Equal(t, 40+FooterSize, len(encoded))
+
+	file, err := CreateAndWrite(SSTableFilePath(1, rootPath), encoded)
+	assert.Nil(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	readFooter, err := readFooter(file)
+	assert.Nil(t, err)
+	assert.Equal(t, footer, readFooter)
+}
+
+func TestEncodeAndReadFooterWithoutProperties(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	footer := Footer{
+		MetaSection:   SectionHandle{Offset: 0, Size: 10},
+		BloomSection:  SectionHandle{Offset: 10, Size: 20},
+		FormatVersion: FooterFormatVersionInitial,
+	}
+	encoded := append(make([]byte, 30), footer.encode()...)
+	assert.Equal(t, 30+2*sectionHandleSize+footerTrailerSize, len(encoded))
+
+	file, err := CreateAndWrite(SSTableFilePath(1, rootPath), encoded)
+	assert.Nil(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	readFooter, err := readFooter(file)
+	assert.Nil(t, err)
+	assert.Equal(t, footer, readFooter)
+	assert.False(t, readFooter.HasProperties())
+}
+
+func TestLoadAFileWhichIsNotAnSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	assert.Nil(t, os.WriteFile(SSTableFilePath(1, rootPath), []byte("LSM Tree: Log storage merge tree, not an SSTable"), 0666))
+
+	_, err := Load(1, rootPath)
+	assert.ErrorIs(t, err, ErrInvalidSSTable)
+}
+
+func TestLoadAFileSmallerThanTheFooter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	assert.Nil(t, os.WriteFile(SSTableFilePath(1, rootPath), []byte("raft"), 0666))
+
+	_, err := Load(1, rootPath)
+	assert.ErrorIs(t, err, ErrInvalidSSTable)
+}
+
+func TestLoadATruncatedSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	fileSize := buildSSTableForFooter(t, rootPath)
+	assert.Nil(t, os.Truncate(SSTableFilePath(1, rootPath), fileSize-3))
+
+	_, err := Load(1, rootPath)
+	assert.ErrorIs(t, err, ErrInvalidSSTable)
+}
+
+func TestLoadAnSSTableWithUnsupportedFormatVersion(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	fileSize := buildSSTableForFooter(t, rootPath)
+	bytes, err := os.ReadFile(SSTableFilePath(1, rootPath))
+	assert.Nil(t, err)
+	binary.LittleEndian.PutUint32(bytes[fileSize-int64(footerTrailerSize):], 99)
+	assert.Nil(t, os.WriteFile(SSTableFilePath(1, rootPath), bytes, 0666))
+
+	_, err = Load(1, rootPath)
+	assert.ErrorIs(t, err, ErrInvalidSSTable)
+	assert.Contains(t, err.Error(), "unsupported format version 99")
+}
+
+func TestLoadAnSSTableWithACorruptedFooter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	fileSize := buildSSTableForFooter(t, rootPath)
+	corruptByteAt(t, SSTableFilePath(1, rootPath), fileSize-int64(FooterSize)+1)
+
+	_, err := Load(1, rootPath)
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+}
@@ -0,0 +1,331 @@
+package table
+
+import (
+	"encoding/binary"
+	"fmt"
+	"go-lsm/kv"
+	"go-lsm/table/block"
+	"go-lsm/table/cache"
+	"sort"
+)
+
+// IndexType identifies the layout of the block index of an SSTable, it is stored at the beginning of the meta section
+// (from FooterFormatVersionWithIndexType onwards).
+type IndexType uint8
+
+const (
+	// FlatIndexType keeps the block.Meta of all the data blocks in the meta section, which is entirely resident in memory.
+	FlatIndexType IndexType = 1
+	// PartitionedIndexType splits the block.Meta of the data blocks into index partitions, and keeps a top-level index
+	// (pointing to the partitions) in the meta section. Only the top-level index is resident in memory, the partitions are
+	// loaded on demand (through the cache.BlockCache, if any).
+	PartitionedIndexType IndexType = 2
+)
+
+// blockIndex locates the data blocks of an SSTable.
+type blockIndex interface {
+	// numberOfBlocks returns the number of data blocks.
+	numberOfBlocks() int
+	// blockContaining returns the index of the data block which may contain the given key.
+	blockContaining(key kv.Key) (int, error)
+	// offsetRangeOfBlockAt returns the byte offset range of the data block at the given index.
+	offsetRangeOfBlockAt(blockIndex int) (uint32, uint32, error)
+	// startingKey returns the starting key of the first data block.
+	startingKey() kv.Key
+	// endingKey returns the ending key of the last data block.
+	endingKey() kv.Key
+	// partitionSections returns the sections of the index partitions (if any).
+	partitionSections() []SectionHandle
+}
+
+// flatIndex is the blockIndex which keeps the block.MetaList of all the data blocks in memory.
+// endOfDataBlocks is the offset which follows the last data block.
+type flatIndex struct {
+	metaList        *block.MetaList
+	endOfDataBlocks uint32
+}
+
+// indexPartition represents an entry of the top-level index of partitionedIndex.
+// section identifies the encoded block.MetaList (with checksum) of the partition, firstBlockIndex and firstBlockOffset are
+// the index and the offset of the first data block of the partition, startingKey and endingKey are the starting key of
+// the first data block and the ending key of the last data block of the partition.
+type indexPartition struct {
+	section          SectionHandle
+	firstBlockIndex  uint32
+	firstBlockOffset uint32
+	startingKey      kv.Key
+	endingKey        kv.Key
+}
+
+// partitionedIndex is the blockIndex which keeps only the top-level index (partitions) in memory, and reads the index
+// partitions on demand using readPartition (refer to SSTable.readIndexPartition).
+// It is meant to be used with a cache.BlockCache, without it every block lookup reads an index partition from the file.
+type partitionedIndex struct {
+	partitions      []indexPartition
+	totalBlocks     int
+	endOfDataBlocks uint32
+	readPartition   func(partitionIndex int, section SectionHandle) (*block.MetaList, *cache.Handle, error)
+}
+
+// numberOfBlocks returns the number of data blocks.
+func (index *flatIndex) numberOfBlocks() int {
+	return index.metaList.Length()
+}
+
+// blockContaining returns the index of the data block which may contain the given key.
+func (index *flatIndex) blockContaining(key kv.Key) (int, error) {
+	_, blockIndex := index.metaList.MaybeBlockMetaContaining(key)
+	return blockIndex, nil
+}
+
+// offsetRangeOfBlockAt returns the byte offset range of the block at the given index.
+// offsetRangeOfBlockAt works by getting the block.Meta at the given index, and block.Meta at index + 1.
+// If the block.Meta is available at the next index, it returns the BlockStartingOffset of block.Meta at the given index,
+// and block.Meta at index + 1.
+// If the block.Meta is not available at the next index, it returns the BlockStartingOffset of block.Meta at the given index,
+// and endOfDataBlocks, which is essentially the offset of the metadata section (which follows the last data block).
+// Please take a look at the table.SSTableBuilder for encoding of SSTable.
+func (index *flatIndex) offsetRangeOfBlockAt(blockIndex int) (uint32, uint32, error) {
+	blockMeta, blockPresent := index.metaList.GetAt(blockIndex)
+	if !blockPresent {
+		return 0, 0, fmt.Errorf("block meta not found at index %v", blockIndex)
+	}
+	nextBlockMeta, nextBlockPresent := index.metaList.GetAt(blockIndex + 1)
+
+	var endOffset uint32
+	if nextBlockPresent {
+		endOffset = nextBlockMeta.BlockStartingOffset
+	} else {
+		endOffset = index.endOfDataBlocks
+	}
+	return blockMeta.BlockStartingOffset, endOffset, nil
+}
+
+// startingKey returns the starting key of the first data block.
+func (index *flatIndex) startingKey() kv.Key {
+	startingKey, _ := index.metaList.StartingKeyOfFirstBlock()
+	return startingKey
+}
+
+// endingKey returns the ending key of the last data block.
+func (index *flatIndex) endingKey() kv.Key {
+	endingKey, _ := index.metaList.EndingKeyOfLastBlock()
+	return endingKey
+}
+
+// partitionSections returns nil, flatIndex does not have partitions.
+func (index *flatIndex) partitionSections() []SectionHandle {
+	return nil
+}
+
+// numberOfBlocks returns the number of data blocks.
+func (index *partitionedIndex) numberOfBlocks() int {
+	return index.totalBlocks
+}
+
+// blockContaining returns the index of the data block which may contain the given key.
+// It involves the following:
+// 1) Binary search over the starting keys of the partitions to identify the partition which may contain the key.
+// 2) Reading the partition, and identifying the block.Meta which may contain the key within the partition.
+func (index *partitionedIndex) blockContaining(key kv.Key) (int, error) {
+	low, high := 0, len(index.partitions)-1
+	partitionIndex := low
+	for low <= high {
+		mid := low + (high-low)/2
+		comparison := key.CompareKeysWithDescendingTimestamp(index.partitions[mid].startingKey)
+		if comparison < 0 {
+			high = mid - 1
+			continue
+		}
+		partitionIndex = mid
+		if comparison == 0 {
+			break
+		}
+		low = mid + 1
+	}
+	metaList, handle, err := index.readPartition(partitionIndex, index.partitions[partitionIndex].section)
+	if err != nil {
+		return 0, err
+	}
+	defer handle.Release()
+
+	_, blockIndexInPartition := metaList.MaybeBlockMetaContaining(key)
+	return int(index.partitions[partitionIndex].firstBlockIndex) + blockIndexInPartition, nil
+}
+
+// offsetRangeOfBlockAt returns the byte offset range of the block at the given index.
+// It reads the partition which contains the block. The end offset of the last block of a partition is the offset of the
+// first block of the next partition (or endOfDataBlocks for the last partition), which is a part of the top-level index.
+func (index *partitionedIndex) offsetRangeOfBlockAt(blockIndex int) (uint32, uint32, error) {
+	if blockIndex < 0 || blockIndex >= index.totalBlocks {
+		return 0, 0, fmt.Errorf("block meta not found at index %v", blockIndex)
+	}
+	partitionIndex := sort.Search(len(index.partitions), func(partitionIndex int) bool {
+		return int(index.partitions[partitionIndex].firstBlockIndex) > blockIndex
+	}) - 1
+	partition := index.partitions[partitionIndex]
+
+	metaList, handle, err := index.readPartition(partitionIndex, partition.section)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer handle.Release()
+
+	blockIndexInPartition := blockIndex - int(partition.firstBlockIndex)
+	blockMeta, blockPresent := metaList.GetAt(blockIndexInPartition)
+	if !blockPresent {
+		return 0, 0, fmt.Errorf("block meta not found at index %v", blockIndex)
+	}
+	if nextBlockMeta, nextBlockPresent := metaList.GetAt(blockIndexInPartition + 1); nextBlockPresent {
+		return blockMeta.BlockStartingOffset, nextBlockMeta.BlockStartingOffset, nil
+	}
+	if partitionIndex+1 < len(index.partitions) {
+		return blockMeta.BlockStartingOffset, index.partitions[partitionIndex+1].firstBlockOffset, nil
+	}
+	return blockMeta.BlockStartingOffset, index.endOfDataBlocks, nil
+}
+
+// startingKey returns the starting key of the first data block.
+func (index *partitionedIndex) startingKey() kv.Key {
+	if len(index.partitions) == 0 {
+		return kv.Key{}
+	}
+	return index.partitions[0].startingKey
+}
+
+// endingKey returns the ending key of the last data block.
+func (index *partitionedIndex) endingKey() kv.Key {
+	if len(index.partitions) == 0 {
+		return kv.Key{}
+	}
+	return index.partitions[len(index.partitions)-1].endingKey
+}
+
+// partitionSections returns the sections of all the index partitions.
+func (index *partitionedIndex) partitionSections() []SectionHandle {
+	sections := make([]SectionHandle, 0, len(index.partitions))
+	for _, partition := range index.partitions {
+		sections = append(sections, partition.section)
+	}
+	return sections
+}
+
+// encode encodes the top-level index of partitionedIndex.
+/*
+  ------------------------------------------------------------------------------------------------------
+ | 4 bytes number of partitions | 4 bytes number of blocks | 4 bytes end of data blocks | partition ... |
+  ------------------------------------------------------------------------------------------------------
+*/
+// Each partition is encoded as:
+/*
+  -----------------------------------------------------------------------------------------------------------------------------------------------------------
+ | 4 bytes offset | 4 bytes size | 4 bytes first block index | 4 bytes first block offset | 2 bytes key size | starting key | 2 bytes key size | ending key |
+  -----------------------------------------------------------------------------------------------------------------------------------------------------------
+*/
+func (index *partitionedIndex) encode() []byte {
+	buffer := make([]byte, 0, 3*block.Uint32Size)
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(index.partitions)))
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(index.totalBlocks))
+	buffer = binary.LittleEndian.AppendUint32(buffer, index.endOfDataBlocks)
+	for _, partition := range index.partitions {
+		buffer = binary.LittleEndian.AppendUint32(buffer, partition.section.Offset)
+		buffer = binary.LittleEndian.AppendUint32(buffer, partition.section.Size)
+		buffer = binary.LittleEndian.AppendUint32(buffer, partition.firstBlockIndex)
+		buffer = binary.LittleEndian.AppendUint32(buffer, partition.firstBlockOffset)
+		buffer = binary.LittleEndian.AppendUint16(buffer, uint16(partition.startingKey.EncodedSizeInBytes()))
+		buffer = append(buffer, partition.startingKey.EncodedBytes()...)
+		buffer = binary.LittleEndian.AppendUint16(buffer, uint16(partition.endingKey.EncodedSizeInBytes()))
+		buffer = append(buffer, partition.endingKey.EncodedBytes()...)
+	}
+	return buffer
+}
+
+// decodePartitionedIndex decodes the top-level index of partitionedIndex (refer to partitionedIndex.encode).
+// The keys are copied, because the buffer may be reused.
+func decodePartitionedIndex(buffer []byte) (*partitionedIndex, error) {
+	tooSmall := fmt.Errorf("partitioned index of size %v is too small", len(buffer))
+	if len(buffer) < 3*block.Uint32Size {
+		return nil, tooSmall
+	}
+	numberOfPartitions := int(binary.LittleEndian.Uint32(buffer))
+	index := &partitionedIndex{
+		partitions:      make([]indexPartition, 0, numberOfPartitions),
+		totalBlocks:     int(binary.LittleEndian.Uint32(buffer[block.Uint32Size:])),
+		endOfDataBlocks: binary.LittleEndian.Uint32(buffer[2*block.Uint32Size:]),
+	}
+	buffer = buffer[3*block.Uint32Size:]
+
+	decodeKey := func() (kv.Key, bool) {
+		if len(buffer) < block.Uint16Size {
+			return kv.Key{}, false
+		}
+		keySize := int(binary.LittleEndian.Uint16(buffer))
+		if len(buffer) < block.Uint16Size+keySize {
+			return kv.Key{}, false
+		}
+		key := kv.DecodeFrom(append([]byte(nil), buffer[block.Uint16Size:block.Uint16Size+keySize]...))
+		buffer = buffer[block.Uint16Size+keySize:]
+		return key, true
+	}
+	for partitionCount := 0; partitionCount < numberOfPartitions; partitionCount++ {
+		if len(buffer) < 4*block.Uint32Size {
+			return nil, tooSmall
+		}
+		partition := indexPartition{
+			section: SectionHandle{
+				Offset: binary.LittleEndian.Uint32(buffer),
+				Size:   binary.LittleEndian.Uint32(buffer[block.Uint32Size:]),
+			},
+			firstBlockIndex:  binary.LittleEndian.Uint32(buffer[2*block.Uint32Size:]),
+			firstBlockOffset: binary.LittleEndian.Uint32(buffer[3*block.Uint32Size:]),
+		}
+		buffer = buffer[4*block.Uint32Size:]
+
+		var ok bool
+		if partition.startingKey, ok = decodeKey(); !ok {
+			return nil, tooSmall
+		}
+		if partition.endingKey, ok = decodeKey(); !ok {
+			return nil, tooSmall
+		}
+		index.partitions = append(index.partitions, partition)
+	}
+	return index, nil
+}
+
+// encodeBlockIndex encodes the meta section (without checksum) which contains the blockIndex along with its IndexType.
+/*
+  ------------------------------------------------------------------------------------------------
+ | 1 byte IndexType | block.MetaList.Encode() (FlatIndexType) or partitionedIndex.encode() |
+  ------------------------------------------------------------------------------------------------
+*/
+func encodeBlockIndex(index blockIndex) []byte {
+	switch index := index.(type) {
+	case *partitionedIndex:
+		return append([]byte{byte(PartitionedIndexType)}, index.encode()...)
+	case *flatIndex:
+		return append([]byte{byte(FlatIndexType)}, index.metaList.Encode()...)
+	default:
+		panic(fmt.Errorf("unsupported block index %T", index))
+	}
+}
+
+// decodeBlockIndex decodes the meta section (without checksum) to blockIndex.
+// The meta section of the SSTables before FooterFormatVersionWithIndexType is a block.MetaList without the IndexType,
+// and the data blocks of those SSTables end at the meta section.
+func decodeBlockIndex(buffer []byte, footer Footer) (blockIndex, error) {
+	if !footer.HasIndexType() {
+		return &flatIndex{metaList: block.DecodeToBlockMetaList(buffer), endOfDataBlocks: footer.MetaSection.Offset}, nil
+	}
+	if len(buffer) < 1 {
+		return nil, fmt.Errorf("meta section of size %v is too small", len(buffer))
+	}
+	switch IndexType(buffer[0]) {
+	case FlatIndexType:
+		return &flatIndex{metaList: block.DecodeToBlockMetaList(buffer[1:]), endOfDataBlocks: footer.MetaSection.Offset}, nil
+	case PartitionedIndexType:
+		return decodePartitionedIndex(buffer[1:])
+	default:
+		return nil, fmt.Errorf("unsupported index type %v", buffer[0])
+	}
+}
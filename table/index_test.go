@@ -0,0 +1,192 @@
+package table
+
+import (
+	"fmt"
+	"go-lsm/checksum"
+	"go-lsm/kv"
+	"go-lsm/table/block"
+	"go-lsm/table/cache"
+	"go-lsm/test_utility"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSSTableWithPartitionedIndex(t *testing.T, rootPath string, numberOfKeys int) *SSTable {
+	ssTableBuilder := NewSSTableBuilderWithOptions(SSTableBuilderOptions{BlockSize: 50, IndexPartitionSize: 100})
+	for count := 0; count < numberOfKeys; count++ {
+		ssTableBuilder.Add(
+			kv.NewStringKeyWithTimestamp(fmt.Sprintf("key-%03d", count), 5),
+			kv.NewStringValue(fmt.Sprintf("value-%03d", count)),
+		)
+	}
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	return ssTable
+}
+
+func TestSSTableWithPartitionedIndex(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable := buildSSTableWithPartitionedIndex(t, rootPath, 100)
+	assert.Equal(t, PartitionedIndexType, ssTable.IndexType())
+	assert.Equal(t, 100, ssTable.noOfBlocks())
+	assert.True(t, len(ssTable.index.partitionSections()) > 1)
+	assert.Equal(t, "key-000", ssTable.startingKey.RawString())
+	assert.Equal(t, "key-099", ssTable.endingKey.RawString())
+
+	iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("key-042", 10))
+	assert.Nil(t, err)
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("value-042"), iterator.Value())
+	iterator.Close()
+}
+
+func TestLoadSSTableWithPartitionedIndexAndIterateAcrossPartitions(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	assert.Nil(t, buildSSTableWithPartitionedIndex(t, rootPath, 100).Close())
+
+	ssTable, err := Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+	assert.Equal(t, PartitionedIndexType, ssTable.IndexType())
+	assert.Equal(t, "key-000", ssTable.startingKey.RawString())
+	assert.Equal(t, "key-099", ssTable.endingKey.RawString())
+
+	iterator, err := ssTable.SeekToFirst()
+	assert.Nil(t, err)
+	for count := 0; count < 100; count++ {
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, kv.NewStringValue(fmt.Sprintf("value-%03d", count)), iterator.Value())
+		_ = iterator.Next()
+	}
+	assert.False(t, iterator.IsValid())
+	iterator.Close()
+
+	for count := 0; count < 100; count++ {
+		iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp(fmt.Sprintf("key-%03d", count), 10))
+		assert.Nil(t, err)
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, kv.NewStringValue(fmt.Sprintf("value-%03d", count)), iterator.Value())
+		iterator.Close()
+	}
+	assert.Nil(t, ssTable.VerifyChecksums())
+}
+
+func TestSSTableReadsIndexPartitionsThroughBlockCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	assert.Nil(t, buildSSTableWithPartitionedIndex(t, rootPath, 100).Close())
+
+	blockCache := cache.NewBlockCache(1 << 20)
+	ssTable, err := LoadWithReadOptions(1, rootPath, ReadOptions{BlockCache: blockCache})
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("key-000", 10))
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringValue("value-000"), iterator.Value())
+	iterator.Close()
+
+	partitionHandle, ok := blockCache.GetAndPin(cache.BlockId{SSTableId: 1, BlockIndex: 0, Kind: cache.IndexBlock})
+	assert.True(t, ok)
+	_, isMetaList := partitionHandle.Value().(*block.MetaList)
+	assert.True(t, isMetaList)
+	partitionHandle.Release()
+
+	_, ok = blockCache.GetAndPin(cache.BlockId{SSTableId: 1, BlockIndex: 1, Kind: cache.IndexBlock})
+	assert.False(t, ok)
+}
+
+func TestSSTableWithACorruptedIndexPartition(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable := buildSSTableWithPartitionedIndex(t, rootPath, 100)
+	lastPartition := ssTable.index.(*partitionedIndex).partitions[len(ssTable.index.partitionSections())-1].section
+	assert.Nil(t, ssTable.Close())
+
+	corruptByteAt(t, SSTableFilePath(1, rootPath), int64(lastPartition.Offset)+2)
+
+	ssTable, err := Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	_, err = ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("key-099", 10))
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+	assert.ErrorIs(t, ssTable.VerifyChecksums(), checksum.ErrCorruption)
+}
+
+func TestEncodeAndDecodePartitionedIndex(t *testing.T) {
+	index := &partitionedIndex{
+		partitions: []indexPartition{
+			{
+				section:          SectionHandle{Offset: 100, Size: 40},
+				firstBlockIndex:  0,
+				firstBlockOffset: 0,
+				startingKey:      kv.NewStringKeyWithTimestamp("consensus", 5),
+				endingKey:        kv.NewStringKeyWithTimestamp("distributed", 6),
+			},
+			{
+				section:          SectionHandle{Offset: 140, Size: 30},
+				firstBlockIndex:  2,
+				firstBlockOffset: 60,
+				startingKey:      kv.NewStringKeyWithTimestamp("etcd", 7),
+				endingKey:        kv.NewStringKeyWithTimestamp("raft", 8),
+			},
+		},
+		totalBlocks:     3,
+		endOfDataBlocks: 100,
+	}
+	decoded, err := decodeBlockIndex(encodeBlockIndex(index), Footer{FormatVersion: LatestFooterFormatVersion})
+	assert.Nil(t, err)
+
+	decodedIndex := decoded.(*partitionedIndex)
+	assert.Equal(t, index.partitions, decodedIndex.partitions)
+	assert.Equal(t, 3, decodedIndex.numberOfBlocks())
+	assert.Equal(t, uint32(100), decodedIndex.endOfDataBlocks)
+	assert.Equal(t, "consensus", decodedIndex.startingKey().RawString())
+	assert.Equal(t, "raft", decodedIndex.endingKey().RawString())
+}
+
+func TestDecodeBlockIndexWithoutIndexType(t *testing.T) {
+	metaList := block.NewBlockMetaList()
+	metaList.Add(block.Meta{
+		BlockStartingOffset: 0,
+		StartingKey:         kv.NewStringKeyWithTimestamp("consensus", 5),
+		EndingKey:           kv.NewStringKeyWithTimestamp("distributed", 6),
+	})
+	decoded, err := decodeBlockIndex(
+		metaList.Encode(),
+		Footer{MetaSection: SectionHandle{Offset: 80}, FormatVersion: FooterFormatVersionWithFilterType},
+	)
+	assert.Nil(t, err)
+
+	startingOffset, endOffset, err := decoded.offsetRangeOfBlockAt(0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), startingOffset)
+	assert.Equal(t, uint32(80), endOffset)
+}
+
+func TestDecodeBlockIndexWithUnsupportedIndexType(t *testing.T) {
+	_, err := decodeBlockIndex([]byte{10, 0, 0, 0, 0}, Footer{FormatVersion: LatestFooterFormatVersion})
+	assert.Error(t, err)
+}
@@ -1,8 +1,10 @@
 package table
 
 import (
+	"bytes"
 	"go-lsm/kv"
 	"go-lsm/table/block"
+	"go-lsm/table/cache"
 )
 
 // Iterator represents SSTable iterator.
@@ -10,10 +12,18 @@ import (
 // being iterated over.
 // blockIterator is a pointer to the block.Iterator.
 // Effectively, an SSTable Iterator is an iterator which iterates over the blocks of SSTable.
+// blockHandle pins the current block in the cache.BlockCache (if any), it is released when the iterator moves to the
+// next block, becomes invalid or is closed.
+// fillCache determines if the blocks read by the iterator are added to the cache.BlockCache.
+// filePinned is true if the iterator pins the file of the SSTable in the TableCache, it is released when the iterator
+// becomes invalid or is closed.
 type Iterator struct {
 	table         *SSTable
 	blockIndex    int
 	blockIterator *block.Iterator
+	blockHandle   *cache.Handle
+	fillCache     bool
+	filePinned    bool
 }
 
 // Key returns the kv.Key from block.Iterator.
@@ -22,8 +32,14 @@ func (iterator *Iterator) Key() kv.Key {
 }
 
 // Value returns the kv.Value from block.Iterator.
+// If the SSTable file is memory-mapped, the value is copied, because the block refers to the mapped region which is
+// unmapped once the SSTable is removed, whereas the value may outlive the iterator (and the references of the SSTable).
 func (iterator *Iterator) Value() kv.Value {
-	return iterator.blockIterator.Value()
+	value := iterator.blockIterator.Value()
+	if iterator.table.memoryMapped {
+		return kv.NewValue(bytes.Clone(value.Bytes()))
+	}
+	return value
 }
 
 // IsValid returns true of the block.Iterator is valid.
@@ -39,16 +55,45 @@ func (iterator *Iterator) Next() error {
 	}
 	if !iterator.blockIterator.IsValid() {
 		iterator.blockIndex += 1
+		iterator.releaseBlock()
 		if iterator.blockIndex < iterator.table.noOfBlocks() {
-			readBlock, err := iterator.table.readBlock(iterator.blockIndex)
+			readBlock, blockHandle, err := iterator.table.readBlockThroughCache(iterator.blockIndex, iterator.fillCache)
 			if err != nil {
 				return err
 			}
 			iterator.blockIterator = readBlock.SeekToFirst()
+			iterator.blockHandle = blockHandle
 		}
+		iterator.mayBeReleaseBlock()
 	}
 	return nil
 }
 
-// Close does nothing.
-func (iterator *Iterator) Close() {}
+// Close releases the block and the file pinned by the iterator (if any).
+func (iterator *Iterator) Close() {
+	iterator.releaseBlock()
+	iterator.releaseFile()
+}
+
+// mayBeReleaseBlock releases the block and the file pinned by the iterator, if the iterator is invalid.
+// An invalid iterator may not be closed, it is dropped by iterator.MergeIterator, so it must not hold a pinned block (or file).
+func (iterator *Iterator) mayBeReleaseBlock() {
+	if !iterator.IsValid() {
+		iterator.releaseBlock()
+		iterator.releaseFile()
+	}
+}
+
+// releaseFile releases the file pinned by the iterator (if any).
+func (iterator *Iterator) releaseFile() {
+	if iterator.filePinned {
+		iterator.table.releaseAcquiredFile()
+		iterator.filePinned = false
+	}
+}
+
+// releaseBlock releases the block pinned by the iterator (if any).
+func (iterator *Iterator) releaseBlock() {
+	iterator.blockHandle.Release()
+	iterator.blockHandle = nil
+}
@@ -0,0 +1,61 @@
+package table
+
+import (
+	"fmt"
+	"go-lsm/table/bloom"
+)
+
+// prefixFilter represents the bloom filter over the prefixes of the keys of an SSTable, along with the name of the
+// bloom.PrefixExtractor which extracted the prefixes.
+type prefixFilter struct {
+	extractorName string
+	filter        bloom.Filter
+}
+
+// mayContain returns true if the prefix maybe present in the SSTable.
+// It returns true if the prefixFilter was built by a different bloom.PrefixExtractor, because the filter can not
+// rule out the prefix in that case.
+func (prefixFilter *prefixFilter) mayContain(extractor bloom.PrefixExtractor, prefix []byte) bool {
+	if prefixFilter == nil || prefixFilter.extractorName != extractor.Name() {
+		return true
+	}
+	return prefixFilter.filter.MayContainBytes(prefix)
+}
+
+// encode encodes the prefixFilter.
+/*
+  ---------------------------------------------------------------------------------------------------
+ | 1 byte extractor name length | extractor name | 1 byte bloom.FilterType | bloom.Filter.Encode() |
+  ---------------------------------------------------------------------------------------------------
+*/
+// The SSTables before FooterFormatVersionWithFilterType do not have the bloom.FilterType (refer to encodeFilter).
+func (prefixFilter *prefixFilter) encode() ([]byte, error) {
+	if len(prefixFilter.extractorName) > 255 {
+		return nil, fmt.Errorf("prefix extractor name %v is longer than 255 bytes", prefixFilter.extractorName)
+	}
+	encodedFilter, err := encodeFilter(prefixFilter.filter)
+	if err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, 0, 1+len(prefixFilter.extractorName)+len(encodedFilter))
+	buffer = append(buffer, byte(len(prefixFilter.extractorName)))
+	buffer = append(buffer, prefixFilter.extractorName...)
+	return append(buffer, encodedFilter...), nil
+}
+
+// decodePrefixFilter decodes the given buffer to prefixFilter, hasFilterType is true if the filter is preceded by its
+// bloom.FilterType.
+func decodePrefixFilter(buffer []byte, hasFilterType bool) (*prefixFilter, error) {
+	if len(buffer) < 1 || len(buffer) < 1+int(buffer[0]) {
+		return nil, fmt.Errorf("prefix filter section of size %v is too small", len(buffer))
+	}
+	nameLength := int(buffer[0])
+	filter, err := decodeFilter(buffer[1+nameLength:], hasFilterType)
+	if err != nil {
+		return nil, err
+	}
+	return &prefixFilter{
+		extractorName: string(buffer[1 : 1+nameLength]),
+		filter:        filter,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package table
+
+import (
+	"go-lsm/kv"
+	"go-lsm/table/block"
+	"go-lsm/table/bloom"
+	"go-lsm/test_utility"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSSTableWithPrefixExtractor(t *testing.T, rootPath string, extractor bloom.PrefixExtractor) *SSTable {
+	ssTableBuilder := NewSSTableBuilderWithOptions(SSTableBuilderOptions{
+		BlockSize:       block.DefaultBlockSize,
+		PrefixExtractor: extractor,
+	})
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("user:1", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("user:2", 6), kv.NewStringValue("paxos"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("zone", 7), kv.NewStringValue("asia"))
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	return ssTable
+}
+
+func TestBuildAnSSTableWithPrefixFilter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	extractor := bloom.NewFixedLengthPrefixExtractor(5)
+	ssTable := buildSSTableWithPrefixExtractor(t, rootPath, extractor)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.True(t, ssTable.MayContainPrefix(extractor, []byte("user:")))
+	assert.False(t, ssTable.MayContainPrefix(extractor, []byte("item:")))
+}
+
+func TestLoadAnSSTableWithPrefixFilter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	extractor := bloom.NewFixedLengthPrefixExtractor(5)
+	assert.Nil(t, buildSSTableWithPrefixExtractor(t, rootPath, extractor).Close())
+
+	ssTable, err := Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.True(t, ssTable.MayContainPrefix(extractor, []byte("user:")))
+	assert.False(t, ssTable.MayContainPrefix(extractor, []byte("item:")))
+	assert.Nil(t, ssTable.VerifyChecksums())
+}
+
+func TestSSTableWithPrefixFilterOfADifferentPrefixExtractor(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable := buildSSTableWithPrefixExtractor(t, rootPath, bloom.NewFixedLengthPrefixExtractor(5))
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.True(t, ssTable.MayContainPrefix(bloom.NewFixedLengthPrefixExtractor(4), []byte("item")))
+}
+
+func TestSSTableWithoutPrefixFilter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable := buildSSTableWithPrefixExtractor(t, rootPath, nil)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.True(t, ssTable.MayContainPrefix(bloom.NewFixedLengthPrefixExtractor(5), []byte("item:")))
+}
@@ -0,0 +1,204 @@
+package table
+
+import (
+	"encoding/binary"
+	"fmt"
+	"go-lsm/kv"
+	"go-lsm/table/block"
+	"unsafe"
+)
+
+// Properties represents the statistics of an SSTable, collected by the SSTableBuilder and stored in the properties section.
+// NumberOfTombstones is the number of deleted keys (keys with an empty value).
+// MinimumTimestamp and MaximumTimestamp are the smallest and the largest commit-timestamps of the keys in the SSTable.
+// RawKeySizeInBytes and RawValueSizeInBytes are the total sizes of the raw keys (without timestamps) and the values.
+// UncompressedDataSizeInBytes is the total size of the encoded data blocks before compression, and DataSizeInBytes is the
+// total size of the data blocks on disk (including their trailers).
+// BlockSizeInBytes is the (configured) block size the SSTable was built with, it is used when the SSTable is loaded.
+// CreationTimeInUnixSeconds is the wall-clock time when the SSTable was built (the commit-timestamps are logical, and do not
+// tell the age of an SSTable), it is 0 for the SSTables built before the property was introduced.
+//
+// SSTables written before the properties section was introduced (FooterFormatVersionInitial) and the SSTables without the
+// footer (FooterFormatVersionWithoutFooter) have zero Properties.
+type Properties struct {
+	NumberOfEntries             uint64
+	NumberOfTombstones          uint64
+	NumberOfDataBlocks          uint64
+	MinimumTimestamp            uint64
+	MaximumTimestamp            uint64
+	RawKeySizeInBytes           uint64
+	RawValueSizeInBytes         uint64
+	UncompressedDataSizeInBytes uint64
+	DataSizeInBytes             uint64
+	BlockSizeInBytes            uint64
+	CreationTimeInUnixSeconds   uint64
+}
+
+// propertyId identifies a property in the encoded properties section.
+type propertyId uint16
+
+const (
+	numberOfEntriesPropertyId propertyId = iota + 1
+	numberOfTombstonesPropertyId
+	numberOfDataBlocksPropertyId
+	minimumTimestampPropertyId
+	maximumTimestampPropertyId
+	rawKeySizeInBytesPropertyId
+	rawValueSizeInBytesPropertyId
+	uncompressedDataSizeInBytesPropertyId
+	dataSizeInBytesPropertyId
+	blockSizeInBytesPropertyId
+	creationTimeInUnixSecondsPropertyId
+)
+
+var propertyIdSize = int(unsafe.Sizeof(propertyId(0)))
+var propertyValueSize = int(unsafe.Sizeof(uint64(0)))
+
+// CompressionRatio returns the ratio of the uncompressed size of the data blocks to their size on disk.
+// It returns 0 if the SSTable has no data blocks (or no Properties).
+func (properties Properties) CompressionRatio() float64 {
+	if properties.DataSizeInBytes == 0 {
+		return 0
+	}
+	return float64(properties.UncompressedDataSizeInBytes) / float64(properties.DataSizeInBytes)
+}
+
+// Merge returns the Properties which aggregate the properties and the other Properties.
+// It is used to aggregate the Properties of all the SSTables in a level.
+// The merged BlockSizeInBytes is 0 if the two Properties have different block sizes, and the merged
+// CreationTimeInUnixSeconds is the oldest (non-zero) creation time.
+func (properties Properties) Merge(other Properties) Properties {
+	blockSizeInBytes := properties.BlockSizeInBytes
+	if properties.NumberOfEntries == 0 {
+		blockSizeInBytes = other.BlockSizeInBytes
+	} else if other.NumberOfEntries > 0 && other.BlockSizeInBytes != properties.BlockSizeInBytes {
+		blockSizeInBytes = 0
+	}
+	minimumTimestamp, maximumTimestamp := other.MinimumTimestamp, other.MaximumTimestamp
+	if other.NumberOfEntries == 0 {
+		minimumTimestamp, maximumTimestamp = properties.MinimumTimestamp, properties.MaximumTimestamp
+	} else if properties.NumberOfEntries > 0 {
+		minimumTimestamp = min(properties.MinimumTimestamp, other.MinimumTimestamp)
+		maximumTimestamp = max(properties.MaximumTimestamp, other.MaximumTimestamp)
+	}
+	creationTime := properties.CreationTimeInUnixSeconds
+	if creationTime == 0 || (other.CreationTimeInUnixSeconds > 0 && other.CreationTimeInUnixSeconds < creationTime) {
+		creationTime = other.CreationTimeInUnixSeconds
+	}
+	return Properties{
+		NumberOfEntries:             properties.NumberOfEntries + other.NumberOfEntries,
+		NumberOfTombstones:          properties.NumberOfTombstones + other.NumberOfTombstones,
+		NumberOfDataBlocks:          properties.NumberOfDataBlocks + other.NumberOfDataBlocks,
+		MinimumTimestamp:            minimumTimestamp,
+		MaximumTimestamp:            maximumTimestamp,
+		RawKeySizeInBytes:           properties.RawKeySizeInBytes + other.RawKeySizeInBytes,
+		RawValueSizeInBytes:         properties.RawValueSizeInBytes + other.RawValueSizeInBytes,
+		UncompressedDataSizeInBytes: properties.UncompressedDataSizeInBytes + other.UncompressedDataSizeInBytes,
+		DataSizeInBytes:             properties.DataSizeInBytes + other.DataSizeInBytes,
+		BlockSizeInBytes:            blockSizeInBytes,
+		CreationTimeInUnixSeconds:   creationTime,
+	}
+}
+
+// addEntry collects the statistics of the key/value pair.
+func (properties *Properties) addEntry(key kv.Key, value kv.Value) {
+	if properties.NumberOfEntries == 0 || key.Timestamp() < properties.MinimumTimestamp {
+		properties.MinimumTimestamp = key.Timestamp()
+	}
+	if key.Timestamp() > properties.MaximumTimestamp {
+		properties.MaximumTimestamp = key.Timestamp()
+	}
+	properties.NumberOfEntries++
+	if value.IsEmpty() {
+		properties.NumberOfTombstones++
+	}
+	properties.RawKeySizeInBytes += uint64(key.RawSizeInBytes())
+	properties.RawValueSizeInBytes += uint64(value.SizeInBytes())
+}
+
+// addDataBlock collects the statistics of a finished data block.
+func (properties *Properties) addDataBlock(uncompressedSize, sizeOnDisk int) {
+	properties.NumberOfDataBlocks++
+	properties.UncompressedDataSizeInBytes += uint64(uncompressedSize)
+	properties.DataSizeInBytes += uint64(sizeOnDisk)
+}
+
+// encode encodes the Properties.
+// Each property is encoded as (2 bytes property id, 8 bytes value), preceded by 2 bytes for the number of properties.
+/*
+  --------------------------------------------------------------------------------------------------
+ | 2 bytes number of properties | 2 bytes id | 8 bytes value | 2 bytes id | 8 bytes value | ... |
+  --------------------------------------------------------------------------------------------------
+*/
+// A reader ignores the property ids that it does not know, which allows adding new properties without changing the
+// format version.
+func (properties Properties) encode() []byte {
+	values := []struct {
+		id    propertyId
+		value uint64
+	}{
+		{numberOfEntriesPropertyId, properties.NumberOfEntries},
+		{numberOfTombstonesPropertyId, properties.NumberOfTombstones},
+		{numberOfDataBlocksPropertyId, properties.NumberOfDataBlocks},
+		{minimumTimestampPropertyId, properties.MinimumTimestamp},
+		{maximumTimestampPropertyId, properties.MaximumTimestamp},
+		{rawKeySizeInBytesPropertyId, properties.RawKeySizeInBytes},
+		{rawValueSizeInBytesPropertyId, properties.RawValueSizeInBytes},
+		{uncompressedDataSizeInBytesPropertyId, properties.UncompressedDataSizeInBytes},
+		{dataSizeInBytesPropertyId, properties.DataSizeInBytes},
+		{blockSizeInBytesPropertyId, properties.BlockSizeInBytes},
+		{creationTimeInUnixSecondsPropertyId, properties.CreationTimeInUnixSeconds},
+	}
+	buffer := make([]byte, 0, block.Uint16Size+len(values)*(propertyIdSize+propertyValueSize))
+	buffer = binary.LittleEndian.AppendUint16(buffer, uint16(len(values)))
+	for _, property := range values {
+		buffer = binary.LittleEndian.AppendUint16(buffer, uint16(property.id))
+		buffer = binary.LittleEndian.AppendUint64(buffer, property.value)
+	}
+	return buffer
+}
+
+// decodeProperties decodes the given buffer to Properties.
+func decodeProperties(buffer []byte) (Properties, error) {
+	if len(buffer) < block.Uint16Size {
+		return Properties{}, fmt.Errorf("properties section of size %v is too small", len(buffer))
+	}
+	numberOfProperties := int(binary.LittleEndian.Uint16(buffer))
+	buffer = buffer[block.Uint16Size:]
+	if len(buffer) < numberOfProperties*(propertyIdSize+propertyValueSize) {
+		return Properties{}, fmt.Errorf("properties section is too small for %v properties", numberOfProperties)
+	}
+
+	var properties Properties
+	for index := 0; index < numberOfProperties; index++ {
+		id := propertyId(binary.LittleEndian.Uint16(buffer))
+		value := binary.LittleEndian.Uint64(buffer[propertyIdSize:])
+		buffer = buffer[propertyIdSize+propertyValueSize:]
+
+		switch id {
+		case numberOfEntriesPropertyId:
+			properties.NumberOfEntries = value
+		case numberOfTombstonesPropertyId:
+			properties.NumberOfTombstones = value
+		case numberOfDataBlocksPropertyId:
+			properties.NumberOfDataBlocks = value
+		case minimumTimestampPropertyId:
+			properties.MinimumTimestamp = value
+		case maximumTimestampPropertyId:
+			properties.MaximumTimestamp = value
+		case rawKeySizeInBytesPropertyId:
+			properties.RawKeySizeInBytes = value
+		case rawValueSizeInBytesPropertyId:
+			properties.RawValueSizeInBytes = value
+		case uncompressedDataSizeInBytesPropertyId:
+			properties.UncompressedDataSizeInBytes = value
+		case dataSizeInBytesPropertyId:
+			properties.DataSizeInBytes = value
+		case blockSizeInBytesPropertyId:
+			properties.BlockSizeInBytes = value
+		case creationTimeInUnixSecondsPropertyId:
+			properties.CreationTimeInUnixSeconds = value
+		}
+	}
+	return properties, nil
+}
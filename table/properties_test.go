@@ -0,0 +1,172 @@
+package table
+
+import (
+	"encoding/binary"
+	"go-lsm/kv"
+	"go-lsm/test_utility"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeAndDecodeProperties(t *testing.T) {
+	properties := Properties{
+		NumberOfEntries:             10,
+		NumberOfTombstones:          2,
+		NumberOfDataBlocks:          3,
+		MinimumTimestamp:            5,
+		MaximumTimestamp:            50,
+		RawKeySizeInBytes:           100,
+		RawValueSizeInBytes:         200,
+		UncompressedDataSizeInBytes: 400,
+		DataSizeInBytes:             200,
+		BlockSizeInBytes:            4096,
+		CreationTimeInUnixSeconds:   1700000000,
+	}
+	decoded, err := decodeProperties(properties.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, properties, decoded)
+	assert.Equal(t, 2.0, decoded.CompressionRatio())
+}
+
+func TestDecodePropertiesIgnoringAnUnknownProperty(t *testing.T) {
+	encoded := Properties{NumberOfEntries: 10}.encode()
+	numberOfProperties := binary.LittleEndian.Uint16(encoded)
+	binary.LittleEndian.PutUint16(encoded, numberOfProperties+1)
+	encoded = binary.LittleEndian.AppendUint16(encoded, 1000)
+	encoded = binary.LittleEndian.AppendUint64(encoded, 99)
+
+	decoded, err := decodeProperties(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, Properties{NumberOfEntries: 10}, decoded)
+}
+
+func TestDecodeTruncatedProperties(t *testing.T) {
+	encoded := Properties{NumberOfEntries: 10}.encode()
+
+	_, err := decodeProperties(encoded[:len(encoded)-1])
+	assert.Error(t, err)
+}
+
+func TestMergeProperties(t *testing.T) {
+	properties := Properties{
+		NumberOfEntries:    2,
+		NumberOfTombstones: 1,
+		MinimumTimestamp:   5,
+		MaximumTimestamp:   10,
+		RawKeySizeInBytes:  10,
+	}
+	other := Properties{
+		NumberOfEntries:   3,
+		MinimumTimestamp:  2,
+		MaximumTimestamp:  8,
+		RawKeySizeInBytes: 20,
+	}
+	merged := properties.Merge(other)
+	assert.Equal(t, Properties{
+		NumberOfEntries:    5,
+		NumberOfTombstones: 1,
+		MinimumTimestamp:   2,
+		MaximumTimestamp:   10,
+		RawKeySizeInBytes:  30,
+	}, merged)
+}
+
+func TestMergePropertiesWithDifferentBlockSizes(t *testing.T) {
+	properties := Properties{NumberOfEntries: 2, BlockSizeInBytes: 4096}
+
+	assert.Equal(t, uint64(4096), properties.Merge(Properties{NumberOfEntries: 1, BlockSizeInBytes: 4096}).BlockSizeInBytes)
+	assert.Equal(t, uint64(0), properties.Merge(Properties{NumberOfEntries: 1, BlockSizeInBytes: 8192}).BlockSizeInBytes)
+}
+
+func TestMergePropertiesWithTheOldestCreationTime(t *testing.T) {
+	properties := Properties{NumberOfEntries: 2, CreationTimeInUnixSeconds: 200}
+
+	assert.Equal(t, uint64(100), properties.Merge(Properties{NumberOfEntries: 1, CreationTimeInUnixSeconds: 100}).CreationTimeInUnixSeconds)
+	assert.Equal(t, uint64(200), properties.Merge(Properties{NumberOfEntries: 1}).CreationTimeInUnixSeconds)
+}
+
+func TestMergePropertiesWithEmptyProperties(t *testing.T) {
+	properties := Properties{NumberOfEntries: 2, MinimumTimestamp: 5, MaximumTimestamp: 10, BlockSizeInBytes: 4096}
+
+	assert.Equal(t, properties, Properties{}.Merge(properties))
+	assert.Equal(t, properties, properties.Merge(Properties{}))
+}
+
+func TestBuildAnSSTableWithProperties(t *testing.T) {
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 10), kv.EmptyValue)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("etcd", 7), kv.NewStringValue("bbolt"))
+
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	properties := ssTable.Properties()
+	assert.Equal(t, uint64(3), properties.NumberOfEntries)
+	assert.Equal(t, uint64(1), properties.NumberOfTombstones)
+	assert.Equal(t, uint64(1), properties.NumberOfDataBlocks)
+	assert.Equal(t, uint64(5), properties.MinimumTimestamp)
+	assert.Equal(t, uint64(10), properties.MaximumTimestamp)
+	assert.Equal(t, uint64(len("consensus")+len("distributed")+len("etcd")), properties.RawKeySizeInBytes)
+	assert.Equal(t, uint64(len("raft")+len("bbolt")), properties.RawValueSizeInBytes)
+	assert.True(t, properties.DataSizeInBytes > 0)
+	assert.True(t, properties.CompressionRatio() > 0)
+	assert.True(t, properties.CreationTimeInUnixSeconds > 0)
+}
+
+func TestLoadAnSSTableWithProperties(t *testing.T) {
+	ssTableBuilder := NewSSTableBuilder(50)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 10), kv.NewStringValue("TiKV"))
+
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	builtProperties := ssTable.Properties()
+	assert.Nil(t, ssTable.Close())
+
+	ssTable, err = Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.Equal(t, builtProperties, ssTable.Properties())
+	assert.Equal(t, uint64(2), ssTable.Properties().NumberOfDataBlocks)
+	assert.Nil(t, ssTable.VerifyChecksums())
+}
+
+func TestLoadAnSSTableWithTheBlockSizeItWasBuiltWith(t *testing.T) {
+	ssTableBuilder := NewSSTableBuilder(50)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 10), kv.NewStringValue("TiKV"))
+
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(50), ssTable.BlockSize())
+	assert.Nil(t, ssTable.Close())
+
+	ssTable, err = Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.Equal(t, uint(50), ssTable.BlockSize())
+	assert.Equal(t, uint64(50), ssTable.Properties().BlockSizeInBytes)
+}
@@ -1,157 +1,267 @@
 package table
 
 import (
-	"encoding/binary"
 	"fmt"
+	"go-lsm/checksum"
 	"go-lsm/kv"
 	"go-lsm/table/block"
 	"go-lsm/table/bloom"
+	"go-lsm/table/cache"
+	"go-lsm/table/compress"
+	"log/slog"
 	"os"
+	"sync"
 	"sync/atomic"
 )
 
+// blockFormatVersionSize is the size of block.FormatVersion stored after every (compressed) data block.
+const blockFormatVersionSize = 1
+
+// ReadOptions represents the options used for reading the blocks of an SSTable.
+// BlockCache is optional, if it is nil, every block is read from the file.
+// MemoryMapped memory-maps the SSTable file, so that the (uncompressed) blocks are decoded in place, without copying them
+// into fresh buffers.
+// TableCache is optional, if it is nil, the file of the SSTable is kept open for the lifetime of the SSTable.
+type ReadOptions struct {
+	BlockCache   *cache.BlockCache
+	MemoryMapped bool
+	TableCache   *TableCache
+}
+
 // SSTable is an in-memory representation of the file on disk. An SSTable contains the data sorted by key.
 // SSTables can be created by flushing an immutable Memtable or by merging SSTables (/compaction).
+// If the SSTable has a cache.BlockCache, the blocks are read through the cache, and the cached blocks of the SSTable are
+// evicted when the SSTable is removed or closed.
+//
+// The file of an SSTable is either kept open for the lifetime of the SSTable (file), or it is managed by the TableCache
+// (file is nil), which opens the file (using filePath) on demand.
+// The file of an SSTable (and its memory-mapped region, if any) is deleted only when the SSTable is removed and its
+// references drop to zero, so an SSTable which is removed while iterators are still using it, keeps serving them.
 type SSTable struct {
-	id                      uint64
-	blockMetaList           *block.MetaList
-	bloomFilter             bloom.Filter
-	file                    *File
-	blockMetaStartingOffset uint32
-	blockSize               uint
-	startingKey             kv.Key
-	endingKey               kv.Key
-	references              atomic.Int64
+	id             uint64
+	formatVersion  FooterFormatVersion
+	index          blockIndex
+	bloomFilter    bloom.Filter
+	prefixFilter   *prefixFilter
+	file           *File
+	filePath       string
+	memoryMapped   bool
+	blockSize      uint
+	startingKey    kv.Key
+	endingKey      kv.Key
+	properties     Properties
+	sizeInBytes    int64
+	blockCache     *cache.BlockCache
+	tableCache     *TableCache
+	references     atomic.Int64
+	removed        atomic.Bool
+	releaseFile    sync.Once
+	releaseFileErr error
 }
 
 // Load loads the entire SSTable from the given rootPath.
 // Please take a look at table.SSTableBuilder to understand the encoding of SSTable.
-func Load(id uint64, rootPath string, blockSize uint) (*SSTable, error) {
-	file, err := Open(SSTableFilePath(id, rootPath))
+// Loading starts by reading the Footer, which identifies the metadata section and the bloom filter section.
+// An SSTable written before the Footer was introduced (FooterFormatVersionWithoutFooter) is loaded without verifying the
+// checksums, and its data blocks are read in block.FormatVersionFullKeys.
+// The block size is read from the Properties of the SSTable, it is 0 for the SSTables which do not have the block size in
+// their Properties (SSTables before FooterFormatVersionWithProperties).
+// It returns ErrInvalidSSTable if the file is not an SSTable (or has an unsupported format version), and
+// checksum.CorruptionError if the checksum of the Footer, the metadata section or the bloom filter section does not match.
+func Load(id uint64, rootPath string) (*SSTable, error) {
+	return LoadWithReadOptions(id, rootPath, ReadOptions{})
+}
+
+// LoadWithReadOptions loads the entire SSTable from the given rootPath, and uses the given ReadOptions for reading its blocks.
+// The bloom filter and the block index are kept in memory, so they are available even when the file is closed by the
+// TableCache. With a partitioned index (PartitionedIndexType), only the top-level index is kept in memory, and the
+// index partitions are read on demand through the cache.BlockCache (if any).
+func LoadWithReadOptions(id uint64, rootPath string, readOptions ReadOptions) (*SSTable, error) {
+	filePath := SSTableFilePath(id, rootPath)
+	file, err := openSSTableFile(filePath, readOptions.MemoryMapped)
 	if err != nil {
 		return nil, err
 	}
-
-	fileSize := file.Size()
-
-	//bloomFilter reads the bloom filter section in the file. It involves the following:
-	// 1) Read the last 4 bytes to get the starting offset of the bloom filter. Let's call this offset as X.
-	// 2) Read the buffer of size (fileSize - X - 4 bytes) from offset X.
-	// 3) Decode the buffer to bloom filter.
-	// Let's consider that the file size is 1024 bytes and the last 4 bytes denote the starting position (/offset) of the
-	// bloom filter. Let's say that the last 4 bytes contain the offset as 996. That means, the byte buffer from offset
-	// 996 to (1024 - 4) [24 bytes] is the bloom filter buffer => 1024 - 996 - 4 is same as fileSize - X - 4 bytes.
-	// This means, read 24 bytes after seeking to offset X in the file.
-	bloomFilter := func() (bloom.Filter, uint32, error) {
-		offsetBuffer := make([]byte, block.Uint32Size)
-		n, err := file.Read(fileSize-int64(block.Uint32Size), offsetBuffer)
-		if err != nil {
-			return bloom.Filter{}, 0, err
-		}
-
-		bloomOffset := binary.LittleEndian.Uint32(offsetBuffer[:n])
-		bloomBuffer := make([]byte, fileSize-int64(bloomOffset)-int64(block.Uint32Size))
-		_, err = file.Read(int64(bloomOffset), bloomBuffer)
-		if err != nil {
-			return bloom.Filter{}, 0, err
-		}
-		filter, err := bloom.DecodeToBloomFilter(bloomBuffer, bloom.FalsePositiveRate)
-		return filter, bloomOffset, err
-	}
-	//blockMetaList reads the block meta-list from the file. It involves the following:
-	// 1) Read the 4 bytes before the bloom filter section to get the starting offset of the meta section. Let's call this offset as Y.
-	// 2) Read the buffer of size (bloom offset - 4 bytes) from offset Y.
-	// 3) Decode the buffer to meta-list.
-	// Let's consider that the file size is 1024 bytes and the last 4 bytes denote the starting position (/offset) of the
-	// bloom filter. Let's say that the last 4 bytes contain the offset as 996.
-	// Also, consider that the 4 bytes before the bloom section contain 900 as the starting offset of metadata section.
-	// This means, read a total of 992-900 (92) bytes from offset 900 will give the meta-list buffer.
-	// 996 if the starting offset of bloom filter section, minus 4 bytes which contain the meta-list starting offset gives us
-	// offset 992. So, reading from the offset 900 (the starting offset of metadata section) to 992 gives us meta-list buffer.
-	blockMetaList := func(bloomOffset uint32) (*block.MetaList, uint32, error) {
-		blockMetaOffsetBuffer := make([]byte, block.Uint32Size)
-		n, err := file.Read(int64(bloomOffset-uint32(block.Uint32Size)), blockMetaOffsetBuffer)
-		if err != nil {
-			return nil, 0, err
-		}
-
-		blockMetaOffset := binary.LittleEndian.Uint32(blockMetaOffsetBuffer[:n])
-		blockMetaListBuffer := make([]byte, int64(bloomOffset)-int64(block.Uint32Size))
-		_, err = file.Read(int64(blockMetaOffset), blockMetaListBuffer)
-		if err != nil {
-			return nil, 0, err
-		}
-		return block.DecodeToBlockMetaList(blockMetaListBuffer), blockMetaOffset, nil
+	footer, err := readFooter(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
 	}
-
-	filter, bloomOffset, err := bloomFilter()
+	filter, err := readBloomFilterSection(file, footer)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	index, err := readBlockIndexSection(file, footer)
 	if err != nil {
+		_ = file.Close()
 		return nil, err
 	}
-	metaList, metaOffset, err := blockMetaList(bloomOffset)
+	properties, err := readPropertiesSection(file, footer)
 	if err != nil {
+		_ = file.Close()
 		return nil, err
 	}
-	startingKey, _ := metaList.StartingKeyOfFirstBlock()
-	endingKey, _ := metaList.EndingKeyOfLastBlock()
-	return &SSTable{
-		id:                      id,
-		blockMetaList:           metaList,
-		bloomFilter:             filter,
-		blockMetaStartingOffset: metaOffset,
-		file:                    file,
-		blockSize:               blockSize,
-		startingKey:             startingKey,
-		endingKey:               endingKey,
-	}, nil
+	prefixFilter, err := readPrefixFilterSection(file, footer)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return newSSTable(&SSTable{
+		id:            id,
+		formatVersion: footer.FormatVersion,
+		index:         index,
+		bloomFilter:   filter,
+		prefixFilter:  prefixFilter,
+		blockSize:     uint(properties.BlockSizeInBytes),
+		startingKey:   index.startingKey(),
+		endingKey:     index.endingKey(),
+		properties:    properties,
+	}, file, readOptions), nil
+}
+
+// newSSTable completes the given SSTable with its (open) file and the ReadOptions.
+// If the ReadOptions has a TableCache, the file is handed over to the TableCache.
+// A partitionedIndex reads its partitions through the SSTable (refer to SSTable.readIndexPartition).
+func newSSTable(table *SSTable, file *File, readOptions ReadOptions) *SSTable {
+	if index, ok := table.index.(*partitionedIndex); ok {
+		index.readPartition = table.readIndexPartition
+	}
+	table.filePath = file.Path()
+	table.sizeInBytes = file.Size()
+	table.memoryMapped = file.IsMemoryMapped()
+	table.blockCache = readOptions.BlockCache
+	table.tableCache = readOptions.TableCache
+	if table.tableCache != nil {
+		table.tableCache.add(table, file)
+	} else {
+		table.file = file
+	}
+	return table
+}
+
+// openSSTableFile opens the SSTable file at the given filePath, and memory-maps it if memoryMapped is true.
+func openSSTableFile(filePath string, memoryMapped bool) (*File, error) {
+	file, err := Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if memoryMapped {
+		if err := file.memoryMap(); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
 }
 
 // SeekToFirst seeks to the first key in the SSTable.
 // First key is a part of the first block, so the block at index 0 is read and a block.Iterator
 // is created over the read block.
-// It is used in compact.Compaction.
+// It is used in compact.Compaction, which reads every block of the SSTable only once. Hence, the blocks which are not
+// present in the cache.BlockCache are not added to it, to avoid evicting the blocks used by Get and Scan.
 func (table *SSTable) SeekToFirst() (*Iterator, error) {
-	readBlock, err := table.readBlock(0)
+	if _, err := table.acquireFile(); err != nil {
+		return nil, err
+	}
+	readBlock, blockHandle, err := table.readBlockThroughCache(0, false)
 	if err != nil {
+		table.releaseAcquiredFile()
 		return nil, err
 	}
-	return &Iterator{
+	iterator := &Iterator{
 		table:         table,
 		blockIndex:    0,
 		blockIterator: readBlock.SeekToFirst(),
-	}, nil
+		blockHandle:   blockHandle,
+		fillCache:     false,
+		filePinned:    true,
+	}
+	iterator.mayBeReleaseBlock()
+	return iterator, nil
 }
 
 // SeekToKey seeks to the block that contains a key greater than or equal to the given key.
 // It involves the following:
-// 1) Identify the block.Meta that may contain the key.
+// 1) Identify the block that may contain the key using the block index.
 // 2) Read the block identified by blockIndex.
 // 3) Seek to the key within the read block (seeks to the offset where the key >= the given key)
 // 4) Handle the case where block.Iterator may become invalid.
+// The block which is being iterated over is pinned in the cache.BlockCache (if any) till the iterator moves past it, and
+// the file is pinned in the TableCache (if any) till the iterator becomes invalid or is closed.
+// It increments the references of the SSTable, which are decremented by the caller (refer to DecrementReferenceFor).
 func (table *SSTable) SeekToKey(key kv.Key) (*Iterator, error) {
-	_, blockIndex := table.blockMetaList.MaybeBlockMetaContaining(key)
-	readBlock, err := table.readBlock(blockIndex)
+	return table.seekToKeyAndIncrementReference(key, block.Block.SeekToKey)
+}
+
+// SeekToKeyForCompaction seeks to the key greater than or equal to the given key (like SeekToKey).
+// It is used in compact.Compaction to start a subcompaction from a key. Like SeekToFirst, the blocks which are not
+// present in the cache.BlockCache are not added to it, and it does not increment the references of the SSTable.
+func (table *SSTable) SeekToKeyForCompaction(key kv.Key) (*Iterator, error) {
+	return table.seekToKey(key, block.Block.SeekToKey, false)
+}
+
+// SeekToKeyForPointLookup seeks to the key greater than or equal to the given key (like SeekToKey), using the hash index
+// of the blocks (if any) to skip the binary search within a block. It is meant for point lookups (StorageState.Get):
+// the returned Iterator is positioned at the key greater or equal to the given key only if the raw key is present in the
+// SSTable, hence the caller must compare the raw key of the Iterator with the raw key of the given key.
+// Please check block.Block.SeekToKeyUsingHashIndex.
+func (table *SSTable) SeekToKeyForPointLookup(key kv.Key) (*Iterator, error) {
+	return table.seekToKeyAndIncrementReference(key, block.Block.SeekToKeyUsingHashIndex)
+}
+
+// seekToKeyAndIncrementReference seeks to the key greater than or equal to the given key using the given seek function
+// (filling the cache.BlockCache), and increments the references of the SSTable.
+func (table *SSTable) seekToKeyAndIncrementReference(key kv.Key, seek func(block.Block, kv.Key) *block.Iterator) (*Iterator, error) {
+	iterator, err := table.seekToKey(key, seek, true)
+	if err != nil {
+		return nil, err
+	}
+	table.incrementReference()
+	return iterator, nil
+}
+
+// seekToKey seeks to the key greater than or equal to the given key using the given seek function within the blocks.
+// The blocks read by the seek (and later, by the Iterator) are added to the cache.BlockCache only if fillCache is true.
+func (table *SSTable) seekToKey(key kv.Key, seek func(block.Block, kv.Key) *block.Iterator, fillCache bool) (*Iterator, error) {
+	if _, err := table.acquireFile(); err != nil {
+		return nil, err
+	}
+	blockIndex, err := table.index.blockContaining(key)
 	if err != nil {
+		table.releaseAcquiredFile()
+		return nil, err
+	}
+	readBlock, blockHandle, err := table.readBlockThroughCache(blockIndex, fillCache)
+	if err != nil {
+		table.releaseAcquiredFile()
 		return nil, err
 	}
 
-	blockIterator := readBlock.SeekToKey(key)
+	blockIterator := seek(readBlock, key)
 	if !blockIterator.IsValid() {
 		blockIndex += 1
 		if blockIndex < table.noOfBlocks() {
-			readBlock, err := table.readBlock(blockIndex)
+			blockHandle.Release()
+			readBlock, nextBlockHandle, err := table.readBlockThroughCache(blockIndex, fillCache)
 			if err != nil {
+				table.releaseAcquiredFile()
 				return nil, err
 			}
-			blockIterator = readBlock.SeekToKey(key)
+			blockIterator = seek(readBlock, key)
+			blockHandle = nextBlockHandle
 		}
 	}
-	table.incrementReference()
-	return &Iterator{
+	iterator := &Iterator{
 		table:         table,
 		blockIndex:    blockIndex,
 		blockIterator: blockIterator,
-	}, nil
+		blockHandle:   blockHandle,
+		fillCache:     fillCache,
+		filePinned:    true,
+	}
+	iterator.mayBeReleaseBlock()
+	return iterator, nil
 }
 
 // ContainsInclusive returns true if the SSTable contains the inclusiveKeyRange.
@@ -169,12 +279,57 @@ func (table *SSTable) ContainsInclusive(inclusiveKeyRange kv.InclusiveKeyRange[k
 	return true
 }
 
+// StartingKey returns the starting (smallest) key of the SSTable.
+func (table *SSTable) StartingKey() kv.Key {
+	return table.startingKey
+}
+
+// EndingKey returns the ending (largest) key of the SSTable.
+func (table *SSTable) EndingKey() kv.Key {
+	return table.endingKey
+}
+
 // MayContain uses bloom filter to determine if the given key maybe present in the SSTable.
 // Returns true if the key MAYBE present, false otherwise.
 func (table *SSTable) MayContain(key kv.Key) bool {
 	return table.bloomFilter.MayContain(key)
 }
 
+// MayContainPrefix uses the prefix bloom filter to determine if any key with the given prefix maybe present in the SSTable.
+// The prefix must be extracted by the given bloom.PrefixExtractor. It returns true if the SSTable does not have a prefix
+// filter, or its prefix filter was built by a different bloom.PrefixExtractor.
+func (table *SSTable) MayContainPrefix(extractor bloom.PrefixExtractor, prefix []byte) bool {
+	return table.prefixFilter.mayContain(extractor, prefix)
+}
+
+// FilterType returns the bloom.FilterType of the bloom filter of the SSTable.
+func (table *SSTable) FilterType() bloom.FilterType {
+	return table.bloomFilter.Type()
+}
+
+// IndexType returns the IndexType of the block index of the SSTable.
+func (table *SSTable) IndexType() IndexType {
+	if _, ok := table.index.(*partitionedIndex); ok {
+		return PartitionedIndexType
+	}
+	return FlatIndexType
+}
+
+// BlockSize returns the block size the SSTable was built with, it is 0 if the SSTable does not record it in its Properties.
+func (table *SSTable) BlockSize() uint {
+	return table.blockSize
+}
+
+// Properties returns the Properties of the SSTable.
+func (table *SSTable) Properties() Properties {
+	return table.properties
+}
+
+// SizeInBytes returns the size of the SSTable file.
+func (table *SSTable) SizeInBytes() int64 {
+	return table.sizeInBytes
+}
+
 // Id returns the id of SSTable.
 func (table *SSTable) Id() uint64 {
 	return table.id
@@ -185,61 +340,242 @@ func (table *SSTable) TotalReferences() int64 {
 	return table.references.Load()
 }
 
-// Remove removes the SSTable.
+// Close closes the SSTable file, without removing it.
+// It is used when the storage is opened in read-only mode, where the SSTables are never removed.
+func (table *SSTable) Close() error {
+	table.evictCachedBlocks()
+	return table.releaseFileOnce()
+}
+
+// Remove removes the SSTable, along with its blocks from the cache.BlockCache (if any).
+// The file is closed (and unmapped, if it is memory-mapped) and deleted only when the references of the SSTable drop
+// to zero. table.SSTableCleaner removes an SSTable only if it has no references, however, the SSTable must never unmap
+// (or delete) a file which is being used by live iterators.
 func (table *SSTable) Remove() error {
-	if err := table.file.file.Close(); err != nil {
+	table.evictCachedBlocks()
+	table.removed.Store(true)
+	if table.TotalReferences() <= 0 {
+		return table.releaseFileOnce()
+	}
+	return nil
+}
+
+// VerifyChecksums verifies the checksums of the Footer, the metadata section, the index partitions (if any), the bloom
+// filter section and all the data blocks of the SSTable by reading them from the file.
+// It returns checksum.CorruptionError for the first section (or block) whose checksum does not match.
+// An SSTable in FooterFormatVersionWithoutFooter has no checksums, so only its data blocks are read (and decoded).
+func (table *SSTable) VerifyChecksums() error {
+	file, err := table.acquireFile()
+	if err != nil {
+		return err
+	}
+	defer table.releaseAcquiredFile()
+
+	footer, err := readFooter(file)
+	if err != nil {
+		return err
+	}
+	if !footer.HasChecksums() {
+		return table.readAllBlocks()
+	}
+	if _, err := readSection(file, footer.BloomSection); err != nil {
 		return err
 	}
-	if err := os.Remove(table.file.file.Name()); err != nil {
+	if _, err := readSection(file, footer.MetaSection); err != nil {
 		return err
 	}
+	for _, section := range table.index.partitionSections() {
+		if _, err := readSection(file, section); err != nil {
+			return err
+		}
+	}
+	if footer.HasProperties() {
+		if _, err := readSection(file, footer.PropertiesSection); err != nil {
+			return err
+		}
+	}
+	if footer.HasPrefixFilter() {
+		if _, err := readSection(file, footer.PrefixFilterSection); err != nil {
+			return err
+		}
+	}
+	return table.readAllBlocks()
+}
+
+// readAllBlocks reads all the data blocks of the SSTable from the file.
+func (table *SSTable) readAllBlocks() error {
+	for blockIndex := 0; blockIndex < table.noOfBlocks(); blockIndex++ {
+		if _, err := table.readBlock(blockIndex); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// IncrementReferenceFor increments the references for all the SSTables.
+// It is used when the SSTables are read without creating iterators, an SSTable with references > 0 is not removed.
+func IncrementReferenceFor(tables []*SSTable) {
+	for _, table := range tables {
+		table.incrementReference()
+	}
+}
+
 // DecrementReferenceFor decrements the references for all the SSTables.
+// The file of a removed SSTable is released when its references drop to zero.
 func DecrementReferenceFor(tables []*SSTable) {
 	for _, table := range tables {
-		table.references.Add(-1)
+		if table.references.Add(-1) <= 0 && table.removed.Load() {
+			if err := table.releaseFileOnce(); err != nil {
+				slog.Error(fmt.Sprintf("error in releasing the file of ssTable %v, %v", table.id, err))
+			}
+		}
+	}
+}
+
+// readBlockThroughCache returns the block at the given blockIndex along with the cache.Handle which pins the block in the
+// cache.BlockCache. The returned cache.Handle is nil if the SSTable does not have a cache.BlockCache, or if the block
+// was read from the file with fillCache as false.
+// The caller must release the returned cache.Handle once it is done with the block.
+func (table *SSTable) readBlockThroughCache(blockIndex int, fillCache bool) (block.Block, *cache.Handle, error) {
+	if table.blockCache == nil {
+		readBlock, err := table.readBlock(blockIndex)
+		return readBlock, nil, err
+	}
+	blockId := cache.BlockId{SSTableId: table.id, BlockIndex: blockIndex}
+	if blockHandle, ok := table.blockCache.GetAndPin(blockId); ok {
+		return blockHandle.Value().(block.Block), blockHandle, nil
+	}
+	readBlock, err := table.readBlock(blockIndex)
+	if err != nil {
+		return block.Block{}, nil, err
+	}
+	if !fillCache {
+		return readBlock, nil, nil
+	}
+	blockHandle := table.blockCache.PutAndPin(blockId, readBlock)
+	return blockHandle.Value().(block.Block), blockHandle, nil
+}
+
+// releaseFileOnce closes (and unmaps) the file of the SSTable exactly once, and deletes the file if the SSTable is removed.
+func (table *SSTable) releaseFileOnce() error {
+	table.releaseFile.Do(func() {
+		if table.tableCache != nil {
+			table.releaseFileErr = table.tableCache.close(table.id)
+		} else {
+			table.releaseFileErr = table.file.Close()
+		}
+		if table.releaseFileErr == nil && table.removed.Load() {
+			table.releaseFileErr = os.Remove(table.filePath)
+		}
+	})
+	return table.releaseFileErr
+}
+
+// acquireFile returns the file of the SSTable. If the file is managed by the TableCache, the file is opened (if required)
+// and pinned, and it must be released using releaseAcquiredFile.
+func (table *SSTable) acquireFile() (*File, error) {
+	if table.tableCache == nil {
+		return table.file, nil
 	}
+	return table.tableCache.acquire(table)
 }
 
-// readBlock reads the block at the given blockIndex.
+// releaseAcquiredFile releases the file acquired using acquireFile.
+func (table *SSTable) releaseAcquiredFile() {
+	if table.tableCache != nil {
+		table.tableCache.release(table.id)
+	}
+}
+
+// evictCachedBlocks evicts all the blocks of the SSTable from the cache.BlockCache (if any).
+func (table *SSTable) evictCachedBlocks() {
+	if table.blockCache != nil {
+		table.blockCache.EvictSSTable(table.id)
+	}
+}
+
+// readBlock reads the block at the given blockIndex from the file (bypassing the cache.BlockCache), verifies its checksum
+// and decompresses it using the codec identified by the block trailer. The decompressed block is decoded as per its block.FormatVersion.
+// If the file is memory-mapped, an uncompressed block is decoded in place (it refers to the mapped region), and the caller
+// must keep the file pinned (refer to TableCache) while it uses the block.
+// It returns checksum.CorruptionError if the checksum of the block does not match.
+// A block of an SSTable in FooterFormatVersionWithoutFooter has neither the checksum nor the block trailer, it is
+// decoded (in place) in block.FormatVersionFullKeys.
 func (table *SSTable) readBlock(blockIndex int) (block.Block, error) {
-	startingOffset, endOffset := table.offsetRangeOfBlockAt(blockIndex)
-	buffer := make([]byte, endOffset-startingOffset)
-	n, err := table.file.Read(int64(startingOffset), buffer)
+	file, err := table.acquireFile()
+	if err != nil {
+		return block.Block{}, err
+	}
+	defer table.releaseAcquiredFile()
+
+	startingOffset, endOffset, err := table.index.offsetRangeOfBlockAt(blockIndex)
+	if err != nil {
+		return block.Block{}, err
+	}
+	buffer, err := file.readView(int64(startingOffset), int(endOffset-startingOffset))
+	if err != nil {
+		return block.Block{}, err
+	}
+	if table.formatVersion == FooterFormatVersionWithoutFooter {
+		decodedBlock, err := block.DecodeToBlockOfFormatVersion(buffer, block.FormatVersionFullKeys)
+		if err != nil {
+			return block.Block{}, fmt.Errorf("failed to decode block %v of SSTable %v: %w", blockIndex, table.id, err)
+		}
+		return decodedBlock, nil
+	}
+	verified, err := checksum.Verify(buffer, table.filePath, int64(startingOffset))
 	if err != nil {
 		return block.Block{}, err
 	}
-	return block.DecodeToBlock(buffer[:n]), nil
+	if len(verified) < blockFormatVersionSize {
+		return block.Block{}, checksum.NewCorruptionError(table.filePath, int64(startingOffset), "block is too small")
+	}
+	formatVersion := block.FormatVersion(verified[len(verified)-blockFormatVersionSize])
+	decompressed, err := compress.Decompress(verified[:len(verified)-blockFormatVersionSize])
+	if err != nil {
+		return block.Block{}, fmt.Errorf("failed to decompress block %v of SSTable %v: %w", blockIndex, table.id, err)
+	}
+	decodedBlock, err := block.DecodeToBlockOfFormatVersion(decompressed, formatVersion)
+	if err != nil {
+		return block.Block{}, fmt.Errorf("failed to decode block %v of SSTable %v: %w", blockIndex, table.id, err)
+	}
+	return decodedBlock, nil
 }
 
 // noOfBlocks returns the number of blocks in SSTable.
 func (table *SSTable) noOfBlocks() int {
-	return table.blockMetaList.Length()
-}
-
-// offsetRangeOfBlockAt returns the byte offset range of the block at the given index.
-// offsetRangeOfBlockAt works by getting the block.Meta at the given index, and block.Meta at index + 1.
-// If the block.Meta is available at the next index, it returns the BlockStartingOffset of block.Meta at the given index,
-// and block.Meta at index + 1.
-// If the block.Meta is not available at the next index, it returns the BlockStartingOffset of block.Meta at the given index,
-// and table.blockMetaBeginOffset, which is essentially the offset of the 4-bytes which denote the meta starting offset.
-// Please take a look at the table.SSTableBuilder for encoding of SSTable.
-func (table *SSTable) offsetRangeOfBlockAt(blockIndex int) (uint32, uint32) {
-	blockMeta, blockPresent := table.blockMetaList.GetAt(blockIndex)
-	if !blockPresent {
-		panic(fmt.Errorf("block meta not found at index %v", blockIndex))
-	}
-	nextBlockMeta, nextBlockPresent := table.blockMetaList.GetAt(blockIndex + 1)
-
-	var endOffset uint32
-	if nextBlockPresent {
-		endOffset = nextBlockMeta.BlockStartingOffset
-	} else {
-		endOffset = table.blockMetaStartingOffset
+	return table.index.numberOfBlocks()
+}
+
+// readIndexPartition returns the index partition (block.MetaList) at the given partitionIndex along with the cache.Handle
+// which pins the partition in the cache.BlockCache. The partitions are cached as cache.IndexBlock, so they do not collide
+// with the data blocks of the SSTable. The returned cache.Handle is nil if the SSTable does not have a cache.BlockCache.
+// The partition is read into a fresh buffer (even if the file is memory-mapped), because a cached partition may outlive
+// the mapping of the file.
+// The caller must release the returned cache.Handle once it is done with the partition.
+func (table *SSTable) readIndexPartition(partitionIndex int, section SectionHandle) (*block.MetaList, *cache.Handle, error) {
+	partitionId := cache.BlockId{SSTableId: table.id, BlockIndex: partitionIndex, Kind: cache.IndexBlock}
+	if table.blockCache != nil {
+		if partitionHandle, ok := table.blockCache.GetAndPin(partitionId); ok {
+			return partitionHandle.Value().(*block.MetaList), partitionHandle, nil
+		}
+	}
+	file, err := table.acquireFile()
+	if err != nil {
+		return nil, nil, err
 	}
-	return blockMeta.BlockStartingOffset, endOffset
+	defer table.releaseAcquiredFile()
+
+	encodedPartition, err := readSection(file, section)
+	if err != nil {
+		return nil, nil, err
+	}
+	metaList := block.DecodeToBlockMetaList(encodedPartition)
+	if table.blockCache == nil {
+		return metaList, nil, nil
+	}
+	partitionHandle := table.blockCache.PutAndPin(partitionId, metaList)
+	return partitionHandle.Value().(*block.MetaList), partitionHandle, nil
 }
 
 // incrementReference increments the references of the SSTable.
@@ -248,3 +584,91 @@ func (table *SSTable) offsetRangeOfBlockAt(blockIndex int) (uint32, uint32) {
 func (table *SSTable) incrementReference() {
 	table.references.Add(1)
 }
+
+// readBloomFilterSection reads the bloom filter section identified by the Footer, verifies its checksum and decodes the
+// section (without checksum) to bloom filter.
+func readBloomFilterSection(file *File, footer Footer) (bloom.Filter, error) {
+	encodedFilter, err := footer.readSectionOf(file, footer.BloomSection)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := decodeFilter(encodedFilter, footer.HasFilterType())
+	if err != nil {
+		return nil, checksum.NewCorruptionError(file.Path(), int64(footer.BloomSection.Offset), err.Error())
+	}
+	return filter, nil
+}
+
+// readBlockIndexSection reads the block meta section identified by the Footer, verifies its checksum and decodes
+// the section (without checksum) to blockIndex.
+func readBlockIndexSection(file *File, footer Footer) (blockIndex, error) {
+	encodedIndex, err := footer.readSectionOf(file, footer.MetaSection)
+	if err != nil {
+		return nil, err
+	}
+	index, err := decodeBlockIndex(encodedIndex, footer)
+	if err != nil {
+		return nil, checksum.NewCorruptionError(file.Path(), int64(footer.MetaSection.Offset), err.Error())
+	}
+	return index, nil
+}
+
+// readPropertiesSection reads the properties section identified by the Footer, verifies its checksum and decodes the
+// section (without checksum) to Properties. It returns zero Properties if the SSTable does not have the properties section.
+func readPropertiesSection(file *File, footer Footer) (Properties, error) {
+	if !footer.HasProperties() {
+		return Properties{}, nil
+	}
+	encodedProperties, err := footer.readSectionOf(file, footer.PropertiesSection)
+	if err != nil {
+		return Properties{}, err
+	}
+	properties, err := decodeProperties(encodedProperties)
+	if err != nil {
+		return Properties{}, checksum.NewCorruptionError(file.Path(), int64(footer.PropertiesSection.Offset), err.Error())
+	}
+	return properties, nil
+}
+
+// readPrefixFilterSection reads the prefix filter section identified by the Footer, verifies its checksum and decodes the
+// section (without checksum) to prefixFilter. It returns nil if the SSTable does not have the prefix filter section.
+func readPrefixFilterSection(file *File, footer Footer) (*prefixFilter, error) {
+	if !footer.HasPrefixFilter() {
+		return nil, nil
+	}
+	encodedPrefixFilter, err := footer.readSectionOf(file, footer.PrefixFilterSection)
+	if err != nil {
+		return nil, err
+	}
+	prefixFilter, err := decodePrefixFilter(encodedPrefixFilter, footer.HasFilterType())
+	if err != nil {
+		return nil, checksum.NewCorruptionError(file.Path(), int64(footer.PrefixFilterSection.Offset), err.Error())
+	}
+	return prefixFilter, nil
+}
+
+// encodeFilter encodes the bloom.Filter along with its bloom.FilterType.
+/*
+  -------------------------------------------------
+ | 1 byte bloom.FilterType | bloom.Filter.Encode() |
+  -------------------------------------------------
+*/
+func encodeFilter(filter bloom.Filter) ([]byte, error) {
+	encodedFilter, err := filter.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(filter.Type())}, encodedFilter...), nil
+}
+
+// decodeFilter decodes the given buffer to bloom.Filter. If the buffer does not start with the bloom.FilterType
+// (SSTables before FooterFormatVersionWithFilterType), the filter is decoded as bloom.StandardFilter.
+func decodeFilter(buffer []byte, hasFilterType bool) (bloom.Filter, error) {
+	if !hasFilterType {
+		return bloom.DecodeToBloomFilter(buffer)
+	}
+	if len(buffer) < 1 {
+		return nil, fmt.Errorf("filter of size %v is too small", len(buffer))
+	}
+	return bloom.DecodeFilter(bloom.FilterType(buffer[0]), buffer[1:])
+}
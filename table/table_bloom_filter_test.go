@@ -2,6 +2,7 @@ package table
 
 import (
 	"go-lsm/kv"
+	"go-lsm/table/bloom"
 	"go-lsm/test_utility"
 	"testing"
 
@@ -22,7 +23,7 @@ func TestLoadSSTableWithSingleBlockAndCheckKeysForExistenceUsingBloom(t *testing
 	_, err := ssTableBuilder.Build(1, rootPath)
 	assert.Nil(t, err)
 
-	ssTable, err := Load(1, rootPath, 4096)
+	ssTable, err := Load(1, rootPath)
 
 	assert.Nil(t, err)
 	assert.True(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("consensus", 8)))
@@ -44,7 +45,7 @@ func TestLoadSSTableWithSingleBlockAndCheckKeysForNonExistenceUsingBloom(t *test
 	_, err := ssTableBuilder.Build(1, rootPath)
 	assert.Nil(t, err)
 
-	ssTable, err := Load(1, rootPath, 4096)
+	ssTable, err := Load(1, rootPath)
 
 	assert.Nil(t, err)
 	assert.False(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("paxos", 7)))
@@ -64,9 +65,62 @@ func TestLoadAnSSTableWithTwoBlocksAndCheckKeysForExistenceUsingBloom(t *testing
 	_, err := ssTableBuilder.Build(1, rootPath)
 	assert.Nil(t, err)
 
-	ssTable, err := Load(1, rootPath, 30)
+	ssTable, err := Load(1, rootPath)
 	assert.Nil(t, err)
 	assert.True(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("consensus", 7)))
 	assert.True(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("distributed", 7)))
 	assert.False(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("etcd", 8)))
 }
+
+func TestLoadSSTableWithBlockedBloomFilter(t *testing.T) {
+	ssTableBuilder := NewSSTableBuilderWithOptions(SSTableBuilderOptions{
+		BlockSize:  4096,
+		FilterType: bloom.BlockedFilterType,
+	})
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 6), kv.NewStringValue("TiKV"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("etcd", 7), kv.NewStringValue("bbolt"))
+
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	assert.Equal(t, bloom.BlockedFilterType, ssTable.FilterType())
+	assert.Nil(t, ssTable.Close())
+
+	ssTable, err = Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.Equal(t, bloom.BlockedFilterType, ssTable.FilterType())
+	assert.True(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("consensus", 8)))
+	assert.True(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("distributed", 9)))
+	assert.True(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("etcd", 10)))
+	assert.False(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("paxos", 7)))
+}
+
+func TestLoadSSTableWithStandardBloomFilterByDefault(t *testing.T) {
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	_, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	ssTable, err := Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.Equal(t, bloom.StandardFilterType, ssTable.FilterType())
+}
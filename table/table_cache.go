@@ -0,0 +1,149 @@
+package table
+
+import (
+	"container/list"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// TableCache bounds the number of open SSTable files (similar to the table cache in LevelDB).
+// Every loaded SSTable keeps its bloom filter and its block index (or the top-level index) in memory, only the file handle is
+// managed by the TableCache. A file is opened on demand (when a block of the SSTable is read), and the least-recently-used
+// idle file is closed when the number of open files goes beyond maxOpenFiles.
+//
+// A file is pinned while it is in use: during a block read, and for the lifetime of an Iterator. A pinned file is never
+// closed, so the number of open files may temporarily go beyond maxOpenFiles if many iterators are open at the same time.
+// A memory-mapped file is unmapped when it is closed, hence its blocks are evicted from the cache.BlockCache (if any),
+// the blocks in use are safe because their iterators pin the file.
+//
+// The file of a removed SSTable is closed when the references of the SSTable drop to zero (refer to SSTable.Remove).
+type TableCache struct {
+	lock         sync.Mutex
+	maxOpenFiles int
+	openFiles    map[uint64]*openFile
+	idle         *list.List
+}
+
+// openFile represents an open SSTable file in the TableCache.
+// element is non-nil only if the file is idle (pins = 0), idle files are present in the idle list with the most recently
+// used file at the front.
+type openFile struct {
+	ssTable *SSTable
+	file    *File
+	pins    int
+	element *list.Element
+}
+
+// NewTableCache creates a new instance of TableCache which keeps at most maxOpenFiles (idle) files open.
+func NewTableCache(maxOpenFiles int) *TableCache {
+	if maxOpenFiles <= 0 {
+		panic("maxOpenFiles must be greater than 0")
+	}
+	return &TableCache{
+		maxOpenFiles: maxOpenFiles,
+		openFiles:    make(map[uint64]*openFile),
+		idle:         list.New(),
+	}
+}
+
+// OpenFiles returns the number of open files.
+func (tableCache *TableCache) OpenFiles() int {
+	tableCache.lock.Lock()
+	defer tableCache.lock.Unlock()
+
+	return len(tableCache.openFiles)
+}
+
+// add adds the (already open) file of the SSTable as an idle file. It is used when an SSTable is loaded or built, because
+// its file is open at that point, and it is likely to be read soon.
+func (tableCache *TableCache) add(ssTable *SSTable, file *File) {
+	tableCache.lock.Lock()
+	defer tableCache.lock.Unlock()
+
+	if _, ok := tableCache.openFiles[ssTable.id]; ok {
+		_ = file.Close()
+		return
+	}
+	added := &openFile{ssTable: ssTable, file: file}
+	added.element = tableCache.idle.PushFront(added)
+	tableCache.openFiles[ssTable.id] = added
+	tableCache.mayBeCloseIdleFiles()
+}
+
+// acquire returns the pinned file of the SSTable, opening the file if it is not open.
+// Every acquire must be followed by release.
+func (tableCache *TableCache) acquire(ssTable *SSTable) (*File, error) {
+	tableCache.lock.Lock()
+	defer tableCache.lock.Unlock()
+
+	existing, ok := tableCache.openFiles[ssTable.id]
+	if !ok {
+		file, err := openSSTableFile(ssTable.filePath, ssTable.memoryMapped)
+		if err != nil {
+			return nil, err
+		}
+		existing = &openFile{ssTable: ssTable, file: file}
+		tableCache.openFiles[ssTable.id] = existing
+	}
+	if existing.element != nil {
+		tableCache.idle.Remove(existing.element)
+		existing.element = nil
+	}
+	existing.pins++
+	tableCache.mayBeCloseIdleFiles()
+	return existing.file, nil
+}
+
+// release unpins the file of the SSTable, the file becomes idle if it has no pins.
+func (tableCache *TableCache) release(ssTableId uint64) {
+	tableCache.lock.Lock()
+	defer tableCache.lock.Unlock()
+
+	existing, ok := tableCache.openFiles[ssTableId]
+	if !ok || existing.pins == 0 {
+		return
+	}
+	existing.pins--
+	if existing.pins == 0 {
+		existing.element = tableCache.idle.PushFront(existing)
+		tableCache.mayBeCloseIdleFiles()
+	}
+}
+
+// close closes the file of the SSTable (if it is open), irrespective of its pins.
+// It is used when the SSTable is closed or removed, at which point the SSTable has no references.
+func (tableCache *TableCache) close(ssTableId uint64) error {
+	tableCache.lock.Lock()
+	defer tableCache.lock.Unlock()
+
+	existing, ok := tableCache.openFiles[ssTableId]
+	if !ok {
+		return nil
+	}
+	return tableCache.closeFile(existing)
+}
+
+// mayBeCloseIdleFiles closes the least-recently-used idle files till the number of open files is within maxOpenFiles.
+func (tableCache *TableCache) mayBeCloseIdleFiles() {
+	for len(tableCache.openFiles) > tableCache.maxOpenFiles && tableCache.idle.Len() > 0 {
+		leastRecentlyUsed := tableCache.idle.Back().Value.(*openFile)
+		if err := tableCache.closeFile(leastRecentlyUsed); err != nil {
+			slog.Error(fmt.Sprintf("error in closing the file of ssTable %v, %v", leastRecentlyUsed.ssTable.id, err))
+		}
+	}
+}
+
+// closeFile closes the file and removes it from the TableCache.
+// The blocks of a memory-mapped file are evicted from the cache.BlockCache before the file is unmapped.
+func (tableCache *TableCache) closeFile(existing *openFile) error {
+	if existing.element != nil {
+		tableCache.idle.Remove(existing.element)
+		existing.element = nil
+	}
+	delete(tableCache.openFiles, existing.ssTable.id)
+	if existing.file.IsMemoryMapped() {
+		existing.ssTable.evictCachedBlocks()
+	}
+	return existing.file.Close()
+}
@@ -0,0 +1,124 @@
+package table
+
+import (
+	"fmt"
+	"go-lsm/kv"
+	"go-lsm/table/cache"
+	"go-lsm/test_utility"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSSTablesWithTableCache(t *testing.T, rootPath string, numberOfSSTables int, readOptions ReadOptions) []*SSTable {
+	ssTables := make([]*SSTable, 0, numberOfSSTables)
+	for id := 1; id <= numberOfSSTables; id++ {
+		ssTableBuilder := NewSSTableBuilder(4096)
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(fmt.Sprintf("consensus-%d", id), 5), kv.NewStringValue(fmt.Sprintf("raft-%d", id)))
+		ssTable, err := ssTableBuilder.BuildWithReadOptions(uint64(id), rootPath, readOptions)
+		assert.Nil(t, err)
+		ssTables = append(ssTables, ssTable)
+	}
+	return ssTables
+}
+
+func TestTableCacheBoundsTheNumberOfOpenFiles(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	tableCache := NewTableCache(2)
+	ssTables := buildSSTablesWithTableCache(t, rootPath, 5, ReadOptions{TableCache: tableCache})
+	assert.Equal(t, 2, tableCache.OpenFiles())
+
+	for attempt := 0; attempt < 2; attempt++ {
+		for index, ssTable := range ssTables {
+			iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp(fmt.Sprintf("consensus-%d", index+1), 10))
+			assert.Nil(t, err)
+			assert.True(t, iterator.IsValid())
+			assert.Equal(t, kv.NewStringValue(fmt.Sprintf("raft-%d", index+1)), iterator.Value())
+			iterator.Close()
+			DecrementReferenceFor([]*SSTable{ssTable})
+
+			assert.True(t, tableCache.OpenFiles() <= 2)
+		}
+	}
+}
+
+func TestTableCacheDoesNotCloseAFilePinnedByAnIterator(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	tableCache := NewTableCache(1)
+	ssTables := buildSSTablesWithTableCache(t, rootPath, 3, ReadOptions{TableCache: tableCache})
+
+	first, err := ssTables[0].SeekToKey(kv.NewStringKeyWithTimestamp("consensus-1", 10))
+	assert.Nil(t, err)
+	second, err := ssTables[1].SeekToKey(kv.NewStringKeyWithTimestamp("consensus-2", 10))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, tableCache.OpenFiles())
+
+	assert.Nil(t, ssTables[2].VerifyChecksums())
+	assert.Equal(t, 2, tableCache.OpenFiles())
+
+	assert.Equal(t, kv.NewStringValue("raft-1"), first.Value())
+	assert.Equal(t, kv.NewStringValue("raft-2"), second.Value())
+
+	first.Close()
+	second.Close()
+	assert.Equal(t, 1, tableCache.OpenFiles())
+}
+
+func TestTableCacheWithMemoryMappedFilesAndBlockCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	tableCache := NewTableCache(1)
+	blockCache := cache.NewBlockCache(1 << 20)
+	ssTables := buildSSTablesWithTableCache(t, rootPath, 2, ReadOptions{TableCache: tableCache, BlockCache: blockCache, MemoryMapped: true})
+
+	iterator, err := ssTables[0].SeekToKey(kv.NewStringKeyWithTimestamp("consensus-1", 10))
+	assert.Nil(t, err)
+	iterator.Close()
+	assert.Equal(t, 1, blockCache.Stats().Entries)
+
+	//reading the second SSTable closes (and unmaps) the file of the first SSTable, which evicts its blocks.
+	iterator, err = ssTables[1].SeekToKey(kv.NewStringKeyWithTimestamp("consensus-2", 10))
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringValue("raft-2"), iterator.Value())
+	iterator.Close()
+
+	assert.Equal(t, 1, tableCache.OpenFiles())
+	assert.Equal(t, 1, blockCache.Stats().Entries)
+}
+
+func TestRemoveSSTableWithTableCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	tableCache := NewTableCache(1)
+	ssTables := buildSSTablesWithTableCache(t, rootPath, 2, ReadOptions{TableCache: tableCache})
+
+	iterator, err := ssTables[0].SeekToKey(kv.NewStringKeyWithTimestamp("consensus-1", 10))
+	assert.Nil(t, err)
+	assert.Nil(t, ssTables[0].Remove())
+
+	_, err = os.Stat(SSTableFilePath(1, rootPath))
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringValue("raft-1"), iterator.Value())
+
+	iterator.Close()
+	DecrementReferenceFor([]*SSTable{ssTables[0]})
+
+	_, err = os.Stat(SSTableFilePath(1, rootPath))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, 0, tableCache.OpenFiles())
+}
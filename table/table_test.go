@@ -1,8 +1,16 @@
 package table
 
 import (
+	"errors"
+	"fmt"
+	"go-lsm/checksum"
 	"go-lsm/kv"
+	"go-lsm/table/cache"
+	"go-lsm/table/compress"
 	"go-lsm/test_utility"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -62,7 +70,7 @@ func TestLoadSSTableWithSingleBlockContainingMultipleKeyValuePairs(t *testing.T)
 	_, err := ssTableBuilder.Build(1, rootPath)
 	assert.Nil(t, err)
 
-	ssTable, err := Load(1, rootPath, 4096)
+	ssTable, err := Load(1, rootPath)
 	assert.Nil(t, err)
 
 	iterator, err := ssTable.SeekToFirst()
@@ -99,7 +107,7 @@ func TestLoadSSTableWithSingleBlockContainingMultipleKeyValuePairsWithStartingAn
 	_, err := ssTableBuilder.Build(1, rootPath)
 	assert.Nil(t, err)
 
-	ssTable, err := Load(1, rootPath, 4096)
+	ssTable, err := Load(1, rootPath)
 	assert.Nil(t, err)
 	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 10), ssTable.startingKey)
 	assert.Equal(t, kv.NewStringKeyWithTimestamp("etcd", 30), ssTable.endingKey)
@@ -118,7 +126,7 @@ func TestLoadAnSSTableWithTwoBlocks(t *testing.T) {
 	_, err := ssTableBuilder.Build(1, rootPath)
 	assert.Nil(t, err)
 
-	ssTable, err := Load(1, rootPath, 30)
+	ssTable, err := Load(1, rootPath)
 	assert.Nil(t, err)
 
 	iterator, err := ssTable.SeekToFirst()
@@ -149,7 +157,7 @@ func TestLoadAnSSTableWithTwoBlocksWithStartingAndEndingKey(t *testing.T) {
 	_, err := ssTableBuilder.Build(1, rootPath)
 	assert.Nil(t, err)
 
-	ssTable, err := Load(1, rootPath, 30)
+	ssTable, err := Load(1, rootPath)
 	assert.Nil(t, err)
 	assert.Equal(t, kv.NewStringKeyWithTimestamp("consensus", 20), ssTable.startingKey)
 	assert.Equal(t, kv.NewStringKeyWithTimestamp("distributed", 30), ssTable.endingKey)
@@ -244,3 +252,484 @@ func TestRemoveSSTable(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, ssTable.Remove())
 }
+
+func TestSSTableReadsBlocksThroughBlockCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 6), kv.NewStringValue("TiKV"))
+	_, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	blockCache := cache.NewBlockCache(1 << 20)
+	ssTable, err := LoadWithReadOptions(1, rootPath, ReadOptions{BlockCache: blockCache})
+	assert.Nil(t, err)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("distributed", 10))
+		assert.Nil(t, err)
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, kv.NewStringValue("TiKV"), iterator.Value())
+		iterator.Close()
+	}
+
+	stats := blockCache.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+}
+
+func TestSSTableIteratorReleasesBlocksOfBlockCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(50)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 6), kv.NewStringValue("TiKV"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("etcd", 7), kv.NewStringValue("bbolt"))
+	_, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	//a capacity smaller than a single block: every unpinned block is evicted.
+	blockCache := cache.NewBlockCacheWithShards(1, 1)
+	ssTable, err := LoadWithReadOptions(1, rootPath, ReadOptions{BlockCache: blockCache})
+	assert.Nil(t, err)
+
+	iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, blockCache.Stats().Entries)
+
+	for iterator.IsValid() {
+		assert.Equal(t, 1, blockCache.Stats().Entries)
+		_ = iterator.Next()
+	}
+	assert.Equal(t, 0, blockCache.Stats().Entries)
+	assert.Equal(t, uint64(3), blockCache.Stats().Evictions)
+	iterator.Close()
+}
+
+func TestSSTableSeekToFirstDoesNotFillBlockCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	_, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	blockCache := cache.NewBlockCache(1 << 20)
+	ssTable, err := LoadWithReadOptions(1, rootPath, ReadOptions{BlockCache: blockCache})
+	assert.Nil(t, err)
+
+	iterator, err := ssTable.SeekToFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringValue("raft"), iterator.Value())
+	iterator.Close()
+
+	assert.Equal(t, 0, blockCache.Stats().Entries)
+}
+
+func TestSSTableSeekToKeyForCompactionDoesNotFillBlockCacheOrIncrementReferences(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 6), kv.NewStringValue("etcd"))
+	_, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	blockCache := cache.NewBlockCache(1 << 20)
+	ssTable, err := LoadWithReadOptions(1, rootPath, ReadOptions{BlockCache: blockCache})
+	assert.Nil(t, err)
+
+	iterator, err := ssTable.SeekToKeyForCompaction(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
+	assert.Equal(t, kv.NewStringValue("etcd"), iterator.Value())
+	iterator.Close()
+
+	assert.Equal(t, 0, blockCache.Stats().Entries)
+	assert.Equal(t, int64(0), ssTable.TotalReferences())
+}
+
+func TestRemoveSSTableEvictsItsBlocksFromBlockCache(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	blockCache := cache.NewBlockCache(1 << 20)
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTable, err := ssTableBuilder.BuildWithReadOptions(1, rootPath, ReadOptions{BlockCache: blockCache})
+	assert.Nil(t, err)
+
+	iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	iterator.Close()
+	assert.Equal(t, 1, blockCache.Stats().Entries)
+
+	assert.Nil(t, ssTable.Remove())
+	assert.Equal(t, 0, blockCache.Stats().Entries)
+	assert.Equal(t, int64(0), blockCache.Stats().SizeInBytes)
+}
+
+func TestLoadMemoryMappedSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(256)
+	for count := 0; count < 100; count++ {
+		ssTableBuilder.Add(kv.NewStringKeyWithTimestamp(fmt.Sprintf("consensus-%03d", count), 5), kv.NewStringValue(fmt.Sprintf("raft-%03d", count)))
+	}
+	_, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	ssTable, err := LoadWithReadOptions(1, rootPath, ReadOptions{MemoryMapped: true})
+	assert.Nil(t, err)
+	assert.True(t, ssTable.file.IsMemoryMapped())
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("consensus-050", 10))
+	assert.Nil(t, err)
+	defer iterator.Close()
+
+	for count := 50; count < 100; count++ {
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, kv.NewStringKeyWithTimestamp(fmt.Sprintf("consensus-%03d", count), 5), iterator.Key())
+		assert.Equal(t, kv.NewStringValue(fmt.Sprintf("raft-%03d", count)), iterator.Value())
+		_ = iterator.Next()
+	}
+	assert.False(t, iterator.IsValid())
+	assert.Nil(t, ssTable.VerifyChecksums())
+}
+
+func TestRemoveMemoryMappedSSTableWithALiveIterator(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(50)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 6), kv.NewStringValue("TiKV"))
+	ssTable, err := ssTableBuilder.BuildWithReadOptions(1, rootPath, ReadOptions{MemoryMapped: true})
+	assert.Nil(t, err)
+
+	iterator, err := ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("consensus", 10))
+	assert.Nil(t, err)
+	value := iterator.Value()
+
+	assert.Nil(t, ssTable.Remove())
+	_, err = os.Stat(SSTableFilePath(1, rootPath))
+	assert.Nil(t, err)
+	assert.True(t, ssTable.file.IsMemoryMapped())
+
+	_ = iterator.Next()
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("TiKV"), iterator.Value())
+
+	iterator.Close()
+	DecrementReferenceFor([]*SSTable{ssTable})
+	assert.False(t, ssTable.file.IsMemoryMapped())
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	_, err = os.Stat(SSTableFilePath(1, rootPath))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadSSTableWithCompressedBlocks(t *testing.T) {
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+	for _, codecType := range []compress.CodecType{compress.Flate, compress.Zlib, compress.Snappy} {
+		t.Run(codecType.String(), func(t *testing.T) {
+			rootPath := test_utility.SetupADirectoryWithTestName(t)
+			defer func() {
+				test_utility.CleanupDirectoryWithTestName(t)
+			}()
+
+			uncompressedBuilder := NewSSTableBuilder(4096)
+			compressedBuilder := NewSSTableBuilderWithOptions(SSTableBuilderOptions{BlockSize: 4096, Compression: codecType})
+			for count := 0; count < 300; count++ {
+				key := kv.NewStringKeyWithTimestamp(fmt.Sprintf("consensus-%03d", count), 5)
+				uncompressedBuilder.Add(key, kv.NewStringValue("raft-consensus-algorithm"))
+				compressedBuilder.Add(key, kv.NewStringValue("raft-consensus-algorithm"))
+			}
+			uncompressedSSTable, err := uncompressedBuilder.Build(1, rootPath)
+			assert.Nil(t, err)
+			compressedSSTable, err := compressedBuilder.Build(2, rootPath)
+			assert.Nil(t, err)
+			assert.True(t, compressedSSTable.file.Size() < uncompressedSSTable.file.Size()/2)
+
+			ssTable, err := Load(2, rootPath)
+			assert.Nil(t, err)
+
+			iterator, err := ssTable.SeekToFirst()
+			assert.Nil(t, err)
+			defer iterator.Close()
+
+			for count := 0; count < 300; count++ {
+				assert.True(t, iterator.IsValid())
+				assert.Equal(t, kv.NewStringKeyWithTimestamp(fmt.Sprintf("consensus-%03d", count), 5), iterator.Key())
+				assert.Equal(t, kv.NewStringValue("raft-consensus-algorithm"), iterator.Value())
+				_ = iterator.Next()
+			}
+			assert.False(t, iterator.IsValid())
+		})
+	}
+}
+
+func TestSSTableWithIncompressibleBlocksStoredRaw(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	random := rand.New(rand.NewSource(3))
+	value := make([]byte, 4000)
+	random.Read(value)
+
+	ssTableBuilder := NewSSTableBuilderWithOptions(SSTableBuilderOptions{BlockSize: 4096, Compression: compress.Snappy})
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewValue(value))
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	startingOffset, endOffset, err := ssTable.index.offsetRangeOfBlockAt(0)
+	assert.Nil(t, err)
+	buffer := make([]byte, endOffset-startingOffset)
+	_, err = ssTable.file.Read(int64(startingOffset), buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(compress.None), buffer[len(buffer)-checksum.Size-blockFormatVersionSize-compress.CodecTypeSize])
+
+	block, err := ssTable.readBlock(0)
+	assert.Nil(t, err)
+
+	blockIterator := block.SeekToFirst()
+	assert.True(t, blockIterator.IsValid())
+	assert.Equal(t, kv.NewValue(value), blockIterator.Value())
+}
+
+func TestSSTableWithACorruptedBlock(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(50)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 20), kv.NewStringValue("TiKV"))
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	secondBlockOffset, _, err := ssTable.index.offsetRangeOfBlockAt(1)
+	assert.Nil(t, err)
+	assert.Nil(t, ssTable.Close())
+
+	corruptByteAt(t, SSTableFilePath(1, rootPath), int64(secondBlockOffset)+2)
+
+	ssTable, err = Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	_, err = ssTable.readBlock(0)
+	assert.Nil(t, err)
+
+	_, err = ssTable.readBlock(1)
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+
+	var corruptionError *checksum.CorruptionError
+	assert.True(t, errors.As(err, &corruptionError))
+	assert.Equal(t, SSTableFilePath(1, rootPath), corruptionError.FilePath)
+	assert.Equal(t, int64(secondBlockOffset), corruptionError.Offset)
+
+	assert.ErrorIs(t, ssTable.VerifyChecksums(), checksum.ErrCorruption)
+}
+
+func TestLoadSSTableWithACorruptedBlockMetaList(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft"))
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	blockMetaStartingOffset := ssTable.index.(*flatIndex).endOfDataBlocks
+	assert.Nil(t, ssTable.Close())
+
+	corruptByteAt(t, SSTableFilePath(1, rootPath), int64(blockMetaStartingOffset)+5)
+
+	_, err = Load(1, rootPath)
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+}
+
+func TestLoadSSTableWithACorruptedBloomFilter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(4096)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft"))
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	fileSize := ssTable.file.Size()
+	assert.Nil(t, ssTable.Close())
+
+	corruptByteAt(t, SSTableFilePath(1, rootPath), fileSize-int64(FooterSize)-checksum.Size-2)
+
+	_, err = Load(1, rootPath)
+	assert.ErrorIs(t, err, checksum.ErrCorruption)
+}
+
+func TestVerifyChecksumsOfAnSSTable(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilder(50)
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 20), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 20), kv.NewStringValue("TiKV"))
+
+	ssTable, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.Nil(t, ssTable.VerifyChecksums())
+}
+
+func corruptByteAt(t *testing.T, filePath string, offset int64) {
+	bytes, err := os.ReadFile(filePath)
+	assert.Nil(t, err)
+	bytes[offset] = bytes[offset] ^ 0x01
+	assert.Nil(t, os.WriteFile(filePath, bytes, 0666))
+}
+
+func TestSSTableSeekToKeyForPointLookupWithDataBlockHashIndex(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	ssTableBuilder := NewSSTableBuilderWithOptions(SSTableBuilderOptions{BlockSize: 60, DataBlockHashIndex: true})
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("consensus", 9), kv.NewStringValue("raft"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 12), kv.NewStringValue("etcd"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 10), kv.NewStringValue("TiKV"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("distributed", 8), kv.NewStringValue("foundationDB"))
+	ssTableBuilder.Add(kv.NewStringKeyWithTimestamp("storage", 7), kv.NewStringValue("NVMe"))
+	_, err := ssTableBuilder.Build(1, rootPath)
+	assert.Nil(t, err)
+
+	ssTable, err := Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+	assert.True(t, ssTable.noOfBlocks() > 1)
+
+	readBlock, err := ssTable.readBlock(0)
+	assert.Nil(t, err)
+	assert.True(t, readBlock.HasHashIndex())
+
+	pointLookup := func(key kv.Key) (kv.Value, bool) {
+		iterator, err := ssTable.SeekToKeyForPointLookup(key)
+		assert.Nil(t, err)
+		defer iterator.Close()
+		if iterator.IsValid() && iterator.Key().IsRawKeyEqualTo(key) {
+			return iterator.Value(), true
+		}
+		return kv.EmptyValue, false
+	}
+
+	value, ok := pointLookup(kv.NewStringKeyWithTimestamp("distributed", 9))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("foundationDB"), value)
+
+	value, ok = pointLookup(kv.NewStringKeyWithTimestamp("storage", 10))
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("NVMe"), value)
+
+	_, ok = pointLookup(kv.NewStringKeyWithTimestamp("etcd", 10))
+	assert.False(t, ok)
+
+	_, ok = pointLookup(kv.NewStringKeyWithTimestamp("consensus", 8))
+	assert.False(t, ok)
+}
+
+func TestLoadAnSSTableWithoutTheFooter(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	bytes, err := os.ReadFile(filepath.Join("testdata", "sstable_without_footer.sst"))
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(SSTableFilePath(1, rootPath), bytes, 0666))
+
+	ssTable, err := Load(1, rootPath)
+	assert.Nil(t, err)
+	defer func() {
+		_ = ssTable.Close()
+	}()
+
+	assert.Equal(t, uint(0), ssTable.BlockSize())
+	assert.True(t, ssTable.noOfBlocks() > 1)
+	assert.Nil(t, ssTable.VerifyChecksums())
+	assert.True(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("storage", 9)))
+	assert.False(t, ssTable.MayContain(kv.NewStringKeyWithTimestamp("unknown", 9)))
+
+	iterator, err := ssTable.SeekToFirst()
+	assert.Nil(t, err)
+	defer iterator.Close()
+
+	expected := []struct {
+		key   kv.Key
+		value kv.Value
+	}{
+		{kv.NewStringKeyWithTimestamp("consensus", 6), kv.NewStringValue("raft")},
+		{kv.NewStringKeyWithTimestamp("consensus", 5), kv.NewStringValue("paxos")},
+		{kv.NewStringKeyWithTimestamp("deleted", 7), kv.EmptyValue},
+		{kv.NewStringKeyWithTimestamp("distributed", 8), kv.NewStringValue("etcd")},
+		{kv.NewStringKeyWithTimestamp("storage", 9), kv.NewStringValue("NVMe")},
+		{kv.NewStringKeyWithTimestamp("tree", 10), kv.NewStringValue("LSM")},
+	}
+	for _, keyValue := range expected {
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, keyValue.key, iterator.Key())
+		assert.Equal(t, keyValue.value.String(), iterator.Value().String())
+		assert.Equal(t, keyValue.value.IsEmpty(), iterator.Value().IsEmpty())
+		_ = iterator.Next()
+	}
+	assert.False(t, iterator.IsValid())
+
+	iterator, err = ssTable.SeekToKey(kv.NewStringKeyWithTimestamp("distributed", 10))
+	assert.Nil(t, err)
+	defer iterator.Close()
+
+	assert.True(t, iterator.IsValid())
+	assert.Equal(t, kv.NewStringValue("etcd"), iterator.Value())
+}
@@ -1,11 +1,18 @@
 package tests
 
 import (
+	"errors"
+	"fmt"
 	go_lsm "go-lsm"
+	"go-lsm/compact/meta"
 	"go-lsm/kv"
 	"go-lsm/state"
+	"go-lsm/table"
 	"go-lsm/test_utility"
 	"go-lsm/txn"
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 
@@ -28,7 +35,8 @@ func TestReadWithEmptyDb(t *testing.T) {
 	}()
 
 	err := db.Read(func(transaction *txn.Transaction) {
-		_, ok := transaction.Get([]byte("consensus"))
+		_, ok, err := transaction.Get([]byte("consensus"))
+		assert.NoError(t, err)
 		assert.False(t, ok)
 	})
 	assert.NoError(t, err)
@@ -59,11 +67,13 @@ func TestReadAnExistingKeyValue(t *testing.T) {
 	assert.True(t, future.Status().IsOk())
 
 	err = db.Read(func(transaction *txn.Transaction) {
-		value, ok := transaction.Get([]byte("raft"))
+		value, ok, err := transaction.Get([]byte("raft"))
+		assert.NoError(t, err)
 		assert.True(t, ok)
 		assert.Equal(t, []byte("consensus algorithm"), value.Bytes())
 
-		value, ok = transaction.Get([]byte("VSR"))
+		value, ok, err = transaction.Get([]byte("VSR"))
+		assert.NoError(t, err)
 		assert.True(t, ok)
 		assert.Equal(t, []byte("consensus algorithm"), value.Bytes())
 	})
@@ -204,9 +214,9 @@ func TestGetAfterCompaction(t *testing.T) {
 		FlushMemtableDuration: 1 * time.Millisecond,
 		SSTableSizeInBytes:    1 * 1024 * 1024,
 		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
 			StrategyOptions: state.SimpleLeveledCompactionOptions{
 				NumberOfSSTablesRatioPercentage: 200,
-				MaxLevels:                       3,
 				Level0FilesCompactionTrigger:    2,
 			},
 			Duration: 10 * time.Millisecond,
@@ -236,23 +246,457 @@ func TestGetAfterCompaction(t *testing.T) {
 	time.Sleep(2 * time.Second)
 
 	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
-		value, ok := transaction.Get([]byte("raft"))
+		value, ok, err := transaction.Get([]byte("raft"))
+		assert.NoError(t, err)
 		assert.True(t, ok)
 		assert.Equal(t, "consensus algorithm", value.String())
 	}))
 	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
-		value, ok := transaction.Get([]byte("storage"))
+		value, ok, err := transaction.Get([]byte("storage"))
+		assert.NoError(t, err)
 		assert.True(t, ok)
 		assert.Equal(t, "Flash SSD", value.String())
 	}))
 	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
-		value, ok := transaction.Get([]byte("disk type"))
+		value, ok, err := transaction.Get([]byte("disk type"))
+		assert.NoError(t, err)
 		assert.True(t, ok)
 		assert.Equal(t, "NVMe", value.String())
 	}))
 	assert.Nil(t, db.Read(func(transaction *txn.Transaction) {
-		value, ok := transaction.Get([]byte("data-structure"))
+		value, ok, err := transaction.Get([]byte("data-structure"))
+		assert.NoError(t, err)
 		assert.True(t, ok)
 		assert.Equal(t, "Buffered BTree", value.String())
 	}))
 }
+
+func TestOpenAnAlreadyOpenedDb(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	_, err = go_lsm.Open(storageOptions)
+	assert.ErrorIs(t, err, go_lsm.ErrDatabaseLocked)
+}
+
+func TestOpenADbLockedByAnotherProcess(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	lockFile, err := os.OpenFile(filepath.Join(directory, "LOCK"), os.O_RDWR|os.O_CREATE, 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB))
+
+	_, err = go_lsm.Open(storageOptions)
+	assert.ErrorIs(t, err, go_lsm.ErrDatabaseLocked)
+
+	assert.NoError(t, lockFile.Close())
+}
+
+func TestReopenADbAfterClose(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	db, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+
+	future, err := db.Write(func(transaction *txn.Transaction) {
+		assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
+	})
+	assert.NoError(t, err)
+	future.Wait()
+	db.Close()
+
+	db, err = go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Read(func(transaction *txn.Transaction) {
+		value, ok, err := transaction.Get([]byte("raft"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "consensus algorithm", value.String())
+	})
+	assert.NoError(t, err)
+}
+
+func TestReadonlyDbDoesNotWriteToTheDirectory(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	db, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+
+	future, err := db.Write(func(transaction *txn.Transaction) {
+		assert.NoError(t, transaction.Set([]byte("raft"), []byte("consensus algorithm")))
+		assert.NoError(t, transaction.Set([]byte("wisckey"), []byte("modified LSM")))
+	})
+	assert.NoError(t, err)
+	future.Wait()
+	db.Close()
+
+	directoryContents := func() map[string]int64 {
+		contents := make(map[string]int64)
+		assert.NoError(t, filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			contents[path] = info.Size()
+			return nil
+		}))
+		return contents
+	}
+	contentsBeforeReadonlyOpen := directoryContents()
+
+	storageOptions.ReadOnly = true
+	readonlyDb, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+
+	err = readonlyDb.Read(func(transaction *txn.Transaction) {
+		value, ok, err := transaction.Get([]byte("raft"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "consensus algorithm", value.String())
+	})
+	assert.NoError(t, err)
+
+	keyValues, err := readonlyDb.Scan(kv.NewInclusiveKeyRange(kv.RawKey("raft"), kv.RawKey("wisckey")))
+	assert.NoError(t, err)
+	assert.Equal(t, []go_lsm.KeyValue{
+		{Key: kv.RawKey("raft"), Value: []byte("consensus algorithm")},
+		{Key: kv.RawKey("wisckey"), Value: []byte("modified LSM")},
+	}, keyValues)
+
+	_, err = readonlyDb.Write(func(transaction *txn.Transaction) {
+		assert.NoError(t, transaction.Set([]byte("storage"), []byte("NVMe")))
+	})
+	assert.ErrorIs(t, err, go_lsm.DbReadOnlyErr)
+
+	readonlyDb.Close()
+	assert.Equal(t, contentsBeforeReadonlyOpen, directoryContents())
+}
+
+func TestMultipleReadonlyDbsOverTheSameDirectory(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	db, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	db.Close()
+
+	storageOptions.ReadOnly = true
+	readonlyDb, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	defer readonlyDb.Close()
+
+	anotherReadonlyDb, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	defer anotherReadonlyDb.Close()
+
+	storageOptions.ReadOnly = false
+	_, err = go_lsm.Open(storageOptions)
+	assert.ErrorIs(t, err, go_lsm.ErrDatabaseLocked)
+}
+
+func TestOpenADbAfterAReadonlyDbOverADirectoryWithoutTheLockFile(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	db, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	db.Close()
+	assert.NoError(t, os.Remove(filepath.Join(directory, "LOCK")))
+
+	storageOptions.ReadOnly = true
+	readonlyDb, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+
+	storageOptions.ReadOnly = false
+	_, err = go_lsm.Open(storageOptions)
+	assert.ErrorIs(t, err, go_lsm.ErrDatabaseLocked)
+
+	readonlyDb.Close()
+
+	db, err = go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	db.Close()
+}
+
+func TestOpenReadonlyDbWithNonExistingDirectory(t *testing.T) {
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  filepath.Join(".", t.Name()),
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		ReadOnly:              true,
+	}
+	_, err := go_lsm.Open(storageOptions)
+	assert.Error(t, err)
+
+	_, err = os.Stat(storageOptions.Path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDbVerifyChecksums(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+	}
+	db, _ := go_lsm.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	for count := 0; count < 20; count++ {
+		future, err := db.Write(func(transaction *txn.Transaction) {
+			assert.NoError(t, transaction.Set([]byte(fmt.Sprintf("consensus-%d", count)), []byte("raft")))
+		})
+		assert.NoError(t, err)
+		future.Wait()
+	}
+	var ssTableFilePath string
+	assert.Eventually(t, func() bool {
+		matches, _ := filepath.Glob(filepath.Join(directory, "*.sst"))
+		if len(matches) > 0 {
+			ssTableFilePath = matches[0]
+			return true
+		}
+		return false
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, db.VerifyChecksums())
+
+	bytes, err := os.ReadFile(ssTableFilePath)
+	assert.NoError(t, err)
+	bytes[1] = bytes[1] ^ 0x01
+	assert.NoError(t, os.WriteFile(ssTableFilePath, bytes, 0666))
+
+	err = db.VerifyChecksums()
+	assert.ErrorIs(t, err, go_lsm.ErrCorruption)
+	assert.Contains(t, err.Error(), ssTableFilePath)
+}
+
+func TestDbIsDegradedToReadonlyAfterPersistentCompactionFailures(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Millisecond,
+		SSTableSizeInBytes:    4096,
+		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
+			StrategyOptions: state.SimpleLeveledCompactionOptions{
+				NumberOfSSTablesRatioPercentage: 200,
+				Level0FilesCompactionTrigger:    100,
+			},
+			Duration: 10 * time.Millisecond,
+		},
+	}
+	db, _ := go_lsm.Open(storageOptions)
+	for count := 0; count < 20; count++ {
+		future, err := db.Write(func(transaction *txn.Transaction) {
+			assert.NoError(t, transaction.Set([]byte(fmt.Sprintf("consensus-%d", count)), []byte("raft")))
+		})
+		assert.NoError(t, err)
+		future.Wait()
+	}
+	var ssTableFilePaths []string
+	assert.Eventually(t, func() bool {
+		ssTableFilePaths, _ = filepath.Glob(filepath.Join(directory, "*.sst"))
+		return len(ssTableFilePaths) > 0
+	}, 5*time.Second, 5*time.Millisecond)
+	db.Close()
+
+	for _, ssTableFilePath := range ssTableFilePaths {
+		bytes, err := os.ReadFile(ssTableFilePath)
+		assert.NoError(t, err)
+		bytes[1] = bytes[1] ^ 0x01
+		assert.NoError(t, os.WriteFile(ssTableFilePath, bytes, 0666))
+	}
+
+	storageOptions.CompactionOptions.StrategyOptions.Level0FilesCompactionTrigger = 2
+	storageOptions.CompactionOptions.MaxConsecutiveFailures = 3
+	storageOptions.CompactionOptions.MaxRetryBackoff = 20 * time.Millisecond
+	db, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, err := db.Write(func(transaction *txn.Transaction) {
+			assert.NoError(t, transaction.Set([]byte("storage"), []byte("NVMe")))
+		})
+		return errors.Is(err, go_lsm.DbDegradedErr)
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.ErrorIs(t, db.BackgroundError(), go_lsm.ErrCorruption)
+	_, err = db.Write(func(transaction *txn.Transaction) {})
+	assert.ErrorIs(t, err, go_lsm.ErrCorruption)
+
+	assert.Eventually(t, func() bool {
+		remainingSSTableFilePaths, _ := filepath.Glob(filepath.Join(directory, "*.sst"))
+		return len(db.StorageState().Snapshot().SSTables) == len(remainingSSTableFilePaths)
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.NoError(t, db.Read(func(transaction *txn.Transaction) {}))
+}
+
+func TestDbRemovesTheNewSSTablesOfACompactionWhichCouldNotBeApplied(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   250,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Minute,
+		SSTableSizeInBytes:    4096,
+		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 2,
+			StrategyOptions: state.SimpleLeveledCompactionOptions{
+				NumberOfSSTablesRatioPercentage: 200,
+				Level0FilesCompactionTrigger:    2,
+			},
+			Duration: 1 * time.Minute,
+		},
+	}
+	db, _ := go_lsm.Open(storageOptions)
+	defer func() {
+		db.Close()
+		test_utility.CleanupDirectoryWithTestName(t)
+	}()
+
+	buildSSTable := func(keys ...kv.Key) *table.SSTable {
+		ssTableBuilder := table.NewSSTableBuilder(4096)
+		for _, key := range keys {
+			ssTableBuilder.Add(key, kv.NewStringValue("raft"))
+		}
+		ssTable, err := ssTableBuilder.Build(db.StorageState().SSTableIdGenerator().NextId(), directory)
+		assert.NoError(t, err)
+		return ssTable
+	}
+	existingSSTable := buildSSTable(kv.NewStringKeyWithTimestamp("bolt", 5), kv.NewStringKeyWithTimestamp("etcd", 5))
+	db.StorageState().SetSSTableAtLevel(existingSSTable, 1)
+
+	overlappingSSTable := buildSSTable(kv.NewStringKeyWithTimestamp("consensus", 6))
+	event := state.NewStorageStateChangeEvent([]*table.SSTable{overlappingSSTable}, meta.CompactionDescription{OutputLevel: 1})
+
+	retriable, err := db.ApplyCompaction(event)
+	assert.True(t, retriable)
+	assert.ErrorIs(t, err, state.ErrOverlappingSSTables)
+
+	_, err = os.Stat(table.SSTableFilePath(overlappingSSTable.Id(), directory))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(table.SSTableFilePath(existingSSTable.Id(), directory))
+	assert.NoError(t, err)
+}
+
+func TestOpenADbWrittenBeforeTheSSTableFooterAndTheWALHeader(t *testing.T) {
+	directory := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := state.StorageOptions{
+		MemTableSizeInBytes:   1 * 1024,
+		Path:                  directory,
+		MaximumMemtables:      2,
+		FlushMemtableDuration: 1 * time.Minute,
+		SSTableSizeInBytes:    4096,
+		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
+			StrategyOptions: state.SimpleLeveledCompactionOptions{
+				NumberOfSSTablesRatioPercentage: 200,
+				Level0FilesCompactionTrigger:    2,
+			},
+			Duration: 1 * time.Minute,
+		},
+	}
+	defer test_utility.CleanupDirectoryWithTestName(t)
+
+	assert.NoError(t, os.CopyFS(directory, os.DirFS(filepath.Join("testdata", "db_without_footer"))))
+
+	db, err := go_lsm.Open(storageOptions)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.NoError(t, db.VerifyChecksums())
+	keyValuePairs, err := db.Scan(kv.NewInclusiveKeyRange(kv.RawKey("key-000"), kv.RawKey("key-099")))
+	assert.NoError(t, err)
+	assert.Equal(t, 90, len(keyValuePairs))
+
+	err = db.Read(func(transaction *txn.Transaction) {
+		value, ok, err := transaction.Get([]byte("key-001"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "value-001", value.String())
+
+		value, ok, err = transaction.Get([]byte("key-099"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "value-099", value.String())
+
+		_, ok, err = transaction.Get([]byte("key-010"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	assert.NoError(t, err)
+}
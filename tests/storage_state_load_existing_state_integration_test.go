@@ -29,9 +29,9 @@ func testStorageStateOptionsWithCompactionOptions(memtableSizeInBytes int64, dir
 		FlushMemtableDuration: 1 * time.Minute,
 		SSTableSizeInBytes:    1 * 1024 * 1024 * 1024,
 		CompactionOptions: state.CompactionOptions{
+			MaxLevels: 3,
 			StrategyOptions: state.SimpleLeveledCompactionOptions{
 				NumberOfSSTablesRatioPercentage: 200,
-				MaxLevels:                       3,
 				Level0FilesCompactionTrigger:    2,
 			},
 		},
@@ -73,15 +73,18 @@ func TestStorageStateLoadExistingStateWithMultipleImmutableMemtables(t *testing.
 		loadedStorageState.Close()
 	}()
 
-	value, ok := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 8))
+	value, ok, err := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("raft"), value)
 
-	value, ok = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 8))
+	value, ok, err = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("SSD-HDD"), value)
 
-	value, ok = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 8))
+	value, ok, err = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 8))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("B+Tree"), value)
 }
@@ -122,15 +125,18 @@ func TestStorageStateLoadExistingStateWithSSTable(t *testing.T) {
 		loadedStorageState.Close()
 	}()
 
-	value, ok := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	value, ok, err := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("raft"), value)
 
-	value, ok = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 11))
+	value, ok, err = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("Flash SSD"), value)
 
-	value, ok = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 11))
+	value, ok, err = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("Buffered B-Tree"), value)
 }
@@ -173,8 +179,8 @@ func TestStorageStateLoadExistingStateAfterCompaction(t *testing.T) {
 	stateChangeEvent, err := compaction.Start(storageState.Snapshot())
 	assert.Nil(t, err)
 
-	assert.Equal(t, -1, stateChangeEvent.CompactionUpperLevel())
-	assert.Equal(t, 1, stateChangeEvent.CompactionLowerLevel())
+	assert.Equal(t, 1, stateChangeEvent.CompactionOutputLevel())
+	assert.True(t, len(stateChangeEvent.CompactionSSTableIdsAt(0)) > 1)
 
 	err = storageState.Apply(stateChangeEvent, false)
 	assert.Nil(t, err)
@@ -189,15 +195,123 @@ func TestStorageStateLoadExistingStateAfterCompaction(t *testing.T) {
 
 	assert.True(t, loadedStorageState.TotalSSTablesAtLevel(1) >= 1)
 
-	value, ok := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	value, ok, err := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("raft"), value)
 
-	value, ok = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 11))
+	value, ok, err = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("Flash SSD"), value)
 
-	value, ok = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 11))
+	value, ok, err = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("data-structure", 11))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, kv.NewStringValue("Buffered B-Tree"), value)
 }
+
+func TestStorageStateLoadExistingStateAfterFIFOCompactionDroppedSSTables(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageOptions := testStorageStateOptionsWithCompactionOptions(50, rootPath)
+	storageOptions.CompactionOptions.Strategy = state.FIFOCompactionStrategy
+	storageOptions.CompactionOptions.FIFOOptions = state.FIFOCompactionOptions{MaxTotalSizeInBytes: 1}
+
+	storageState, _ := state.NewStorageStateWithOptions(storageOptions)
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+	compaction := compact.NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		oracle.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("Flash SSD"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	assert.Nil(t, storageState.ForceFlushNextImmutableMemtable())
+	assert.Equal(t, 1, storageState.TotalSSTablesAtLevel(0))
+
+	stateChangeEvent, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(stateChangeEvent.NewSSTableIds))
+	assert.Nil(t, storageState.Apply(stateChangeEvent, false))
+
+	storageState.Close()
+	loadedStorageState, err := state.NewStorageStateWithOptions(storageOptions)
+	assert.Nil(t, err)
+
+	defer func() {
+		loadedStorageState.Close()
+	}()
+
+	assert.Equal(t, 0, loadedStorageState.TotalSSTablesAtLevel(0))
+
+	_, ok, err := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestStorageStateLoadExistingStateAfterTrivialMove(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageStateWithOptions(testStorageStateOptionsWithCompactionOptions(
+		50,
+		rootPath,
+	))
+
+	oracle := txn.NewOracle(txn.NewExecutor(storageState))
+	compaction := compact.NewCompaction(oracle, storageState.SSTableIdGenerator(), storageState.Options())
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		oracle.Close()
+	}()
+
+	batch := kv.NewBatch()
+	_ = batch.Put([]byte("consensus"), []byte("raft"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 8)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("storage"), []byte("Flash SSD"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 9)))
+
+	batch = kv.NewBatch()
+	_ = batch.Put([]byte("TiKV"), []byte("raft-engine"))
+	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, 10)))
+
+	assert.Nil(t, storageState.ForceFlushNextImmutableMemtable())
+	assert.Nil(t, storageState.ForceFlushNextImmutableMemtable())
+	assert.Equal(t, 2, storageState.TotalSSTablesAtLevel(0))
+
+	stateChangeEvent, err := compaction.Start(storageState.Snapshot())
+	assert.Nil(t, err)
+	assert.True(t, stateChangeEvent.IsTrivialMove())
+
+	assert.Nil(t, storageState.Apply(stateChangeEvent, false))
+
+	storageState.Close()
+	loadedStorageState, err := state.NewStorageStateWithOptions(testStorageStateOptionsWithCompactionOptions(250, rootPath))
+	assert.Nil(t, err)
+
+	defer func() {
+		loadedStorageState.Close()
+	}()
+
+	assert.Equal(t, 0, loadedStorageState.TotalSSTablesAtLevel(0))
+	assert.Equal(t, 2, loadedStorageState.TotalSSTablesAtLevel(1))
+
+	value, ok, err := loadedStorageState.Get(kv.NewStringKeyWithTimestamp("consensus", 11))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("raft"), value)
+
+	value, ok, err = loadedStorageState.Get(kv.NewStringKeyWithTimestamp("storage", 11))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, kv.NewStringValue("Flash SSD"), value)
+}
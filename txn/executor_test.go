@@ -28,7 +28,8 @@ func TestSetsABatchWithOneKeyValueUsingExecutor(t *testing.T) {
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
 
-	value, ok := storageState.Get(kv.NewKey([]byte("kv"), 6))
+	value, ok, err := storageState.Get(kv.NewKey([]byte("kv"), 6))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "distributed", value.String())
 }
@@ -54,7 +55,8 @@ func TestSetsABatchWithOneKeyValueUsingExecutorAndRunsTheCallback(t *testing.T)
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
 
-	value, ok := storageState.Get(kv.NewKey([]byte("kv"), 6))
+	value, ok, err := storageState.Get(kv.NewKey([]byte("kv"), 6))
+	assert.Nil(t, err)
 	assert.True(t, applied)
 	assert.True(t, ok)
 	assert.Equal(t, "distributed", value.String())
@@ -79,11 +81,13 @@ func TestSetsABatchWithMultipleKeyValuesUsingExecutor(t *testing.T) {
 	future.Wait()
 	assert.True(t, future.Status().IsOk())
 
-	value, ok := storageState.Get(kv.NewKey([]byte("raft"), 6))
+	value, ok, err := storageState.Get(kv.NewKey([]byte("raft"), 6))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "consensus", value.String())
 
-	value, ok = storageState.Get(kv.NewKey([]byte("kv"), 6))
+	value, ok, err = storageState.Get(kv.NewKey([]byte("kv"), 6))
+	assert.Nil(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "distributed", value.String())
 }
@@ -119,6 +123,7 @@ func TestSetsABatchWithMultipleKeyValuesUsingExecutor1(t *testing.T) {
 	executeSet(executor)
 	executeDelete(executor)
 
-	_, ok := storageState.Get(kv.NewKey([]byte("raft"), 6))
+	_, ok, err := storageState.Get(kv.NewKey([]byte("raft"), 6))
+	assert.Nil(t, err)
 	assert.False(t, ok)
 }
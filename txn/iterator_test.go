@@ -35,12 +35,14 @@ func TestIterateOverTransactionIteratorWithAnExistingStateInTheSystem(t *testing
 		kv.NewStringKeyWithTimestamp("accurate", transaction.beginTimestamp),
 		kv.NewStringKeyWithTimestamp("distributed", transaction.beginTimestamp),
 	)
+	stateIterator, err := storageState.Scan(keyRange)
+	assert.Nil(t, err)
 	transactionIterator, _ := NewTransactionIterator(transaction, iterator.NewMergeIterator([]iterator.Iterator{
 		NewPendingWritesIterator(transaction.batch, transaction.beginTimestamp, kv.NewInclusiveKeyRange(
 			kv.RawKey("accurate"),
 			kv.RawKey("distributed"),
 		)),
-		storageState.Scan(keyRange),
+		stateIterator,
 	}, iterator.NoOperationOnCloseCallback))
 
 	assert.Equal(t, "consensus", transactionIterator.Key().RawString())
@@ -81,12 +83,14 @@ func TestIterateOverTransactionIteratorWithADeletedKeyAndAnExistingStateInTheSys
 		kv.NewStringKeyWithTimestamp("accurate", transaction.beginTimestamp),
 		kv.NewStringKeyWithTimestamp("distributed", transaction.beginTimestamp),
 	)
+	stateIterator, err := storageState.Scan(keyRange)
+	assert.Nil(t, err)
 	transactionIterator, _ := NewTransactionIterator(transaction, iterator.NewMergeIterator([]iterator.Iterator{
 		NewPendingWritesIterator(transaction.batch, transaction.beginTimestamp, kv.NewInclusiveKeyRange(
 			kv.RawKey("accurate"),
 			kv.RawKey("distributed"),
 		)),
-		storageState.Scan(keyRange),
+		stateIterator,
 	}, iterator.NoOperationOnCloseCallback))
 
 	assert.Equal(t, "consensus", transactionIterator.Key().RawString())
@@ -122,12 +126,14 @@ func TestIterateOverTransactionIteratorWithADeletedKeyAndAnExistingDeletedKeyInT
 		kv.NewStringKeyWithTimestamp("accurate", transaction.beginTimestamp),
 		kv.NewStringKeyWithTimestamp("distributed", transaction.beginTimestamp),
 	)
+	stateIterator, err := storageState.Scan(keyRange)
+	assert.Nil(t, err)
 	transactionIterator, _ := NewTransactionIterator(transaction, iterator.NewMergeIterator([]iterator.Iterator{
 		NewPendingWritesIterator(transaction.batch, transaction.beginTimestamp, kv.NewInclusiveKeyRange(
 			kv.RawKey("accurate"),
 			kv.RawKey("distributed"),
 		)),
-		storageState.Scan(keyRange),
+		stateIterator,
 	}, iterator.NoOperationOnCloseCallback))
 
 	assert.False(t, transactionIterator.IsValid())
@@ -159,12 +165,14 @@ func TestIterateOverTransactionIteratorWithAnExistingStateInTheSystemWithABoundC
 		kv.NewStringKeyWithTimestamp("accurate", transaction.beginTimestamp),
 		kv.NewStringKeyWithTimestamp("consensus", transaction.beginTimestamp),
 	)
+	stateIterator, err := storageState.Scan(keyRange)
+	assert.Nil(t, err)
 	transactionIterator, _ := NewTransactionIterator(transaction, iterator.NewMergeIterator([]iterator.Iterator{
 		NewPendingWritesIterator(transaction.batch, transaction.beginTimestamp, kv.NewInclusiveKeyRange(
 			kv.RawKey("accurate"),
 			kv.RawKey("consensus"),
 		)),
-		storageState.Scan(keyRange),
+		stateIterator,
 	}, iterator.NoOperationOnCloseCallback))
 
 	assert.Equal(t, "consensus", transactionIterator.Key().RawString())
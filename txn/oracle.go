@@ -21,6 +21,8 @@ type ReadyToCommitTransaction struct {
 // beginTimestampMark is used to indicate till what timestamp have the transactions begun. This information is used to clean up
 // the readyToCommitTransactions.
 // commitTimestampMark is used to block the new transactions, so all previous commits are visible to a new read.
+// activeBeginTimestamps counts the transactions which have begun (and not finished) by their begin-timestamps, it is used
+// by compaction to identify the versions which are visible to the running transactions (refer to MaxActiveBeginTimestamp).
 type Oracle struct {
 	lock                      sync.Mutex
 	executorLock              sync.Mutex
@@ -29,6 +31,7 @@ type Oracle struct {
 	commitTimestampMark       *TransactionTimestampWaterMark
 	executor                  *Executor
 	readyToCommitTransactions []ReadyToCommitTransaction
+	activeBeginTimestamps     map[uint64]int
 }
 
 // NewOracle creates a new instance of Oracle. It is called once in the entire application.
@@ -45,10 +48,11 @@ func NewOracle(executor *Executor) *Oracle {
 // as finished for timestamp lastCommitTimestamp.
 func NewOracleWithLastCommitTimestamp(executor *Executor, lastCommitTimestamp uint64) *Oracle {
 	oracle := &Oracle{
-		nextTimestamp:       lastCommitTimestamp + 1,
-		beginTimestampMark:  NewTransactionTimestampWaterMark(),
-		commitTimestampMark: NewTransactionTimestampWaterMark(),
-		executor:            executor,
+		nextTimestamp:         lastCommitTimestamp + 1,
+		beginTimestampMark:    NewTransactionTimestampWaterMark(),
+		commitTimestampMark:   NewTransactionTimestampWaterMark(),
+		executor:              executor,
+		activeBeginTimestamps: make(map[uint64]int),
 	}
 
 	oracle.beginTimestampMark.Finish(oracle.nextTimestamp - 1)
@@ -66,8 +70,13 @@ func (oracle *Oracle) Close() {
 // FinishBeginTimestamp indicates that the beginTimestamp of the transaction is finished.
 // This is an indication to the TransactionTimestampWaterMark that all the transactions upto a given `beginTimestamp`
 // are done. This information will be used in cleaning up the committed transactions.
+// The beginTimestamp of a transaction is finished only once, a Readwrite transaction finishes it when it gets the
+// commit-timestamp (refer to mayBeCommitTimestampFor), and again when its callback returns (refer to go_lsm.Db.Write).
 func (oracle *Oracle) FinishBeginTimestamp(transaction *Transaction) {
-	oracle.beginTimestampMark.Finish(transaction.beginTimestamp)
+	oracle.lock.Lock()
+	defer oracle.lock.Unlock()
+
+	oracle.finishBeginTimestamp(transaction)
 }
 
 // MaxBeginTimestamp returns the maximum begin timestamp.
@@ -76,6 +85,21 @@ func (oracle *Oracle) MaxBeginTimestamp() uint64 {
 	return oracle.beginTimestampMark.DoneTill()
 }
 
+// MaxActiveBeginTimestamp returns the maximum begin-timestamp of the transactions which have not finished, and false if
+// there are no such transactions.
+// A version of a key with commit-timestamp > MaxActiveBeginTimestamp() is not visible to any running transaction, it is
+// used in compaction to decide the versions which can be passed to the meta.CompactionFilter.
+func (oracle *Oracle) MaxActiveBeginTimestamp() (uint64, bool) {
+	oracle.lock.Lock()
+	defer oracle.lock.Unlock()
+
+	var maxActiveBeginTimestamp uint64
+	for beginTimestamp := range oracle.activeBeginTimestamps {
+		maxActiveBeginTimestamp = max(maxActiveBeginTimestamp, beginTimestamp)
+	}
+	return maxActiveBeginTimestamp, len(oracle.activeBeginTimestamps) > 0
+}
+
 // beginTimestamp returns the begin-timestamp of a transaction.
 // beginTimestamp = nextTimestamp - 1
 // Before returning the begin-timestamp, the system performs a wait on the commitTimestampMark.
@@ -84,6 +108,7 @@ func (oracle *Oracle) beginTimestamp() uint64 {
 	oracle.lock.Lock()
 	beginTimestamp := oracle.nextTimestamp - 1
 	oracle.beginTimestampMark.Begin(beginTimestamp)
+	oracle.activeBeginTimestamps[beginTimestamp]++
 	oracle.lock.Unlock()
 
 	_ = oracle.commitTimestampMark.WaitForMark(context.Background(), beginTimestamp)
@@ -109,7 +134,7 @@ func (oracle *Oracle) mayBeCommitTimestampFor(transaction *Transaction) (uint64,
 		return 0, ConflictErr
 	}
 
-	oracle.FinishBeginTimestamp(transaction)
+	oracle.finishBeginTimestamp(transaction)
 	oracle.cleanupReadyToCommitTransactions()
 
 	commitTimestamp := oracle.nextTimestamp
@@ -120,6 +145,19 @@ func (oracle *Oracle) mayBeCommitTimestampFor(transaction *Transaction) (uint64,
 	return commitTimestamp, nil
 }
 
+// finishBeginTimestamp finishes the beginTimestamp of the transaction (if it is not already finished), it must be called
+// with the lock held.
+func (oracle *Oracle) finishBeginTimestamp(transaction *Transaction) {
+	if transaction.beginFinished {
+		return
+	}
+	transaction.beginFinished = true
+	oracle.beginTimestampMark.Finish(transaction.beginTimestamp)
+	if oracle.activeBeginTimestamps[transaction.beginTimestamp]--; oracle.activeBeginTimestamps[transaction.beginTimestamp] <= 0 {
+		delete(oracle.activeBeginTimestamps, transaction.beginTimestamp)
+	}
+}
+
 // hasConflictFor determines of the transaction has a conflict with other concurrent transactions.
 // A Readwrite transaction Tx conflicts with other transaction if:
 // the keys read by the transaction Tx are modified by another transaction that has the commitTimestamp > beginTimestampOf(Tx).
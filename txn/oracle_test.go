@@ -56,6 +56,41 @@ func TestGetsTheMaxBeginTimestamp(t *testing.T) {
 	assert.Equal(t, uint64(5), oracle.MaxBeginTimestamp())
 }
 
+func TestGetsTheMaxActiveBeginTimestamp(t *testing.T) {
+	rootPath := test_utility.SetupADirectoryWithTestName(t)
+	storageState, _ := state.NewStorageState(rootPath)
+	oracle := NewOracleWithLastCommitTimestamp(NewExecutor(storageState), 5)
+
+	defer func() {
+		test_utility.CleanupDirectoryWithTestName(t)
+		storageState.Close()
+		oracle.Close()
+	}()
+
+	_, ok := oracle.MaxActiveBeginTimestamp()
+	assert.False(t, ok)
+
+	aTransaction := NewReadonlyTransaction(oracle, storageState)
+	oracle.nextTimestamp = 8
+	oracle.commitTimestampMark.Finish(7)
+	anotherTransaction := NewReadonlyTransaction(oracle, storageState)
+
+	maxActiveBeginTimestamp, ok := oracle.MaxActiveBeginTimestamp()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(7), maxActiveBeginTimestamp)
+
+	oracle.FinishBeginTimestamp(anotherTransaction)
+	oracle.FinishBeginTimestamp(anotherTransaction)
+
+	maxActiveBeginTimestamp, ok = oracle.MaxActiveBeginTimestamp()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5), maxActiveBeginTimestamp)
+
+	oracle.FinishBeginTimestamp(aTransaction)
+	_, ok = oracle.MaxActiveBeginTimestamp()
+	assert.False(t, ok)
+}
+
 func TestGetsCommitTimestampForTransactionGivenNoTransactionsAreCurrentlyTracked(t *testing.T) {
 	rootPath := test_utility.SetupADirectoryWithTestName(t)
 	storageState, _ := state.NewStorageState(rootPath)
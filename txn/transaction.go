@@ -35,6 +35,7 @@ More details are available [here](https://tech-lessons.in/en/blog/serializable_s
 // - a reference to kv.Batch which is a collection of key/value pairs, that a transaction operates on.
 // - a collection of all the keys read within the transaction.
 // readLock is used as a lock over the `reads` field, because multiple iterators can be created in a Readwrite transaction.
+// beginFinished is true once the begin-timestamp of the transaction is finished, it is guarded by the lock of the Oracle.
 type Transaction struct {
 	oracle         *Oracle
 	state          *state.StorageState
@@ -43,6 +44,7 @@ type Transaction struct {
 	batch          *kv.Batch
 	reads          []kv.RawKey
 	readLock       sync.Mutex
+	beginFinished  bool
 }
 
 // NewReadonlyTransaction creates a new instance of Readonly transaction.
@@ -70,19 +72,20 @@ func NewReadwriteTransaction(oracle *Oracle, state *state.StorageState) *Transac
 }
 
 // Get gets the value for the given key.
-// It returns a tuple (kv.Value, true), if the key exists, else (kv.EmptyValue, false).
+// It returns a tuple (kv.Value, true, nil), if the key exists, else (kv.EmptyValue, false, nil). An error in reading
+// state.StorageState (e.g. checksum.ErrCorruption) is returned as (kv.EmptyValue, false, error).
 // The Get method involves the following:
 // 1) Getting the begin-timestamp of the transaction.
 // 2) Getting the value corresponding to the timestamped key from state.StorageState.
 // Please note: the system returns the value where the timestamp of the key in the system <= begin-timestamp of the transaction.
-func (transaction *Transaction) Get(key []byte) (kv.Value, bool) {
+func (transaction *Transaction) Get(key []byte) (kv.Value, bool, error) {
 	versionedKey := kv.NewKey(key, transaction.beginTimestamp)
 	if transaction.readonly {
 		return transaction.state.Get(versionedKey)
 	}
 	transaction.trackReads(key)
 	if value, ok := transaction.batch.Get(key); ok {
-		return value, true
+		return value, true, nil
 	}
 	return transaction.state.Get(versionedKey)
 }
@@ -99,12 +102,16 @@ func (transaction *Transaction) Scan(keyRange kv.InclusiveKeyRange[kv.RawKey]) (
 		kv.NewKey(keyRange.End(), transaction.beginTimestamp),
 	)
 	if transaction.readonly {
-		return transaction.state.Scan(versionedKeyRange), nil
+		return transaction.state.Scan(versionedKeyRange)
+	}
+	stateIterator, err := transaction.state.Scan(versionedKeyRange)
+	if err != nil {
+		return nil, err
 	}
 	pendingWritesIteratorMergedWithStateIterator := iterator.NewMergeIterator(
 		[]iterator.Iterator{
 			NewPendingWritesIterator(transaction.batch, transaction.beginTimestamp, keyRange),
-			transaction.state.Scan(versionedKeyRange),
+			stateIterator,
 		},
 		iterator.NoOperationOnCloseCallback,
 	)
@@ -21,7 +21,8 @@ func TestReadonlyTransactionWithEmptyState(t *testing.T) {
 	}()
 
 	transaction := NewReadonlyTransaction(oracle, storageState)
-	_, ok := transaction.Get([]byte("paxos"))
+	_, ok, err := transaction.Get([]byte("paxos"))
+	assert.Nil(t, err)
 
 	assert.False(t, ok)
 }
@@ -46,7 +47,8 @@ func TestReadonlyTransactionWithAnExistingKey(t *testing.T) {
 	oracle.commitTimestampMark.Finish(commitTimestamp)
 
 	transaction := NewReadonlyTransaction(oracle, storageState)
-	value, ok := transaction.Get([]byte("consensus"))
+	value, ok, err := transaction.Get([]byte("consensus"))
+	assert.Nil(t, err)
 
 	assert.True(t, ok)
 	assert.Equal(t, "raft", value.String())
@@ -74,7 +76,8 @@ func TestReadonlyTransactionWithAnExistingKeyButWithATimestampHigherThanCommitTi
 	assert.Nil(t, storageState.Set(kv.NewTimestampedBatchFrom(*batch, commitTimestamp)))
 	oracle.commitTimestampMark.Finish(commitTimestamp)
 
-	_, ok := transaction.Get([]byte("raft"))
+	_, ok, err := transaction.Get([]byte("raft"))
+	assert.Nil(t, err)
 
 	assert.False(t, ok)
 }
@@ -195,15 +198,18 @@ func TestGetsAnExistingKeyInAReadwriteTransaction(t *testing.T) {
 
 	readonlyTransaction := NewReadonlyTransaction(oracle, storageState)
 
-	value, ok := readonlyTransaction.Get([]byte("HDD"))
+	value, ok, err := readonlyTransaction.Get([]byte("HDD"))
+	assert.Nil(t, err)
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk", value.String())
 
-	value, ok = readonlyTransaction.Get([]byte("SSD"))
+	value, ok, err = readonlyTransaction.Get([]byte("SSD"))
+	assert.Nil(t, err)
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Solid state drive", value.String())
 
-	_, ok = readonlyTransaction.Get([]byte("non-existing"))
+	_, ok, err = readonlyTransaction.Get([]byte("non-existing"))
+	assert.Nil(t, err)
 	assert.Equal(t, false, ok)
 }
 
@@ -221,7 +227,8 @@ func TestGetsTheValueFromAKeyInAReadwriteTransactionFromBatch(t *testing.T) {
 	transaction := NewReadwriteTransaction(oracle, storageState)
 	_ = transaction.Set([]byte("HDD"), []byte("Hard disk"))
 
-	value, ok := transaction.Get([]byte("HDD"))
+	value, ok, err := transaction.Get([]byte("HDD"))
+	assert.Nil(t, err)
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "Hard disk", value.String())
 
@@ -408,7 +415,8 @@ func TestReferencesToSSTableInTransactionGet(t *testing.T) {
 	storageState.SetSSTableAtLevel(ssTable, 0)
 
 	readonlyTransaction := NewReadonlyTransaction(oracle, storageState)
-	value, ok := readonlyTransaction.Get([]byte("consensus"))
+	value, ok, err := readonlyTransaction.Get([]byte("consensus"))
+	assert.Nil(t, err)
 
 	assert.True(t, ok)
 	assert.Equal(t, "paxos", value.String())